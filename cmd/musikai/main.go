@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"os"
-	"os/signal"
 
 	"github.com/igolaizola/musikai/pkg/cli"
+	"github.com/igolaizola/musikai/pkg/maxruntime"
+	"github.com/igolaizola/musikai/pkg/shutdown"
 )
 
 // Build flags
@@ -16,12 +18,20 @@ var date = ""
 
 func main() {
 	// Create signal based context
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := shutdown.Notify(context.Background())
 	defer cancel()
 
 	// Launch command
 	cmd := cli.New(version, commit, date)
 	if err := cmd.ParseAndRun(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, maxruntime.ErrReached) {
+			log.Println(err)
+			os.Exit(3)
+		}
+		if errors.Is(err, shutdown.ErrStopped) {
+			log.Println(err)
+			os.Exit(130)
+		}
 		log.Fatal(err)
 	}
 }
@@ -29,6 +29,9 @@ require (
 	github.com/smarty/cproxy/v2 v2.1.0
 	github.com/zmb3/spotify/v2 v2.4.1
 	golang.org/x/image v0.11.0
+	golang.org/x/net v0.24.0
+	golang.org/x/oauth2 v0.0.0-20210810183815-faf39c7919d5
+	gonum.org/v1/gonum v0.14.0
 	gonum.org/v1/plot v0.14.0
 	google.golang.org/api v0.30.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -105,8 +108,6 @@ require (
 	github.com/ysmood/leakless v0.8.0 // indirect
 	go.opencensus.io v0.22.4 // indirect
 	golang.org/x/crypto v0.22.0 // indirect
-	golang.org/x/net v0.24.0 // indirect
-	golang.org/x/oauth2 v0.0.0-20210810183815-faf39c7919d5 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
@@ -0,0 +1,217 @@
+package bandcamp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+type Album struct {
+	Artist      string
+	Title       string
+	Description string
+	Cover       string
+	Tags        []string
+	Price       float64
+	ReleaseDate time.Time
+	Songs       []*Song
+}
+
+type Song struct {
+	Title string
+	File  string
+}
+
+func (a *Album) Validate() error {
+	if a.Artist == "" {
+		return fmt.Errorf("bandcamp: artist is empty")
+	}
+	if a.Title == "" {
+		return fmt.Errorf("bandcamp: title is empty")
+	}
+	if a.Price < 0 {
+		return fmt.Errorf("bandcamp: price is negative")
+	}
+	if len(a.Songs) == 0 {
+		return fmt.Errorf("bandcamp: no songs")
+	}
+	if a.Cover == "" {
+		return fmt.Errorf("bandcamp: cover is empty")
+	}
+	if _, err := os.Stat(a.Cover); os.IsNotExist(err) {
+		return fmt.Errorf("bandcamp: cover file doesn't exist: %s", a.Cover)
+	}
+	for i, song := range a.Songs {
+		if song.Title == "" {
+			return fmt.Errorf("bandcamp: song %d title is empty", i+1)
+		}
+		if song.File == "" {
+			return fmt.Errorf("bandcamp: song %d file is empty", i+1)
+		}
+		if _, err := os.Stat(song.File); os.IsNotExist(err) {
+			return fmt.Errorf("bandcamp: song %d file doesn't exist: %s", i+1, song.File)
+		}
+	}
+	return nil
+}
+
+// Publish publishes a new album and returns its URL.
+// If auto is false, the album is left in the editor until it is published
+// manually.
+func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (string, error) {
+	// Validate album
+	if err := album.Validate(); err != nil {
+		return "", err
+	}
+
+	// Create a new tab based on client context
+	ctx, cancel := chromedp.NewContext(c.browserContext)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-parent.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Navigate to the new album page
+	if err := chromedp.Run(ctx,
+		chromedp.Navigate(fmt.Sprintf("%s/edit_album", c.artistURL)),
+		chromedp.WaitVisible("body", chromedp.ByQuery),
+	); err != nil {
+		return "", fmt.Errorf("bandcamp: couldn't navigate to url: %w", err)
+	}
+
+	// Set the album data
+	if err := setValue(ctx, "#album-name", album.Title); err != nil {
+		return "", err
+	}
+	if err := setValue(ctx, "#album-artist", album.Artist); err != nil {
+		return "", err
+	}
+	if err := setValue(ctx, "#album-price", fmt.Sprintf("%.2f", album.Price)); err != nil {
+		return "", err
+	}
+	if !album.ReleaseDate.IsZero() {
+		if err := setValue(ctx, "#album-release-date", album.ReleaseDate.Format("01/02/2006")); err != nil {
+			return "", err
+		}
+	}
+	if album.Description != "" {
+		if err := setValue(ctx, "#album-about", album.Description); err != nil {
+			return "", err
+		}
+	}
+	for _, tag := range album.Tags {
+		if err := typeValue(ctx, "#album-tags input", tag+"\n"); err != nil {
+			return "", err
+		}
+	}
+
+	// Upload cover
+	if err := upload(ctx, "#album-art-upload input[type=file]", album.Cover, "#album-art-upload img"); err != nil {
+		return "", err
+	}
+
+	// Upload tracks
+	for i, song := range album.Songs {
+		n := i + 1
+		if err := upload(ctx, "#add-track input[type=file]", song.File, fmt.Sprintf("#track-list li:nth-child(%d) .track-title input", n)); err != nil {
+			return "", err
+		}
+		if err := setValue(ctx, fmt.Sprintf("#track-list li:nth-child(%d) .track-title input", n), song.Title); err != nil {
+			return "", err
+		}
+	}
+
+	// Wait for all uploads to finish
+	if err := notVisible(ctx, "#track-list .upload-progress"); err != nil {
+		return "", err
+	}
+
+	if auto {
+		// Click on the publish button
+		if err := click(ctx, "#publish-button"); err != nil {
+			return "", err
+		}
+	}
+
+	// Wait until the album page is loaded after publishing
+	var u string
+	for {
+		if err := chromedp.Run(ctx, chromedp.Location(&u)); err != nil {
+			return "", fmt.Errorf("bandcamp: couldn't get location: %w", err)
+		}
+		if strings.Contains(u, "/album/") {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("bandcamp: couldn't wait for album url: %w", ctx.Err())
+		case <-time.After(1 * time.Second):
+		}
+	}
+
+	// Wait a bit more before closing
+	time.Sleep(1 * time.Second)
+	return u, nil
+}
+
+func click(ctx context.Context, sel string) error {
+	if err := chromedp.Run(ctx,
+		chromedp.WaitVisible(sel, chromedp.ByQuery),
+		chromedp.Click(sel, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: couldn't click %s: %w", sel, err)
+	}
+	return nil
+}
+
+func notVisible(ctx context.Context, sel string) error {
+	if err := chromedp.Run(ctx,
+		chromedp.WaitNotPresent(sel, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: %s is still present: %w", sel, err)
+	}
+	return nil
+}
+
+func setValue(ctx context.Context, sel, val string) error {
+	if err := chromedp.Run(ctx,
+		chromedp.WaitVisible(sel, chromedp.ByQuery),
+		chromedp.SetValue(sel, val, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: couldn't set value %s %s: %w", sel, val, err)
+	}
+	return nil
+}
+
+func typeValue(ctx context.Context, sel, val string) error {
+	if err := chromedp.Run(ctx,
+		chromedp.WaitVisible(sel, chromedp.ByQuery),
+		chromedp.SendKeys(sel, val, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: couldn't type value %s %s: %w", sel, val, err)
+	}
+	return nil
+}
+
+func upload(ctx context.Context, sel, file, wait string) error {
+	if err := chromedp.Run(ctx,
+		chromedp.SetUploadFiles(sel, []string{file}, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: couldn't set upload %s %s: %w", sel, file, err)
+	}
+	if err := chromedp.Run(ctx,
+		chromedp.WaitVisible(wait, chromedp.ByQuery),
+	); err != nil {
+		return fmt.Errorf("bandcamp: couldn't wait for %s: %w", wait, err)
+	}
+	return nil
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,21 +18,34 @@ import (
 	"github.com/igolaizola/musikai/pkg/cmd/background"
 	"github.com/igolaizola/musikai/pkg/cmd/classify"
 	"github.com/igolaizola/musikai/pkg/cmd/cover"
+	"github.com/igolaizola/musikai/pkg/cmd/dedup"
 	"github.com/igolaizola/musikai/pkg/cmd/describe"
 	"github.com/igolaizola/musikai/pkg/cmd/download"
 	"github.com/igolaizola/musikai/pkg/cmd/draft"
+	"github.com/igolaizola/musikai/pkg/cmd/export"
 	"github.com/igolaizola/musikai/pkg/cmd/generate"
+	"github.com/igolaizola/musikai/pkg/cmd/isrc"
 	"github.com/igolaizola/musikai/pkg/cmd/jamendo"
+	"github.com/igolaizola/musikai/pkg/cmd/jobs"
 	"github.com/igolaizola/musikai/pkg/cmd/migrate"
+	"github.com/igolaizola/musikai/pkg/cmd/mixdown"
 	"github.com/igolaizola/musikai/pkg/cmd/process"
 	"github.com/igolaizola/musikai/pkg/cmd/publish"
 	"github.com/igolaizola/musikai/pkg/cmd/setting"
 	"github.com/igolaizola/musikai/pkg/cmd/single"
+	"github.com/igolaizola/musikai/pkg/cmd/spotify"
 	"github.com/igolaizola/musikai/pkg/cmd/sync"
 	"github.com/igolaizola/musikai/pkg/cmd/title"
 	"github.com/igolaizola/musikai/pkg/cmd/upscale"
 	"github.com/igolaizola/musikai/pkg/cmd/web"
+	"github.com/igolaizola/musikai/pkg/cmd/youtube"
+	"github.com/igolaizola/musikai/pkg/distrokid"
 	"github.com/igolaizola/musikai/pkg/imageai"
+	"github.com/igolaizola/musikai/pkg/logfile"
+	"github.com/igolaizola/musikai/pkg/queue"
+	"github.com/igolaizola/musikai/pkg/replicate"
+	"github.com/igolaizola/musikai/pkg/shutdown"
+	"github.com/igolaizola/musikai/pkg/sound"
 	"github.com/igolaizola/musikai/pkg/webcli"
 	"github.com/peterbourgon/ff/ffyaml"
 	"github.com/peterbourgon/ff/v3"
@@ -46,29 +61,45 @@ func New(version, commit, date string) *ffcli.Command {
 		newSettingCommand(),
 		newWebCommand(),
 
-		newGenerateCommand(),
-		newProcessCommand(),
+		withShutdownGrace(newGenerateCommand()),
+		newProviderJobsCommand(),
+		withShutdownGrace(withExitZeroOnEmpty(newProcessCommand())),
+		newDedupCommand(),
 		newTitleCommand(),
+		newTitleAuditCommand(),
+		newExportCommand(),
+		newImportCommand(),
+		newExportMetadataCommand(),
 		newDraftCommand(),
-		newCoverCommand(),
+		withShutdownGrace(newCoverCommand()),
+		newCoverStatusCommand(),
 		newUpscaleCommand(),
 
-		newAlbumCommand(),
+		withExitZeroOnEmpty(newAlbumCommand()),
 		newSingleCommand(),
 		newDeleteAlbumCommand(),
 		newCoverAlbumCommand(),
+		newRefreshAlbumGenresCommand(),
 		newBackgroundCommand(),
 
-		newPublishCommand(),
+		withShutdownGrace(withExitZeroOnEmpty(newPublishCommand())),
 		newSyncCommand(),
+		newISRCCommand(),
+		newSpotifyCommand(),
+		newYoutubeReviewCommand(),
+		newYoutubeUploadCommand(),
 		newJamendoCommand(),
-		newClassifyCommand(),
+		withExitZeroOnEmpty(newClassifyCommand()),
 		newDescribeCommand(),
 
 		newDownloadCommand(),
 		newDownloadAlbumCommand(),
+		newMixdownCommand(),
 		newAnalyzeCommand(),
 	}
+	for _, c := range cmds {
+		withLogFile(c)
+	}
 	port := fs.Int("port", 0, "port number")
 	return &ffcli.Command{
 		ShortUsage: "musikai [flags] <subcommand>",
@@ -83,6 +114,60 @@ func New(version, commit, date string) *ffcli.Command {
 	}
 }
 
+// withLogFile adds the log file flags to the command and sets up the log
+// output before running it.
+func withLogFile(c *ffcli.Command) {
+	if c.FlagSet == nil || c.Exec == nil {
+		return
+	}
+	logFile := c.FlagSet.String("log-file", "", "also write logs to this file (optional)")
+	logMaxSize := c.FlagSet.Int("log-max-size", 10, "max size of the log file in MB before rotating it (0 means no rotation)")
+	exec := c.Exec
+	c.Exec = func(ctx context.Context, args []string) error {
+		if *logFile == "" {
+			return exec(ctx, args)
+		}
+		w, err := logfile.New(*logFile, int64(*logMaxSize)*1024*1024)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = w.Close() }()
+		out := log.Writer()
+		log.SetOutput(io.MultiWriter(out, w))
+		defer log.SetOutput(out)
+		return exec(ctx, args)
+	}
+}
+
+// withShutdownGrace adds the shutdown grace flag to a command that finishes
+// its in-flight work on interrupt.
+func withShutdownGrace(c *ffcli.Command) *ffcli.Command {
+	grace := c.FlagSet.Duration("shutdown-grace", 5*time.Minute, "time to finish in-flight work after an interrupt, a second interrupt forces exit (0 means exit immediately)")
+	exec := c.Exec
+	c.Exec = func(ctx context.Context, args []string) error {
+		shutdown.WithGrace(ctx, *grace)
+		return exec(ctx, args)
+	}
+	return c
+}
+
+// withExitZeroOnEmpty adds the exit zero on empty flag to a command that fails
+// when there is nothing to process, so scheduled runs that find an empty
+// queue don't appear as errors.
+func withExitZeroOnEmpty(c *ffcli.Command) *ffcli.Command {
+	exitZero := c.FlagSet.Bool("exit-zero-on-empty", false, "exit with code 0 instead of failing when there is nothing to process")
+	exec := c.Exec
+	c.Exec = func(ctx context.Context, args []string) error {
+		err := exec(ctx, args)
+		if *exitZero && errors.Is(err, queue.ErrEmpty) {
+			log.Printf("%s: nothing to do: %v\n", c.Name, err)
+			return nil
+		}
+		return err
+	}
+	return c
+}
+
 func newVersionCommand(version, commit, date string) *ffcli.Command {
 	return &ffcli.Command{
 		Name:       "version",
@@ -148,8 +233,8 @@ func newSettingCommand() *ffcli.Command {
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
 	fs.StringVar(&cfg.Service, "service", "", "distrokid or suno")
 	fs.StringVar(&cfg.Account, "account", "", "account name")
-	fs.StringVar(&cfg.Value, "value", "", "value to set")
-	fs.StringVar(&cfg.Type, "type", "cookie", "value type")
+	fs.StringVar(&cfg.Value, "value", "", "value to set, a go duration for ratelimit (e.g. 4s, 1m30s)")
+	fs.StringVar(&cfg.Type, "type", "cookie", "value type (cookie, ratelimit)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -201,16 +286,26 @@ func newGenerateCommand() *ffcli.Command {
 	cfg := &generate.Config{}
 
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.PprofAddr, "pprof", "", "serve pprof on this address during the run, bound to localhost if no host is set (e.g. :6060)")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "maximum run time, exits with code 3 if work remains (0 means no limit)")
+	fs.DurationVar(&cfg.ProgressInterval, "progress-interval", 30*time.Second, "time between progress logs (0 means no progress logs)")
 	fs.DurationVar(&cfg.WaitMin, "wait-min", 3*time.Second, "minimum wait time between songs")
 	fs.DurationVar(&cfg.WaitMax, "wait-max", 1*time.Minute, "maximum wait time between songs")
-	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
-
-	fs.StringVar(&cfg.Account, "account", "", "account to use")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use, comma separated or a file with one per line to rotate them on each iteration")
+	fs.IntVar(&cfg.ProxyMaxFailures, "proxy-max-failures", 3, "consecutive failures after which a rotated proxy is skipped (0 means never)")
+	fs.DurationVar(&cfg.ProxyCooldown, "proxy-cooldown", 10*time.Minute, "time a failing proxy is skipped")
+
+	fs.StringVar(&cfg.Account, "account", "", "account to use, comma separated to use several accounts in round-robin")
+	fs.DurationVar(&cfg.AccountCooldown, "account-cooldown", 30*time.Minute, "time to skip an account after it runs out of credits or fails to authenticate")
+	fs.DurationVar(&cfg.PerAccountMinInterval, "per-account-min-interval", 0, "minimum time between requests of the same account, in addition to the global wait (0 means no minimum)")
+	fs.IntVar(&cfg.MinCredits, "min-credits", 0, "skip accounts with less credits than this value and stop when none is left (0 means no check)")
+	fs.IntVar(&cfg.CreditsLog, "credits-log", 10, "log the remaining credits every n iterations (0 means no logging)")
 	fs.StringVar(&cfg.Provider, "provider", "", "provider to use (suno, udio)")
 
 	fs.StringVar(&cfg.Input, "input", "", "csv or json with prompts or styles (fields: weight,type,prompt,style,instrumental)")
@@ -223,7 +318,11 @@ func newGenerateCommand() *ffcli.Command {
 	fs.DurationVar(&cfg.MinDuration, "min-duration", 0, "minimum duration for the song")
 	fs.DurationVar(&cfg.MaxDuration, "max-duration", 0, "maximum duration for the song")
 	fs.IntVar(&cfg.MaxExtensions, "max-extensions", 0, "maximum number of extensions for the song")
+	fs.DurationVar(&cfg.FadeOutWindow, "fade-out-window", sound.DefaultFadeOutWindow, "duration at the end of a clip analyzed to detect a fade out")
+	fs.Float64Var(&cfg.FadeOutThreshold, "fade-out-threshold", sound.DefaultFadeOutThreshold, "minimum rms decrease between 50ms windows counted as a drop when detecting a fade out")
 	fs.StringVar(&cfg.Notes, "notes", "", "text notes stored with the song")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "random seed used to choose the clips to extend (0 means time based)")
+	fsMapVar(fs, &cfg.StyleTemplates, "style-templates", nil, "per provider prompt templates using {style} as placeholder (semicolon separated) Example: suno:{style};udio:a song with {style}")
 
 	// Suno specific parameters
 	fs.StringVar(&cfg.EndLyrics, "end-lyrics", "[end]", "end lyrics text to use")
@@ -231,11 +330,27 @@ func newGenerateCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.EndStyleAppend, "end-style-append", true, "append end style instead of replacing it")
 	fs.StringVar(&cfg.ForceEndLyrics, "force-end-lyrics", "[end]", "force end lyrics text to use")
 	fs.StringVar(&cfg.ForceEndStyle, "force-end-style", "short, end", "force end style to use")
+	fs.StringVar(&cfg.ExtendStrategy, "extend-strategy", "heuristic", "how to choose the clip to continue on each extension (heuristic, longest, random)")
 
 	// Udio specific parameters
 	fs.StringVar(&cfg.CaptchaKey, "captcha-key", "", "captcha api key")
 	fs.StringVar(&cfg.CaptchaProvider, "captcha-provider", "", "captcha provider to use (nopecha, 2captcha)")
 	fs.StringVar(&cfg.CaptchaProxy, "captcha-proxy", "", "captcha proxy to use")
+	fs.BoolVar(&cfg.SkipIntro, "skip-intro", false, "don't generate an intro fragment")
+	fs.DurationVar(&cfg.IntroDuration, "intro-duration", 0, "duration reserved for the intro (0 means 30s)")
+	fs.IntVar(&cfg.IntroExtensions, "intro-extensions", 0, "extensions reserved for the intro (0 means 1)")
+	fs.IntVar(&cfg.GenerateAttempts, "generate-attempts", 3, "number of attempts when generation fails with status 500")
+	fs.BoolVar(&cfg.SkipCaptchaRefresh, "skip-captcha-refresh", false, "don't solve a new captcha when generation fails due to the captcha")
+	fs.StringVar(&cfg.IDStrategy, "id-strategy", "ulid", "id strategy for songs and generations (ulid, nanoid, external to use the provider ids)")
+
+	// Auto process
+	fs.BoolVar(&cfg.AutoProcess, "auto-process", false, "process the generations right after they are saved")
+	fs.IntVar(&cfg.ProcessConcurrency, "process-concurrency", 1, "number of concurrent processes when auto processing")
+	fs.StringVar(&cfg.Process.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav), used to auto process")
+	fs.StringVar(&cfg.Process.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav, used to auto process")
+	processFlags(fs, &cfg.Process, "used to auto process")
+	fs.BoolVar(&cfg.Process.AutoSelect, "auto-select", false, "select the best generation of each song once all are processed, used to auto process")
+	fs.StringVar(&cfg.Process.SelectCriteria, "select-criteria", process.DefaultSelectCriteria, "comma separated criteria to select the best generation sorted by priority (ends, flags, longest), used to auto process")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -253,6 +368,70 @@ func newGenerateCommand() *ffcli.Command {
 	}
 }
 
+func newProviderJobsCommand() *ffcli.Command {
+	cmd := "provider-jobs"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &jobs.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
+
+	fs.StringVar(&cfg.Account, "account", "", "account to use")
+	fs.StringVar(&cfg.Provider, "provider", "", "provider to use (suno, udio)")
+	fs.DurationVar(&cfg.MinAge, "min-age", 0, "only consider jobs older than this duration")
+	fs.BoolVar(&cfg.Cancel, "cancel", false, "cancel the listed jobs")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [-provider suno|udio] [-account name] [-min-age duration] [-cancel] [flags]", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: "list the in-flight provider generations and optionally cancel them",
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return jobs.Run(ctx, cfg)
+		},
+	}
+}
+
+func newDedupCommand() *ffcli.Command {
+	cmd := "dedup"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &dedup.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+
+	fs.StringVar(&cfg.Type, "type", "", "type to use")
+	fs.Float64Var(&cfg.Threshold, "threshold", 0.95, "minimum fingerprint similarity to consider songs duplicates (1 means exact match)")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "only report the duplicates without rejecting them")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return dedup.Run(ctx, cfg)
+		},
+	}
+}
+
 func newProcessCommand() *ffcli.Command {
 	cmd := "process"
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
@@ -261,22 +440,28 @@ func newProcessCommand() *ffcli.Command {
 	cfg := &process.Config{}
 
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.PprofAddr, "pprof", "", "serve pprof on this address during the run, bound to localhost if no host is set (e.g. :6060)")
+	fs.BoolVar(&cfg.Profile, "profile", false, "print the time spent on each stage at the end of the run")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
-	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes (0 means one per CPU)")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "maximum run time, exits with code 3 if work remains (0 means no limit)")
+	fs.DurationVar(&cfg.ProgressInterval, "progress-interval", 30*time.Second, "time between progress logs (0 means no progress logs)")
 
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.BoolVar(&cfg.Reprocess, "reprocess", false, "reprocess the song")
-	fs.DurationVar(&cfg.ShortFadeOut, "short-fadeout", 0, "short fade out duration")
-	fs.DurationVar(&cfg.LongFadeOut, "long-fadeout", 0, "long fade out duration")
-	fs.BoolVar(&cfg.SkipMaster, "skip-master", false, "skip the master process")
-	fs.BoolVar(&cfg.Docker, "docker", false, "use docker to master the song")
+	processFlags(fs, cfg, "")
+	fs.BoolVar(&cfg.AutoSelect, "auto-select", false, "select the best generation of each song once all are processed")
+	fs.StringVar(&cfg.SelectCriteria, "select-criteria", process.DefaultSelectCriteria, "comma separated criteria to select the best generation sorted by priority (ends, flags, longest)")
+	fs.BoolVar(&cfg.MasterPreview, "master-preview", false, "master into temporary files and log the original vs mastered loudness without storing anything")
+	fs.DurationVar(&cfg.Since, "since", 0, "only process generations created within this duration, e.g. 72h (0 means full scan)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -294,6 +479,30 @@ func newProcessCommand() *ffcli.Command {
 	}
 }
 
+// processFlags binds the flags that set how the generations are processed,
+// so every command that processes them masters the songs the same way.
+// The note is appended to the usage to tell when the flags are used.
+func processFlags(fs *flag.FlagSet, cfg *process.Config, note string) {
+	usage := func(s string) string {
+		if note == "" {
+			return s
+		}
+		return s + ", " + note
+	}
+	fs.DurationVar(&cfg.ShortFadeOut, "short-fadeout", process.DefaultShortFadeOut, usage("short fade out duration"))
+	fs.DurationVar(&cfg.LongFadeOut, "long-fadeout", process.DefaultLongFadeOut, usage("long fade out duration"))
+	fs.BoolVar(&cfg.NoFadeOnEnding, "no-fade-on-ending", false, usage("don't fade out songs that already end, only cut the trailing silence"))
+	fs.DurationVar(&cfg.FadeIn, "fade-in", 0, usage("fade in duration (0 means no fade in)"))
+	fs.BoolVar(&cfg.TrimLead, "trim-lead", true, usage("trim the silence at the start of the songs"))
+	fs.DurationVar(&cfg.TrimLeadMax, "trim-lead-max", 2*time.Second, usage("longest leading silence trimmed, longer ones are kept as intentional intros"))
+	fs.StringVar(&cfg.PostProcessCmd, "post-process-cmd", "", usage("external command run on the mastered audio with {input} and {output} placeholders (optional)"))
+	fs.BoolVar(&cfg.SkipMaster, "skip-master", false, usage("skip the master process"))
+	fs.BoolVar(&cfg.Docker, "docker", false, usage("use docker to master the song"))
+	fs.Float64Var(&cfg.TargetLUFS, "target-lufs", 0, usage("normalize the mastered song to this integrated loudness, e.g. -14 (0 means disabled)"))
+	fs.StringVar(&cfg.Format, "format", "mp3", usage("format of the processed songs (mp3, flac, wav), lossless formats are stored along with the mp3"))
+	fs.StringVar(&cfg.WaveThemes, "wave-themes", "", usage("yaml or json file with the wave image themes by type (optional)"))
+}
+
 func newClassifyCommand() *ffcli.Command {
 	cmd := "classify"
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
@@ -309,8 +518,13 @@ func newClassifyCommand() *ffcli.Command {
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "maximum run time, exits with code 3 if work remains (0 means no limit)")
 
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
+	fs.StringVar(&cfg.Provider, "provider", "sonoteller", "classification provider (sonoteller, local, auto)")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav), used by the local provider")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav, used by the local provider")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -343,6 +557,7 @@ func newDescribeCommand() *ffcli.Command {
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
 
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.Key, "key", "", "openai api key")
@@ -373,15 +588,26 @@ func newWebCommand() *ffcli.Command {
 	cfg := &web.Config{}
 
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.PprofAddr, "pprof", "", "serve pprof on this address during the run, bound to localhost if no host is set (e.g. :6060)")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.StringVar(&cfg.Addr, "addr", ":1337", "address to listen on")
 	fsMapVar(fs, &cfg.Credentials, "creds", nil, "credentials to use (comma separated) Example: user1:pass1,user2:pass2")
 	fsMapVar(fs, &cfg.Volumes, "volumes", nil, "volumes to mount (comma separated) Example: ./Pictures:/pics,./Videos:/vids")
+	fs.IntVar(&cfg.ThumbnailSize, "thumbnail-size", 300, "max width and height of cover thumbnails")
+	fs.IntVar(&cfg.AlbumPageSize, "album-page-size", 10, "number of albums per page when the request doesn't set the size")
+	fs.BoolVar(&cfg.CacheClear, "cache-clear", false, "clear the cache folder on shutdown")
+	fs.IntVar(&cfg.CacheMaxSize, "cache-max-size", 0, "max size of the cache in MB when it is cleared, the oldest files are removed first (0 removes all)")
+	fs.BoolVar(&cfg.LikeApproves, "like-approves", true, "liking a song or cover also approves it, set to false to like without approving")
+	fs.BoolVar(&cfg.AutoTitle, "auto-title", false, "generate a title when adding a song to an album and there are no approved titles left")
+	fs.StringVar(&cfg.BannedWords, "banned-words", "", "comma separated list or file with one word per line, titles containing these words (case-insensitive, whole-word) are rejected")
+
+	fs.BoolVar(&cfg.SelectProcess, "select-process", false, "allow to process unprocessed generations when they are selected")
+	processFlags(fs, &cfg.Process, "used to process on select")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -429,6 +655,127 @@ func newTitleCommand() *ffcli.Command {
 	}
 }
 
+func newTitleAuditCommand() *ffcli.Command {
+	cmd := "title-audit"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &title.AuditConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Type, "type", "", "type to audit (optional)")
+	fs.StringVar(&cfg.BannedWords, "banned-words", "", "comma separated list or file with one word per line")
+	fs.BoolVar(&cfg.Reject, "reject", false, "reject the unused titles containing banned words")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return title.Audit(ctx, cfg)
+		},
+	}
+}
+
+func newExportCommand() *ffcli.Command {
+	cmd := "export"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &export.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Output, "output", "", "output json file")
+	fs.StringVar(&cfg.Type, "type", "", "type to export (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return export.Run(ctx, cfg)
+		},
+	}
+}
+
+func newExportMetadataCommand() *ffcli.Command {
+	cmd := "export-metadata"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &export.MetadataConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Output, "output", "", "output file")
+	fs.StringVar(&cfg.Format, "format", "csv", "output format (csv, ddex)")
+	fs.StringVar(&cfg.Template, "template", "", "csv template with a header row and a row of placeholders (optional)")
+	fs.StringVar(&cfg.Type, "type", "", "type to export (optional)")
+	fs.StringVar(&cfg.Album, "album", "", "album id to export (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return export.RunMetadata(ctx, cfg)
+		},
+	}
+}
+
+func newImportCommand() *ffcli.Command {
+	cmd := "import"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &export.ImportConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Input, "input", "", "input json file created with export")
+	fs.BoolVar(&cfg.Overwrite, "overwrite", false, "overwrite the items that already exist")
+	fs.StringVar(&cfg.IDStrategy, "id-strategy", "ulid", "id strategy for items without id (ulid, nanoid, external to require ids)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return export.Import(ctx, cfg)
+		},
+	}
+}
+
 func newDraftCommand() *ffcli.Command {
 	cmd := "draft"
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
@@ -470,15 +817,23 @@ func newCoverCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav), required by the replicate provider")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.Template, "template", "", "default template to use when there isn't a match on the input file")
 	fs.StringVar(&cfg.Input, "input", "", "input templates in csv or json format (fields: type,template)")
 	fs.IntVar(&cfg.Minimum, "minimum", 0, "minimum number of covers to generate per album")
+	fs.IntVar(&cfg.Styles, "styles", 3, "number of dominant song styles and moods used in the {styles} and {moods} template placeholders")
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number of images to process (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.IntVar(&cfg.MaxRequests, "max-requests", 0, "maximum number of discord requests (imagine and upscale) per run (0 means no limit)")
+	fs.DurationVar(&cfg.ProgressInterval, "progress-interval", 30*time.Second, "time between progress logs (0 means no progress logs)")
 	fs.DurationVar(&cfg.WaitMin, "wait-min", 3*time.Second, "minimum wait time between images")
 	fs.DurationVar(&cfg.WaitMax, "wait-max", 1*time.Minute, "maximum wait time between images")
+	fs.StringVar(&cfg.Provider, "cover-provider", "discord", "image provider (discord, replicate)")
+	fs.StringVar(&cfg.ReplicateModel, "replicate-model", replicate.DefaultModel, "replicate model used by the replicate provider (owner/name)")
 
 	// Discord parameters
 	cfg.Discord = &imageai.Config{}
@@ -486,6 +841,8 @@ func newCoverCommand() *ffcli.Command {
 	fs.StringVar(&cfg.Discord.Proxy, "proxy", "", "discord proxy")
 	fs.StringVar(&cfg.Discord.Channel, "channel", "", "discord channel id")
 	fs.StringVar(&cfg.Discord.ReplicateToken, "replicate-token", "", "replicate token")
+	fsIntSliceVar(fs, &cfg.Discord.Variants, "variants", nil, "grid cells to upscale, comma separated (0 top-left, 1 top-right, 2 bottom-left, 3 bottom-right), random if empty")
+	fs.StringVar(&cfg.Discord.Aspect, "aspect", "", "expected aspect ratio of upscaled cells (e.g. 1:1), mismatches are discarded")
 
 	// Session
 	fs.StringVar(&cfg.Discord.SessionFile, "session", "session.yaml", "session config file (optional)")
@@ -537,6 +894,7 @@ func newBackgroundCommand() *ffcli.Command {
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number of images to process (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
 	fs.DurationVar(&cfg.WaitMin, "wait-min", 3*time.Second, "minimum wait time between images")
 	fs.DurationVar(&cfg.WaitMax, "wait-max", 1*time.Minute, "maximum wait time between images")
 
@@ -546,6 +904,8 @@ func newBackgroundCommand() *ffcli.Command {
 	fs.StringVar(&cfg.Discord.Proxy, "proxy", "", "discord proxy")
 	fs.StringVar(&cfg.Discord.Channel, "channel", "", "discord channel id")
 	fs.StringVar(&cfg.Discord.ReplicateToken, "replicate-token", "", "replicate token")
+	fsIntSliceVar(fs, &cfg.Discord.Variants, "variants", nil, "grid cells to upscale, comma separated (0 top-left, 1 top-right, 2 bottom-left, 3 bottom-right), random if empty")
+	fs.StringVar(&cfg.Discord.Aspect, "aspect", "", "expected aspect ratio of upscaled cells (e.g. 1:1), mismatches are discarded")
 
 	// Session
 	fs.StringVar(&cfg.Discord.SessionFile, "session", "session.yaml", "session config file (optional)")
@@ -581,6 +941,36 @@ func newBackgroundCommand() *ffcli.Command {
 	}
 }
 
+func newCoverStatusCommand() *ffcli.Command {
+	cmd := "cover-status"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &cover.StatusConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Type, "type", "", "type to use")
+	fs.IntVar(&cfg.Minimum, "minimum", 0, "minimum number of approved and upscaled covers per draft")
+	fs.BoolVar(&cfg.Missing, "missing", false, "only report drafts below the minimum")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [-type type] [-minimum n] [-missing] [flags]", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: "report the approved and upscaled covers of each draft against a minimum",
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return cover.RunStatus(ctx, cfg)
+		},
+	}
+}
+
 func newUpscaleCommand() *ffcli.Command {
 	cmd := "upscale"
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
@@ -589,21 +979,24 @@ func newUpscaleCommand() *ffcli.Command {
 	cfg := &upscale.Config{}
 
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.BoolVar(&cfg.Profile, "profile", false, "print the time spent on each stage at the end of the run")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number of images to process (0 means no limit)")
-	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 5, "consecutive errors tolerated before aborting")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes (0 means one per CPU)")
 	fs.StringVar(&cfg.Type, "type", "", "filter by type")
 
 	// Upscale parameters
 	fs.StringVar(&cfg.UpscaleType, "upscale-type", "topaz", "upscale type (topaz, esrgan)")
 	fs.StringVar(&cfg.UpscaleBin, "upscale-bin", "", "upscale binary path")
 	fs.IntVar(&cfg.UploadConcurrency, "upload-concurrency", 1, "number of concurrent uploads")
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", 3, "max upscale attempts per cover before marking it as failed (0 means no limit)")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -629,10 +1022,11 @@ func newAlbumCommand() *ffcli.Command {
 	cfg := &album.Config{}
 
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.BoolVar(&cfg.Profile, "profile", false, "print the time spent on each stage at the end of the run")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
@@ -640,12 +1034,35 @@ func newAlbumCommand() *ffcli.Command {
 
 	fs.StringVar(&cfg.Type, "type", "", "filter by type")
 	fs.StringVar(&cfg.Artist, "artist", "", "artist to apply")
+	fs.StringVar(&cfg.ArtistPool, "artist-pool", "", "comma separated list or file with one artist per line to choose the artist of each album from")
+	fs.StringVar(&cfg.ArtistStrategy, "artist-strategy", "round-robin", "how to choose the artist from the pool (round-robin, type)")
+	fs.IntVar(&cfg.ArtistMaxLength, "artist-max-length", 100, "maximum length of the artist names (0 means no limit)")
+	fs.StringVar(&cfg.ArtistDisallowed, "artist-disallowed", album.DefaultArtistDisallowed, "characters removed from the artist names")
+	fs.BoolVar(&cfg.ArtistCheckExisting, "artist-check-existing", false, "check the collisions with existing artists that only differ in case, spacing or punctuation")
+	fs.BoolVar(&cfg.ArtistMergeCollisions, "artist-merge-collisions", false, "use the previous spelling of colliding artists instead of failing")
+	fs.BoolVar(&cfg.AutoTitle, "auto-title", false, "generate a title from the song style when there are no approved titles left")
+	fs.StringVar(&cfg.BannedWords, "banned-words", "", "comma separated list or file with one word per line, titles containing these words (case-insensitive, whole-word) are rejected")
 	fs.StringVar(&cfg.Overlay, "overlay", "", "overlay file to use")
 	fs.StringVar(&cfg.Font, "font", "", "font file to use")
+	fs.BoolVar(&cfg.RenderTitle, "render-title", false, "draw the album title on the cover")
+	fs.StringVar(&cfg.TitlePosition, "title-position", "top-center", "preferred position of the title (top-left, top-center, top-right, center, bottom-left, bottom-center, bottom-right)")
+	fs.StringVar(&cfg.TitleFont, "title-font", "", "font file to use for the title (font is used if empty)")
 	fs.IntVar(&cfg.MinSongs, "min-songs", 6, "minimum number of songs")
 	fs.IntVar(&cfg.MaxSongs, "max-songs", 10, "maximum number of songs")
+	fs.BoolVar(&cfg.RequireProcessed, "require-processed", true, "only include songs that are already processed")
+	fs.BoolVar(&cfg.AllowUnmastered, "allow-unmastered", false, "include processed songs that weren't mastered when processed songs are required")
+	fs.Float64Var(&cfg.TempoMin, "tempo-min", 0, "minimum tempo in bpm of the songs (0 means unconstrained)")
+	fs.Float64Var(&cfg.TempoMax, "tempo-max", 0, "maximum tempo in bpm of the songs (0 means unconstrained)")
+	fs.Float64Var(&cfg.TempoSpread, "tempo-spread", 0, "maximum bpm difference between the songs and the first song of the album (0 means unconstrained)")
+	fs.StringVar(&cfg.Targets, "targets", "", "comma separated list of platforms to publish the albums to (distrokid, jamendo, bandcamp, soundcloud)")
 	fs.StringVar(&cfg.Genres, "genres", "", "genres file to use (.csv or .json) fields: type,primary,secondary")
 	fs.BoolVar(&cfg.ReuseCover, "reuse-cover", false, "reuse the same album cover (only for volume albums)")
+	fs.StringVar(&cfg.VolumeCoverPolicy, "volume-cover-policy", "", "cover policy for volume albums (reuse, distinct), if empty reuse-cover is used")
+	fs.StringVar(&cfg.IDStrategy, "id-strategy", "ulid", "id strategy for albums (ulid, nanoid)")
+	fs.BoolVar(&cfg.RequireISRC, "require-isrc", false, "fail if the songs of an album don't have isrc")
+	fs.BoolVar(&cfg.AssignISRC, "assign-isrc", false, "assign isrcs to the songs that don't have one when the album is built")
+	fs.StringVar(&cfg.ISRCPrefix, "isrc-prefix", "", "isrc prefix with country, registrant and year (CC-XXX-YY) used to assign isrcs")
+	fs.IntVar(&cfg.DistrokidMaxSongs, "distrokid-max-songs", distrokid.DefaultMaxSongs, "maximum number of songs of a distrokid album, caps max-songs if the albums can be published to distrokid")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -673,8 +1090,8 @@ func newSingleCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 	fs.StringVar(&cfg.Chrome, "chrome", "", "chrome binary path (optional)")
 
@@ -691,6 +1108,7 @@ func newSingleCommand() *ffcli.Command {
 	fs.StringVar(&cfg.Overlay, "overlay", "", "overlay file to use")
 	fs.StringVar(&cfg.Font, "font", "", "font file to use")
 	fs.StringVar(&cfg.FontSize, "font-size", "9vw", "font size to use")
+	fs.StringVar(&cfg.CoverPairing, "cover-pairing", single.PairingHash, "strategy to pick the cover of a song the first time (hash, random), it is kept on later runs")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -708,6 +1126,34 @@ func newSingleCommand() *ffcli.Command {
 	}
 }
 
+func newRefreshAlbumGenresCommand() *ffcli.Command {
+	cmd := "refresh-album-genres"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &album.GenresConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Type, "type", "", "type of the albums to refresh (optional)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return album.RunGenres(ctx, cfg)
+		},
+	}
+}
+
 func newDeleteAlbumCommand() *ffcli.Command {
 	cmd := "delete-album"
 	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
@@ -746,8 +1192,8 @@ func newCoverAlbumCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.StringVar(&cfg.ID, "id", "", "album id")
@@ -779,23 +1225,42 @@ func newPublishCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 	fs.StringVar(&cfg.Chrome, "chrome", "", "chrome binary path (optional)")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.DurationVar(&cfg.MaxRuntime, "max-runtime", 0, "maximum run time, exits with code 3 if work remains (0 means no limit)")
+	fs.DurationVar(&cfg.ProgressInterval, "progress-interval", 30*time.Second, "time between progress logs (0 means no progress logs)")
 	fs.DurationVar(&cfg.WaitMin, "wait-min", 3*time.Second, "minimum wait time between songs")
 	fs.DurationVar(&cfg.WaitMax, "wait-max", 1*time.Minute, "maximum wait time between songs")
 
 	fs.BoolVar(&cfg.Auto, "auto", false, "auto publish (if disabled, the user will need to click the publish button)")
+	fs.StringVar(&cfg.Provider, "provider", "distrokid", "provider to publish to (distrokid, bandcamp, soundcloud, jamendo) or targets to use the targets of each album")
 	fs.StringVar(&cfg.Account, "account", "", "account to use")
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.FirstName, "first-name", "", "songwriter first name to use")
 	fs.StringVar(&cfg.LastName, "last-name", "", "songwriter last name to use")
 	fs.StringVar(&cfg.RecordLabel, "record-label", "", "record label to use")
+	fs.StringVar(&cfg.ScreenshotDir, "screenshot-dir", "logs", "folder to save the screenshots taken before submitting")
+	fs.BoolVar(&cfg.UploadScreenshot, "upload-screenshot", false, "upload the screenshot to the file storage as screenshot_<album-id>.png")
+	fs.DurationVar(&cfg.WaitBeforeClose, "wait-before-close", 1*time.Second, "time to wait after the album is submitted before closing the tab")
+	fs.DurationVar(&cfg.SubmitTimeout, "submit-timeout", 5*time.Minute, "time to wait for the preview page in auto mode before flagging the album for manual review")
+	fs.IntVar(&cfg.DistrokidMaxSongs, "distrokid-max-songs", distrokid.DefaultMaxSongs, "maximum number of songs of a distrokid album")
+	fs.Float64Var(&cfg.Price, "price", 7, "album price for bandcamp")
+	fs.StringVar(&cfg.JamendoArtistName, "jamendo-artist-name", "", "jamendo artist name")
+	fs.IntVar(&cfg.JamendoArtistID, "jamendo-artist-id", 0, "jamendo artist id")
+	fs.IntVar(&cfg.JamendoAttempts, "jamendo-attempts", 3, "maximum attempts of each jamendo api request")
+	fs.IntVar(&cfg.JamendoMaxGenres, "jamendo-max-genres", 2, "maximum number of genres per jamendo song, the highest ranked are kept")
+	fs.IntVar(&cfg.JamendoMaxTags, "jamendo-max-tags", 2, "maximum number of tags per jamendo song, the highest ranked are kept")
+	fs.IntVar(&cfg.JamendoConvertConcurrency, "jamendo-convert-concurrency", jamendo.DefaultConvertConcurrency, "number of songs downloaded and converted to wav at the same time for jamendo")
+	fs.BoolVar(&cfg.JamendoLoginRetry, "jamendo-login-retry", true, "reload the jamendo cookie from the database and retry once if the session has expired")
+	fs.BoolVar(&cfg.Resume, "resume", false, "resume after the last album published in order by a previous run")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "prepare and validate the albums, downloading their files, and report them without publishing")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -823,14 +1288,15 @@ func newJamendoCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 	fs.StringVar(&cfg.Chrome, "chrome", "", "chrome binary path (optional)")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.Attempts, "attempts", 3, "maximum attempts of each jamendo api request")
 
 	fs.BoolVar(&cfg.Auto, "auto", false, "auto publish (if disabled, the user will need to click the publish button)")
 	fs.StringVar(&cfg.Account, "account", "", "account to use")
@@ -838,6 +1304,12 @@ func newJamendoCommand() *ffcli.Command {
 	fs.IntVar(&cfg.ArtistID, "artist-id", 0, "jamendo artist id")
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.Albums, "albums", "", "album IDs to publish (comma separated)")
+	fs.IntVar(&cfg.MaxGenres, "max-genres", 2, "maximum number of genres per song, the highest ranked are kept")
+	fs.IntVar(&cfg.MaxTags, "max-tags", 2, "maximum number of tags per song, the highest ranked are kept")
+	fs.BoolVar(&cfg.AllowMissingAnalysis, "allow-missing-analysis", false, "publish songs without spotify analysis skipping energy, mood and acousticness instead of failing")
+	fs.BoolVar(&cfg.Resume, "resume", false, "resume after the last album published in order by a previous run")
+	fs.IntVar(&cfg.ConvertConcurrency, "convert-concurrency", jamendo.DefaultConvertConcurrency, "number of songs downloaded and converted to wav at the same time")
+	fs.BoolVar(&cfg.LoginRetry, "login-retry", true, "reload the cookie from the database and retry once if the session has expired")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -870,6 +1342,7 @@ func newSyncCommand() *ffcli.Command {
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
 	fs.DurationVar(&cfg.WaitMin, "wait-min", 3*time.Second, "minimum wait time between songs")
 	fs.DurationVar(&cfg.WaitMax, "wait-max", 1*time.Minute, "maximum wait time between songs")
 
@@ -878,8 +1351,11 @@ func newSyncCommand() *ffcli.Command {
 	fs.StringVar(&cfg.SpotifySecret, "spotify-secret", "", "spotify client secret")
 
 	fs.StringVar(&cfg.Channels, "channels", "", "comma separated list of youtube channels to sync")
-	fs.StringVar(&cfg.From, "from", "", "from date to sync (only for youtube)")
+	fs.StringVar(&cfg.From, "from", "", "from date to sync (only for youtube), defaults to the last synced video of each channel or the last 30 days")
 	fs.StringVar(&cfg.YoutubeKey, "youtube-key", "", "youtube api key")
+	fs.DurationVar(&cfg.YoutubeWait, "youtube-wait", 1*time.Second, "minimum wait time between youtube requests")
+	fs.IntVar(&cfg.YoutubeQuota, "youtube-quota", 0, "maximum youtube quota units to use (0 means no limit)")
+	fs.Float64Var(&cfg.YoutubeMinConfidence, "youtube-min-confidence", 0.9, "minimum confidence to link a youtube video to a song without review")
 
 	return &ffcli.Command{
 		Name:       cmd,
@@ -897,6 +1373,151 @@ func newSyncCommand() *ffcli.Command {
 	}
 }
 
+func newISRCCommand() *ffcli.Command {
+	cmd := "isrc"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &isrc.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+
+	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number of songs and albums to assign (0 means no limit)")
+	fs.StringVar(&cfg.Type, "type", "", "type of the songs and albums to assign (optional)")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "log the codes that would be assigned without saving them")
+
+	fs.StringVar(&cfg.ISRCPrefix, "isrc-prefix", "", "isrc prefix with country, registrant and year (CC-XXX-YY) used to assign isrcs to songs")
+	fs.StringVar(&cfg.UPCPrefix, "upc-prefix", "", "gs1 company prefix (6 to 10 digits) used to assign upcs to albums")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return isrc.Run(ctx, cfg)
+		},
+	}
+}
+
+func newSpotifyCommand() *ffcli.Command {
+	cmd := "spotify"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &spotify.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
+
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
+	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.StringVar(&cfg.Type, "type", "", "type of the songs to enrich (optional)")
+
+	fs.StringVar(&cfg.SpotifyID, "spotify-id", "", "spotify client id")
+	fs.StringVar(&cfg.SpotifySecret, "spotify-secret", "", "spotify client secret")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return spotify.Run(ctx, cfg)
+		},
+	}
+}
+
+func newYoutubeUploadCommand() *ffcli.Command {
+	cmd := "youtube-upload"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &youtube.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
+
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
+	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
+	fs.StringVar(&cfg.Type, "type", "", "type of the songs to upload (optional)")
+	fs.StringVar(&cfg.Account, "account", "", "account of the youtube oauth token setting")
+
+	fs.StringVar(&cfg.Privacy, "privacy", "private", "privacy status of the videos (public, unlisted, private)")
+	fs.StringVar(&cfg.ClientID, "youtube-client-id", "", "oauth client id to refresh the token (optional)")
+	fs.StringVar(&cfg.ClientSecret, "youtube-client-secret", "", "oauth client secret to refresh the token (optional)")
+	fs.DurationVar(&cfg.Wait, "youtube-wait", 1*time.Second, "minimum wait time between youtube requests")
+	fs.IntVar(&cfg.Quota, "youtube-quota", 0, "maximum youtube quota units to use (0 means no limit)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return youtube.Run(ctx, cfg)
+		},
+	}
+}
+
+func newYoutubeReviewCommand() *ffcli.Command {
+	cmd := "youtube-review"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &sync.ReviewConfig{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+
+	fs.StringVar(&cfg.Action, "action", "list", "action to perform (list, confirm, reject)")
+	fs.StringVar(&cfg.Video, "video", "", "youtube video id to confirm or reject")
+	fs.StringVar(&cfg.Song, "song", "", "song id to link the video to (optional, defaults to the matched song)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return sync.RunYoutubeReview(ctx, cfg)
+		},
+	}
+}
+
 func loadSession(fs *flag.FlagSet, file string) error {
 	if file == "" {
 		return fmt.Errorf("session file not specified")
@@ -921,17 +1542,25 @@ func newDownloadCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
 
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.Output, "output", ".cache", "output folder")
 
+	// Audio format parameters
+	fs.StringVar(&cfg.Format, "format", "", "transcode audio to this format (wav, flac, mp3), empty keeps the stored format")
+	fs.IntVar(&cfg.SampleRate, "sample-rate", 0, "sample rate when transcoding (0 keeps the original)")
+	fs.IntVar(&cfg.BitDepth, "bit-depth", 0, "bit depth when transcoding to wav (16, 24, 32) or flac (16, 24)")
+	fs.StringVar(&cfg.Bitrate, "bitrate", "320k", "bitrate when transcoding to mp3")
+	fs.BoolVar(&cfg.NoTags, "no-tags", false, "don't write id3 tags to the downloaded mp3 files")
+
 	return &ffcli.Command{
 		Name:       cmd,
 		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
@@ -958,17 +1587,26 @@ func newDownloadAlbumCommand() *ffcli.Command {
 	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
 	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
 	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
-	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, telegram)")
-	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, token@chat for telegram")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
 	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
 
 	fs.DurationVar(&cfg.Timeout, "timeout", 0, "timeout for the process (0 means no timeout)")
 	fs.IntVar(&cfg.Concurrency, "concurrency", 1, "number of concurrent processes")
 	fs.IntVar(&cfg.Limit, "limit", 0, "limit the number iterations (0 means no limit)")
+	fs.IntVar(&cfg.MaxConsecutiveErrors, "max-consecutive-errors", 10, "consecutive errors tolerated before aborting")
 
 	fs.StringVar(&cfg.Type, "type", "", "type to use")
 	fs.StringVar(&cfg.Output, "output", ".cache", "output folder")
 
+	// Audio format parameters
+	fs.StringVar(&cfg.Format, "format", "", "transcode audio to this format (wav, flac, mp3), empty keeps the stored format")
+	fs.IntVar(&cfg.SampleRate, "sample-rate", 0, "sample rate when transcoding (0 keeps the original)")
+	fs.IntVar(&cfg.BitDepth, "bit-depth", 0, "bit depth when transcoding to wav (16, 24, 32) or flac (16, 24)")
+	fs.StringVar(&cfg.Bitrate, "bitrate", "320k", "bitrate when transcoding to mp3")
+	fs.BoolVar(&cfg.NoTags, "no-tags", false, "don't write id3 tags to the downloaded mp3 files")
+	fs.BoolVar(&cfg.Force, "force", false, "download the files again even if they already exist")
+
 	return &ffcli.Command{
 		Name:       cmd,
 		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
@@ -985,6 +1623,41 @@ func newDownloadAlbumCommand() *ffcli.Command {
 	}
 }
 
+func newMixdownCommand() *ffcli.Command {
+	cmd := "mixdown"
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	_ = fs.String("config", "", "config file (optional)")
+
+	cfg := &mixdown.Config{}
+
+	fs.BoolVar(&cfg.Debug, "debug", false, "debug mode")
+	fs.StringVar(&cfg.DBType, "db-type", "", "db type (local, sqlite, mysql, postgres)")
+	fs.StringVar(&cfg.DBConn, "db-conn", "", "path for sqlite, dsn for mysql or postgres")
+	fs.StringVar(&cfg.FSType, "fs-type", "", "fs type (local, s3, gcs, telegram, webdav)")
+	fs.StringVar(&cfg.FSConn, "fs-conn", "", "path for local, key:secret@bucker.region for s3, keyfile.json@bucket for gcs, token@chat for telegram, user:pass@https://host/path for webdav")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy to use")
+
+	fs.StringVar(&cfg.ID, "id", "", "album id")
+	fs.StringVar(&cfg.Output, "output", ".cache", "output folder")
+	fs.DurationVar(&cfg.Fade, "fade", 3*time.Second, "crossfade duration between songs (0 joins them without gaps)")
+	fs.StringVar(&cfg.Format, "format", "mp3", "output audio format (wav, flac, mp3)")
+
+	return &ffcli.Command{
+		Name:       cmd,
+		ShortUsage: fmt.Sprintf("musikai %s [flags] <key> <value data...>", cmd),
+		Options: []ff.Option{
+			ff.WithConfigFileFlag("config"),
+			ff.WithConfigFileParser(ffyaml.Parser),
+			ff.WithEnvVarPrefix("MUSIKAI"),
+		},
+		ShortHelp: fmt.Sprintf("musikai %s action", cmd),
+		FlagSet:   fs,
+		Exec: func(ctx context.Context, args []string) error {
+			return mixdown.Run(ctx, cfg)
+		},
+	}
+}
+
 type mapValue struct {
 	v *map[string]string
 }
@@ -1011,6 +1684,40 @@ func (m *mapValue) Set(value string) error {
 	return nil
 }
 
+type intSliceValue struct {
+	v *[]int
+}
+
+func (m *intSliceValue) String() string {
+	if m.v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", *m.v)
+}
+
+func (m *intSliceValue) Set(value string) error {
+	if m.v == nil {
+		return errors.New("nil slice reference")
+	}
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid int entry: %s", v)
+		}
+		*m.v = append(*m.v, i)
+	}
+	return nil
+}
+
+func fsIntSliceVar(fs *flag.FlagSet, p *[]int, name string, value []int, usage string) {
+	*p = value
+	fs.Var(&intSliceValue{p}, name, usage)
+}
+
 func fsMapVar(fs *flag.FlagSet, p *map[string]string, name string, value map[string]string, usage string) {
 	if value == nil {
 		value = make(map[string]string)
@@ -3,8 +3,11 @@ package album
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	stdimage "image"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
@@ -14,11 +17,14 @@ import (
 	"time"
 
 	"github.com/gocarina/gocsv"
+	"github.com/igolaizola/musikai/pkg/cmd/isrc"
+	"github.com/igolaizola/musikai/pkg/cmd/title"
 	"github.com/igolaizola/musikai/pkg/distrokid"
 	"github.com/igolaizola/musikai/pkg/filestore"
 	"github.com/igolaizola/musikai/pkg/image"
+	"github.com/igolaizola/musikai/pkg/queue"
 	"github.com/igolaizola/musikai/pkg/storage"
-	"github.com/oklog/ulid/v2"
+	"github.com/igolaizola/musikai/pkg/timing"
 )
 
 type Config struct {
@@ -39,8 +45,90 @@ type Config struct {
 	Font       string
 	Genres     string
 	ReuseCover bool
+
+	// VolumeCoverPolicy sets whether volumes of the same draft share a cover
+	// ("reuse") or each one gets a different cover ("distinct").
+	// If empty, ReuseCover is used to choose the policy.
+	VolumeCoverPolicy string
+
+	// ArtistPool is a comma separated list or a file with one artist per line.
+	// If set, the artist of each album is chosen from the pool using the
+	// ArtistStrategy ("round-robin" or "type").
+	ArtistPool     string
+	ArtistStrategy string
+
+	// ArtistMaxLength caps the length of the artist names and
+	// ArtistDisallowed are the characters removed from them.
+	// ArtistCheckExisting also checks the pool artists for collisions with
+	// the existing artists, which only differ in case, spacing or
+	// punctuation.
+	// ArtistMergeCollisions uses the previous spelling of the colliding
+	// artists instead of failing.
+	ArtistMaxLength       int
+	ArtistDisallowed      string
+	ArtistCheckExisting   bool
+	ArtistMergeCollisions bool
+
+	// AutoTitle generates a title when there are no approved titles left.
+	AutoTitle bool
+	// BannedWords is a comma separated list or a file with one word per line.
+	// Titles containing any of these words are rejected instead of assigned.
+	BannedWords string
+
+	// IDStrategy is the strategy used to generate the album IDs (ulid or
+	// nanoid).
+	IDStrategy string
+
+	// Profile prints the time spent on each stage at the end of the run.
+	Profile bool
+
+	// Targets is the comma separated list of platforms the albums are
+	// published to.
+	Targets string
+
+	// RequireProcessed excludes the songs that aren't processed yet, so albums
+	// never reference a missing master.
+	RequireProcessed bool
+	// AllowUnmastered includes the processed songs that weren't mastered
+	// (processed with skip master) when processed songs are required.
+	AllowUnmastered bool
+
+	// TempoMin and TempoMax limit the songs to a BPM range.
+	// TempoSpread limits the songs to a window of BPMs around the tempo of the
+	// first song, so albums have a coherent tempo.
+	// Zero values mean unconstrained.
+	TempoMin    float64
+	TempoMax    float64
+	TempoSpread float64
+
+	// RenderTitle draws the album title on the cover, in addition to the
+	// subtitle and volume.
+	// TitlePosition is the preferred position of the title (e.g. top-center)
+	// and TitleFont the font used, Font if empty.
+	// If the title would overlap the overlay or the subtitle, the first free
+	// position is used instead.
+	RenderTitle   bool
+	TitlePosition string
+	TitleFont     string
+
+	// RequireISRC fails when the songs of an album don't have ISRCs.
+	// AssignISRC assigns ISRCs with the ISRCPrefix (CC-XXX-YY) to the songs
+	// that don't have one when the album is built.
+	RequireISRC bool
+	AssignISRC  bool
+	ISRCPrefix  string
+
+	// DistrokidMaxSongs is the maximum number of songs of a DistroKid album
+	// (distrokid.DefaultMaxSongs if zero). It caps MaxSongs when the albums
+	// can be published to DistroKid.
+	DistrokidMaxSongs int
 }
 
+const (
+	VolumeCoverReuse    = "reuse"
+	VolumeCoverDistinct = "distinct"
+)
+
 type typeGenres struct {
 	Type      string `json:"type" csv:"type"`
 	Primary   string `json:"primary" csv:"primary"`
@@ -66,21 +154,82 @@ func Run(ctx context.Context, cfg *Config) error {
 	if cfg.MinSongs == 0 {
 		return fmt.Errorf("album: min songs not set")
 	}
+	if cfg.IDStrategy == storage.IDExternal {
+		return fmt.Errorf("album: external ids aren't supported")
+	}
+	ids, err := storage.NewIDGenerator(cfg.IDStrategy)
+	if err != nil {
+		return fmt.Errorf("album: %w", err)
+	}
 	if cfg.MaxSongs < cfg.MinSongs {
 		return fmt.Errorf("album: max songs must equal or greater than min songs")
 	}
-	if cfg.Artist == "" {
-		return fmt.Errorf("album: artist not set")
-	}
 	if cfg.Overlay == "" {
 		return fmt.Errorf("album: overlay file not set")
 	}
+	targets, err := storage.ParseTargets(cfg.Targets)
+	if err != nil {
+		return fmt.Errorf("album: %w", err)
+	}
+	if cfg.TempoMin < 0 || cfg.TempoMax < 0 || cfg.TempoSpread < 0 {
+		return fmt.Errorf("album: tempo constraints can't be negative")
+	}
+	if cfg.TempoMax > 0 && cfg.TempoMax < cfg.TempoMin {
+		return fmt.Errorf("album: tempo max must be equal or greater than tempo min")
+	}
+	maxSongs := cfg.MaxSongs
+	if allowsDistrokid(targets) {
+		dkMax := cfg.DistrokidMaxSongs
+		if dkMax == 0 {
+			dkMax = distrokid.DefaultMaxSongs
+		}
+		if cfg.MinSongs > dkMax {
+			return fmt.Errorf("album: min songs %d exceed the distrokid maximum of %d", cfg.MinSongs, dkMax)
+		}
+		if maxSongs > dkMax {
+			log.Printf("album: max songs capped to the distrokid maximum of %d\n", dkMax)
+			maxSongs = dkMax
+		}
+	}
+	tempoConstrained := cfg.TempoMin > 0 || cfg.TempoMax > 0 || cfg.TempoSpread > 0
+	if cfg.AssignISRC && cfg.ISRCPrefix == "" {
+		return fmt.Errorf("album: isrc prefix is required to assign isrcs")
+	}
+
+	policy := cfg.VolumeCoverPolicy
+	switch policy {
+	case "":
+		policy = VolumeCoverDistinct
+		if cfg.ReuseCover {
+			policy = VolumeCoverReuse
+		}
+	case VolumeCoverReuse, VolumeCoverDistinct:
+	default:
+		return fmt.Errorf("album: invalid volume cover policy %q", policy)
+	}
 
 	// Check if overlay file exists
 	if _, err := os.Stat(cfg.Overlay); err != nil {
 		return fmt.Errorf("album: couldn't find overlay file: %w", err)
 	}
 
+	titlePosition := image.TopCenter
+	titleFont := cfg.Font
+	if cfg.RenderTitle {
+		if cfg.TitlePosition != "" {
+			titlePosition, err = image.ParsePosition(cfg.TitlePosition)
+			if err != nil {
+				return fmt.Errorf("album: %w", err)
+			}
+		}
+		if cfg.TitleFont != "" {
+			titleFont = cfg.TitleFont
+		}
+		if _, err := os.Stat(titleFont); err != nil {
+			return fmt.Errorf("album: couldn't find title font file: %w", err)
+		}
+	}
+
 	// Check if genres file exists
 	if _, err := os.Stat(cfg.Genres); err != nil {
 		return fmt.Errorf("album: couldn't find genres file: %w", err)
@@ -116,8 +265,37 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 	}
 
+	// Continue the round-robin from the number of existing albums
+	var offset int
+	if cfg.ArtistPool != "" {
+		n, err := store.CountAlbums(ctx)
+		if err != nil {
+			return fmt.Errorf("album: couldn't count albums: %w", err)
+		}
+		offset = int(n)
+	}
+	artists, err := newArtistPicker(cfg.Artist, cfg.ArtistPool, cfg.ArtistStrategy, offset)
+	if err != nil {
+		return err
+	}
+	var existing []string
+	if cfg.ArtistCheckExisting {
+		existing, err = store.ListArtists(ctx)
+		if err != nil {
+			return fmt.Errorf("album: couldn't list artists: %w", err)
+		}
+	}
+	if err := artists.adjust(cfg.ArtistMaxLength, cfg.ArtistDisallowed, existing, cfg.ArtistMergeCollisions); err != nil {
+		return err
+	}
+	banned, err := title.NewBanned(cfg.BannedWords)
+	if err != nil {
+		return err
+	}
+
 	// Print time stats
 	start := time.Now()
+	prof := timing.New(cfg.Profile)
 	defer func() {
 		total := time.Since(start)
 		i := iteration
@@ -125,6 +303,7 @@ func Run(ctx context.Context, cfg *Config) error {
 			i = 1
 		}
 		log.Printf("album: total time %s, average time %s\n", total, total/time.Duration(i))
+		prof.Log("album")
 	}()
 
 	timeout := cfg.Timeout
@@ -154,6 +333,9 @@ func Run(ctx context.Context, cfg *Config) error {
 			filters = append(filters, storage.Where("drafts.type LIKE ?", cfg.Type))
 		}
 		draft, err := store.NextDraftCandidate(ctx, cfg.MinSongs, "", filters...)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("album: no draft candidates: %w", queue.ErrEmpty)
+		}
 		if err != nil {
 			return fmt.Errorf("album: couldn't get next draft: %w", err)
 		}
@@ -169,6 +351,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		// If volumes is enabled, obtain the last volume
 		var cover *storage.Cover
 		var volume int
+		var artist string
 		if draft.Volumes > 0 {
 			volume = 1
 			albumFilters := []storage.Filter{
@@ -180,7 +363,9 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 			if len(albums) > 0 {
 				volume = albums[0].Volume + 1
-				if cfg.ReuseCover {
+				// Keep the artist of previous volumes
+				artist = albums[0].Artist
+				if policy == VolumeCoverReuse {
 					// Get cover from last volume
 					cover, err = store.GetCover(ctx, albums[0].CoverID)
 					if err != nil {
@@ -206,6 +391,9 @@ func Run(ctx context.Context, cfg *Config) error {
 				return fmt.Errorf("album: couldn't get cover: %w", err)
 			}
 			if len(covers) == 0 {
+				if draft.Volumes > 0 && volume > 1 && policy == VolumeCoverDistinct {
+					return fmt.Errorf("album: no distinct cover left for %q volume %d", draft.Title, volume)
+				}
 				return fmt.Errorf("album: no cover found")
 			}
 			cover = covers[0]
@@ -221,12 +409,53 @@ func Run(ctx context.Context, cfg *Config) error {
 			storage.Where("type LIKE ?", draft.Type),
 			storage.Where("album_id = ?", ""),
 		}
-		songs, err := store.ListSongs(ctx, 1, cfg.MaxSongs, "likes desc, random()", songsFilters...)
+		var unprocessed int64
+		if cfg.RequireProcessed {
+			processed := storage.Where("generations.processed = ?", true)
+			unprocessedFilter := storage.Where("generations.processed = ?", false)
+			if !cfg.AllowUnmastered {
+				processed = storage.Where("generations.processed = ? AND generations.mastered = ?", true, true)
+				unprocessedFilter = storage.Where("(generations.processed = ? OR generations.mastered = ?)", false, false)
+			}
+			unprocessed, err = store.CountSongs(ctx, append(songsFilters, unprocessedFilter)...)
+			if err != nil {
+				return fmt.Errorf("album: couldn't count unprocessed songs: %w", err)
+			}
+			if unprocessed > 0 {
+				log.Printf("album: excluding %d approved %s songs that aren't processed and mastered yet\n", unprocessed, draft.Type)
+			}
+			songsFilters = append(songsFilters, processed)
+		}
+		if tempoConstrained {
+			// Songs without a detected tempo can't be checked
+			songsFilters = append(songsFilters, storage.Where("generations.tempo > ?", 0))
+		}
+		if cfg.TempoMin > 0 {
+			songsFilters = append(songsFilters, storage.Where("generations.tempo >= ?", cfg.TempoMin))
+		}
+		if cfg.TempoMax > 0 {
+			songsFilters = append(songsFilters, storage.Where("generations.tempo <= ?", cfg.TempoMax))
+		}
+		size := maxSongs
+		if cfg.TempoSpread > 0 {
+			// Get more candidates to find a window with enough songs
+			size = 1000
+		}
+		songs, err := store.ListSongs(ctx, 1, size, "likes desc, random()", songsFilters...)
 		if err != nil {
 			return fmt.Errorf("album: couldn't get songs: %w", err)
 		}
+		if cfg.TempoSpread > 0 {
+			songs = tempoWindow(songs, cfg.MinSongs, maxSongs, cfg.TempoSpread)
+		}
 		if len(songs) < cfg.MinSongs {
-			return fmt.Errorf("album: not enough songs")
+			if unprocessed > 0 {
+				return fmt.Errorf("album: not enough processed songs (%d pending processing or mastering): %w", unprocessed, queue.ErrEmpty)
+			}
+			if tempoConstrained {
+				return fmt.Errorf("album: not enough songs within the tempo constraints (%d found): %w", len(songs), queue.ErrEmpty)
+			}
+			return fmt.Errorf("album: not enough songs: %w", queue.ErrEmpty)
 		}
 
 		// Choose randomly number of songs
@@ -236,6 +465,26 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 		songs = songs[:n]
 
+		// Check the ISRCs before building the album, so songs without them
+		// don't fail later when publishing.
+		// Albums that can only be published to DistroKid are skipped because
+		// it assigns its own codes.
+		if needsISRC(targets) {
+			if cfg.AssignISRC {
+				assigned, err := isrc.AssignSongs(ctx, store, cfg.ISRCPrefix, songs)
+				if err != nil {
+					return fmt.Errorf("album: couldn't assign isrcs: %w", err)
+				}
+				debug("album: %d isrcs assigned", assigned)
+			}
+			if missing := missingISRCs(songs); missing > 0 {
+				if cfg.RequireISRC {
+					return fmt.Errorf("album: %d of %d songs don't have isrc", missing, len(songs))
+				}
+				log.Printf("album: warning, %d of %d songs don't have isrc\n", missing, len(songs))
+			}
+		}
+
 		// Assign titles to songs
 		var titles []*storage.Title
 		var inTitles []string
@@ -253,12 +502,36 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 			// Order so the titles with a matching style are first
 			orderBy := fmt.Sprintf("CASE WHEN style = '%s' THEN 1 ELSE 2 END, random()", song.Style)
-			resp, err := store.ListTitles(ctx, 1, 1, orderBy, titleFilters...)
-			if err != nil {
-				return fmt.Errorf("album: couldn't get titles: %w", err)
+			var resp []*storage.Title
+			for {
+				resp, err = store.ListTitles(ctx, 1, 1, orderBy, titleFilters...)
+				if err != nil {
+					return fmt.Errorf("album: couldn't get titles: %w", err)
+				}
+				if len(resp) == 0 {
+					break
+				}
+				word, ok := banned.Match(resp[0].Title)
+				if !ok {
+					break
+				}
+				// Reject the title so it isn't picked again
+				log.Printf("album: title %q rejected due to banned word %q\n", resp[0].Title, word)
+				resp[0].State = storage.Rejected
+				if err := store.SetTitle(ctx, resp[0]); err != nil {
+					return fmt.Errorf("album: couldn't set title: %w", err)
+				}
 			}
 			if len(resp) == 0 {
-				return fmt.Errorf("album: not enough titles")
+				if !cfg.AutoTitle {
+					return fmt.Errorf("album: not enough titles")
+				}
+				t, err := title.Auto(ctx, store, draft.Type, song.Style, inTitles, banned)
+				if err != nil {
+					return fmt.Errorf("album: couldn't generate title: %w", err)
+				}
+				log.Printf("album: no titles left for %s, generated %q\n", draft.Type, t.Title)
+				resp = append(resp, t)
 			}
 			song.Title = resp[0].Title
 			titles = append(titles, resp[0])
@@ -266,21 +539,30 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 
 		debug("album: start download cover %s", cover.ID)
+		stop := prof.Start("download cover")
 		name := filestore.JPG(cover.ID)
 		original := filepath.Join(os.TempDir(), name)
 		if err := fs.GetJPG(ctx, original, cover.ID); err != nil {
 			return fmt.Errorf("album: couldn't download cover image: %w", err)
 		}
 		defer func() { _ = os.Remove(original) }()
+		stop()
 		debug("album: end download cover %s", cover.ID)
 
-		albumID := ulid.Make().String()
+		albumID, err := ids.NewID("")
+		if err != nil {
+			return fmt.Errorf("album: couldn't create id: %w", err)
+		}
+		if artist == "" {
+			artist = artists.pick(draft.Type)
+		}
 
 		input := original
 		output := filepath.Join(os.TempDir(), fmt.Sprintf("%s.jpeg", albumID))
 		defer func() { _ = os.Remove(output) }()
 
 		// Add subtitle to cover
+		stop = prof.Start("edit cover")
 		subtitle := draft.Subtitle
 		if volume > 0 {
 			if subtitle != "" {
@@ -288,6 +570,35 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 			subtitle = fmt.Sprintf("%sVol. %d", subtitle, volume)
 		}
+
+		// Add title to cover
+		if cfg.RenderTitle && draft.Title != "" {
+			width, height, err := image.Dimensions(input)
+			if err != nil {
+				return fmt.Errorf("album: couldn't get cover dimensions: %w", err)
+			}
+			overlay, err := image.OverlayBounds(cfg.Overlay, width, height)
+			if err != nil {
+				return fmt.Errorf("album: couldn't get overlay bounds: %w", err)
+			}
+			occupied := []stdimage.Rectangle{overlay}
+			if subtitle != "" {
+				occupied = append(occupied, image.BottomLeft.Area(width, height))
+			}
+			position, ok := image.FreePosition(titlePosition, width, height, occupied...)
+			switch {
+			case !ok:
+				log.Printf("album: title overlaps the overlay or subtitle at any position, using %s\n", position)
+			case position != titlePosition:
+				log.Printf("album: title moved from %s to %s to avoid overlapping the overlay or subtitle\n", titlePosition, position)
+			}
+			log.Println("Adding title to cover", draft.Title)
+			if err := image.AddText(draft.Title, position, titleFont, input, output); err != nil {
+				return fmt.Errorf("album: couldn't add title to cover: %w", err)
+			}
+			input = output
+		}
+
 		if subtitle != "" {
 			log.Println("Adding subtitle to cover", subtitle)
 			if err := image.AddText(subtitle, image.BottomLeft, cfg.Font, input, output); err != nil {
@@ -301,11 +612,15 @@ func Run(ctx context.Context, cfg *Config) error {
 			return fmt.Errorf("album: couldn't add overlay to cover: %w", err)
 		}
 
+		stop()
+
 		// Upload cover to telegram
 		debug("album: upload start %s", albumID)
+		stop = prof.Start("upload cover")
 		if err := fs.SetJPG(ctx, output, albumID); err != nil {
 			return fmt.Errorf("album: couldn't upload cover image: %w", err)
 		}
+		stop()
 		debug("album: upload end %s", albumID)
 
 		// Create the album
@@ -314,12 +629,13 @@ func Run(ctx context.Context, cfg *Config) error {
 			CoverID:        cover.ID,
 			DraftID:        draft.ID,
 			Type:           draft.Type,
-			Artist:         cfg.Artist,
+			Artist:         artist,
 			Title:          draft.Title,
 			Subtitle:       draft.Subtitle,
 			Volume:         volume,
 			PrimaryGenre:   primaryGenre,
 			SecondaryGenre: secondaryGenre,
+			Targets:        targets,
 			State:          storage.Pending,
 		}
 		if err := store.SetAlbum(ctx, album); err != nil {
@@ -367,6 +683,80 @@ func Run(ctx context.Context, cfg *Config) error {
 
 }
 
+// missingISRCs returns the number of songs without ISRC.
+func missingISRCs(songs []*storage.Song) int {
+	var n int
+	for _, s := range songs {
+		if s.ISRC == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// needsISRC returns whether the albums can be published to platforms other
+// than DistroKid, which assigns its own codes, so the songs need ISRCs.
+// Albums without targets can be published to any platform.
+func needsISRC(targets string) bool {
+	if targets == "" {
+		return true
+	}
+	for _, t := range strings.Split(targets, ",") {
+		if t != "" && t != storage.TargetDistrokid {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsDistrokid returns whether the albums can be published to DistroKid.
+// Albums without targets can be published to any platform.
+func allowsDistrokid(targets string) bool {
+	if targets == "" {
+		return true
+	}
+	for _, t := range strings.Split(targets, ",") {
+		if t == storage.TargetDistrokid {
+			return true
+		}
+	}
+	return false
+}
+
+// tempoWindow returns up to max songs whose tempo is within the spread of
+// the tempo of an anchor song.
+// Songs are sorted by preference, so the first anchor with at least min songs
+// in its window is used, and the songs keep their order.
+// If no window has enough songs, the largest window is returned.
+func tempoWindow(songs []*storage.Song, min, max int, spread float64) []*storage.Song {
+	var best []*storage.Song
+	for _, anchor := range songs {
+		var window []*storage.Song
+		for _, s := range songs {
+			if math.Abs(float64(tempo(s)-tempo(anchor))) <= spread {
+				window = append(window, s)
+			}
+			if len(window) == max {
+				break
+			}
+		}
+		if len(window) >= min {
+			return window
+		}
+		if len(window) > len(best) {
+			best = window
+		}
+	}
+	return best
+}
+
+func tempo(s *storage.Song) float32 {
+	if s.Generation == nil {
+		return 0
+	}
+	return s.Generation.Tempo
+}
+
 func toGenres(input string) (map[string][2]string, error) {
 	b, err := os.ReadFile(input)
 	if err != nil {
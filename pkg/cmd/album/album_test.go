@@ -0,0 +1,123 @@
+package album
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestTempoWindow(t *testing.T) {
+	var songs []*storage.Song
+	for i, tempo := range []float32{120, 80, 82, 125, 85, 78, 118} {
+		songs = append(songs, &storage.Song{
+			ID:         string(rune('a' + i)),
+			Generation: &storage.Generation{Tempo: tempo},
+		})
+	}
+	ids := func(songs []*storage.Song) string {
+		var s string
+		for _, v := range songs {
+			s += v.ID
+		}
+		return s
+	}
+	tests := []struct {
+		min, max int
+		spread   float64
+		want     string
+	}{
+		// The first song has enough songs in its window
+		{2, 10, 5, "adg"},
+		// The first song doesn't have enough songs in its window
+		{4, 10, 5, "bcef"},
+		// The window is limited to max songs
+		{2, 2, 5, "ad"},
+		// No window has enough songs, the largest one is returned
+		{5, 10, 5, "bcef"},
+	}
+	for _, tt := range tests {
+		got := ids(tempoWindow(songs, tt.min, tt.max, tt.spread))
+		if got != tt.want {
+			t.Errorf("tempoWindow(%d, %d, %.0f) = %q, want %q", tt.min, tt.max, tt.spread, got, tt.want)
+		}
+	}
+}
+
+func TestArtistAdjust(t *testing.T) {
+	tests := []struct {
+		pool     string
+		existing []string
+		merge    bool
+		want     []string
+		wantErr  bool
+	}{
+		{"  Lo  Fi\tDreams ", nil, false, []string{"Lo Fi Dreams"}, false},
+		{"DJ <Night>*,Night Owl", nil, false, []string{"DJ Night", "Night Owl"}, false},
+		{"An artist name that is too long", nil, false, []string{"An artist name"}, false},
+		{"LoFi Dreams,Night Owl", []string{"LoFi Dreams"}, false, []string{"LoFi Dreams", "Night Owl"}, false},
+		{"lofi dreams,Night Owl", []string{"LoFi Dreams"}, false, nil, true},
+		{"lofi dreams,Night Owl", []string{"LoFi Dreams"}, true, []string{"LoFi Dreams", "Night Owl"}, false},
+		{"Night Owl,night-owl", nil, false, nil, true},
+		{"Night Owl,night-owl", nil, true, []string{"Night Owl", "Night Owl"}, false},
+	}
+	for _, tt := range tests {
+		p, err := newArtistPicker("", tt.pool, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.adjust(16, DefaultArtistDisallowed, tt.existing, tt.merge)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("adjust(%q, %v) unexpected error %v", tt.pool, tt.merge, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if strings.Join(p.artists, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("adjust(%q) = %q, want %q", tt.pool, p.artists, tt.want)
+		}
+	}
+
+	p, err := newArtistPicker("", "<>", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.adjust(0, DefaultArtistDisallowed, nil, false); err == nil {
+		t.Error("expected error for an empty artist")
+	}
+}
+
+func TestAllowsDistrokid(t *testing.T) {
+	tests := []struct {
+		targets string
+		want    bool
+	}{
+		{"", true},
+		{"distrokid", true},
+		{"distrokid,jamendo", true},
+		{"jamendo,bandcamp", false},
+	}
+	for _, tt := range tests {
+		if got := allowsDistrokid(tt.targets); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.targets, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsISRC(t *testing.T) {
+	tests := []struct {
+		targets string
+		want    bool
+	}{
+		{"", true},
+		{"distrokid", false},
+		{"distrokid,jamendo", true},
+		{"bandcamp", true},
+	}
+	for _, tt := range tests {
+		if got := needsISRC(tt.targets); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.targets, got, tt.want)
+		}
+	}
+}
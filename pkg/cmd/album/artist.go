@@ -0,0 +1,172 @@
+package album
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ArtistRoundRobin = "round-robin"
+	ArtistByType     = "type"
+)
+
+// artistPicker chooses the artist of each album from a pool.
+type artistPicker struct {
+	artists  []string
+	strategy string
+	next     int
+}
+
+func newArtistPicker(artist, pool, strategy string, offset int) (*artistPicker, error) {
+	switch strategy {
+	case "":
+		strategy = ArtistRoundRobin
+	case ArtistRoundRobin, ArtistByType:
+	default:
+		return nil, fmt.Errorf("album: invalid artist strategy %q", strategy)
+	}
+	artists, err := parseArtists(artist, pool)
+	if err != nil {
+		return nil, err
+	}
+	if len(artists) == 0 {
+		return nil, fmt.Errorf("album: artist not set")
+	}
+	return &artistPicker{
+		artists:  artists,
+		strategy: strategy,
+		next:     offset,
+	}, nil
+}
+
+// parseArtists returns the artist pool, read from a file with one artist per
+// line or from a comma separated list.
+// If the pool is empty, the single artist is used.
+func parseArtists(artist, pool string) ([]string, error) {
+	if pool == "" {
+		if artist == "" {
+			return nil, nil
+		}
+		return []string{artist}, nil
+	}
+	sep := ","
+	if _, err := os.Stat(pool); err == nil {
+		b, err := os.ReadFile(pool)
+		if err != nil {
+			return nil, fmt.Errorf("album: couldn't read artist pool: %w", err)
+		}
+		pool = string(b)
+		sep = "\n"
+	}
+	var artists []string
+	for _, a := range strings.Split(pool, sep) {
+		a = strings.TrimSpace(a)
+		if a == "" || strings.HasPrefix(a, "#") {
+			continue
+		}
+		artists = append(artists, a)
+	}
+	return artists, nil
+}
+
+// DefaultArtistDisallowed are the characters removed from the artist names,
+// as distribution platforms reject them.
+const DefaultArtistDisallowed = `<>{}[]|\^~*@#$%;`
+
+// sanitizeArtist removes the disallowed characters of the name, collapses
+// the spaces and caps its length, cutting at a word boundary if possible.
+func sanitizeArtist(name string, maxLength int, disallowed string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		if strings.ContainsRune(disallowed, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	if maxLength > 0 && utf8.RuneCountInString(name) > maxLength {
+		runes := []rune(name)
+		cut := string(runes[:maxLength])
+		if runes[maxLength] != ' ' {
+			if i := strings.LastIndex(cut, " "); i > 0 {
+				cut = cut[:i]
+			}
+		}
+		name = strings.TrimSpace(cut)
+	}
+	return name
+}
+
+// artistKey normalizes the name to detect artists that only differ in case,
+// spacing or punctuation.
+func artistKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// adjust sanitizes the artists of the pool and detects the artists that
+// collide with the existing ones or with a previous artist of the pool, as
+// they only differ in case, spacing or punctuation.
+// Collisions are an error unless merge is set, in which case the artist uses
+// the previous spelling, so the catalog doesn't end up with duplicated
+// artists.
+// Adjustments are logged, and artists that end up empty are an error.
+func (p *artistPicker) adjust(maxLength int, disallowed string, existing []string, merge bool) error {
+	spellings := map[string]string{}
+	for _, a := range existing {
+		if k := artistKey(a); k != "" {
+			if _, ok := spellings[k]; !ok {
+				spellings[k] = a
+			}
+		}
+	}
+	var collisions []string
+	for i, original := range p.artists {
+		a := sanitizeArtist(original, maxLength, disallowed)
+		if a == "" {
+			return fmt.Errorf("album: artist %q is empty after sanitization", original)
+		}
+		if a != original {
+			log.Printf("album: artist %q sanitized to %q\n", original, a)
+		}
+		if e, ok := spellings[artistKey(a)]; ok && e != a {
+			if !merge {
+				collisions = append(collisions, fmt.Sprintf("%q with %q", a, e))
+				continue
+			}
+			log.Printf("album: artist %q collides with artist %q, using %q\n", a, e, e)
+			a = e
+		}
+		if _, ok := spellings[artistKey(a)]; !ok {
+			spellings[artistKey(a)] = a
+		}
+		p.artists[i] = a
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("album: artists collide with other spellings (%s), fix the pool or enable merging them", strings.Join(collisions, ", "))
+	}
+	return nil
+}
+
+func (p *artistPicker) pick(typ string) string {
+	if p.strategy == ArtistByType {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(typ))
+		return p.artists[int(h.Sum32()%uint32(len(p.artists)))]
+	}
+	a := p.artists[p.next%len(p.artists)]
+	p.next++
+	return a
+}
@@ -0,0 +1,186 @@
+package album
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/distrokid"
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type GenresConfig struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	Type   string
+}
+
+// RunGenres recomputes the genres of the unpublished albums from the
+// classifications of their songs.
+func RunGenres(ctx context.Context, cfg *GenresConfig) error {
+	log.Printf("album: refresh genres started\n")
+	defer func() {
+		log.Printf("album: refresh genres ended\n")
+	}()
+
+	debug := func(format string, args ...any) {
+		if !cfg.Debug {
+			return
+		}
+		format += "\n"
+		log.Printf(format, args...)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("album: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("album: couldn't start orm store: %w", err)
+	}
+
+	// Published albums are skipped
+	filters := []storage.Filter{
+		storage.Where("state != ?", storage.Used),
+		storage.Where("distrokid_id = ?", ""),
+	}
+	if cfg.Type != "" {
+		filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
+	}
+
+	var total, changed int
+	var currID string
+	for {
+		albums, err := store.ListAlbums(ctx, 1, 100, "id asc", append(filters, storage.Where("id > ?", currID))...)
+		if err != nil {
+			return fmt.Errorf("album: couldn't list albums: %w", err)
+		}
+		if len(albums) == 0 {
+			break
+		}
+		currID = albums[len(albums)-1].ID
+
+		for _, a := range albums {
+			total++
+			songs, err := store.ListSongs(ctx, 1, 1000, "",
+				storage.Where("album_id = ?", a.ID),
+				storage.Where("classification != ?", ""),
+			)
+			if err != nil {
+				return fmt.Errorf("album: couldn't list songs: %w", err)
+			}
+			var analyses []*sonoteller.Analysis
+			for _, s := range songs {
+				var analysis sonoteller.Analysis
+				if err := json.Unmarshal([]byte(s.Classification), &analysis); err != nil {
+					log.Printf("album: couldn't unmarshal classification of song %s: %v\n", s.ID, err)
+					continue
+				}
+				analyses = append(analyses, &analysis)
+			}
+			primary, secondary := classifiedGenres(analyses)
+			if primary == "" {
+				debug("album: no genres found for %s %s", a.ID, a.FullTitle())
+				continue
+			}
+			if primary == a.PrimaryGenre && secondary == a.SecondaryGenre {
+				continue
+			}
+			log.Printf("album: %s %s genres changed from (%s, %s) to (%s, %s)\n", a.ID, a.FullTitle(), a.PrimaryGenre, a.SecondaryGenre, primary, secondary)
+			a.PrimaryGenre = primary
+			a.SecondaryGenre = secondary
+			if err := store.SetAlbum(ctx, a); err != nil {
+				return fmt.Errorf("album: couldn't set album %s: %w", a.ID, err)
+			}
+			changed++
+		}
+	}
+	log.Printf("album: genres changed for %d of %d albums\n", changed, total)
+	return nil
+}
+
+// classifiedGenres returns the two distrokid genres with the highest score
+// summing the genre scores of all the analyses.
+func classifiedGenres(analyses []*sonoteller.Analysis) (string, string) {
+	scores := map[string]int{}
+	for _, a := range analyses {
+		for name, score := range a.Music.Genres {
+			g, ok := distrokidGenre(name)
+			if !ok {
+				continue
+			}
+			scores[g] += score
+		}
+	}
+	var genres []string
+	for g := range scores {
+		genres = append(genres, g)
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if scores[genres[i]] != scores[genres[j]] {
+			return scores[genres[i]] > scores[genres[j]]
+		}
+		return genres[i] < genres[j]
+	})
+	var primary, secondary string
+	if len(genres) > 0 {
+		primary = genres[0]
+	}
+	if len(genres) > 1 {
+		secondary = genres[1]
+	}
+	return primary, secondary
+}
+
+// genreAliases maps classification genres that don't match the distrokid
+// vocabulary by name.
+var genreAliases = map[string]string{
+	"electronic": "Electronic:Electronica / Downtempo",
+	"ambient":    "New Age",
+	"lo fi":      "Electronic:Chill Out",
+	"lofi":       "Electronic:Chill Out",
+	"chill":      "Electronic:Chill Out",
+	"chillout":   "Electronic:Chill Out",
+	"orchestral": "Classical",
+	"cinematic":  "Soundtrack",
+	"gospel":     "Christian/Gospel",
+}
+
+// distrokidGenre returns the distrokid genre matching the name.
+// Genres and subgenres are matched first and then each of the parts of the
+// genres separated by slashes.
+func distrokidGenre(name string) (string, bool) {
+	n := normalizeGenre(name)
+	if n == "" {
+		return "", false
+	}
+	if g, ok := genreAliases[n]; ok {
+		return g, true
+	}
+	for _, g := range distrokid.Genres {
+		split := strings.Split(g, ":")
+		if normalizeGenre(g) == n || normalizeGenre(split[len(split)-1]) == n {
+			return g, true
+		}
+	}
+	for _, g := range distrokid.Genres {
+		split := strings.Split(g, ":")
+		for _, p := range strings.Split(split[len(split)-1], "/") {
+			if normalizeGenre(p) == n {
+				return g, true
+			}
+		}
+	}
+	return "", false
+}
+
+func normalizeGenre(s string) string {
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "-", " ")
+	return strings.Join(strings.Fields(s), " ")
+}
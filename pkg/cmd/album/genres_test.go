@@ -0,0 +1,40 @@
+package album
+
+import (
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+)
+
+func TestDistrokidGenre(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Jazz", "Jazz"},
+		{"hip-hop", "Hip Hop/Rap"},
+		{"Deep House", "Electronic:Deep House"},
+		{"soul", "R&B/Soul"},
+		{"downtempo", "Electronic:Electronica / Downtempo"},
+		{"Lo-Fi", "Electronic:Chill Out"},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		got, _ := distrokidGenre(tt.name)
+		if got != tt.want {
+			t.Errorf("distrokidGenre(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifiedGenres(t *testing.T) {
+	analyses := []*sonoteller.Analysis{
+		{Music: sonoteller.MusicAnalysis{Genres: map[string]int{"jazz": 60, "blues": 30, "unknown": 90}}},
+		{Music: sonoteller.MusicAnalysis{Genres: map[string]int{"jazz": 40, "lofi": 50}}},
+		{Music: sonoteller.MusicAnalysis{Genres: map[string]int{"chill": 20}}},
+	}
+	primary, secondary := classifiedGenres(analyses)
+	if primary != "Jazz" || secondary != "Electronic:Chill Out" {
+		t.Errorf("got (%s, %s); want (Jazz, Electronic:Chill Out)", primary, secondary)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/gocarina/gocsv"
 	"github.com/igolaizola/bulkai/pkg/ai"
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/imageai"
 	"github.com/igolaizola/musikai/pkg/storage"
 	"github.com/oklog/ulid/v2"
@@ -32,6 +33,10 @@ type Config struct {
 	Template    string
 	Input       string
 
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
 	Discord *imageai.Config
 }
 
@@ -92,7 +97,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 	}()
 
-	nErr := 0
+	streak := errstreak.New("background", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -121,15 +126,9 @@ func Run(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("background: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -184,7 +183,7 @@ func generate(ctx context.Context, generator *imageai.Generator, store *storage.
 	// Generate the images.
 	prompt := template
 
-	urls, err := generator.Generate(ctx, prompt)
+	imgs, err := generator.Generate(ctx, prompt)
 	var aiErr ai.Error
 	if errors.As(err, &aiErr) {
 		if aiErr.Fatal() {
@@ -199,13 +198,14 @@ func generate(ctx context.Context, generator *imageai.Generator, store *storage.
 	}
 
 	// Save the generated images to the database.
-	for _, u := range urls {
+	for _, img := range imgs {
 		if err := store.SetCover(ctx, &storage.Cover{
 			ID:       ulid.Make().String(),
 			Type:     typ,
 			Template: template,
-			DsURL:    u[0],
-			MjURL:    u[1],
+			DsURL:    img.DsURL,
+			MjURL:    img.MjURL,
+			Variant:  img.Variant,
 			State:    storage.Approved,
 		}); err != nil {
 			return fmt.Errorf("background: couldn't save image to database: %w", err)
@@ -3,13 +3,17 @@ package classify
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/igolaizola/musikai/pkg/sonoteller"
+	"github.com/igolaizola/musikai/pkg/errstreak"
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/maxruntime"
+	"github.com/igolaizola/musikai/pkg/queue"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -20,9 +24,20 @@ type Config struct {
 	Timeout     time.Duration
 	Concurrency int
 	Limit       int
+	MaxRuntime  time.Duration
 	Proxy       string
+	FSType      string
+	FSConn      string
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
 
 	Type string
+	// Provider is the classification provider: sonoteller, local or auto.
+	// Auto uses sonoteller for songs published on youtube and local for the
+	// rest.
+	Provider string
 }
 
 // Run launches the classification process
@@ -49,14 +64,25 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("classify: couldn't start orm store: %w", err)
 	}
 
-	// Create a sonoteller client
-	sonoClient, err := sonoteller.New(&sonoteller.Config{
-		Wait:  1 * time.Second,
-		Debug: cfg.Debug,
-		Proxy: cfg.Proxy,
-	})
-	if err != nil {
-		return fmt.Errorf("classify: couldn't create sonoteller client: %w", err)
+	// Create the providers
+	var remote, local Provider
+	switch cfg.Provider {
+	case "", "sonoteller", "auto":
+		remote, err = newSonotellerProvider(cfg.Debug, cfg.Proxy)
+		if err != nil {
+			return err
+		}
+	case "local":
+	default:
+		return fmt.Errorf("classify: unknown provider %q", cfg.Provider)
+	}
+	var fs *filestore.Store
+	if cfg.Provider == "local" || cfg.Provider == "auto" {
+		local = &localProvider{}
+		fs, err = filestore.New(cfg.FSType, cfg.FSConn, cfg.Proxy, cfg.Debug, store)
+		if err != nil {
+			return fmt.Errorf("classify: couldn't create file storage: %w", err)
+		}
 	}
 
 	// Print time stats
@@ -66,7 +92,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("classify: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("classify", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -88,6 +114,22 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	maxRuntime, stopMaxRuntime := maxruntime.After(cfg.MaxRuntime)
+	defer stopMaxRuntime()
+
+	// Base filters
+	baseFilters := []storage.Filter{
+		storage.Where("classified = ?", false),
+		storage.Where("state = ?", storage.Used),
+	}
+	if local == nil {
+		// Sonoteller can only analyze songs published on youtube
+		baseFilters = append(baseFilters, storage.Where("youtube_id != ''"))
+	}
+	if cfg.Type != "" {
+		baseFilters = append(baseFilters, storage.Where("type LIKE ?", cfg.Type))
+	}
+
 	var songs []*storage.Song
 	var currID string
 	for {
@@ -96,16 +138,17 @@ func Run(ctx context.Context, cfg *Config) error {
 			return fmt.Errorf("classify: %w", ctx.Err())
 		case <-ticker.C:
 			return nil
-		case err := <-errC:
+		case <-maxRuntime:
+			remaining, err := store.CountSongs(ctx, baseFilters...)
 			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
+				log.Println(err)
 			}
-
+			log.Printf("classify: stopped due to max runtime with %d items remaining\n", remaining)
+			return fmt.Errorf("classify: %w", maxruntime.ErrReached)
+		case err := <-errC:
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("classify: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -118,15 +161,9 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 
 			// Get next song
-			filters := []storage.Filter{
-				storage.Where("classified = ?", false),
-				storage.Where("state = ?", storage.Used),
-				storage.Where("youtube_id != ''"),
+			filters := append([]storage.Filter{
 				storage.Where("songs.id > ?", currID),
-			}
-			if cfg.Type != "" {
-				filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
-			}
+			}, baseFilters...)
 
 			// Get next song
 			if len(songs) == 0 {
@@ -145,7 +182,7 @@ func Run(ctx context.Context, cfg *Config) error {
 			go func() {
 				defer wg.Done()
 				debug("classify: start %s", song.ID)
-				err := classify(ctx, song, debug, store, sonoClient)
+				err := classify(ctx, song, debug, store, fs, remote, local)
 				if err != nil {
 					log.Println(err)
 				}
@@ -171,25 +208,48 @@ func list(ctx context.Context, store *storage.Store, currID string, filters ...s
 		return nil, fmt.Errorf("classify: couldn't get song from database: %w", err)
 	}
 	if len(songs) == 0 {
-		return nil, errors.New("classify: no songs to process")
+		return nil, fmt.Errorf("classify: no songs to process: %w", queue.ErrEmpty)
 	}
 	return songs, nil
 }
 
-func classify(ctx context.Context, song *storage.Song, debug func(string, ...any), store *storage.Store, sonoClient *sonoteller.Client) error {
-	if song.YoutubeID == "" {
+// classify analyzes the song with the remote provider if it is published on
+// youtube, or with the local provider otherwise.
+func classify(ctx context.Context, song *storage.Song, debug func(string, ...any), store *storage.Store, fs *filestore.Store, remote, local Provider) error {
+	var provider Provider
+	var input string
+	switch {
+	case remote != nil && song.YoutubeID != "":
+		provider = remote
+		input = song.YoutubeID
+	case local != nil:
+		if song.GenerationID == nil {
+			return fmt.Errorf("classify: song %s has no generation", song.ID)
+		}
+		genID := *song.GenerationID
+		provider = local
+		input = filepath.Join(os.TempDir(), filestore.MP3(genID))
+		debug("classify: start download %s", genID)
+		if err := fs.GetMP3(ctx, input, genID); err != nil {
+			return fmt.Errorf("classify: couldn't download song %s: %w", song.ID, err)
+		}
+		debug("classify: end download %s", genID)
+		defer func() { _ = os.Remove(input) }()
+	default:
 		return fmt.Errorf("classify: song %s has no youtube id", song.ID)
 	}
-	analysis, err := sonoClient.Analyze(ctx, song.YoutubeID)
+
+	analysis, err := provider.Analyze(ctx, input)
 	if err != nil {
-		return fmt.Errorf("classify: couldn't analyze song %s: %w", song.ID, err)
+		return fmt.Errorf("classify: couldn't analyze song %s with %s: %w", song.ID, provider.Name(), err)
 	}
 	js, err := json.Marshal(analysis)
 	if err != nil {
 		return fmt.Errorf("classify: couldn't marshal analysis %v: %w", analysis, err)
 	}
-	debug("classify: %s", js)
+	debug("classify: %s %s", provider.Name(), js)
 	song.Classification = string(js)
+	song.ClassificationProvider = provider.Name()
 	song.Classified = true
 	if err := store.SetSong(ctx, song); err != nil {
 		return fmt.Errorf("classify: couldn't update song: %w", err)
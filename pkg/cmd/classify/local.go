@@ -0,0 +1,144 @@
+package classify
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/sound"
+	"github.com/igolaizola/musikai/pkg/sound/aubio"
+)
+
+// localProvider classifies songs without any external API, deriving the
+// genres and moods heuristically from the tempo and spectral features of the
+// audio. It is less accurate than sonoteller but works for any song.
+type localProvider struct{}
+
+func (p *localProvider) Name() string {
+	return "local"
+}
+
+// Analyze analyzes the mp3 file at the given path.
+func (p *localProvider) Analyze(ctx context.Context, audioPath string) (*Analysis, error) {
+	tempo, err := aubio.Tempo(ctx, audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("classify: couldn't get tempo: %w", err)
+	}
+	analyzer, err := sound.NewAnalyzer(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("classify: couldn't create analyzer: %w", err)
+	}
+	f := features{
+		Tempo:      tempo,
+		Key:        analyzer.Key(),
+		RMS:        analyzer.Levels().RMS,
+		Brightness: analyzer.Brightness(),
+	}
+	return f.analysis(), nil
+}
+
+// features are the audio features used to classify a song locally.
+type features struct {
+	// Tempo in beats per minute.
+	Tempo float64
+	// Key of the song (e.g. "A minor"), empty if unknown.
+	Key string
+	// RMS level in dBFS.
+	RMS float64
+	// Brightness is the estimated spectral centroid in Hz.
+	Brightness float64
+}
+
+// genrePrototype is the typical tempo, brightness and loudness of a genre.
+type genrePrototype struct {
+	name       string
+	tempo      float64
+	brightness float64
+	rms        float64
+}
+
+var genrePrototypes = []genrePrototype{
+	{"Ambient", 70, 800, -24},
+	{"Classical", 80, 1000, -22},
+	{"Chillout", 90, 1400, -17},
+	{"Downtempo", 95, 1600, -15},
+	{"Lounge", 105, 1800, -16},
+	{"Jazz", 115, 2000, -18},
+	{"Pop", 115, 2600, -10},
+	{"House", 124, 3000, -9},
+	{"Electronic", 128, 2800, -10},
+	{"Rock", 130, 3600, -9},
+	{"Metal", 145, 4500, -8},
+}
+
+// maxGenres is the number of genres returned by the local analysis.
+const maxGenres = 3
+
+// analysis maps the features to sonoteller genres and moods. Scores range
+// from 0 to 100 like the sonoteller ones.
+func (f features) analysis() *Analysis {
+	genres := map[string]int{}
+	type score struct {
+		name  string
+		value float64
+	}
+	var scores []score
+	for _, g := range genrePrototypes {
+		d := math.Pow((f.Tempo-g.tempo)/30, 2) +
+			math.Pow((f.Brightness-g.brightness)/1000, 2) +
+			math.Pow((f.RMS-g.rms)/6, 2)
+		scores = append(scores, score{name: g.name, value: 100 * math.Exp(-d/2)})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].value > scores[j].value
+	})
+	for _, s := range scores[:maxGenres] {
+		genres[s.name] = int(math.Round(s.value))
+	}
+
+	a := &Analysis{}
+	a.Music.BPM = f.Tempo
+	a.Music.Key = f.Key
+	a.Music.Genres = genres
+	a.Music.Moods = f.moods()
+	return a
+}
+
+// moods returns the moods based on the energy, derived from the tempo and
+// loudness, and the valence, derived from the key mode.
+func (f features) moods() map[string]int {
+	energy := (clamp((f.Tempo-60)/100) + clamp((f.RMS+30)/24)) / 2
+	minor := strings.HasSuffix(f.Key, "minor")
+	major := strings.HasSuffix(f.Key, "major")
+
+	var moods []string
+	switch {
+	case energy >= 0.5 && major:
+		moods = []string{"Energetic", "Upbeat", "Uplifting"}
+	case energy >= 0.5 && minor:
+		moods = []string{"Intense", "Driving", "Dark"}
+	case energy >= 0.5:
+		moods = []string{"Energetic", "Dynamic", "Driving"}
+	case major:
+		moods = []string{"Relaxing", "Peaceful", "Dreamy"}
+	case minor:
+		moods = []string{"Melancholic", "Introspective", "Mysterious"}
+	default:
+		moods = []string{"Calming", "Mellow", "Atmospheric"}
+	}
+
+	// The further the energy is from the middle, the more confident the
+	// mood is
+	confidence := 50 + 100*math.Abs(energy-0.5)
+	m := map[string]int{}
+	for i, mood := range moods {
+		m[mood] = int(math.Round(confidence)) - 10*i
+	}
+	return m
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,63 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+)
+
+func TestLocalAnalysis(t *testing.T) {
+	tests := []struct {
+		name     string
+		features features
+		genre    string
+		mood     string
+	}{
+		{"ambient", features{Tempo: 68, Key: "D major", RMS: -25, Brightness: 700}, "Ambient", "Relaxing"},
+		{"jazz", features{Tempo: 112, Key: "", RMS: -19, Brightness: 2100}, "Jazz", "Calming"},
+		{"metal", features{Tempo: 150, Key: "E minor", RMS: -7, Brightness: 4800}, "Metal", "Intense"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.features.analysis()
+			if len(a.Music.Genres) != maxGenres {
+				t.Fatalf("expected %d genres, got %v", maxGenres, a.Music.Genres)
+			}
+			best, score := "", -1
+			for g, s := range a.Music.Genres {
+				if s > score {
+					best, score = g, s
+				}
+			}
+			if best != tt.genre {
+				t.Errorf("expected genre %s, got %s (%v)", tt.genre, best, a.Music.Genres)
+			}
+			if _, ok := a.Music.Moods[tt.mood]; !ok {
+				t.Errorf("expected mood %s, got %v", tt.mood, a.Music.Moods)
+			}
+			// Names must be known by the consumers of the classification
+			for g := range a.Music.Genres {
+				if !contains(sonoteller.Genres, g) {
+					t.Errorf("unknown genre %s", g)
+				}
+			}
+			for m, s := range a.Music.Moods {
+				if !contains(sonoteller.Moods, m) {
+					t.Errorf("unknown mood %s", m)
+				}
+				if s < 0 || s > 100 {
+					t.Errorf("mood %s score out of range: %d", m, s)
+				}
+			}
+		})
+	}
+}
+
+func contains(vs []string, v string) bool {
+	for _, s := range vs {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package classify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+)
+
+// Analysis is the classification of a song. The sonoteller format is used by
+// all the providers so consumers can read it regardless of its origin.
+type Analysis = sonoteller.Analysis
+
+// Provider analyzes a song to obtain its genres, moods and other attributes.
+type Provider interface {
+	// Name is the provider name stored along the classification.
+	Name() string
+	// Analyze analyzes the audio. Depending on the provider the audio path
+	// is a local file or a remote reference, like a youtube id.
+	Analyze(ctx context.Context, audioPath string) (*Analysis, error)
+}
+
+// sonotellerProvider classifies songs published on youtube using sonoteller.
+type sonotellerProvider struct {
+	client *sonoteller.Client
+}
+
+func newSonotellerProvider(debug bool, proxy string) (*sonotellerProvider, error) {
+	client, err := sonoteller.New(&sonoteller.Config{
+		Wait:  1 * time.Second,
+		Debug: debug,
+		Proxy: proxy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("classify: couldn't create sonoteller client: %w", err)
+	}
+	return &sonotellerProvider{client: client}, nil
+}
+
+func (p *sonotellerProvider) Name() string {
+	return "sonoteller"
+}
+
+// Analyze analyzes the song with the given youtube id.
+func (p *sonotellerProvider) Analyze(ctx context.Context, youtubeID string) (*Analysis, error) {
+	return p.client.Analyze(ctx, youtubeID)
+}
@@ -9,13 +9,18 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gocarina/gocsv"
 	"github.com/igolaizola/bulkai/pkg/ai"
+	"github.com/igolaizola/musikai/pkg/errstreak"
+	"github.com/igolaizola/musikai/pkg/filestore"
 	"github.com/igolaizola/musikai/pkg/imageai"
+	"github.com/igolaizola/musikai/pkg/progress"
+	"github.com/igolaizola/musikai/pkg/replicate"
+	"github.com/igolaizola/musikai/pkg/shutdown"
 	"github.com/igolaizola/musikai/pkg/storage"
 	"github.com/oklog/ulid/v2"
 )
@@ -24,15 +29,35 @@ type Config struct {
 	Debug       bool
 	DBType      string
 	DBConn      string
+	FSType      string
+	FSConn      string
 	Timeout     time.Duration
 	Concurrency int
 	WaitMin     time.Duration
 	WaitMax     time.Duration
 	Limit       int
+	MaxRequests int
 	Type        string
 	Template    string
 	Input       string
 	Minimum     int
+	// Styles is the number of dominant song styles and moods used to fill
+	// the {styles} and {moods} template placeholders.
+	Styles int
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	// Provider generates the images, discord (default) or replicate.
+	// Replicate uses the replicate token and the aspect of the discord
+	// config.
+	Provider       string
+	ReplicateModel string
+
+	// ProgressInterval is the time between progress logs.
+	// Zero disables them.
+	ProgressInterval time.Duration
 
 	Discord *imageai.Config
 }
@@ -45,9 +70,15 @@ type input struct {
 // Run launches the image generation process.
 func Run(ctx context.Context, cfg *Config) error {
 	var iteration int
+	var images int64
+	var generator imageGenerator
 	log.Println("cover: process started")
 	defer func() {
-		log.Printf("cover: process ended (%d)\n", iteration)
+		var requests int
+		if generator != nil {
+			requests = generator.Requests()
+		}
+		log.Printf("cover: process ended (%d iterations, %d requests, %d images)\n", iteration, requests, atomic.LoadInt64(&images))
 	}()
 
 	debug := func(format string, args ...interface{}) {
@@ -85,20 +116,50 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("cover: couldn't start orm store: %w", err)
 	}
 
-	generator, err := imageai.New(cfg.Discord, store)
-	if err != nil {
-		return fmt.Errorf("cover: couldn't create discord generator: %w", err)
-	}
-	if err := generator.Start(ctx); err != nil {
-		return fmt.Errorf("cover: couldn't start discord generator: %w", err)
-	}
-	defer func() {
-		if err := generator.Stop(); err != nil {
-			log.Printf("cover: couldn't stop discord generator: %v\n", err)
+	switch cfg.Provider {
+	case "", "discord":
+		discordGenerator, err := imageai.New(cfg.Discord, store)
+		if err != nil {
+			return fmt.Errorf("cover: couldn't create discord generator: %w", err)
 		}
-	}()
+		if err := discordGenerator.Start(ctx); err != nil {
+			return fmt.Errorf("cover: couldn't start discord generator: %w", err)
+		}
+		defer func() {
+			if err := discordGenerator.Stop(); err != nil {
+				log.Printf("cover: couldn't stop discord generator: %v\n", err)
+			}
+		}()
+		generator = discordGenerator
+	case "replicate":
+		// Replicate output URLs expire, so images are stored in the file
+		// storage
+		if cfg.FSType == "" {
+			return errors.New("cover: file storage is required with the replicate provider")
+		}
+		fs, err := filestore.New(cfg.FSType, cfg.FSConn, cfg.Discord.Proxy, cfg.Debug, store)
+		if err != nil {
+			return fmt.Errorf("cover: couldn't create file storage: %w", err)
+		}
+		client, err := replicate.New(&replicate.Config{
+			Token: cfg.Discord.ReplicateToken,
+			Model: cfg.ReplicateModel,
+			Proxy: cfg.Discord.Proxy,
+			Debug: cfg.Debug,
+		})
+		if err != nil {
+			return fmt.Errorf("cover: couldn't create replicate client: %w", err)
+		}
+		aspect := cfg.Discord.Aspect
+		if aspect == "" {
+			aspect = "1:1"
+		}
+		generator = &replicateGenerator{client: client, fs: fs, aspect: aspect}
+	default:
+		return fmt.Errorf("cover: unknown provider %q", cfg.Provider)
+	}
 
-	nErr := 0
+	streak := errstreak.New("cover", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -120,6 +181,36 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	// Base filters
+	baseFilters := []storage.Filter{
+		storage.Where("drafts.state = ?", storage.Approved),
+		storage.Where(`(
+	(
+		drafts.volumes > 0 AND 
+		(select count(*) from covers where covers.state IN (?,?) and covers.draft_id = drafts.id) < drafts.volumes 
+	)
+OR 
+	(
+		drafts.volumes = 0 AND
+		(select count(*) from covers where covers.state = ? and covers.draft_id = drafts.id) < 1
+	)
+)`, storage.Approved, storage.Used, storage.Approved),
+	}
+	if cfg.Type != "" {
+		baseFilters = append(baseFilters, storage.Where("drafts.type LIKE ?", cfg.Type))
+	}
+
+	// Progress of the pending drafts
+	reporter := progress.New("cover", cfg.ProgressInterval, cfg.Limit, func(ctx context.Context) (int64, error) {
+		return remainingIterations(ctx, store, cfg.Minimum, baseFilters)
+	})
+	stopProgress := reporter.Start(ctx)
+	defer func() {
+		// Log the last progress once the running items are done
+		wg.Wait()
+		stopProgress()
+	}()
+
 	var drafts []*storage.Draft
 	var currID string
 	for {
@@ -129,19 +220,21 @@ func Run(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("cover: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if shutdown.Requested(ctx) {
+				log.Printf("cover: shutdown requested, waiting for in-flight work\n")
+				return fmt.Errorf("cover: %w", shutdown.ErrStopped)
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
 			}
+			if cfg.MaxRequests > 0 && generator.Requests() >= cfg.MaxRequests {
+				log.Printf("cover: max requests reached (%d)\n", cfg.MaxRequests)
+				return nil
+			}
 
 			iteration++
 			if time.Since(last) > 60*time.Minute {
@@ -161,24 +254,9 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 
 			// Get next drafts
-			filters := []storage.Filter{
+			filters := append([]storage.Filter{
 				storage.Where("drafts.id > ?", currID),
-				storage.Where("drafts.state = ?", storage.Approved),
-				storage.Where(`(
-	(
-		drafts.volumes > 0 AND 
-		(select count(*) from covers where covers.state IN (?,?) and covers.draft_id = drafts.id) < drafts.volumes 
-	)
-OR 
-	(
-		drafts.volumes = 0 AND
-		(select count(*) from covers where covers.state = ? and covers.draft_id = drafts.id) < 1
-	)
-)`, storage.Approved, storage.Used, storage.Approved),
-			}
-			if cfg.Type != "" {
-				filters = append(filters, storage.Where("drafts.type LIKE ?", cfg.Type))
-			}
+			}, baseFilters...)
 
 			// Get next draft
 			if len(drafts) == 0 {
@@ -209,16 +287,29 @@ OR
 				return fmt.Errorf("cover: couldn't find template for (%s, %s)", draft.Type, draft.Title)
 			}
 
+			// Get the music of the draft only if the template uses it, so
+			// static templates don't query the songs
+			var m music
+			if hasMusicPlaceholders(template) {
+				m, err = draftMusic(ctx, store, draft, cfg.Styles)
+				if err != nil {
+					return err
+				}
+				debug("cover: draft %s styles %v moods %v", draft.ID, m.Styles, m.Moods)
+			}
+
 			// Launch generate in a goroutine
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 				debug("cover: start (%s, %s)", draft.Type, draft.Title)
 
-				err := generate(ctx, generator, store, draft, template)
+				n, err := generate(ctx, generator, store, draft, template, m)
+				atomic.AddInt64(&images, int64(n))
 				if err != nil {
 					log.Println(err)
 				}
+				reporter.Done(err)
 				errC <- err
 				debug("cover: end (%s, %s)", draft.Type, draft.Title)
 			}()
@@ -226,45 +317,77 @@ OR
 	}
 }
 
-func generate(ctx context.Context, generator *imageai.Generator, store *storage.Store, draft *storage.Draft, template string) error {
+// remainingIterations returns the number of iterations needed to generate
+// the covers of the pending drafts, each iteration generates 4 covers.
+func remainingIterations(ctx context.Context, store *storage.Store, minimum int, filters []storage.Filter) (int64, error) {
+	var n int64
+	var currID string
+	for {
+		draftCovers, err := store.ListDraftCovers(ctx, minimum, 1, 100, "drafts.id",
+			append([]storage.Filter{storage.Where("drafts.id > ?", currID)}, filters...)...)
+		if err != nil {
+			return 0, fmt.Errorf("cover: couldn't count drafts: %w", err)
+		}
+		if len(draftCovers) == 0 {
+			return n, nil
+		}
+		currID = draftCovers[len(draftCovers)-1].ID
+		for _, dc := range draftCovers {
+			for i := dc.Covers; i < minimum; i += 4 {
+				n++
+			}
+		}
+	}
+}
+
+func generate(ctx context.Context, generator imageGenerator, store *storage.Store, draft *storage.Draft, template string, m music) (int, error) {
 	// Generate the images.
-	prompt := strings.ReplaceAll(template, "{title}", draft.Title)
-	prompt = strings.ReplaceAll(prompt, "{TITLE}", strings.ToUpper(draft.Title))
+	prompt := toPrompt(template, draft, m)
 
-	urls, err := generator.Generate(ctx, prompt)
+	imgs, err := generator.Generate(ctx, prompt)
 	var aiErr ai.Error
 	if errors.As(err, &aiErr) {
 		if aiErr.Fatal() {
-			return fmt.Errorf("cover: fatal error: %w (%s, %s)", err, draft.ID, prompt)
+			return 0, fmt.Errorf("cover: fatal error: %w (%s, %s)", err, draft.ID, prompt)
 		}
 		if !aiErr.Temporary() {
 			draft.State = storage.Rejected
 			if err := store.SetDraft(ctx, draft); err != nil {
-				return fmt.Errorf("describe: couldn't update draft: %w", err)
+				return 0, fmt.Errorf("describe: couldn't update draft: %w", err)
 			}
 			log.Printf("cover: draft disabled %s\n", draft.ID)
 		}
 	}
 	if err != nil {
-		return fmt.Errorf("cover: couldn't generate images for (%s, %s): %w", draft.ID, prompt, err)
+		return 0, fmt.Errorf("cover: couldn't generate images for (%s, %s): %w", draft.ID, prompt, err)
 	}
 
-	// Save the generated images to the database.
-	for _, u := range urls {
+	// Save the generated images to the database, storing them first if their
+	// URLs expire.
+	storer, stored := generator.(imageStorer)
+	for i, img := range imgs {
+		id := ulid.Make().String()
+		if stored {
+			if err := storer.Store(ctx, img, id); err != nil {
+				return i, err
+			}
+		}
 		if err := store.SetCover(ctx, &storage.Cover{
-			ID:       ulid.Make().String(),
+			ID:       id,
 			Type:     draft.Type,
 			Title:    draft.Title,
 			Template: template,
-			DsURL:    u[0],
-			MjURL:    u[1],
+			DsURL:    img.DsURL,
+			MjURL:    img.MjURL,
+			Variant:  img.Variant,
+			Stored:   stored,
 			DraftID:  draft.ID,
 			State:    storage.Pending,
 		}); err != nil {
-			return fmt.Errorf("cover: couldn't save image to database: %w", err)
+			return i, fmt.Errorf("cover: couldn't save image to database: %w", err)
 		}
 	}
-	return nil
+	return len(imgs), nil
 }
 
 func toTemplateLookup(file string) (map[string]string, error) {
@@ -0,0 +1,72 @@
+package cover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/igolaizola/bulkai/pkg/ai"
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/imageai"
+	"github.com/igolaizola/musikai/pkg/replicate"
+)
+
+// imageGenerator generates the images of a prompt.
+type imageGenerator interface {
+	Generate(ctx context.Context, prompt string) ([]*imageai.Image, error)
+	Requests() int
+}
+
+// imageStorer is implemented by the generators whose image URLs expire, so
+// the images are stored in the file storage before saving the covers.
+type imageStorer interface {
+	Store(ctx context.Context, img *imageai.Image, id string) error
+}
+
+// replicateGenerator generates 4 images of each prompt with a single
+// replicate prediction, the same number of cells of a discord grid.
+type replicateGenerator struct {
+	client   *replicate.Client
+	fs       *filestore.Store
+	aspect   string
+	requests int64
+}
+
+func (g *replicateGenerator) Generate(ctx context.Context, prompt string) ([]*imageai.Image, error) {
+	atomic.AddInt64(&g.requests, 1)
+	urls, err := g.client.Generate(ctx, prompt, 4, g.aspect)
+	if errors.Is(err, replicate.ErrFailed) {
+		// Failed predictions are usually due to the prompt, so the draft is
+		// disabled as with the non temporary discord errors
+		return nil, ai.NewError(err, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var imgs []*imageai.Image
+	for i, u := range urls {
+		imgs = append(imgs, &imageai.Image{Variant: i, DsURL: u})
+	}
+	return imgs, nil
+}
+
+func (g *replicateGenerator) Requests() int {
+	return int(atomic.LoadInt64(&g.requests))
+}
+
+// Store downloads the image and uploads it to the file storage as the PNG of
+// the cover, as replicate output URLs expire after an hour.
+func (g *replicateGenerator) Store(ctx context.Context, img *imageai.Image, id string) error {
+	path := filepath.Join(os.TempDir(), filestore.PNG(id))
+	defer func() { _ = os.Remove(path) }()
+	if err := g.client.Download(ctx, img.DsURL, path); err != nil {
+		return fmt.Errorf("cover: couldn't download image: %w", err)
+	}
+	if err := g.fs.SetPNG(ctx, path, id); err != nil {
+		return fmt.Errorf("cover: couldn't upload image: %w", err)
+	}
+	return nil
+}
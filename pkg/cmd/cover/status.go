@@ -0,0 +1,92 @@
+package cover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type StatusConfig struct {
+	Debug   bool
+	DBType  string
+	DBConn  string
+	Type    string
+	Minimum int
+	Missing bool
+}
+
+// RunStatus reports the number of approved and upscaled covers for each
+// approved draft compared to the minimum.
+func RunStatus(ctx context.Context, cfg *StatusConfig) error {
+	if cfg.Minimum < 1 {
+		return errors.New("cover: minimum is required")
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("cover: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("cover: couldn't start orm store: %w", err)
+	}
+
+	var filters []storage.Filter
+	if cfg.Type != "" {
+		filters = append(filters, storage.Where("drafts.type LIKE ?", cfg.Type))
+	}
+
+	type typeStatus struct {
+		drafts  int
+		missing int
+		covers  int
+	}
+	types := map[string]*typeStatus{}
+
+	page := 1
+	for {
+		drafts, err := store.ListDraftCoverStatus(ctx, page, 100, "drafts.type, drafts.title", filters...)
+		if err != nil {
+			return fmt.Errorf("cover: couldn't list draft covers: %w", err)
+		}
+		for _, d := range drafts {
+			ts, ok := types[d.Type]
+			if !ok {
+				ts = &typeStatus{}
+				types[d.Type] = ts
+			}
+			ts.drafts++
+			ts.covers += d.Covers
+			missing := cfg.Minimum - d.Covers
+			if missing > 0 {
+				ts.missing += missing
+			}
+			if cfg.Missing && missing <= 0 {
+				continue
+			}
+			mark := "✅"
+			if missing > 0 {
+				mark = "❌"
+			}
+			fmt.Printf("%s %s | %s | %d/%d\n", mark, d.Type, d.Title, d.Covers, cfg.Minimum)
+		}
+		if len(drafts) < 100 {
+			break
+		}
+		page++
+	}
+
+	var keys []string
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ts := types[k]
+		log.Printf("cover: type %s: %d drafts, %d covers, %d missing\n", k, ts.drafts, ts.covers, ts.missing)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// music are the dominant styles and moods of the songs of a draft.
+type music struct {
+	Styles []string
+	Moods  []string
+}
+
+// hasMusicPlaceholders returns whether the template uses the placeholders
+// filled with the song classifications.
+func hasMusicPlaceholders(template string) bool {
+	return strings.Contains(template, "{styles}") || strings.Contains(template, "{moods}")
+}
+
+// toPrompt fills the template placeholders with the draft title and the
+// dominant styles and moods of its songs.
+func toPrompt(template string, draft *storage.Draft, m music) string {
+	prompt := strings.ReplaceAll(template, "{title}", draft.Title)
+	prompt = strings.ReplaceAll(prompt, "{TITLE}", strings.ToUpper(draft.Title))
+	prompt = replaceList(prompt, "{styles}", m.Styles)
+	prompt = replaceList(prompt, "{moods}", m.Moods)
+	return prompt
+}
+
+// replaceList replaces the placeholder with the comma separated values.
+// Without values, the placeholder is removed along with its separator, so
+// prompts don't end up with dangling commas.
+func replaceList(prompt, placeholder string, values []string) string {
+	if len(values) > 0 {
+		return strings.ReplaceAll(prompt, placeholder, strings.ToLower(strings.Join(values, ", ")))
+	}
+	for _, sep := range []string{", ", ",", " "} {
+		prompt = strings.ReplaceAll(prompt, sep+placeholder, "")
+		prompt = strings.ReplaceAll(prompt, placeholder+sep, "")
+	}
+	return strings.ReplaceAll(prompt, placeholder, "")
+}
+
+// draftMusic returns the n dominant styles and moods of the classified songs
+// of the album created from the draft. Covers are usually generated before
+// the album exists, so the classified songs of the draft type are used
+// instead. If no song has styles, the draft type is used as the style.
+func draftMusic(ctx context.Context, store *storage.Store, draft *storage.Draft, n int) (music, error) {
+	if n <= 0 {
+		return music{}, nil
+	}
+	filters := []storage.Filter{
+		storage.Where("songs.album_id IN (SELECT id FROM albums WHERE draft_id = ?)", draft.ID),
+		storage.Where("songs.classification != ''"),
+	}
+	songs, err := store.ListSongs(ctx, 1, 100, "", filters...)
+	if err != nil {
+		return music{}, fmt.Errorf("cover: couldn't list songs of draft %s: %w", draft.ID, err)
+	}
+	if len(songs) == 0 {
+		// Only published songs are classified, so songs in other albums
+		// are used too
+		filters := []storage.Filter{
+			storage.Where("songs.type = ?", draft.Type),
+			storage.Where("songs.classification != ''"),
+		}
+		songs, err = store.ListSongs(ctx, 1, 100, "", filters...)
+		if err != nil {
+			return music{}, fmt.Errorf("cover: couldn't list songs of type %s: %w", draft.Type, err)
+		}
+	}
+	var analyses []*sonoteller.Analysis
+	for _, s := range songs {
+		var analysis sonoteller.Analysis
+		if err := json.Unmarshal([]byte(s.Classification), &analysis); err != nil {
+			log.Printf("cover: couldn't unmarshal classification of song %s: %v\n", s.ID, err)
+			continue
+		}
+		analyses = append(analyses, &analysis)
+	}
+	m := dominantMusic(analyses, n)
+	if len(m.Styles) == 0 && draft.Type != "" {
+		m.Styles = []string{draft.Type}
+	}
+	return m, nil
+}
+
+// dominantMusic returns the n styles and moods with the highest score summing
+// the scores of all the analyses.
+func dominantMusic(analyses []*sonoteller.Analysis, n int) music {
+	var styles, moods []map[string]int
+	for _, a := range analyses {
+		// The local classifier only sets genres
+		if len(a.Music.Styles) > 0 {
+			styles = append(styles, a.Music.Styles)
+		} else {
+			styles = append(styles, a.Music.Genres)
+		}
+		moods = append(moods, a.Music.Moods)
+	}
+	return music{
+		Styles: top(styles, n),
+		Moods:  top(moods, n),
+	}
+}
+
+func top(scores []map[string]int, n int) []string {
+	total := map[string]int{}
+	for _, s := range scores {
+		for k, v := range s {
+			total[k] += v
+		}
+	}
+	var keys []string
+	for k := range total {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if total[keys[i]] != total[keys[j]] {
+			return total[keys[i]] > total[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
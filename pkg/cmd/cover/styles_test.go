@@ -0,0 +1,104 @@
+package cover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/sonoteller"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestDraftMusic(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	addSong := func(id, typ, albumID string, styles, moods map[string]int) {
+		t.Helper()
+		var a sonoteller.Analysis
+		a.Music.Styles = styles
+		a.Music.Moods = moods
+		js, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gid := "g" + id
+		if err := store.SetGeneration(ctx, &storage.Generation{ID: gid, SongID: &id}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetSong(ctx, &storage.Song{ID: id, Type: typ, AlbumID: albumID, GenerationID: &gid, Classification: string(js)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		addSong(fmt.Sprintf("free%d", i), "jazz", "", map[string]int{"Smooth Jazz": 90, "Bebop": 40 + i}, map[string]int{"Relaxing": 80})
+	}
+	addSong("other", "rock", "", map[string]int{"Hard Rock": 100}, map[string]int{"Aggressive": 100})
+	addSong("album1", "jazz", "a1", map[string]int{"Big Band": 70, "Swing": 60}, map[string]int{"Upbeat": 90, "Fun": 50})
+	if err := store.SetAlbum(ctx, &storage.Album{ID: "a1", DraftID: "d1", Type: "jazz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The songs of the album created from the draft are used
+	got, err := draftMusic(ctx, store, &storage.Draft{ID: "d1", Type: "jazz"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (music{Styles: []string{"Big Band"}, Moods: []string{"Upbeat"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Without album, the classified songs of the type are used
+	got, err = draftMusic(ctx, store, &storage.Draft{ID: "d2", Type: "jazz"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (music{Styles: []string{"Smooth Jazz", "Bebop", "Big Band"}, Moods: []string{"Relaxing", "Upbeat", "Fun"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	prompt := toPrompt("{TITLE} cover, {styles}, {moods} mood", &storage.Draft{Title: "Blue"}, got)
+	if want := "BLUE cover, smooth jazz, bebop, big band, relaxing, upbeat, fun mood"; prompt != want {
+		t.Errorf("got %q, want %q", prompt, want)
+	}
+
+	// Without classified songs, the draft type is used as the style
+	got, err = draftMusic(ctx, store, &storage.Draft{ID: "d3", Type: "lofi"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (music{Styles: []string{"lofi"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestToPromptEmpty(t *testing.T) {
+	draft := &storage.Draft{Title: "Blue"}
+	tests := []struct {
+		template string
+		m        music
+		want     string
+	}{
+		{"{TITLE} cover, {styles}, {moods} mood", music{}, "BLUE cover mood"},
+		{"{TITLE} cover, {styles}, {moods} mood", music{Moods: []string{"Calm"}}, "BLUE cover, calm mood"},
+		{"{styles}, {title}", music{}, "Blue"},
+		{"inspired by {styles} music with a {moods} feel", music{Styles: []string{"Jazz"}}, "inspired by jazz music with a feel"},
+	}
+	for _, tt := range tests {
+		if got := toPrompt(tt.template, draft, tt.m); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
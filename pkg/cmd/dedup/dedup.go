@@ -0,0 +1,182 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/igolaizola/musikai/pkg/sound"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type Config struct {
+	Debug     bool
+	DBType    string
+	DBConn    string
+	Type      string
+	Threshold float64
+	DryRun    bool
+}
+
+// Run detects songs with near-identical generations within the same type and
+// rejects all of them but the longest one.
+// Songs that are used or belong to an album are never rejected, and they are
+// kept instead of the longest one when they have duplicates.
+func Run(ctx context.Context, cfg *Config) error {
+	threshold := cfg.Threshold
+	if threshold <= 0 || threshold > 1 {
+		return fmt.Errorf("dedup: invalid threshold %f", threshold)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("dedup: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("dedup: couldn't start orm store: %w", err)
+	}
+
+	// Get the selected generations of the songs, grouped by type
+	filters := []storage.Filter{
+		storage.Where("songs.state != ?", storage.Rejected),
+		storage.Where("songs.generation_id = generations.id"),
+		storage.Where("generations.fingerprint != ''"),
+	}
+	if cfg.Type != "" {
+		filters = append(filters, storage.Like("songs.type", cfg.Type))
+	}
+	byType := map[string][]*storage.Generation{}
+	var types []string
+	var currID string
+	for {
+		gens, err := store.ListGenerations(ctx, 1, 100, "generations.id",
+			append(filters, storage.Where("generations.id > ?", currID))...)
+		if err != nil {
+			return fmt.Errorf("dedup: couldn't list generations: %w", err)
+		}
+		if len(gens) == 0 {
+			break
+		}
+		currID = gens[len(gens)-1].ID
+		for _, g := range gens {
+			t := g.Song.Type
+			if _, ok := byType[t]; !ok {
+				types = append(types, t)
+			}
+			byType[t] = append(byType[t], g)
+		}
+	}
+
+	var rejected int
+	for _, t := range types {
+		groups, err := duplicates(byType[t], threshold)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			keep, dups := split(group)
+			for _, g := range dups {
+				log.Printf("dedup: (%s) song %s (%.0fs) duplicates %s (%.0fs)\n", t, g.Song.ID, g.Duration, keep.Song.ID, keep.Duration)
+				rejected++
+				if cfg.DryRun {
+					continue
+				}
+				song := g.Song
+				song.State = storage.Rejected
+				song.Likes = 0
+				if err := store.SetSong(ctx, song); err != nil {
+					return fmt.Errorf("dedup: couldn't reject song %s: %w", song.ID, err)
+				}
+			}
+		}
+	}
+	if cfg.DryRun {
+		log.Printf("dedup: %d songs would be rejected\n", rejected)
+	} else {
+		log.Printf("dedup: %d songs rejected\n", rejected)
+	}
+	return nil
+}
+
+// protected returns whether the song can't be rejected because it is used or
+// belongs to an album.
+func protected(song *storage.Song) bool {
+	return song.State == storage.Used || song.AlbumID != ""
+}
+
+// split returns the generation to keep of a group of duplicates and the ones
+// to reject. The first protected song is kept if there is any, otherwise the
+// longest one, and protected songs are never rejected.
+func split(group []*storage.Generation) (*storage.Generation, []*storage.Generation) {
+	keep := group[0]
+	for _, g := range group {
+		if protected(g.Song) {
+			keep = g
+			break
+		}
+	}
+	var reject []*storage.Generation
+	for _, g := range group {
+		if g == keep || protected(g.Song) {
+			continue
+		}
+		reject = append(reject, g)
+	}
+	return keep, reject
+}
+
+// duplicates groups the generations whose fingerprints have a similarity
+// greater or equal than the threshold. Only groups with more than one
+// generation are returned, and the longest generation is the first of each
+// group.
+func duplicates(gens []*storage.Generation, threshold float64) ([][]*storage.Generation, error) {
+	// Union-find to group transitive matches
+	parent := make([]int, len(gens))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := 0; i < len(gens); i++ {
+		for j := i + 1; j < len(gens); j++ {
+			sim, err := sound.Similarity(gens[i].Fingerprint, gens[j].Fingerprint)
+			if err != nil {
+				return nil, fmt.Errorf("dedup: %w", err)
+			}
+			if sim >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	lookup := map[int][]*storage.Generation{}
+	var roots []int
+	for i, g := range gens {
+		r := find(i)
+		if _, ok := lookup[r]; !ok {
+			roots = append(roots, r)
+		}
+		lookup[r] = append(lookup[r], g)
+	}
+	var groups [][]*storage.Generation
+	for _, r := range roots {
+		group := lookup[r]
+		if len(group) < 2 {
+			continue
+		}
+		longest := 0
+		for i, g := range group {
+			if g.Duration > group[longest].Duration {
+				longest = i
+			}
+		}
+		group[0], group[longest] = group[longest], group[0]
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
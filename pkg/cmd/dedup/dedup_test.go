@@ -0,0 +1,54 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestSplit(t *testing.T) {
+	gen := func(id string, state storage.State, albumID string) *storage.Generation {
+		return &storage.Generation{ID: id, Song: &storage.Song{ID: id, State: state, AlbumID: albumID}}
+	}
+	ids := func(gens []*storage.Generation) []string {
+		var ids []string
+		for _, g := range gens {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name   string
+		group  []*storage.Generation
+		keep   string
+		reject []string
+	}{
+		{
+			"longest",
+			[]*storage.Generation{gen("a", storage.Pending, ""), gen("b", storage.Approved, "")},
+			"a", []string{"b"},
+		},
+		{
+			"used",
+			[]*storage.Generation{gen("a", storage.Pending, ""), gen("b", storage.Used, "album")},
+			"b", []string{"a"},
+		},
+		{
+			"in album",
+			[]*storage.Generation{gen("a", storage.Approved, ""), gen("b", storage.Approved, "album"), gen("c", storage.Used, "other")},
+			"b", []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, reject := split(tt.group)
+			if keep.ID != tt.keep {
+				t.Errorf("keep: got %s, want %s", keep.ID, tt.keep)
+			}
+			if got := ids(reject); strings.Join(got, ",") != strings.Join(tt.reject, ",") {
+				t.Errorf("reject: got %v, want %v", got, tt.reject)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/openai"
 	"github.com/igolaizola/musikai/pkg/sonoteller"
 	"github.com/igolaizola/musikai/pkg/storage"
@@ -26,6 +27,10 @@ type Config struct {
 	Limit       int
 	Proxy       string
 
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
 	Type  string
 	Key   string
 	Model string
@@ -84,7 +89,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("describe: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("describe", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -115,15 +120,9 @@ func Run(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("describe: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
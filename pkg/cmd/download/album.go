@@ -9,10 +9,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -31,6 +35,9 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 		log.Printf(format, args...)
 	}
 
+	if err := cfg.validate(); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
 		return fmt.Errorf("download: couldn't create output directory: %w", err)
 	}
@@ -68,7 +75,7 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 		log.Printf("download: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("download", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -90,7 +97,18 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	albumLookup := map[string]string{}
+	// Files already downloaded by previous runs are skipped
+	var downloaded, skipped int64
+	defer func() {
+		wg.Wait()
+		log.Printf("download: %d songs downloaded, %d skipped\n", atomic.LoadInt64(&downloaded), atomic.LoadInt64(&skipped))
+	}()
+
+	type albumInfo struct {
+		album *storage.Album
+		dir   string
+	}
+	albumLookup := map[string]albumInfo{}
 	var lck sync.Mutex
 
 	var currID string
@@ -102,15 +120,9 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("download: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -158,20 +170,28 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 				debug("download: start %s", song.ID)
 
 				lck.Lock()
-				albumDir, ok := albumLookup[song.AlbumID]
+				info, ok := albumLookup[song.AlbumID]
 				if !ok {
-					albumDir, err = downloadCover(ctx, song.AlbumID, debug, store, fs, cfg.Output)
+					album, albumDir, err := downloadCover(ctx, cfg, song.AlbumID, debug, store, fs)
 					if err != nil {
+						lck.Unlock()
 						log.Println(err)
 						errC <- err
 						return
 					}
-					albumLookup[song.AlbumID] = albumDir
+					info = albumInfo{album: album, dir: albumDir}
+					albumLookup[song.AlbumID] = info
 				}
 				lck.Unlock()
 
-				if err := downloadSong(ctx, song, debug, fs, albumDir); err != nil {
+				ok, err := downloadSong(ctx, cfg, song, info.album, debug, fs, info.dir)
+				switch {
+				case err != nil:
 					log.Println(err)
+				case ok:
+					atomic.AddInt64(&downloaded, 1)
+				default:
+					atomic.AddInt64(&skipped, 1)
 				}
 				debug("download: end %s", song.ID)
 				errC <- err
@@ -180,45 +200,100 @@ func RunAlbum(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func downloadCover(ctx context.Context, albumID string, debug func(string, ...any), store *storage.Store, fs *filestore.Store, output string) (string, error) {
+func downloadCover(ctx context.Context, cfg *Config, albumID string, debug func(string, ...any), store *storage.Store, fs *filestore.Store) (*storage.Album, string, error) {
 	album, err := store.GetAlbum(ctx, albumID)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 
 	name := album.FullTitle()
 	name += " - " + album.Artist
 
-	albumDir := filepath.Join(output, name)
+	albumDir := filepath.Join(cfg.Output, name)
 
 	// Download the cover
 	if err := os.MkdirAll(albumDir, 0755); err != nil {
-		return "", fmt.Errorf("download: couldn't create output directory: %w", err)
+		return nil, "", fmt.Errorf("download: couldn't create output directory: %w", err)
 	}
 	file := filestore.JPG(album.ID)
 	cover := filepath.Join(albumDir, file)
-	if _, err := os.Stat(cover); err != nil {
+	if cfg.Force || !completed(cover) {
 		debug("download: start download cover %s", album.ID)
-		if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
-			return "", fmt.Errorf("download: couldn't download master audio: %w", err)
+		if err := atomicDownload(cover, func(part string) error {
+			return fs.GetJPG(ctx, part, album.ID)
+		}); err != nil {
+			return nil, "", fmt.Errorf("download: couldn't download cover: %w", err)
 		}
-		debug("download: end download master %s", album.ID)
+		debug("download: end download cover %s", album.ID)
 	}
-	return albumDir, nil
+	return album, albumDir, nil
 }
 
-func downloadSong(ctx context.Context, song *storage.Song, debug func(string, ...any), fs *filestore.Store, output string) error {
+// downloadSong downloads the song audio with its tags into the album
+// directory. It returns false if the song was already downloaded.
+func downloadSong(ctx context.Context, cfg *Config, song *storage.Song, album *storage.Album, debug func(string, ...any), fs *filestore.Store, output string) (bool, error) {
 	name := fmt.Sprintf("%02d - %s", song.Order, song.Title)
 
 	// Download the mastered audio
-	mastered := filepath.Join(output, fmt.Sprintf("%s.mp3", name))
-	if _, err := os.Stat(mastered); err != nil {
+	var stored string
+	if song.Generation != nil {
+		stored = song.Generation.Format
+	}
+	mastered := filepath.Join(output, name+cfg.audioExt(stored))
+	if !cfg.Force && completed(mastered) {
+		debug("download: skip %s, already downloaded", mastered)
+		return false, nil
+	}
+
+	year := album.PublishedAt.Year()
+	if album.PublishedAt.IsZero() {
+		year = album.CreatedAt.Year()
+	}
+	if err := atomicDownload(mastered, func(part string) error {
 		debug("download: start download master %s", song.GenerationID)
-		if err := fs.GetMP3(ctx, mastered, *song.GenerationID); err != nil {
-			return fmt.Errorf("download: couldn't download master audio: %w", err)
+		if err := getAudio(ctx, cfg, fs, part, *song.GenerationID, stored); err != nil {
+			return err
 		}
 		debug("download: end download master %s", song.GenerationID)
+		return writeTags(ctx, cfg, part, ffmpeg.Tags{
+			Title:  song.Title,
+			Artist: album.Artist,
+			Album:  album.FullTitle(),
+			Track:  song.Order,
+			Year:   year,
+			Genre:  strings.Split(album.PrimaryGenre, ":")[0],
+			Cover:  filepath.Join(output, filestore.JPG(album.ID)),
+		})
+	}); err != nil {
+		return false, err
 	}
+	return true, nil
+}
+
+// completed returns whether the file exists and isn't empty.
+func completed(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() > 0
+}
 
+// atomicDownload downloads to a partial file next to the path (e.g.
+// "song.part.mp3", keeping the extension that is used to choose the format)
+// and renames it once it is complete, so interrupted downloads aren't taken
+// as completed by the next run.
+func atomicDownload(path string, download func(part string) error) error {
+	ext := filepath.Ext(path)
+	part := strings.TrimSuffix(path, ext) + ".part" + ext
+	_ = os.Remove(part)
+	if err := download(part); err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	if !completed(part) {
+		_ = os.Remove(part)
+		return fmt.Errorf("download: empty file %s", path)
+	}
+	if err := os.Rename(part, path); err != nil {
+		return fmt.Errorf("download: couldn't rename %s: %w", part, err)
+	}
 	return nil
 }
@@ -0,0 +1,74 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestDownloadSongResume(t *testing.T) {
+	ctx := context.Background()
+	remote := t.TempDir()
+	output := t.TempDir()
+	fs, err := filestore.New("local", remote, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "g1.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{NoTags: true}
+	gid := "g1"
+	song := &storage.Song{ID: "s1", Title: "Intro", Order: 1, GenerationID: &gid}
+	album := &storage.Album{ID: "a1"}
+	debug := func(string, ...any) {}
+	path := filepath.Join(output, "01 - Intro.mp3")
+
+	// An empty file left by an interrupted run is downloaded again
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := downloadSong(ctx, cfg, song, album, debug, fs, output)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v; want true, nil", ok, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "audio" {
+		t.Fatalf("got %q", b)
+	}
+
+	// Completed files are skipped
+	if err := os.WriteFile(filepath.Join(remote, "g1.mp3"), []byte("updated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ok, err = downloadSong(ctx, cfg, song, album, debug, fs, output)
+	if err != nil || ok {
+		t.Fatalf("got %v, %v; want false, nil", ok, err)
+	}
+
+	// Force downloads them again
+	cfg.Force = true
+	ok, err = downloadSong(ctx, cfg, song, album, debug, fs, output)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v; want true, nil", ok, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "updated" {
+		t.Errorf("got %q", b)
+	}
+
+	// Failed downloads don't leave partial files
+	gid = "missing"
+	if _, err := downloadSong(ctx, cfg, song, album, debug, fs, output); err == nil {
+		t.Fatal("expected error")
+	}
+	entries, err := os.ReadDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the downloaded file, got %d entries", len(entries))
+	}
+}
@@ -9,10 +9,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -27,9 +31,37 @@ type Config struct {
 	Limit       int
 	Proxy       string
 
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
 	Output string
 
 	Type string
+
+	// Audio format parameters
+	Format     string
+	SampleRate int
+	BitDepth   int
+	Bitrate    string
+
+	// NoTags disables writing ID3 tags to the downloaded mp3 files
+	NoTags bool
+
+	// Force downloads the album files again even if they already exist.
+	Force bool
+}
+
+// audioExt returns the extension of the downloaded audio files given the
+// lossless format stored for the generation, if any.
+func (c *Config) audioExt(stored string) string {
+	if c.Format != "" {
+		return "." + c.Format
+	}
+	if stored != "" {
+		return "." + stored
+	}
+	return ".mp3"
 }
 
 // Run launches the gen generation process.
@@ -48,6 +80,9 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf(format, args...)
 	}
 
+	if err := cfg.validate(); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
 		return fmt.Errorf("download: couldn't create output directory: %w", err)
 	}
@@ -85,7 +120,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("download: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("download", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -113,9 +148,14 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("download: couldn't read output directory: %w", err)
 	}
 	var currID string
+	exts := []string{cfg.audioExt("")}
+	if cfg.Format == "" {
+		exts = append(exts, ".flac", ".wav")
+	}
 	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".mp3" {
-			currID = file.Name()[:len(file.Name())-4]
+		ext := filepath.Ext(file.Name())
+		if slices.Contains(exts, ext) {
+			currID = strings.TrimSuffix(file.Name(), ext)
 		}
 	}
 
@@ -127,15 +167,9 @@ func Run(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("download: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -178,7 +212,7 @@ func Run(ctx context.Context, cfg *Config) error {
 				defer wg.Done()
 				debug("download: start %s", gen.ID)
 
-				if err := download(ctx, gen, debug, fs, cfg.Output); err != nil {
+				if err := download(ctx, cfg, gen, debug, fs); err != nil {
 					log.Println(err)
 				}
 				debug("download: end %s", gen.ID)
@@ -188,18 +222,42 @@ func Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func download(ctx context.Context, gen *storage.Generation, debug func(string, ...any), fs *filestore.Store, output string) error {
+func (c *Config) validate() error {
+	switch c.Format {
+	case "", "mp3", "wav", "flac":
+	default:
+		return fmt.Errorf("download: unsupported format %s", c.Format)
+	}
+	return nil
+}
+
+func download(ctx context.Context, cfg *Config, gen *storage.Generation, debug func(string, ...any), fs *filestore.Store) error {
+	output := cfg.Output
+
 	// Download the mastered audio
-	name := filestore.MP3(gen.ID)
-	mastered := filepath.Join(output, name)
+	mastered := filepath.Join(output, gen.ID+cfg.audioExt(gen.Format))
 	if _, err := os.Stat(mastered); err != nil {
 		debug("download: start download master %s", gen.ID)
-		if err := fs.GetMP3(ctx, mastered, gen.ID); err != nil {
-			return fmt.Errorf("download: couldn't download master audio: %w", err)
+		if err := getAudio(ctx, cfg, fs, mastered, gen.ID, gen.Format); err != nil {
+			return err
 		}
 		debug("download: end download master %s", gen.ID)
+
+		tags := ffmpeg.Tags{
+			Title: gen.Title,
+			Year:  gen.CreatedAt.Year(),
+		}
+		if gen.Song != nil {
+			if gen.Song.Title != "" {
+				tags.Title = gen.Song.Title
+			}
+			tags.Track = gen.Song.Order
+		}
+		if err := writeTags(ctx, cfg, mastered, tags); err != nil {
+			return err
+		}
 	}
-	name = filestore.JPG(gen.ID)
+	name := filestore.JPG(gen.ID)
 	wave := filepath.Join(output, name)
 	if _, err := os.Stat(wave); err != nil {
 		debug("download: start download wave %s", gen.ID)
@@ -210,3 +268,37 @@ func download(ctx context.Context, gen *storage.Generation, debug func(string, .
 	}
 	return nil
 }
+
+// writeTags writes the ID3 tags if the output is an mp3 file and tags are
+// enabled.
+func writeTags(ctx context.Context, cfg *Config, path string, t ffmpeg.Tags) error {
+	if cfg.NoTags || filepath.Ext(path) != ".mp3" {
+		return nil
+	}
+	if err := ffmpeg.WriteTags(ctx, path, t); err != nil {
+		return fmt.Errorf("download: couldn't write tags: %w", err)
+	}
+	return nil
+}
+
+// getAudio downloads the stored audio and transcodes it if a format is set.
+// The lossless audio is used if it was stored, otherwise the mp3 is used.
+func getAudio(ctx context.Context, cfg *Config, fs *filestore.Store, output, id, stored string) error {
+	if cfg.Format == "" {
+		if err := fs.GetAudio(ctx, output, id, stored); err != nil {
+			return fmt.Errorf("download: couldn't download master audio: %w", err)
+		}
+		return nil
+	}
+
+	// Download to a temporary file and transcode it to the output
+	tmp := filepath.Join(os.TempDir(), filestore.Audio(id, stored))
+	if err := fs.GetAudio(ctx, tmp, id, stored); err != nil {
+		return fmt.Errorf("download: couldn't download master audio: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp) }()
+	if err := ffmpeg.Transcode(ctx, tmp, output, cfg.SampleRate, cfg.BitDepth, cfg.Bitrate); err != nil {
+		return fmt.Errorf("download: couldn't transcode master audio: %w", err)
+	}
+	return nil
+}
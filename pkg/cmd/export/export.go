@@ -0,0 +1,207 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// Version is the version of the export format.
+const Version = 1
+
+// tables are the exported tables in the order they are written.
+// The import follows the same order.
+var tables = []string{"titles", "drafts", "covers", "albums", "songs", "generations"}
+
+type Config struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	Output string
+	Type   string
+}
+
+// Run exports the catalog to a JSON file.
+// Items are written as they are read from the database so the whole catalog
+// isn't held in memory.
+func Run(ctx context.Context, cfg *Config) error {
+	counts := map[string]int{}
+	log.Println("export: started")
+	defer func() {
+		log.Printf("export: ended %v\n", counts)
+	}()
+
+	if cfg.Output == "" {
+		return fmt.Errorf("export: output is required")
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("export: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("export: couldn't start orm store: %w", err)
+	}
+
+	f, err := os.Create(cfg.Output)
+	if err != nil {
+		return fmt.Errorf("export: couldn't create output file: %w", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	if _, err := fmt.Fprintf(w, "{\"version\":%d", Version); err != nil {
+		return fmt.Errorf("export: couldn't write output: %w", err)
+	}
+	for _, table := range tables {
+		if _, err := fmt.Fprintf(w, ",\n%q:[", table); err != nil {
+			return fmt.Errorf("export: couldn't write output: %w", err)
+		}
+		n, err := exportTable(ctx, store, w, table, cfg.Type)
+		if err != nil {
+			return err
+		}
+		counts[table] = n
+		if _, err := w.WriteString("]"); err != nil {
+			return fmt.Errorf("export: couldn't write output: %w", err)
+		}
+	}
+	if _, err := w.WriteString("}\n"); err != nil {
+		return fmt.Errorf("export: couldn't write output: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("export: couldn't flush output: %w", err)
+	}
+	return nil
+}
+
+// exportTable writes the items of a table paginating by ID.
+func exportTable(ctx context.Context, store *storage.Store, w *bufio.Writer, table, typ string) (int, error) {
+	var n int
+	var currID string
+	for {
+		if ctx.Err() != nil {
+			return n, fmt.Errorf("export: %w", ctx.Err())
+		}
+		items, lastID, err := list(ctx, store, table, typ, currID)
+		if err != nil {
+			return n, fmt.Errorf("export: couldn't list %s: %w", table, err)
+		}
+		if len(items) == 0 {
+			return n, nil
+		}
+		currID = lastID
+		for _, item := range items {
+			js, err := json.Marshal(item)
+			if err != nil {
+				return n, fmt.Errorf("export: couldn't marshal %s: %w", table, err)
+			}
+			if n > 0 {
+				if _, err := w.WriteString(","); err != nil {
+					return n, fmt.Errorf("export: couldn't write output: %w", err)
+				}
+			}
+			if _, err := w.WriteString("\n"); err != nil {
+				return n, fmt.Errorf("export: couldn't write output: %w", err)
+			}
+			if _, err := w.Write(js); err != nil {
+				return n, fmt.Errorf("export: couldn't write output: %w", err)
+			}
+			n++
+		}
+	}
+}
+
+// list returns the next page of items of a table, including rejected ones,
+// and the ID of the last item.
+func list(ctx context.Context, store *storage.Store, table, typ, currID string) ([]any, string, error) {
+	const size = 100
+	var items []any
+	var lastID string
+	switch table {
+	case "titles":
+		filters := typeFilters("titles", typ, currID)
+		vs, err := store.ListAllTitles(ctx, 1, size, "titles.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	case "drafts":
+		filters := typeFilters("drafts", typ, currID)
+		vs, err := store.ListAllDrafts(ctx, 1, size, "drafts.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	case "covers":
+		filters := typeFilters("covers", typ, currID)
+		vs, err := store.ListAllCovers(ctx, 1, size, "covers.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	case "albums":
+		filters := typeFilters("albums", typ, currID)
+		vs, err := store.ListAllAlbums(ctx, 1, size, "albums.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	case "songs":
+		filters := typeFilters("songs", typ, currID)
+		// Songs without a generation are exported too
+		vs, err := store.ListSongRows(ctx, 1, size, "songs.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	case "generations":
+		filters := []storage.Filter{
+			storage.Where("generations.id > ?", currID),
+		}
+		if typ != "" {
+			filters = append(filters, storage.Where("songs.type LIKE ?", typ))
+		}
+		vs, err := store.ListGenerationRows(ctx, 1, size, "generations.id asc", filters...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, v := range vs {
+			items = append(items, v)
+			lastID = v.ID
+		}
+	default:
+		return nil, "", fmt.Errorf("unknown table %s", table)
+	}
+	return items, lastID, nil
+}
+
+func typeFilters(table, typ, currID string) []storage.Filter {
+	filters := []storage.Filter{
+		storage.Where(fmt.Sprintf("%s.id > ?", table), currID),
+	}
+	if typ != "" {
+		filters = append(filters, storage.Where(fmt.Sprintf("%s.type LIKE ?", table), typ))
+	}
+	return filters
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestRoundtrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	newStore := func(name string) (*storage.Store, string) {
+		t.Helper()
+		conn := filepath.Join(dir, name)
+		store, err := storage.New("sqlite", conn, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Migrate(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return store, conn
+	}
+	src, srcConn := newStore("src.db")
+
+	songID, genID := "s1", "g1"
+	if err := src.SetTitle(ctx, &storage.Title{ID: "t1", Type: "jazz", Title: "Blue"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetDraft(ctx, &storage.Draft{ID: "d1", Type: "jazz", Title: "Blue"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetCover(ctx, &storage.Cover{ID: "c1", Type: "jazz", Title: "Blue"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetAlbum(ctx, &storage.Album{ID: "a1", Type: "jazz", Title: "Blue"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetSong(ctx, &storage.Song{ID: songID, Type: "jazz", AlbumID: "a1", State: storage.Rejected}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetGeneration(ctx, &storage.Generation{ID: genID, SongID: &songID, Title: "take 1"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := src.GetSong(ctx, songID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GenerationID = &genID
+	if err := src.SetSong(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	// A song without generation, which must be exported too
+	if err := src.SetSong(ctx, &storage.Song{ID: "s2", Type: "jazz", Title: "Pending"}); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "export.json")
+	if err := Run(ctx, &Config{DBType: "sqlite", DBConn: srcConn, Output: output}); err != nil {
+		t.Fatal(err)
+	}
+	dst, dstConn := newStore("dst.db")
+	if err := Import(ctx, &ImportConfig{DBType: "sqlite", DBConn: dstConn, Input: output}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := dst.GetTitle(ctx, "t1"); err != nil || v.Title != "Blue" {
+		t.Errorf("title: got %+v, %v", v, err)
+	}
+	if v, err := dst.GetDraft(ctx, "d1"); err != nil || v.Title != "Blue" {
+		t.Errorf("draft: got %+v, %v", v, err)
+	}
+	if v, err := dst.GetCover(ctx, "c1"); err != nil || v.Title != "Blue" {
+		t.Errorf("cover: got %+v, %v", v, err)
+	}
+	if v, err := dst.GetAlbum(ctx, "a1"); err != nil || v.Title != "Blue" {
+		t.Errorf("album: got %+v, %v", v, err)
+	}
+	s, err = dst.GetSong(ctx, songID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AlbumID != "a1" || s.State != storage.Rejected || s.GenerationID == nil || *s.GenerationID != genID {
+		t.Errorf("song: got %+v", s)
+	}
+	g, err := dst.GetGeneration(ctx, genID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.SongID == nil || *g.SongID != songID || g.Title != "take 1" {
+		t.Errorf("generation: got %+v", g)
+	}
+	if s, err := dst.GetSong(ctx, "s2"); err != nil || s.Title != "Pending" || s.GenerationID != nil {
+		t.Errorf("song without generation: got %+v, %v", s, err)
+	}
+}
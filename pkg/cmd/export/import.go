@@ -0,0 +1,246 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type ImportConfig struct {
+	Debug     bool
+	DBType    string
+	DBConn    string
+	Input     string
+	Overwrite bool
+	// IDStrategy is used to create the IDs of the items without one
+	// (ulid, nanoid or external to require all the items to have an ID).
+	IDStrategy string
+}
+
+// Import imports a catalog exported with Run.
+// Items whose IDs already exist are skipped unless overwrite is set.
+// Items are read one by one so the whole catalog isn't held in memory.
+func Import(ctx context.Context, cfg *ImportConfig) error {
+	counts := map[string]int{}
+	log.Println("import: started")
+	defer func() {
+		log.Printf("import: ended %v\n", counts)
+	}()
+
+	ids, err := storage.NewIDGenerator(cfg.IDStrategy)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("import: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("import: couldn't start orm store: %w", err)
+	}
+
+	f, err := os.Open(cfg.Input)
+	if err != nil {
+		return fmt.Errorf("import: couldn't open input file: %w", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	// Songs reference generations and generations reference songs, so the
+	// song generation IDs are set once the generations are imported.
+	songGens := map[string]string{}
+
+	var version int
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("import: couldn't read key: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("import: unexpected token %v", tok)
+		}
+		if key == "version" {
+			if err := dec.Decode(&version); err != nil {
+				return fmt.Errorf("import: couldn't decode version: %w", err)
+			}
+			if version != Version {
+				return fmt.Errorf("import: unsupported version %d", version)
+			}
+			continue
+		}
+		if version == 0 {
+			return errors.New("import: version must be the first field")
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			if ctx.Err() != nil {
+				return fmt.Errorf("import: %w", ctx.Err())
+			}
+			ok, err := importItem(ctx, store, dec, key, cfg.Overwrite, ids, songGens)
+			if err != nil {
+				return err
+			}
+			if ok {
+				counts[key]++
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// Link songs with their generations
+	for songID, genID := range songGens {
+		song, err := store.GetSong(ctx, songID)
+		if err != nil {
+			return fmt.Errorf("import: couldn't get song %s: %w", songID, err)
+		}
+		genID := genID
+		song.GenerationID = &genID
+		song.Generation = nil
+		if err := store.SetSong(ctx, song); err != nil {
+			return fmt.Errorf("import: couldn't set song %s: %w", songID, err)
+		}
+	}
+	return nil
+}
+
+// importItem decodes the next item of the table and stores it.
+// It returns false if the item was skipped because it already exists.
+func importItem(ctx context.Context, store *storage.Store, dec *json.Decoder, table string, overwrite bool, ids *storage.IDGenerator, songGens map[string]string) (bool, error) {
+	var err error
+	var exists bool
+	var set func() error
+	switch table {
+	case "titles":
+		var v storage.Title
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode title: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetTitle(ctx, v.ID)
+		set = func() error { return store.SetTitle(ctx, &v) }
+	case "drafts":
+		var v storage.Draft
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode draft: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetDraft(ctx, v.ID)
+		set = func() error { return store.SetDraft(ctx, &v) }
+	case "covers":
+		var v storage.Cover
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode cover: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetCover(ctx, v.ID)
+		set = func() error { return store.SetCover(ctx, &v) }
+	case "albums":
+		var v storage.Album
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode album: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetAlbum(ctx, v.ID)
+		set = func() error { return store.SetAlbum(ctx, &v) }
+	case "songs":
+		var v storage.Song
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode song: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetSong(ctx, v.ID)
+		set = func() error {
+			if v.GenerationID != nil {
+				songGens[v.ID] = *v.GenerationID
+			}
+			v.GenerationID = nil
+			v.Generation = nil
+			return store.SetSong(ctx, &v)
+		}
+	case "generations":
+		var v storage.Generation
+		if err := dec.Decode(&v); err != nil {
+			return false, fmt.Errorf("import: couldn't decode generation: %w", err)
+		}
+		if err := ensureID(&v.ID, ids); err != nil {
+			return false, err
+		}
+		_, err = store.GetGeneration(ctx, v.ID)
+		set = func() error {
+			v.Song = nil
+			return store.SetGeneration(ctx, &v)
+		}
+	default:
+		return false, fmt.Errorf("import: unknown table %s", table)
+	}
+	switch {
+	case err == nil:
+		exists = true
+	case errors.Is(err, storage.ErrNotFound):
+	default:
+		return false, fmt.Errorf("import: couldn't check %s: %w", table, err)
+	}
+	if exists && !overwrite {
+		return false, nil
+	}
+	if err := set(); err != nil {
+		return false, fmt.Errorf("import: %w", err)
+	}
+	return true, nil
+}
+
+// ensureID creates the ID if it is empty or validates it otherwise.
+func ensureID(id *string, ids *storage.IDGenerator) error {
+	if *id != "" {
+		if err := storage.ValidateID(*id); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		return nil
+	}
+	v, err := ids.NewID("")
+	if err != nil {
+		return fmt.Errorf("import: couldn't create id: %w", err)
+	}
+	*id = v
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("import: couldn't read %s: %w", delim, err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("import: expected %s, got %v", delim, tok)
+	}
+	return nil
+}
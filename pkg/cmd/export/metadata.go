@@ -0,0 +1,344 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type MetadataConfig struct {
+	Debug    bool
+	DBType   string
+	DBConn   string
+	Output   string
+	Format   string
+	Template string
+	Type     string
+	Album    string
+}
+
+// metadataTrack is a track of an album with the fields available in the
+// exported metadata.
+type metadataTrack struct {
+	album *storage.Album
+	song  *storage.Song
+	order int
+}
+
+// metadataFields are the placeholders that can be used in the csv template.
+var metadataFields = map[string]func(t *metadataTrack) string{
+	"album_id":        func(t *metadataTrack) string { return t.album.ID },
+	"album_title":     func(t *metadataTrack) string { return t.album.FullTitle() },
+	"artist":          func(t *metadataTrack) string { return t.album.Artist },
+	"upc":             func(t *metadataTrack) string { return t.album.UPC },
+	"primary_genre":   func(t *metadataTrack) string { return t.album.PrimaryGenre },
+	"secondary_genre": func(t *metadataTrack) string { return t.album.SecondaryGenre },
+	"release_date":    func(t *metadataTrack) string { return releaseDate(t.album) },
+	"track_number":    func(t *metadataTrack) string { return strconv.Itoa(t.order) },
+	"track_title":     func(t *metadataTrack) string { return t.song.Title },
+	"isrc":            func(t *metadataTrack) string { return t.song.ISRC },
+	"explicit":        func(t *metadataTrack) string { return "No" },
+	"credits":         func(t *metadataTrack) string { return t.song.Credits },
+	"instrumental": func(t *metadataTrack) string {
+		if t.song.Instrumental {
+			return "Yes"
+		}
+		return "No"
+	},
+	"duration": func(t *metadataTrack) string {
+		if t.song.Generation == nil {
+			return ""
+		}
+		return strconv.Itoa(int(t.song.Generation.Duration))
+	},
+}
+
+// defaultTemplate is the csv template used if none is provided.
+const defaultTemplate = `album_id,album_title,artist,upc,primary_genre,secondary_genre,release_date,track_number,track_title,isrc,explicit,instrumental,duration
+{album_id},{album_title},{artist},{upc},{primary_genre},{secondary_genre},{release_date},{track_number},{track_title},{isrc},{explicit},{instrumental},{duration}
+`
+
+var placeholderRegex = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// RunMetadata exports the metadata of the albums and their tracks for bulk
+// distribution, as a csv or a minimal DDEX XML.
+func RunMetadata(ctx context.Context, cfg *MetadataConfig) error {
+	if cfg.Output == "" {
+		return errors.New("export: output is required")
+	}
+	var write func(io.Writer, [][]*metadataTrack) error
+	switch cfg.Format {
+	case "", "csv":
+		tmpl := defaultTemplate
+		if cfg.Template != "" {
+			b, err := os.ReadFile(cfg.Template)
+			if err != nil {
+				return fmt.Errorf("export: couldn't read template: %w", err)
+			}
+			tmpl = string(b)
+		}
+		header, row, err := parseTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer, albums [][]*metadataTrack) error {
+			return writeCSV(w, header, row, albums)
+		}
+	case "ddex":
+		write = writeDDEX
+	default:
+		return fmt.Errorf("export: unknown metadata format %q", cfg.Format)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("export: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("export: couldn't start orm store: %w", err)
+	}
+
+	// Get the albums and their tracks
+	var filters []storage.Filter
+	if cfg.Album != "" {
+		filters = append(filters, storage.Where("id = ?", cfg.Album))
+	}
+	if cfg.Type != "" {
+		filters = append(filters, storage.Like("type", cfg.Type))
+	}
+	var albums [][]*metadataTrack
+	var skipped int
+	var currID string
+	for {
+		page, err := store.ListAlbums(ctx, 1, 100, "id", append(filters, storage.Where("id > ?", currID))...)
+		if err != nil {
+			return fmt.Errorf("export: couldn't list albums: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		currID = page[len(page)-1].ID
+		for _, album := range page {
+			songs, err := store.ListSongs(ctx, 1, 1000, "\"order\" asc", storage.Where("album_id = ?", album.ID))
+			if err != nil {
+				return fmt.Errorf("export: couldn't list songs of album %s: %w", album.ID, err)
+			}
+			var tracks []*metadataTrack
+			for i, song := range songs {
+				tracks = append(tracks, &metadataTrack{album: album, song: song, order: i + 1})
+			}
+			// Albums with missing data are skipped so they don't block the
+			// export of the rest
+			if err := validateAlbum(album, tracks); err != nil {
+				log.Printf("export: skipping invalid album: %v\n", err)
+				skipped++
+				continue
+			}
+			albums = append(albums, tracks)
+		}
+	}
+	if len(albums) == 0 {
+		return fmt.Errorf("export: no valid albums to export (%d skipped)", skipped)
+	}
+
+	f, err := os.Create(cfg.Output)
+	if err != nil {
+		return fmt.Errorf("export: couldn't create output file: %w", err)
+	}
+	defer f.Close()
+	if err := write(f, albums); err != nil {
+		return err
+	}
+	log.Printf("export: metadata of %d albums exported to %s (%d invalid skipped)\n", len(albums), cfg.Output, skipped)
+	return nil
+}
+
+// validateAlbum checks that the fields required by distributors are set.
+func validateAlbum(album *storage.Album, tracks []*metadataTrack) error {
+	var missing []string
+	if album.Title == "" {
+		missing = append(missing, "title")
+	}
+	if album.Artist == "" {
+		missing = append(missing, "artist")
+	}
+	if album.UPC == "" {
+		missing = append(missing, "upc")
+	}
+	if album.PrimaryGenre == "" {
+		missing = append(missing, "primary genre")
+	}
+	if album.PublishedAt.IsZero() {
+		missing = append(missing, "release date")
+	}
+	if len(tracks) == 0 {
+		missing = append(missing, "tracks")
+	}
+	for _, t := range tracks {
+		if t.song.Title == "" {
+			missing = append(missing, fmt.Sprintf("track %d title", t.order))
+		}
+		if t.song.ISRC == "" {
+			missing = append(missing, fmt.Sprintf("track %d isrc", t.order))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("album %s (%s) is missing %s", album.ID, album.FullTitle(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func releaseDate(album *storage.Album) string {
+	return album.PublishedAt.UTC().Format(time.DateOnly)
+}
+
+// parseTemplate parses a csv template with a header row and a row with the
+// placeholders of each column (e.g. {track_title}).
+func parseTemplate(tmpl string) ([]string, []string, error) {
+	records, err := csv.NewReader(strings.NewReader(tmpl)).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("export: couldn't parse template: %w", err)
+	}
+	if len(records) != 2 {
+		return nil, nil, fmt.Errorf("export: template must have a header and a row, got %d rows", len(records))
+	}
+	for _, m := range placeholderRegex.FindAllStringSubmatch(strings.Join(records[1], ","), -1) {
+		if _, ok := metadataFields[m[1]]; !ok {
+			return nil, nil, fmt.Errorf("export: unknown template field %q", m[1])
+		}
+	}
+	return records[0], records[1], nil
+}
+
+func writeCSV(w io.Writer, header, row []string, albums [][]*metadataTrack) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("export: couldn't write csv: %w", err)
+	}
+	for _, tracks := range albums {
+		for _, t := range tracks {
+			var record []string
+			for _, col := range row {
+				record = append(record, placeholderRegex.ReplaceAllStringFunc(col, func(p string) string {
+					return metadataFields[p[1:len(p)-1]](t)
+				}))
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("export: couldn't write csv: %w", err)
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("export: couldn't write csv: %w", err)
+	}
+	return nil
+}
+
+// ddexMessage is a minimal DDEX ERN new release message with the resources
+// and releases of the exported albums.
+type ddexMessage struct {
+	XMLName      xml.Name          `xml:"ern:NewReleaseMessage"`
+	Namespace    string            `xml:"xmlns:ern,attr"`
+	Header       ddexHeader        `xml:"MessageHeader"`
+	ResourceList []ddexRecording   `xml:"ResourceList>SoundRecording"`
+	ReleaseList  []ddexRelease     `xml:"ReleaseList>Release"`
+	DealList     []ddexReleaseDeal `xml:"DealList>ReleaseDeal"`
+}
+
+type ddexHeader struct {
+	MessageID          string `xml:"MessageId"`
+	MessageCreatedDate string `xml:"MessageCreatedDateTime"`
+}
+
+type ddexRecording struct {
+	Reference    string `xml:"ResourceReference"`
+	ISRC         string `xml:"SoundRecordingId>ISRC"`
+	Title        string `xml:"ReferenceTitle>TitleText"`
+	Artist       string `xml:"DisplayArtistName"`
+	Duration     string `xml:"Duration,omitempty"`
+	Explicit     bool   `xml:"ParentalWarningType>IsExplicit"`
+	Instrumental bool   `xml:"IsInstrumental"`
+}
+
+type ddexRelease struct {
+	Reference      string   `xml:"ReleaseReference"`
+	ICPN           string   `xml:"ReleaseId>ICPN"`
+	Title          string   `xml:"ReferenceTitle>TitleText"`
+	Artist         string   `xml:"DisplayArtistName"`
+	Genre          string   `xml:"Genre>GenreText"`
+	SubGenre       string   `xml:"Genre>SubGenre,omitempty"`
+	ReleaseDate    string   `xml:"OriginalReleaseDate"`
+	ResourceGroups []string `xml:"ResourceGroup>ResourceGroupContentItem>ReleaseResourceReference"`
+}
+
+type ddexReleaseDeal struct {
+	ReleaseReference string `xml:"DealReleaseReference"`
+	StartDate        string `xml:"Deal>DealTerms>ValidityPeriod>StartDate"`
+}
+
+func writeDDEX(w io.Writer, albums [][]*metadataTrack) error {
+	now := time.Now().UTC()
+	msg := ddexMessage{
+		Namespace: "http://ddex.net/xml/ern/43",
+		Header: ddexHeader{
+			MessageID:          fmt.Sprintf("musikai-%d", now.Unix()),
+			MessageCreatedDate: now.Format(time.RFC3339),
+		},
+	}
+	var n int
+	for i, tracks := range albums {
+		album := tracks[0].album
+		release := ddexRelease{
+			Reference:   fmt.Sprintf("R%d", i+1),
+			ICPN:        album.UPC,
+			Title:       album.FullTitle(),
+			Artist:      album.Artist,
+			Genre:       album.PrimaryGenre,
+			SubGenre:    album.SecondaryGenre,
+			ReleaseDate: releaseDate(album),
+		}
+		for _, t := range tracks {
+			n++
+			ref := fmt.Sprintf("A%d", n)
+			var duration string
+			if t.song.Generation != nil {
+				duration = fmt.Sprintf("PT%dS", int(t.song.Generation.Duration))
+			}
+			msg.ResourceList = append(msg.ResourceList, ddexRecording{
+				Reference:    ref,
+				ISRC:         t.song.ISRC,
+				Title:        t.song.Title,
+				Artist:       album.Artist,
+				Duration:     duration,
+				Instrumental: t.song.Instrumental,
+			})
+			release.ResourceGroups = append(release.ResourceGroups, ref)
+		}
+		msg.ReleaseList = append(msg.ReleaseList, release)
+		msg.DealList = append(msg.DealList, ddexReleaseDeal{
+			ReleaseReference: release.Reference,
+			StartDate:        release.ReleaseDate,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("export: couldn't write xml: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(msg); err != nil {
+		return fmt.Errorf("export: couldn't encode xml: %w", err)
+	}
+	return nil
+}
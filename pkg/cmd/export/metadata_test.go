@@ -0,0 +1,117 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// newMetadataStore creates a catalog with a valid album and an album without
+// UPC.
+func newMetadataStore(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+	conn := filepath.Join(t.TempDir(), "test.db")
+	store, err := storage.New("sqlite", conn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	albums := []*storage.Album{
+		{ID: "a1", Type: "jazz", Title: "Blue", Artist: "Miles", UPC: "036000291452", PrimaryGenre: "Jazz", PublishedAt: published, State: storage.Used},
+		{ID: "a2", Type: "jazz", Title: "Green", Artist: "Miles", PrimaryGenre: "Jazz", PublishedAt: published, State: storage.Used},
+	}
+	for _, a := range albums {
+		if err := store.SetAlbum(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 2; i++ {
+			id := fmt.Sprintf("%ss%d", a.ID, i)
+			gid := "g" + id
+			if err := store.SetGeneration(ctx, &storage.Generation{ID: gid, SongID: &id, Duration: 120}); err != nil {
+				t.Fatal(err)
+			}
+			song := &storage.Song{
+				ID:           id,
+				AlbumID:      a.ID,
+				Order:        i,
+				Title:        fmt.Sprintf("%s %d", a.Title, i),
+				ISRC:         fmt.Sprintf("USABC24%05d", i),
+				Instrumental: true,
+				GenerationID: &gid,
+				State:        storage.Used,
+			}
+			if err := store.SetSong(ctx, song); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return conn
+}
+
+func TestRunMetadataCSV(t *testing.T) {
+	conn := newMetadataStore(t)
+	output := filepath.Join(t.TempDir(), "metadata.csv")
+	if err := RunMetadata(context.Background(), &MetadataConfig{DBType: "sqlite", DBConn: conn, Output: output}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `album_id,album_title,artist,upc,primary_genre,secondary_genre,release_date,track_number,track_title,isrc,explicit,instrumental,duration
+a1,Blue,Miles,036000291452,Jazz,,2024-05-01,1,Blue 1,USABC2400001,No,Yes,120
+a1,Blue,Miles,036000291452,Jazz,,2024-05-01,2,Blue 2,USABC2400002,No,Yes,120
+`
+	// The album without UPC is skipped
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+}
+
+func TestRunMetadataDDEX(t *testing.T) {
+	conn := newMetadataStore(t)
+	output := filepath.Join(t.TempDir(), "metadata.xml")
+	if err := RunMetadata(context.Background(), &MetadataConfig{DBType: "sqlite", DBConn: conn, Output: output, Format: "ddex"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, want := range []string{
+		"<ICPN>036000291452</ICPN>",
+		"<ISRC>USABC2400001</ISRC>",
+		"<ISRC>USABC2400002</ISRC>",
+		"<Duration>PT120S</Duration>",
+		"<OriginalReleaseDate>2024-05-01</OriginalReleaseDate>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Green") {
+		t.Errorf("invalid album exported:\n%s", got)
+	}
+}
+
+func TestRunMetadataNoValidAlbums(t *testing.T) {
+	conn := newMetadataStore(t)
+	output := filepath.Join(t.TempDir(), "metadata.csv")
+	if err := RunMetadata(context.Background(), &MetadataConfig{DBType: "sqlite", DBConn: conn, Output: output, Album: "a2"}); err == nil {
+		t.Error("expected error")
+	}
+}
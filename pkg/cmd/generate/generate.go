@@ -3,6 +3,7 @@ package generate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -16,13 +17,18 @@ import (
 	"time"
 
 	"github.com/gocarina/gocsv"
+	"github.com/igolaizola/musikai/pkg/cmd/process"
+	"github.com/igolaizola/musikai/pkg/errstreak"
+	"github.com/igolaizola/musikai/pkg/maxruntime"
 	"github.com/igolaizola/musikai/pkg/music"
 	"github.com/igolaizola/musikai/pkg/ngrok"
+	"github.com/igolaizola/musikai/pkg/pprof"
+	"github.com/igolaizola/musikai/pkg/progress"
+	"github.com/igolaizola/musikai/pkg/shutdown"
 	"github.com/igolaizola/musikai/pkg/sound/aubio"
 	"github.com/igolaizola/musikai/pkg/storage"
 	"github.com/igolaizola/musikai/pkg/suno"
 	"github.com/igolaizola/musikai/pkg/udio"
-	"github.com/oklog/ulid/v2"
 	"github.com/smarty/cproxy/v2"
 )
 
@@ -35,7 +41,25 @@ type Config struct {
 	WaitMin     time.Duration
 	WaitMax     time.Duration
 	Limit       int
-	Proxy       string
+	MaxRuntime  time.Duration
+	PprofAddr   string
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	// Proxy is a proxy URL, a comma separated list of them or a file with
+	// one per line. With several proxies a different one is used on each
+	// iteration.
+	Proxy string
+	// ProxyMaxFailures is the number of consecutive failed generations after
+	// which a proxy is skipped for ProxyCooldown.
+	ProxyMaxFailures int
+	ProxyCooldown    time.Duration
+
+	// ProgressInterval is the time between progress logs.
+	// Zero disables them.
+	ProgressInterval time.Duration
 
 	Account      string
 	Provider     string
@@ -48,18 +72,60 @@ type Config struct {
 	Lyrics       string
 	Notes        string
 
+	// AccountCooldown is the time an account is skipped after it becomes
+	// unavailable (e.g. it ran out of credits). Account can be a comma
+	// separated list of accounts that are used in round-robin.
+	AccountCooldown time.Duration
+	// PerAccountMinInterval is the minimum time between two requests of the
+	// same account, enforced in addition to the global random wait.
+	PerAccountMinInterval time.Duration
+
+	// MinCredits stops using an account when its credits drop under this
+	// value. The process ends when no account has enough credits.
+	MinCredits int
+	// CreditsLog is the number of iterations between credit logs.
+	CreditsLog int
+
+	// StyleTemplates maps each provider to a template applied to the prompt
+	// before generating, where {style} is replaced by the prompt.
+	StyleTemplates map[string]string
+
 	EndLyrics      string
 	EndStyle       string
 	EndStyleAppend bool
 	ForceEndLyrics string
 	ForceEndStyle  string
-	MinDuration    time.Duration
-	MaxDuration    time.Duration
-	MaxExtensions  int
+	ExtendStrategy string
+
+	MinDuration      time.Duration
+	MaxDuration      time.Duration
+	MaxExtensions    int
+	FadeOutWindow    time.Duration
+	FadeOutThreshold float64
 
 	CaptchaProvider string
 	CaptchaKey      string
 	CaptchaProxy    string
+	SkipIntro       bool
+	IntroDuration   time.Duration
+	IntroExtensions int
+
+	GenerateAttempts   int
+	SkipCaptchaRefresh bool
+
+	// Seed initializes the random source used to choose the clips to extend,
+	// so a generation can be reproduced. If zero a time based seed is used.
+	Seed int64
+
+	// IDStrategy is the strategy used to generate the IDs of the songs and
+	// generations (ulid, nanoid or external to use the provider IDs).
+	IDStrategy string
+
+	// AutoProcess processes the generations right after they are saved,
+	// using the Process configuration.
+	AutoProcess        bool
+	ProcessConcurrency int
+	Process            process.Config
 }
 
 type input struct {
@@ -91,6 +157,12 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("generate: couldn't get aubio version: %w", err)
 	}
 
+	if cfg.PprofAddr != "" {
+		if err := pprof.Serve(ctx, cfg.PprofAddr); err != nil {
+			return fmt.Errorf("generate: %w", err)
+		}
+	}
+
 	// Get the template function
 	var fn func() (template, error)
 	if cfg.Input != "" {
@@ -113,6 +185,11 @@ func Run(ctx context.Context, cfg *Config) error {
 		}
 	}
 
+	ids, err := storage.NewIDGenerator(cfg.IDStrategy)
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+
 	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
 	if err != nil {
 		return fmt.Errorf("generate: couldn't create orm store: %w", err)
@@ -121,31 +198,57 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("generate: couldn't start orm store: %w", err)
 	}
 
-	var generator music.Generator
 	switch cfg.Provider {
 	case "suno":
-		generator = suno.New(&suno.Config{
-			Wait:           4 * time.Second,
-			Debug:          cfg.Debug,
-			Proxy:          cfg.Proxy,
-			CookieStore:    store.NewCookieStore("suno", cfg.Account),
-			Parallel:       cfg.Limit == 1,
-			EndLyrics:      cfg.EndLyrics,
-			EndStyle:       cfg.EndStyle,
-			EndStyleAppend: cfg.EndStyleAppend,
-			ForceEndLyrics: cfg.ForceEndLyrics,
-			ForceEndStyle:  cfg.ForceEndStyle,
-			MinDuration:    cfg.MinDuration,
-			MaxDuration:    cfg.MaxDuration,
-			MaxExtensions:  cfg.MaxExtensions,
-		})
+		switch cfg.ExtendStrategy {
+		case "", suno.ExtendHeuristic, suno.ExtendLongest, suno.ExtendRandom:
+		default:
+			return fmt.Errorf("generate: unknown extend strategy %s", cfg.ExtendStrategy)
+		}
 	case "udio":
-		proxy := cfg.Proxy
-		if proxy == "" {
-			// Start a connect proxy server on a random port
+	default:
+		return fmt.Errorf("generate: unknown provider: %s", cfg.Provider)
+	}
+
+	proxies, err := parseProxies(cfg.Proxy)
+	if err != nil {
+		return err
+	}
+	var firstProxy string
+	if len(proxies) > 0 {
+		firstProxy = proxies[0].String()
+	}
+
+	// Rotate the proxies if there are several of them
+	var rotation *proxyPool
+	if len(proxies) > 1 {
+		// The proxy is switched on the client of the account, which would
+		// change it under the generations running concurrently
+		if cfg.Concurrency > 1 {
+			return errors.New("generate: proxy rotation isn't supported with concurrency greater than 1")
+		}
+		cooldown := cfg.ProxyCooldown
+		if cooldown == 0 {
+			cooldown = 10 * time.Minute
+		}
+		rotation = newProxyPool(proxies, cfg.ProxyMaxFailures, cooldown)
+		defer rotation.logStats()
+		log.Printf("generate: rotating %d proxies\n", len(proxies))
+	}
+
+	var proxy, capthaProxy string
+	if cfg.Provider == "udio" {
+		proxy = firstProxy
+		if proxy == "" || (rotation != nil && cfg.CaptchaProxy == "") {
+			// Start a connect proxy server on a random port, it tunnels the
+			// connections through the proxy in use when rotating proxies
 			handler := cproxy.New(
 				cproxy.Options.Logger(logger{}),
 				cproxy.Options.LogConnections(true),
+				cproxy.Options.Dialer(&proxyDialer{
+					proxies: rotation,
+					timeout: 30 * time.Second,
+				}),
 			)
 			listener, err := net.Listen("tcp", ":0")
 			if err != nil {
@@ -161,7 +264,7 @@ func Run(ctx context.Context, cfg *Config) error {
 			}()
 			log.Println("generate: running udio proxy on", proxy)
 		}
-		capthaProxy := cfg.CaptchaProxy
+		capthaProxy = cfg.CaptchaProxy
 		if capthaProxy == "" {
 			// Start a ngrok tunnel to the proxy
 			u, err := url.Parse(proxy)
@@ -176,33 +279,128 @@ func Run(ctx context.Context, cfg *Config) error {
 			log.Printf("generate: ngrok started %s => %s\n", capthaProxy, u.Port())
 			defer cancel()
 		}
-		generator, err = udio.New(&udio.Config{
-			Wait:            4 * time.Second,
-			Debug:           cfg.Debug,
-			Proxy:           cfg.Proxy,
-			CookieStore:     store.NewCookieStore("udio", cfg.Account),
-			Parallel:        cfg.Limit == 1,
-			MinDuration:     cfg.MinDuration,
-			MaxDuration:     cfg.MaxDuration,
-			MaxExtensions:   cfg.MaxExtensions,
-			CaptchaKey:      cfg.CaptchaKey,
-			CaptchaProvider: cfg.CaptchaProvider,
-			CaptchaProxy:    capthaProxy,
-		})
+	}
+
+	// Create a generator for each account
+	cooldown := cfg.AccountCooldown
+	if cooldown == 0 {
+		cooldown = 30 * time.Minute
+	}
+	pool := newAccountPool(cooldown, cfg.PerAccountMinInterval)
+	for _, account := range strings.Split(cfg.Account, ",") {
+		account = strings.TrimSpace(account)
+		if account == "" {
+			continue
+		}
+
+		// Wait time between requests, it can be set for each account using
+		// the ratelimit setting
+		wait, err := store.GetRateLimit(ctx, cfg.Provider, account, 4*time.Second)
 		if err != nil {
-			return fmt.Errorf("generate: couldn't create udio generator: %w", err)
+			return fmt.Errorf("generate: couldn't get rate limit: %w", err)
 		}
-	default:
-		return fmt.Errorf("generate: unknown provider: %s", cfg.Provider)
+
+		var generator music.Generator
+		switch cfg.Provider {
+		case "suno":
+			generator = suno.New(&suno.Config{
+				Wait:           wait,
+				Debug:          cfg.Debug,
+				Proxy:          firstProxy,
+				CookieStore:    store.NewCookieStore("suno", account),
+				Parallel:       cfg.Limit == 1,
+				EndLyrics:      cfg.EndLyrics,
+				EndStyle:       cfg.EndStyle,
+				EndStyleAppend: cfg.EndStyleAppend,
+				ForceEndLyrics: cfg.ForceEndLyrics,
+				ForceEndStyle:  cfg.ForceEndStyle,
+				MinDuration:    cfg.MinDuration,
+				MaxDuration:    cfg.MaxDuration,
+				MaxExtensions:  cfg.MaxExtensions,
+				ExtendStrategy: cfg.ExtendStrategy,
+
+				FadeOutWindow:    cfg.FadeOutWindow,
+				FadeOutThreshold: cfg.FadeOutThreshold,
+				Seed:             cfg.Seed,
+			})
+		case "udio":
+			generator, err = udio.New(&udio.Config{
+				Wait:            wait,
+				Debug:           cfg.Debug,
+				Proxy:           firstProxy,
+				CookieStore:     store.NewCookieStore("udio", account),
+				Parallel:        cfg.Limit == 1,
+				MinDuration:     cfg.MinDuration,
+				MaxDuration:     cfg.MaxDuration,
+				MaxExtensions:   cfg.MaxExtensions,
+				CaptchaKey:      cfg.CaptchaKey,
+				CaptchaProvider: cfg.CaptchaProvider,
+				CaptchaProxy:    capthaProxy,
+				SkipIntro:       cfg.SkipIntro,
+				IntroDuration:   cfg.IntroDuration,
+				IntroExtensions: cfg.IntroExtensions,
+
+				GenerateAttempts:   cfg.GenerateAttempts,
+				SkipCaptchaRefresh: cfg.SkipCaptchaRefresh,
+				FadeOutWindow:      cfg.FadeOutWindow,
+				FadeOutThreshold:   cfg.FadeOutThreshold,
+				Seed:               cfg.Seed,
+			})
+			if err != nil {
+				return fmt.Errorf("generate: couldn't create udio generator: %w", err)
+			}
+		}
+		if err := generator.Start(ctx); err != nil {
+			return fmt.Errorf("generate: couldn't start %s generator (%s): %w", cfg.Provider, account, err)
+		}
+		defer func() {
+			if err := generator.Stop(ctx); err != nil {
+				log.Printf("generate: couldn't stop %s generator (%s): %v\n", cfg.Provider, account, err)
+			}
+		}()
+		pool.add(account, generator)
 	}
-	if err := generator.Start(ctx); err != nil {
-		return fmt.Errorf("generate: couldn't start suno generator: %w", err)
+	if len(pool.accounts) == 0 {
+		return errors.New("generate: missing account")
 	}
-	defer func() {
-		if err := generator.Stop(ctx); err != nil {
-			log.Printf("generate: couldn't stop suno generator: %v\n", err)
+
+	// Process the generations in a bounded worker pool
+	var processC chan *storage.Generation
+	if cfg.AutoProcess {
+		pcfg := cfg.Process
+		pcfg.Debug = cfg.Debug
+		pcfg.DBType = cfg.DBType
+		pcfg.DBConn = cfg.DBConn
+		pcfg.Proxy = firstProxy
+		processor, err := process.NewProcessor(ctx, &pcfg, store, nil)
+		if err != nil {
+			return fmt.Errorf("generate: couldn't create processor: %w", err)
 		}
-	}()
+		workers := cfg.ProcessConcurrency
+		if workers == 0 {
+			workers = 1
+		}
+		processC = make(chan *storage.Generation, 100)
+		var processWG sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			processWG.Add(1)
+			go func() {
+				defer processWG.Done()
+				for gen := range processC {
+					debug("generate: start process %s", gen.ID)
+					if err := processor.Process(ctx, gen); err != nil {
+						log.Println(err)
+					}
+					debug("generate: end process %s", gen.ID)
+				}
+			}()
+		}
+		// Wait for the pending generations to be processed before exiting
+		defer func() {
+			close(processC)
+			processWG.Wait()
+		}()
+	}
 
 	// Print time stats
 	start := time.Now()
@@ -211,7 +409,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("generate: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("generate", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -219,6 +417,8 @@ func Run(ctx context.Context, cfg *Config) error {
 	ticker := time.NewTicker(timeout)
 	last := time.Now()
 	defer ticker.Stop()
+	maxRuntime, stopMaxRuntime := maxruntime.After(cfg.MaxRuntime)
+	defer stopMaxRuntime()
 
 	// Concurrency settings
 	concurrency := cfg.Concurrency
@@ -233,27 +433,72 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	// Progress of the generations, there are no pending items so the total
+	// is the limit
+	reporter := progress.New("generate", cfg.ProgressInterval, cfg.Limit, nil)
+	stopProgress := reporter.Start(ctx)
+	defer func() {
+		// Log the last progress once the running items are done
+		wg.Wait()
+		stopProgress()
+	}()
+
+	maxRuntimeReached := func() error {
+		remaining := "unknown"
+		if cfg.Limit > 0 {
+			remaining = fmt.Sprintf("%d", cfg.Limit-iteration)
+		}
+		log.Printf("generate: stopped due to max runtime with %s items remaining\n", remaining)
+		return fmt.Errorf("generate: %w", maxruntime.ErrReached)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("generate: %w", ctx.Err())
 		case <-ticker.C:
 			return nil
+		case <-maxRuntime:
+			return maxRuntimeReached()
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
-			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("generate: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if shutdown.Requested(ctx) {
+				log.Printf("generate: shutdown requested, waiting for in-flight work\n")
+				return fmt.Errorf("generate: %w", shutdown.ErrStopped)
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
 			}
 
+			// Get the next available account
+			checkCredits := cfg.MinCredits > 0 || (cfg.CreditsLog > 0 && iteration%cfg.CreditsLog == 0)
+			account, err := nextAccount(ctx, pool, cfg.MinCredits, checkCredits)
+			for errors.Is(err, errNoAccounts) {
+				// Wait for the first account to leave its cooldown
+				left := pool.cooldownLeft()
+				log.Printf("generate: all accounts are in cooldown, waiting %s\n", left.Round(time.Second))
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("generate: %w", ctx.Err())
+				case <-ticker.C:
+					return nil
+				case <-maxRuntime:
+					return maxRuntimeReached()
+				case <-time.After(left):
+				}
+				account, err = nextAccount(ctx, pool, cfg.MinCredits, checkCredits)
+			}
+			if errors.Is(err, errLowCredits) {
+				log.Printf("generate: stopping, no account with at least %d credits after %d iterations\n", cfg.MinCredits, iteration)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
 			iteration++
 			if time.Since(last) > 60*time.Minute {
 				last = time.Now()
@@ -265,11 +510,31 @@ func Run(ctx context.Context, cfg *Config) error {
 			if iteration > 1 {
 				wait = time.Duration(rand.Int63n(int64(cfg.WaitMax-cfg.WaitMin))) + cfg.WaitMin
 			}
+			// Wait longer if the account was used too recently
+			if accountWait := pool.wait(account); accountWait > wait {
+				debug("generate: waiting %s for account %s", accountWait, account.name)
+				wait = accountWait
+			}
 			select {
 			case <-ctx.Done():
 				return fmt.Errorf("generate: %w", ctx.Err())
 			case <-time.After(wait):
 			}
+			pool.used(account)
+
+			// Switch the generator to the next proxy
+			var px *poolProxy
+			if rotation != nil {
+				px = rotation.get()
+				setter, ok := account.generator.(music.ProxySetter)
+				if !ok {
+					return fmt.Errorf("generate: %s generator doesn't support proxy rotation", cfg.Provider)
+				}
+				if err := setter.SetProxy(px.url.String()); err != nil {
+					return fmt.Errorf("generate: couldn't set proxy %s: %w", px.name(), err)
+				}
+				debug("generate: using proxy %s (%s)", px.name(), account.name)
+			}
 
 			// Get a template
 			var tmpl template
@@ -295,11 +560,23 @@ func Run(ctx context.Context, cfg *Config) error {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				debug("generate: start %s", tmpl)
-				err := generate(ctx, cfg.Account, cfg.Provider, generator, store, tmpl, cfg.Notes)
+				debug("generate: start %s (%s)", tmpl, account.name)
+				err := generate(ctx, account.name, cfg.Provider, account.generator, store, tmpl, cfg.Notes, cfg.StyleTemplates[cfg.Provider], ids, processC)
+				// Unavailable accounts and cancellations aren't proxy failures
+				if px != nil && !errors.Is(err, music.ErrAccountUnavailable) && ctx.Err() == nil {
+					if rotation.done(px, err) {
+						log.Printf("generate: proxy %s failed %d times in a row, skipped for %s\n", px.name(), cfg.ProxyMaxFailures, rotation.cooldown)
+					}
+				}
+				if errors.Is(err, music.ErrAccountUnavailable) {
+					pool.disable(account)
+					log.Printf("generate: account %s unavailable for %s: %v\n", account.name, cooldown, err)
+					err = nil
+				}
 				if err != nil {
 					log.Println(err)
 				}
+				reporter.Done(err)
 				debug("generate: end %s", tmpl)
 				errC <- err
 			}()
@@ -307,7 +584,9 @@ func Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func generate(ctx context.Context, account, provider string, generator music.Generator, store *storage.Store, t template, notes string) error {
+// generate generates the songs of a template and saves them to the database.
+// If processC is set, the saved generations are sent to it to be processed.
+func generate(ctx context.Context, account, provider string, generator music.Generator, store *storage.Store, t template, notes, styleTemplate string, ids *storage.IDGenerator, processC chan<- *storage.Generation) error {
 	// Load lyrics if specified.
 	var lyrics []string
 	if t.Lyrics != "" {
@@ -329,7 +608,8 @@ func generate(ctx context.Context, account, provider string, generator music.Gen
 	}
 
 	// Generate the songs.
-	songs, err := generator.Generate(ctx, t.Prompt, t.Manual, t.Instrumental, lyrics)
+	prompt := transformStyle(styleTemplate, t.Prompt)
+	songs, err := generator.Generate(ctx, prompt, t.Manual, t.Instrumental, lyrics)
 	if err != nil {
 		return fmt.Errorf("generate: couldn't generate song %s: %w", t, err)
 	}
@@ -339,8 +619,12 @@ func generate(ctx context.Context, account, provider string, generator music.Gen
 		if len(gens) == 0 {
 			continue
 		}
+		songID, err := newID(ctx, ids, gens[0].ID, store.GetSong)
+		if err != nil {
+			return err
+		}
 		song := &storage.Song{
-			ID:           ulid.Make().String(),
+			ID:           songID,
 			Type:         t.Type,
 			Notes:        notes,
 			Prompt:       t.Prompt,
@@ -354,12 +638,16 @@ func generate(ctx context.Context, account, provider string, generator music.Gen
 			return fmt.Errorf("generate: couldn't save song to database: %w", err)
 		}
 		var firstGenID string
+		var saved []*storage.Generation
 		for i, g := range gens {
-			genID := ulid.Make().String()
+			genID, err := newID(ctx, ids, g.ID, store.GetGeneration)
+			if err != nil {
+				return err
+			}
 			if i == 0 {
 				firstGenID = genID
 			}
-			if err := store.SetGeneration(ctx, &storage.Generation{
+			gen := &storage.Generation{
 				ID:         genID,
 				SongID:     &song.ID,
 				ExternalID: g.ID,
@@ -369,18 +657,84 @@ func generate(ctx context.Context, account, provider string, generator music.Gen
 				History:    g.History,
 				Duration:   g.Duration,
 				Lyrics:     g.Lyrics,
-			}); err != nil {
+			}
+			if err := store.SetGeneration(ctx, gen); err != nil {
 				return fmt.Errorf("generate: couldn't save generation to database: %w", err)
 			}
+			saved = append(saved, gen)
 		}
 		song.GenerationID = &firstGenID
 		if err := store.SetSong(ctx, song); err != nil {
 			return fmt.Errorf("generate: couldn't save song to database: %w", err)
 		}
+
+		if processC == nil {
+			continue
+		}
+		for _, gen := range saved {
+			gen.Song = song
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("generate: %w", ctx.Err())
+			case processC <- gen:
+			}
+		}
 	}
 	return nil
 }
 
+var errLowCredits = errors.New("generate: not enough credits")
+
+// nextAccount returns the next available account. If checkCredits is set, the
+// credits of the account are logged and accounts with less than minCredits are
+// put in cooldown.
+func nextAccount(ctx context.Context, pool *accountPool, minCredits int, checkCredits bool) (*poolAccount, error) {
+	var low bool
+	for {
+		account, err := pool.get()
+		if errors.Is(err, errNoAccounts) && low {
+			return nil, errLowCredits
+		}
+		if err != nil {
+			return nil, err
+		}
+		checker, ok := account.generator.(music.CreditChecker)
+		if !checkCredits || !ok {
+			return account, nil
+		}
+		credits, err := checker.Credits(ctx)
+		if err != nil {
+			log.Printf("generate: couldn't get credits of %s: %v\n", account.name, err)
+			return account, nil
+		}
+		log.Printf("generate: account %s has %d credits left\n", account.name, credits)
+		if credits >= minCredits {
+			return account, nil
+		}
+		log.Printf("generate: account %s has less than %d credits\n", account.name, minCredits)
+		pool.disable(account)
+		low = true
+	}
+}
+
+// newID returns a new ID and checks that it isn't already in use, which may
+// happen with external IDs.
+func newID[T any](ctx context.Context, ids *storage.IDGenerator, external string, get func(context.Context, string) (T, error)) (string, error) {
+	id, err := ids.NewID(external)
+	if err != nil {
+		return "", fmt.Errorf("generate: couldn't create id: %w", err)
+	}
+	_, err = get(ctx, id)
+	switch {
+	case err == nil:
+		return "", fmt.Errorf("generate: id %s already exists", id)
+	case errors.Is(err, storage.ErrNotFound):
+		return id, nil
+	default:
+		return "", fmt.Errorf("generate: couldn't check id %s: %w", id, err)
+	}
+}
+
 func toTemplateFunc(file string, random bool) (func() (template, error), error) {
 	b, err := os.ReadFile(file)
 	if err != nil {
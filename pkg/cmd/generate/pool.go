@@ -0,0 +1,135 @@
+package generate
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/music"
+)
+
+var errNoAccounts = errors.New("generate: all accounts are unavailable")
+
+// poolAccount is an account of the pool with its generator.
+type poolAccount struct {
+	name      string
+	generator music.Generator
+	until     time.Time
+	// last is the time of the last request sent with the account
+	last time.Time
+}
+
+// accountPool cycles through the accounts in round-robin, skipping the ones
+// that are in cooldown.
+// It also tracks the last request of each account so a minimum interval
+// between requests of the same account can be enforced.
+type accountPool struct {
+	sync.Mutex
+	accounts    []*poolAccount
+	next        int
+	cooldown    time.Duration
+	minInterval time.Duration
+	now         func() time.Time
+}
+
+func newAccountPool(cooldown, minInterval time.Duration) *accountPool {
+	return &accountPool{
+		cooldown:    cooldown,
+		minInterval: minInterval,
+		now:         time.Now,
+	}
+}
+
+func (p *accountPool) add(name string, generator music.Generator) {
+	p.Lock()
+	defer p.Unlock()
+	p.accounts = append(p.accounts, &poolAccount{
+		name:      name,
+		generator: generator,
+	})
+}
+
+// get returns the next available account in round-robin. Accounts whose
+// minimum interval since the last request hasn't elapsed are only returned
+// if no other account is ready, the one that is ready first, so a recently
+// used account doesn't delay the others. If all accounts are in cooldown, it
+// returns errNoAccounts.
+func (p *accountPool) get() (*poolAccount, error) {
+	p.Lock()
+	defer p.Unlock()
+	now := p.now()
+	var best *poolAccount
+	var bestIdx int
+	var bestWait time.Duration
+	for i := 0; i < len(p.accounts); i++ {
+		idx := (p.next + i) % len(p.accounts)
+		a := p.accounts[idx]
+		if now.Before(a.until) {
+			continue
+		}
+		wait := p.waitAt(a, now)
+		if best == nil || wait < bestWait {
+			best, bestIdx, bestWait = a, idx, wait
+		}
+		if wait == 0 {
+			break
+		}
+	}
+	if best == nil {
+		return nil, errNoAccounts
+	}
+	p.next = (bestIdx + 1) % len(p.accounts)
+	return best, nil
+}
+
+// cooldownLeft returns the time left until the first account in cooldown is
+// available again.
+func (p *accountPool) cooldownLeft() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+	now := p.now()
+	var left time.Duration
+	for i, a := range p.accounts {
+		d := a.until.Sub(now)
+		if d <= 0 {
+			return 0
+		}
+		if i == 0 || d < left {
+			left = d
+		}
+	}
+	return left
+}
+
+// disable puts the account in cooldown.
+func (p *accountPool) disable(a *poolAccount) {
+	p.Lock()
+	defer p.Unlock()
+	a.until = p.now().Add(p.cooldown)
+}
+
+// wait returns the time left until the minimum interval since the last
+// request of the account has elapsed.
+func (p *accountPool) wait(a *poolAccount) time.Duration {
+	p.Lock()
+	defer p.Unlock()
+	return p.waitAt(a, p.now())
+}
+
+func (p *accountPool) waitAt(a *poolAccount, now time.Time) time.Duration {
+	if p.minInterval <= 0 || a.last.IsZero() {
+		return 0
+	}
+	left := a.last.Add(p.minInterval).Sub(now)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// used records that a request is being sent with the account.
+func (p *accountPool) used(a *poolAccount) {
+	p.Lock()
+	defer p.Unlock()
+	a.last = p.now()
+}
@@ -0,0 +1,108 @@
+package generate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccountPool(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newAccountPool(10*time.Minute, 0)
+	p.now = func() time.Time { return now }
+	p.add("a", nil)
+	p.add("b", nil)
+	p.add("c", nil)
+
+	next := func() string {
+		t.Helper()
+		a, err := p.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a.name
+	}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, next())
+	}
+	if want := "a,b,c,a"; strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	// Disable b and check it is skipped
+	p.disable(p.accounts[1])
+	got = nil
+	for i := 0; i < 3; i++ {
+		got = append(got, next())
+	}
+	if want := "c,a,c"; strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	// All accounts unavailable
+	p.disable(p.accounts[0])
+	p.disable(p.accounts[2])
+	if _, err := p.get(); !errors.Is(err, errNoAccounts) {
+		t.Fatalf("got %v, want %v", err, errNoAccounts)
+	}
+	now = now.Add(5 * time.Minute)
+	if got := p.cooldownLeft(); got != 5*time.Minute {
+		t.Fatalf("got %s, want 5m", got)
+	}
+
+	// Accounts are available again after the cooldown
+	now = now.Add(5 * time.Minute)
+	if got := next(); got != "a" {
+		t.Fatalf("got %s, want a", got)
+	}
+}
+
+func TestAccountPoolMinInterval(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newAccountPool(10*time.Minute, time.Minute)
+	p.now = func() time.Time { return now }
+	p.add("a", nil)
+	p.add("b", nil)
+	a, b := p.accounts[0], p.accounts[1]
+
+	// Accounts without previous requests don't wait
+	if got := p.wait(a); got != 0 {
+		t.Fatalf("got %s, want 0", got)
+	}
+	p.used(a)
+
+	// Ready accounts are returned before recently used ones
+	for i := 0; i < 2; i++ {
+		if got, err := p.get(); err != nil || got != b {
+			t.Fatalf("got %v, %v, want b", got, err)
+		}
+	}
+
+	// Each account has its own interval
+	now = now.Add(20 * time.Second)
+	if got := p.wait(a); got != 40*time.Second {
+		t.Fatalf("got %s, want 40s", got)
+	}
+	if got := p.wait(b); got != 0 {
+		t.Fatalf("got %s, want 0", got)
+	}
+	p.used(b)
+
+	// The account that is ready first is returned
+	now = now.Add(10 * time.Second)
+	if got, err := p.get(); err != nil || got != a {
+		t.Fatalf("got %v, %v, want a", got, err)
+	}
+
+	// The interval has elapsed
+	now = now.Add(time.Minute)
+	if got := p.wait(a); got != 0 {
+		t.Fatalf("got %s, want 0", got)
+	}
+	if got := p.wait(b); got != 0 {
+		t.Fatalf("got %s, want 0", got)
+	}
+}
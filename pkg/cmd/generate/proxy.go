@@ -0,0 +1,266 @@
+package generate
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smarty/cproxy/v2"
+)
+
+// parseProxies parses the proxy option, that can be a proxy URL, a comma
+// separated list of them or a file with one proxy per line.
+func parseProxies(value string) ([]*url.URL, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	var candidates []string
+	if info, err := os.Stat(value); err == nil && info.Mode().IsRegular() {
+		b, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("generate: couldn't read proxy file: %w", err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			candidates = append(candidates, line)
+		}
+	} else {
+		for _, c := range strings.Split(value, ",") {
+			c = strings.TrimSpace(c)
+			if c == "" {
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("generate: no proxies found in %q", value)
+	}
+	var proxies []*url.URL
+	for _, c := range candidates {
+		u, err := url.Parse(c)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("generate: invalid proxy %q, expected scheme://host:port", c)
+		}
+		// Only http proxies can be used to tunnel the connections of the
+		// captcha provider
+		if u.Scheme != "http" {
+			return nil, fmt.Errorf("generate: unsupported proxy scheme %q in %q, only http is supported", u.Scheme, c)
+		}
+		proxies = append(proxies, u)
+	}
+	return proxies, nil
+}
+
+// poolProxy is a proxy of the pool with its stats.
+type poolProxy struct {
+	url   *url.URL
+	until time.Time
+	// failures is the number of consecutive failed requests
+	failures int
+	requests int
+	errors   int
+}
+
+// name returns the proxy URL without the password so it can be logged.
+func (p *poolProxy) name() string {
+	return p.url.Redacted()
+}
+
+// proxyPool cycles through the proxies in round-robin, skipping the ones
+// that are in cooldown after failing several times in a row.
+type proxyPool struct {
+	sync.Mutex
+	proxies     []*poolProxy
+	next        int
+	last        *poolProxy
+	maxFailures int
+	cooldown    time.Duration
+	now         func() time.Time
+}
+
+func newProxyPool(proxies []*url.URL, maxFailures int, cooldown time.Duration) *proxyPool {
+	p := &proxyPool{
+		maxFailures: maxFailures,
+		cooldown:    cooldown,
+		now:         time.Now,
+	}
+	for _, u := range proxies {
+		p.proxies = append(p.proxies, &poolProxy{url: u})
+	}
+	return p
+}
+
+// get returns the next proxy that isn't in cooldown. If all of them are in
+// cooldown, the one whose cooldown ends first is returned so the generation
+// continues instead of stopping.
+func (p *proxyPool) get() *poolProxy {
+	p.Lock()
+	defer p.Unlock()
+	now := p.now()
+	var first *poolProxy
+	for i := 0; i < len(p.proxies); i++ {
+		px := p.proxies[p.next]
+		p.next = (p.next + 1) % len(p.proxies)
+		if now.Before(px.until) {
+			if first == nil || px.until.Before(first.until) {
+				first = px
+			}
+			continue
+		}
+		p.last = px
+		return px
+	}
+	p.last = first
+	return first
+}
+
+// current returns the URL of the last proxy returned by get, or nil if get
+// hasn't been called yet.
+func (p *proxyPool) current() *url.URL {
+	p.Lock()
+	defer p.Unlock()
+	if p.last == nil {
+		return nil
+	}
+	return p.last.url
+}
+
+// done records the result of a request sent through the proxy.
+// It returns true if the proxy has been put in cooldown because it reached
+// the maximum number of consecutive failures.
+func (p *proxyPool) done(px *poolProxy, err error) bool {
+	p.Lock()
+	defer p.Unlock()
+	px.requests++
+	if err == nil {
+		px.failures = 0
+		return false
+	}
+	px.errors++
+	px.failures++
+	if p.maxFailures <= 0 || px.failures < p.maxFailures {
+		return false
+	}
+	px.failures = 0
+	px.until = p.now().Add(p.cooldown)
+	return true
+}
+
+// logStats logs the error rate of each proxy.
+func (p *proxyPool) logStats() {
+	p.Lock()
+	defer p.Unlock()
+	for _, px := range p.proxies {
+		if px.requests == 0 {
+			continue
+		}
+		rate := 100 * float64(px.errors) / float64(px.requests)
+		log.Printf("generate: proxy %s failed %d of %d requests (%.0f%%)\n", px.name(), px.errors, px.requests, rate)
+	}
+}
+
+// proxyDialer dials the connections of the local connect proxy through the
+// proxy in use, so the captcha provider connects from the same IP as the
+// generation requests. Without proxies it dials directly.
+type proxyDialer struct {
+	proxies *proxyPool
+	timeout time.Duration
+}
+
+func (d *proxyDialer) Dial(address string) cproxy.Socket {
+	conn, err := d.dial(address)
+	if err != nil {
+		log.Printf("generate: couldn't connect to %s: %v\n", address, err)
+		return nil
+	}
+	return conn
+}
+
+func (d *proxyDialer) dial(address string) (net.Conn, error) {
+	var upstream *url.URL
+	if d.proxies != nil {
+		upstream = d.proxies.current()
+	}
+	if upstream == nil {
+		return net.DialTimeout("tcp", address, d.timeout)
+	}
+	if upstream.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported proxy scheme %s", upstream.Scheme)
+	}
+	host := upstream.Host
+	if upstream.Port() == "" {
+		host = net.JoinHostPort(upstream.Hostname(), "80")
+	}
+	conn, err := net.DialTimeout("tcp", host, d.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't dial proxy %s: %w", upstream.Redacted(), err)
+	}
+
+	// Open a tunnel to the address with a CONNECT request
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if user := upstream.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	_ = conn.SetDeadline(time.Now().Add(d.timeout))
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("couldn't send connect request to %s: %w", upstream.Redacted(), err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("couldn't read connect response from %s: %w", upstream.Redacted(), err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("proxy %s returned %s", upstream.Redacted(), resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// The server may have sent data after the response that is already
+	// buffered
+	tcp, ok := conn.(*net.TCPConn)
+	if br.Buffered() == 0 || !ok {
+		return conn, nil
+	}
+	return &bufferedConn{TCPConn: tcp, r: br}, nil
+}
+
+// bufferedConn is a TCP connection whose first bytes have been buffered by
+// the reader. It keeps the TCP methods so the connect proxy can close each
+// direction on its own.
+type bufferedConn struct {
+	*net.TCPConn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *bufferedConn) WriteTo(w io.Writer) (int64, error) {
+	return c.r.WriteTo(w)
+}
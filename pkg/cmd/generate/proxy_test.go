@@ -0,0 +1,203 @@
+package generate
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProxies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(file, []byte("# proxies\nhttp://a:1\n\nhttp://user:pass@b:2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{"", "", false},
+		{"http://a:1", "http://a:1", false},
+		{" http://a:1 , http://user:pass@b:2,", "http://a:1,http://user:xxxxx@b:2", false},
+		{file, "http://a:1,http://user:xxxxx@b:2", false},
+		{"a:1", "", true},
+		{"socks5://a:1", "", true},
+		{",", "", true},
+	}
+	for _, tt := range tests {
+		proxies, err := parseProxies(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.value, err)
+		}
+		var got []string
+		for _, u := range proxies {
+			got = append(got, u.Redacted())
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.value, strings.Join(got, ","), tt.want)
+		}
+	}
+}
+
+func TestProxyPool(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	proxies, err := parseProxies("http://a:1,http://b:1,http://c:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProxyPool(proxies, 2, 10*time.Minute)
+	p.now = func() time.Time { return now }
+
+	next := func() string {
+		return p.get().url.Hostname()
+	}
+	if p.current() != nil {
+		t.Fatal("expected no current proxy")
+	}
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, next())
+	}
+	if want := "a,b,c,a"; strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+	if got := p.current().Hostname(); got != "a" {
+		t.Errorf("got current %s, want a", got)
+	}
+
+	// A success resets the consecutive failures
+	b := p.proxies[1]
+	fail := errors.New("fail")
+	if p.done(b, fail) || p.done(b, nil) || p.done(b, fail) {
+		t.Fatal("proxy disabled before max failures")
+	}
+	if !p.done(b, fail) {
+		t.Fatal("proxy not disabled after max failures")
+	}
+	got = nil
+	for i := 0; i < 3; i++ {
+		got = append(got, next())
+	}
+	if want := "c,a,c"; strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	// When all are in cooldown the first to recover is returned
+	now = now.Add(time.Minute)
+	p.done(p.proxies[0], fail)
+	p.done(p.proxies[0], fail)
+	p.done(p.proxies[2], fail)
+	p.done(p.proxies[2], fail)
+	if got := next(); got != "b" {
+		t.Fatalf("got %s, want b", got)
+	}
+
+	// Proxies are used again after the cooldown
+	now = now.Add(10 * time.Minute)
+	got = nil
+	for i := 0; i < 3; i++ {
+		got = append(got, next())
+	}
+	if want := "a,b,c"; strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+	if b.requests != 4 || b.errors != 3 {
+		t.Errorf("got %d errors of %d requests, want 3 of 4", b.errors, b.requests)
+	}
+}
+
+func TestProxyDialer(t *testing.T) {
+	// Target server that greets the connections
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("hello"))
+			_ = conn.Close()
+		}
+	}()
+
+	// Upstream proxy that tunnels connect requests
+	var auth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Proxy-Authorization")
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		server, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = server.Close()
+			return
+		}
+		_, _ = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(client, server)
+			_ = client.Close()
+			_ = server.Close()
+		}()
+	}))
+	defer upstream.Close()
+
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.User = url.UserPassword("user", "pass")
+	pool := newProxyPool([]*url.URL{u}, 0, 0)
+	d := &proxyDialer{proxies: pool, timeout: 5 * time.Second}
+
+	read := func() string {
+		t.Helper()
+		conn, err := d.dial(target.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		b, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// Before a proxy is used it dials directly
+	if got := read(); got != "hello" || auth != "" {
+		t.Fatalf("got %q with auth %q", got, auth)
+	}
+
+	// Then it tunnels through the current proxy
+	pool.get()
+	if got := read(); got != "hello" {
+		t.Fatalf("got %q", got)
+	}
+	if want := "Basic dXNlcjpwYXNz"; auth != want {
+		t.Errorf("got auth %q, want %q", auth, want)
+	}
+}
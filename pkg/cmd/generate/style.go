@@ -0,0 +1,13 @@
+package generate
+
+import "strings"
+
+// transformStyle adapts the prompt to the provider using a template where
+// {style} is replaced by the original prompt. Empty templates return the
+// prompt unchanged.
+func transformStyle(tmpl, prompt string) string {
+	if tmpl == "" {
+		return prompt
+	}
+	return strings.ReplaceAll(tmpl, "{style}", prompt)
+}
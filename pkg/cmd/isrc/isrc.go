@@ -0,0 +1,325 @@
+package isrc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type Config struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	Type   string
+	Limit  int
+	DryRun bool
+
+	// ISRCPrefix is the country, registrant and year of the ISRCs
+	// (CC-XXX-YY), the designation code is taken from a counter.
+	ISRCPrefix string
+	// UPCPrefix is the GS1 company prefix of the UPCs, the item reference is
+	// taken from a counter and the check digit is computed.
+	UPCPrefix string
+}
+
+// Run assigns ISRCs to the songs and UPCs to the albums that are ready to be
+// published and don't have them.
+// Codes are generated from the prefixes and counters stored in settings, that
+// are reserved before assigning the codes so concurrent runs don't produce
+// duplicates.
+func Run(ctx context.Context, cfg *Config) error {
+	log.Println("isrc: process started")
+	defer log.Println("isrc: process ended")
+
+	if cfg.ISRCPrefix == "" && cfg.UPCPrefix == "" {
+		return errors.New("isrc: isrc prefix or upc prefix is required")
+	}
+	var isrcPrefix, upcPrefix string
+	if cfg.ISRCPrefix != "" {
+		var err error
+		isrcPrefix, err = parseISRCPrefix(cfg.ISRCPrefix)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.UPCPrefix != "" {
+		var err error
+		upcPrefix, err = parseUPCPrefix(cfg.UPCPrefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("isrc: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("isrc: couldn't start orm store: %w", err)
+	}
+
+	prefix := ""
+	if cfg.DryRun {
+		prefix = "(dry run) "
+	}
+	if isrcPrefix != "" {
+		n, err := assignISRCs(ctx, cfg, store, isrcPrefix)
+		if err != nil {
+			return err
+		}
+		log.Printf("isrc: %s%d songs assigned\n", prefix, n)
+	}
+	if upcPrefix != "" {
+		n, err := assignUPCs(ctx, cfg, store, upcPrefix)
+		if err != nil {
+			return err
+		}
+		log.Printf("isrc: %s%d albums assigned\n", prefix, n)
+	}
+	return nil
+}
+
+// albumFilter matches the albums that are approved or published and can't be
+// distributed with DistroKid, which assigns its own codes. Albums without
+// targets may be distributed with DistroKid, so they are skipped too.
+func albumFilter() (string, []any) {
+	dk := storage.AllowsTarget(storage.TargetDistrokid)
+	q := fmt.Sprintf("state IN (?, ?) AND distrokid_id = '' AND NOT %s", dk.Query)
+	return q, append([]any{storage.Approved, storage.Used}, dk.Args...)
+}
+
+func assignISRCs(ctx context.Context, cfg *Config, store *storage.Store, prefix string) (int, error) {
+	albums, args := albumFilter()
+	filters := []storage.Filter{
+		storage.Where("songs.isrc = ''"),
+		storage.Where("songs.album_id IN (SELECT id FROM albums WHERE "+albums+")", args...),
+	}
+	if cfg.Type != "" {
+		filters = append(filters, storage.Where("songs.type LIKE ?", cfg.Type))
+	}
+
+	counter := isrcCounter(prefix)
+	var n int
+	var currID string
+	for {
+		size := 100
+		if cfg.Limit > 0 {
+			if n >= cfg.Limit {
+				return n, nil
+			}
+			size = min(size, cfg.Limit-n)
+		}
+		songs, err := store.ListSongs(ctx, 1, size, "songs.id", append(filters, storage.Where("songs.id > ?", currID))...)
+		if err != nil {
+			return n, fmt.Errorf("isrc: couldn't list songs: %w", err)
+		}
+		if len(songs) == 0 {
+			return n, nil
+		}
+		currID = songs[len(songs)-1].ID
+
+		first, err := reserve(ctx, cfg.DryRun, store, counter, n, len(songs))
+		if err != nil {
+			return n, err
+		}
+		for i, s := range songs {
+			code, err := isrcCode(prefix, first+i)
+			if err != nil {
+				return n, err
+			}
+			if cfg.DryRun {
+				log.Printf("isrc: (dry run) song %s %q would be assigned %s\n", s.ID, s.Title, code)
+				n++
+				continue
+			}
+			s.ISRC = code
+			if err := store.SetSong(ctx, s); err != nil {
+				return n, fmt.Errorf("isrc: couldn't set song %s: %w", s.ID, err)
+			}
+			log.Printf("isrc: song %s %q assigned %s\n", s.ID, s.Title, code)
+			n++
+		}
+	}
+}
+
+func assignUPCs(ctx context.Context, cfg *Config, store *storage.Store, prefix string) (int, error) {
+	albums, args := albumFilter()
+	filters := []storage.Filter{
+		storage.Where("upc = ''"),
+		storage.Where(albums, args...),
+	}
+	if cfg.Type != "" {
+		filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
+	}
+
+	counter := fmt.Sprintf("upc/%s/counter", prefix)
+	var n int
+	var currID string
+	for {
+		size := 100
+		if cfg.Limit > 0 {
+			if n >= cfg.Limit {
+				return n, nil
+			}
+			size = min(size, cfg.Limit-n)
+		}
+		albums, err := store.ListAlbums(ctx, 1, size, "id", append(filters, storage.Where("id > ?", currID))...)
+		if err != nil {
+			return n, fmt.Errorf("isrc: couldn't list albums: %w", err)
+		}
+		if len(albums) == 0 {
+			return n, nil
+		}
+		currID = albums[len(albums)-1].ID
+
+		first, err := reserve(ctx, cfg.DryRun, store, counter, n, len(albums))
+		if err != nil {
+			return n, err
+		}
+		for i, a := range albums {
+			code, err := upcCode(prefix, first+i)
+			if err != nil {
+				return n, err
+			}
+			if cfg.DryRun {
+				log.Printf("isrc: (dry run) album %s %q would be assigned %s\n", a.ID, a.FullTitle(), code)
+				n++
+				continue
+			}
+			a.UPC = code
+			if err := store.SetAlbum(ctx, a); err != nil {
+				return n, fmt.Errorf("isrc: couldn't set album %s: %w", a.ID, err)
+			}
+			log.Printf("isrc: album %s %q assigned %s\n", a.ID, a.FullTitle(), code)
+			n++
+		}
+	}
+}
+
+// AssignSongs assigns ISRCs with the prefix (CC-XXX-YY) to the songs that
+// don't have one yet and saves them right away, so the codes reserved are
+// never lost. It returns the number of songs assigned.
+func AssignSongs(ctx context.Context, store *storage.Store, prefix string, songs []*storage.Song) (int, error) {
+	p, err := parseISRCPrefix(prefix)
+	if err != nil {
+		return 0, err
+	}
+	var missing []*storage.Song
+	for _, s := range songs {
+		if s.ISRC == "" {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	first, err := reserve(ctx, false, store, isrcCounter(p), 0, len(missing))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for i, s := range missing {
+		code, err := isrcCode(p, first+i)
+		if err != nil {
+			return n, err
+		}
+		s.ISRC = code
+		if err := store.SetSong(ctx, s); err != nil {
+			return n, fmt.Errorf("isrc: couldn't set song %s: %w", s.ID, err)
+		}
+		log.Printf("isrc: song %s %q assigned %s\n", s.ID, s.Title, code)
+		n++
+	}
+	return n, nil
+}
+
+// isrcCounter returns the setting that stores the last designation code
+// used with the prefix.
+func isrcCounter(prefix string) string {
+	return fmt.Sprintf("isrc/%s/counter", prefix)
+}
+
+// reserve reserves size values of the counter and returns the first one.
+// On dry run nothing is reserved and the values following the current value
+// and the ones already shown are returned.
+func reserve(ctx context.Context, dryRun bool, store *storage.Store, counter string, shown, size int) (int, error) {
+	if dryRun {
+		curr, err := store.GetCounter(ctx, counter)
+		if err != nil {
+			return 0, fmt.Errorf("isrc: couldn't get counter: %w", err)
+		}
+		return curr + shown + 1, nil
+	}
+	first, err := store.ReserveCounter(ctx, counter, size)
+	if err != nil {
+		return 0, fmt.Errorf("isrc: couldn't reserve codes: %w", err)
+	}
+	return first, nil
+}
+
+var isrcPrefixRegex = regexp.MustCompile(`^[A-Z]{2}-?[A-Z0-9]{3}-?[0-9]{2}$`)
+
+// parseISRCPrefix validates the prefix (CC-XXX-YY) and returns it without
+// dashes.
+func parseISRCPrefix(s string) (string, error) {
+	p := strings.ToUpper(strings.TrimSpace(s))
+	if !isrcPrefixRegex.MatchString(p) {
+		return "", fmt.Errorf("isrc: invalid isrc prefix %q, expected CC-XXX-YY", s)
+	}
+	return strings.ReplaceAll(p, "-", ""), nil
+}
+
+// isrcCode returns the ISRC with the prefix and the designation code, in
+// its 12 characters form (e.g. USABC2400001).
+func isrcCode(prefix string, n int) (string, error) {
+	if n < 1 || n > 99999 {
+		return "", fmt.Errorf("isrc: designation code %d out of range for prefix %s", n, prefix)
+	}
+	return fmt.Sprintf("%s%05d", prefix, n), nil
+}
+
+var upcPrefixRegex = regexp.MustCompile(`^[0-9]{6,10}$`)
+
+// parseUPCPrefix validates the GS1 company prefix, between 6 and 10 digits.
+func parseUPCPrefix(s string) (string, error) {
+	p := strings.TrimSpace(s)
+	if !upcPrefixRegex.MatchString(p) {
+		return "", fmt.Errorf("isrc: invalid upc prefix %q, expected 6 to 10 digits", s)
+	}
+	return p, nil
+}
+
+// upcCode returns the 12 digits UPC with the prefix, the item reference and
+// the check digit.
+func upcCode(prefix string, n int) (string, error) {
+	digits := 11 - len(prefix)
+	max := 1
+	for i := 0; i < digits; i++ {
+		max *= 10
+	}
+	if n < 1 || n >= max {
+		return "", fmt.Errorf("isrc: item reference %d out of range for prefix %s", n, prefix)
+	}
+	code := fmt.Sprintf("%s%0*d", prefix, digits, n)
+	return code + upcCheckDigit(code), nil
+}
+
+// upcCheckDigit computes the check digit of the first 11 digits of a UPC:
+// odd positions are weighted by 3 and the digit completes the sum to a
+// multiple of 10.
+func upcCheckDigit(code string) string {
+	var sum int
+	for i, r := range code {
+		d := int(r - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return fmt.Sprint((10 - sum%10) % 10)
+}
@@ -0,0 +1,150 @@
+package isrc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestISRCCode(t *testing.T) {
+	for _, in := range []string{"US-ABC-24", "usabc24", " US-A1C-24 "} {
+		p, err := parseISRCPrefix(in)
+		if err != nil {
+			t.Fatalf("%q: %v", in, err)
+		}
+		if len(p) != 7 {
+			t.Errorf("%q: got %q", in, p)
+		}
+	}
+	for _, in := range []string{"", "US-ABC", "USA-ABC-24", "US-ABC-2024", "US-AB_-24"} {
+		if _, err := parseISRCPrefix(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+
+	got, err := isrcCode("USABC24", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "USABC2400042" {
+		t.Errorf("got %s", got)
+	}
+	for _, n := range []int{0, 100000} {
+		if _, err := isrcCode("USABC24", n); err == nil {
+			t.Errorf("%d: expected error", n)
+		}
+	}
+}
+
+func TestUPCCode(t *testing.T) {
+	if _, err := parseUPCPrefix("12345"); err == nil {
+		t.Error("expected error for short prefix")
+	}
+	if _, err := parseUPCPrefix("12345a"); err == nil {
+		t.Error("expected error for non digits")
+	}
+
+	// 036000291452 is a well known valid UPC
+	got, err := upcCode("0360002", 9145)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "036000291452" {
+		t.Errorf("got %s", got)
+	}
+	if _, err := upcCode("0360002", 10000); err == nil {
+		t.Error("expected error when item references are exhausted")
+	}
+}
+
+func TestAssignSongs(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	songs := []*storage.Song{
+		{ID: "a"},
+		{ID: "b", ISRC: "USABC2400999"},
+		{ID: "c"},
+	}
+	for _, s := range songs {
+		if err := store.SetSong(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := AssignSongs(ctx, store, "invalid", songs); err == nil {
+		t.Fatal("expected error for invalid prefix")
+	}
+	n, err := AssignSongs(ctx, store, "US-ABC-24", songs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d assigned, want 2", n)
+	}
+
+	// Codes are persisted and existing ones are kept
+	for id, want := range map[string]string{"a": "USABC2400001", "b": "USABC2400999", "c": "USABC2400002"} {
+		s, err := store.GetSong(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ISRC != want {
+			t.Errorf("%s: got %q, want %q", id, s.ISRC, want)
+		}
+	}
+	if n, err := AssignSongs(ctx, store, "US-ABC-24", songs); err != nil || n != 0 {
+		t.Errorf("got %d, %v, want nothing assigned", n, err)
+	}
+}
+
+func TestAssignUPCsTargets(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	albums := []*storage.Album{
+		{ID: "any", State: storage.Approved},
+		{ID: "distrokid", State: storage.Approved, Targets: "distrokid"},
+		{ID: "both", State: storage.Approved, Targets: "jamendo,distrokid"},
+		{ID: "jamendo", State: storage.Approved, Targets: "jamendo"},
+	}
+	for _, a := range albums {
+		if err := store.SetAlbum(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := assignUPCs(ctx, &Config{}, store, "0360002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d assigned, want 1", n)
+	}
+	// Only albums that can't be distributed with DistroKid get a code
+	for _, a := range albums {
+		got, err := store.GetAlbum(ctx, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := a.ID == "jamendo"; (got.UPC != "") != want {
+			t.Errorf("%s: got upc %q", a.ID, got.UPC)
+		}
+	}
+}
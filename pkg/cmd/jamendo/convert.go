@@ -0,0 +1,104 @@
+package jamendo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// DefaultConvertConcurrency is the number of songs downloaded and converted
+// at the same time if it isn't set.
+const DefaultConvertConcurrency = 4
+
+// convertSongs downloads the songs and converts them to wav using up to
+// concurrency workers.
+// The wav files are returned in the same order as the songs. If any song
+// fails, the files already converted are removed and the error of the first
+// failed song is returned.
+func convertSongs(ctx context.Context, fs *filestore.Store, songs []*storage.Song, concurrency int) ([]string, error) {
+	wavs := make([]string, len(songs))
+	err := parallel(ctx, len(songs), concurrency, func(ctx context.Context, i int) error {
+		s := songs[i]
+
+		// Download song
+		mp3 := filepath.Join(os.TempDir(), filestore.MP3(s.ID))
+		defer func() { _ = os.Remove(mp3) }()
+		if err := fs.GetMP3(ctx, mp3, *s.GenerationID); err != nil {
+			return fmt.Errorf("publish: couldn't download song %s: %w", s.ID, err)
+		}
+
+		// Convert mp3 to wav
+		wav := filepath.Join(os.TempDir(), fmt.Sprintf("%s.wav", s.ID))
+		if err := ffmpeg.Convert(ctx, mp3, wav); err != nil {
+			_ = os.Remove(wav)
+			return fmt.Errorf("publish: couldn't convert song %s to wav: %w", s.ID, err)
+		}
+		wavs[i] = wav
+		return nil
+	})
+	if err != nil {
+		for _, wav := range wavs {
+			if wav != "" {
+				_ = os.Remove(wav)
+			}
+		}
+		return nil, err
+	}
+	return wavs, nil
+}
+
+// parallel runs fn for the indexes from 0 to n-1 using up to concurrency
+// workers. After the first error the pending indexes are skipped and the
+// context passed to fn is canceled. The error with the lowest index is
+// returned, so the result doesn't depend on the scheduling.
+func parallel(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(ctx, i); err != nil {
+					errs[i] = err
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		// The parent context was canceled before any error
+		return fmt.Errorf("publish: %w", ctx.Err())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package jamendo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	ctx := context.Background()
+
+	// All the indexes are run with bounded concurrency
+	var running, maxRunning int32
+	var lck sync.Mutex
+	done := map[int]bool{}
+	err := parallel(ctx, 10, 3, func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		lck.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		done[i] = true
+		lck.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 10 {
+		t.Errorf("got %d indexes run, want 10", len(done))
+	}
+	if maxRunning > 3 {
+		t.Errorf("got %d concurrent runs, want at most 3", maxRunning)
+	}
+
+	// The error with the lowest index is returned and pending ones are
+	// skipped
+	var calls int32
+	err = parallel(ctx, 100, 4, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		switch i {
+		case 1:
+			time.Sleep(20 * time.Millisecond)
+			return fmt.Errorf("song %d", i)
+		case 2:
+			return fmt.Errorf("song %d", i)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(5 * time.Millisecond):
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "song 1" {
+		t.Errorf("got %v, want song 1", err)
+	}
+	if calls >= 100 {
+		t.Errorf("got %d calls, expected pending indexes to be skipped", calls)
+	}
+
+	// Canceled contexts are reported
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = parallel(canceled, 3, 1, func(ctx context.Context, i int) error {
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context canceled", err)
+	}
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/igolaizola/musikai/pkg/filestore"
 	"github.com/igolaizola/musikai/pkg/jamendo"
 	"github.com/igolaizola/musikai/pkg/sonoteller"
-	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
 	"github.com/igolaizola/musikai/pkg/spotify"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
@@ -33,6 +32,7 @@ type Config struct {
 	Timeout     time.Duration
 	Concurrency int
 	Limit       int
+	Attempts    int
 
 	Auto       bool
 	Account    string
@@ -40,6 +40,24 @@ type Config struct {
 	ArtistID   int
 	Type       string
 	Albums     string
+	MaxGenres  int
+	MaxTags    int
+
+	// AllowMissingAnalysis publishes songs without spotify analysis skipping
+	// the energy, mood and acousticness fields instead of failing.
+	AllowMissingAnalysis bool
+
+	// Resume starts after the last album published in order by a previous
+	// run with the same type.
+	Resume bool
+
+	// ConvertConcurrency is the number of songs downloaded and converted to
+	// wav at the same time (DefaultConvertConcurrency if zero).
+	ConvertConcurrency int
+
+	// LoginRetry reloads the cookies from the cookie store and retries once
+	// if the browser session has expired.
+	LoginRetry bool
 }
 
 // Run launches the song generation process.
@@ -58,13 +76,6 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf(format, args...)
 	}
 
-	if cfg.ArtistID == 0 {
-		return errors.New("publish: artist ID is required")
-	}
-	if cfg.ArtistName == "" {
-		return errors.New("publish: artist name is required")
-	}
-
 	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
 	if err != nil {
 		return fmt.Errorf("publish: couldn't create orm store: %w", err)
@@ -78,32 +89,13 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("download: couldn't create file storage: %w", err)
 	}
 
-	cookieStore := store.NewCookieStore("jamendo", cfg.Account)
-
-	client := jamendo.New(&jamendo.Config{
-		Wait:        5 * time.Second,
-		Debug:       cfg.Debug,
-		Proxy:       cfg.Proxy,
-		CookieStore: cookieStore,
-		Name:        cfg.ArtistName,
-		ID:          cfg.ArtistID,
-	})
-	if err := client.Start(ctx); err != nil {
-		return fmt.Errorf("publish: couldn't authenticate jamendo client: %w", err)
-	}
-
-	browser := jamendo.NewBrowser(&jamendo.BrowserConfig{
-		Wait:        1 * time.Second,
-		Proxy:       cfg.Proxy,
-		CookieStore: cookieStore,
-		BinPath:     cfg.Chrome,
-	})
-	if err := browser.Start(ctx); err != nil {
-		return fmt.Errorf("publish: couldn't start jamendo browser: %w", err)
+	publisher, err := NewPublisher(ctx, cfg, store, fs)
+	if err != nil {
+		return err
 	}
 	defer func() {
-		if err := browser.Stop(); err != nil {
-			log.Printf("publish: couldn't stop jamendo browser: %v\n", err)
+		if err := publisher.Stop(); err != nil {
+			log.Println(err)
 		}
 	}()
 
@@ -136,8 +128,32 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	var albums []*storage.Album
+	// Checkpoint of the albums published in order, so the batch can be
+	// resumed after a crash
+	checkpoint := store.NewCheckpoint("publish-jamendo", cfg.Type)
 	var currID string
+	if cfg.Resume {
+		currID, err = checkpoint.Get(ctx)
+		if err != nil {
+			return fmt.Errorf("publish: couldn't get checkpoint: %w", err)
+		}
+		if currID != "" {
+			log.Printf("publish: resuming after album %s\n", currID)
+		}
+	}
+	var completed bool
+	defer func() {
+		// Start the next batch from the beginning once all albums are done
+		wg.Wait()
+		if !completed {
+			return
+		}
+		if err := checkpoint.Finish(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	var albums []*storage.Album
 	for {
 		select {
 		case <-ctx.Done():
@@ -172,6 +188,7 @@ func Run(ctx context.Context, cfg *Config) error {
 				storage.Where("state = ?", storage.Used),
 				storage.Where("jamendo_id = ?", ""),
 				storage.Where("id > ?", currID),
+				storage.AllowsTarget(storage.TargetJamendo),
 			}
 			if cfg.Type != "" {
 				filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
@@ -185,27 +202,32 @@ func Run(ctx context.Context, cfg *Config) error {
 			if len(albums) == 0 {
 				// Get a albums from the database.
 				var err error
-				albums, err = store.ListAlbums(ctx, 1, 100, "", filters...)
+				albums, err = store.ListAlbums(ctx, 1, 100, "id", filters...)
 				if err != nil {
 					return fmt.Errorf("process: couldn't get album from database: %w", err)
 				}
 				if len(albums) == 0 {
+					completed = true
 					return errors.New("process: no albums to process")
 				}
 				currID = albums[len(albums)-1].ID
 			}
 			album := albums[0]
 			albums = albums[1:]
+			checkpoint.Start(album.ID)
 
 			// Launch publish in a goroutine
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 				debug("publish: start %s %s", album.ID, album.FullTitle())
-				err := publish(ctx, browser, client, store, fs, album)
+				err := publisher.Publish(ctx, album)
 				if err != nil {
 					log.Println(err)
 				}
+				if err := checkpoint.Done(ctx, album.ID, err); err != nil {
+					log.Println(err)
+				}
 				debug("publish: end %s%s", album.ID, album.FullTitle())
 				errC <- err
 			}()
@@ -213,7 +235,163 @@ func Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+// Publisher publishes albums to jamendo.
+type Publisher struct {
+	client               *jamendo.Client
+	browser              *jamendo.Browser
+	store                *storage.Store
+	fs                   *filestore.Store
+	maxGenres            int
+	maxTags              int
+	allowMissingAnalysis bool
+	convertConcurrency   int
+}
+
+// NewPublisher authenticates the jamendo client and starts the browser used
+// to publish albums.
+func NewPublisher(ctx context.Context, cfg *Config, store *storage.Store, fs *filestore.Store) (*Publisher, error) {
+	if cfg.ArtistID == 0 {
+		return nil, errors.New("publish: artist ID is required")
+	}
+	if cfg.ArtistName == "" {
+		return nil, errors.New("publish: artist name is required")
+	}
+
+	cookieStore := store.NewCookieStore("jamendo", cfg.Account)
+
+	client := jamendo.New(&jamendo.Config{
+		Wait:        5 * time.Second,
+		Debug:       cfg.Debug,
+		Proxy:       cfg.Proxy,
+		CookieStore: cookieStore,
+		Name:        cfg.ArtistName,
+		ID:          cfg.ArtistID,
+		Attempts:    cfg.Attempts,
+	})
+	if err := client.Start(ctx); err != nil {
+		return nil, fmt.Errorf("publish: couldn't authenticate jamendo client: %w", err)
+	}
+
+	browser := jamendo.NewBrowser(&jamendo.BrowserConfig{
+		Wait:        1 * time.Second,
+		Proxy:       cfg.Proxy,
+		CookieStore: cookieStore,
+		BinPath:     cfg.Chrome,
+		LoginRetry:  cfg.LoginRetry,
+	})
+	if err := browser.Start(ctx); err != nil {
+		return nil, fmt.Errorf("publish: couldn't start jamendo browser: %w", err)
+	}
+	p := NewDryRunPublisher(cfg, store, fs)
+	p.client = client
+	p.browser = browser
+	return p, nil
+}
+
+// NewDryRunPublisher returns a publisher that only prepares albums, without
+// starting the jamendo client and browser, so Publish can't be used.
+func NewDryRunPublisher(cfg *Config, store *storage.Store, fs *filestore.Store) *Publisher {
+	maxGenres := cfg.MaxGenres
+	if maxGenres <= 0 {
+		maxGenres = 2
+	}
+	maxTags := cfg.MaxTags
+	if maxTags <= 0 {
+		maxTags = 2
+	}
+	convertConcurrency := cfg.ConvertConcurrency
+	if convertConcurrency <= 0 {
+		convertConcurrency = DefaultConvertConcurrency
+	}
+	return &Publisher{
+		store:                store,
+		fs:                   fs,
+		maxGenres:            maxGenres,
+		maxTags:              maxTags,
+		allowMissingAnalysis: cfg.AllowMissingAnalysis,
+		convertConcurrency:   convertConcurrency,
+	}
+}
+
+// Stop stops the jamendo browser.
+func (p *Publisher) Stop() error {
+	if p.browser == nil {
+		return nil
+	}
+	if err := p.browser.Stop(); err != nil {
+		return fmt.Errorf("publish: couldn't stop jamendo browser: %w", err)
+	}
+	return nil
+}
+
+// Publish publishes the album to jamendo and stores the jamendo IDs.
+func (p *Publisher) Publish(ctx context.Context, album *storage.Album) error {
+	return publish(ctx, p.browser, p.client, p.store, p.fs, album, p.maxGenres, p.maxTags, p.allowMissingAnalysis, p.convertConcurrency)
+}
+
+// Prepare downloads the files of the album and returns the jamendo album
+// data validated, without publishing it.
+func (p *Publisher) Prepare(ctx context.Context, album *storage.Album) (*jamendo.Album, error) {
+	jmAlbum, _, err := prepare(ctx, p.store, p.fs, album, p.maxGenres, p.maxTags, p.allowMissingAnalysis, p.convertConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if err := jmAlbum.Validate(); err != nil {
+		return jmAlbum, err
+	}
+	return jmAlbum, nil
+}
+
+func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *storage.Store, fs *filestore.Store, album *storage.Album, maxGenres, maxTags int, allowMissingAnalysis bool, convertConcurrency int) error {
+	jmAlbum, songs, err := prepare(ctx, store, fs, album, maxGenres, maxTags, allowMissingAnalysis, convertConcurrency)
+	if err != nil {
+		return err
+	}
+
+	// Publish album
+	pub, err := b.Publish(ctx, jmAlbum, false)
+	if err != nil {
+		return fmt.Errorf("publish: couldn't jamendo publish %s: %w", album.ID, err)
+	}
+	failed := map[string]struct{}{}
+	for i, song := range jmAlbum.Songs {
+		songID := pub.SongIDs[i]
+		storeID := songs[i].ID
+		if err := c.UpdateTrack(ctx, pub.AlbumID, jmAlbum.Title, jmAlbum.ReleaseDate, song, i+1, songID); err != nil {
+			failed[storeID] = struct{}{}
+			log.Printf("❌ jamendo: couldn't update track `%s` %s (%q #%d %q): %v\n", storeID, songID, jmAlbum.Title, i+1, song.Title, err)
+			continue
+		}
+	}
+	if len(failed) == len(jmAlbum.Songs) {
+		return fmt.Errorf("publish: all songs failed to publish %s", album.ID)
+	}
+
+	// Update songs
+	for i, s := range songs {
+		if _, ok := failed[s.ID]; ok {
+			continue
+		}
+		s.JamendoID = pub.SongIDs[i]
+		if err := store.SetSong(ctx, s); err != nil {
+			return fmt.Errorf("publish: couldn't set song %s %s: %w", s.ID, pub.SongIDs, err)
+		}
+	}
+
+	// Update album
+	album.JamendoID = pub.AlbumID
+	album.JamendoAt = time.Now().UTC()
+	album.State = storage.Used
+	if err := store.SetAlbum(ctx, album); err != nil {
+		return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, pub.AlbumID, err)
+	}
+
+	return nil
+}
+
+// prepare downloads the cover and songs of the album and returns the jamendo
+// album data along with the songs in track order.
+func prepare(ctx context.Context, store *storage.Store, fs *filestore.Store, album *storage.Album, maxGenres, maxTags int, allowMissingAnalysis bool, convertConcurrency int) (*jamendo.Album, []*storage.Song, error) {
 	// Get songs for album
 	filter := []storage.Filter{
 		storage.Where("album_id = ?", album.ID),
@@ -221,14 +399,14 @@ func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *
 	}
 	songs, err := store.ListSongs(ctx, 1, 100, "", filter...)
 	if err != nil {
-		return fmt.Errorf("publish: couldn't get songs: %w", err)
+		return nil, nil, fmt.Errorf("publish: couldn't get songs: %w", err)
 	}
 
 	// Download cover
 	name := filestore.JPG(album.ID)
 	cover := filepath.Join(os.TempDir(), name)
 	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
-		return fmt.Errorf("publish: couldn't download cover: %w", err)
+		return nil, nil, fmt.Errorf("publish: couldn't download cover: %w", err)
 	}
 
 	var genres []string
@@ -253,19 +431,15 @@ func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *
 		return songs[i].Order < songs[j].Order
 	})
 
+	// Download the songs and convert them to wav
+	wavs, err := convertSongs(ctx, fs, songs, convertConcurrency)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Create jamendo song data
-	for _, s := range songs {
-		// Download song
-		name := filestore.MP3(s.ID)
-		mp3 := filepath.Join(os.TempDir(), name)
-		if err := fs.GetMP3(ctx, mp3, *s.GenerationID); err != nil {
-			return fmt.Errorf("publish: couldn't download song: %w", err)
-		}
-		// Convert mp3 to wav
-		wav := filepath.Join(os.TempDir(), fmt.Sprintf("%s.wav", s.ID))
-		if err := ffmpeg.Convert(ctx, mp3, wav); err != nil {
-			return fmt.Errorf("publish: couldn't convert mp3 to wav: %w", err)
-		}
+	for i, s := range songs {
+		wav := wavs[i]
 
 		// TODO: initialize with album genres
 		var genres []string
@@ -276,7 +450,7 @@ func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *
 		var analysis sonoteller.Analysis
 		if s.Classification != "" {
 			if err := json.Unmarshal([]byte(s.Classification), &analysis); err != nil {
-				return fmt.Errorf("publish: couldn't unmarshal classification: %w", err)
+				return nil, nil, fmt.Errorf("publish: couldn't unmarshal classification: %w", err)
 			}
 			m := analysis.Music
 			tempo = float32(m.BPM)
@@ -311,18 +485,27 @@ func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *
 			description = s.Style
 		}
 
+		// Add the musical key if it was detected
+		if s.Generation.Key != "" {
+			if description != "" {
+				description += ". "
+			}
+			description += "Key: " + s.Generation.Key
+		}
+
 		var spotifyAnalysis spotify.Analysis
 		if s.SpotifyAnalysis != "" {
 			if err := json.Unmarshal([]byte(s.SpotifyAnalysis), &spotifyAnalysis); err != nil {
-				return fmt.Errorf("publish: couldn't unmarshal spotify analysis: %w", err)
+				return nil, nil, fmt.Errorf("publish: couldn't unmarshal spotify analysis: %w", err)
 			}
 		}
 
-		if len(genres) > 2 {
-			genres = genres[:2]
+		// Keep the highest ranked genres and tags
+		if len(genres) > maxGenres {
+			genres = genres[:maxGenres]
 		}
-		if len(tags) > 2 {
-			tags = tags[:2]
+		if len(tags) > maxTags {
+			tags = tags[:maxTags]
 		}
 
 		dkSong := &jamendo.Song{
@@ -334,52 +517,16 @@ func publish(ctx context.Context, b *jamendo.Browser, c *jamendo.Client, store *
 			Tags:         tags,
 			BPM:          tempo,
 			Description:  description,
+			Credits:      s.Credits,
 			Energy:       spotifyAnalysis.Energy,
 			Mood:         spotifyAnalysis.Valence,
 			Acousticness: spotifyAnalysis.Acousticness,
+			NoAnalysis:   allowMissingAnalysis && s.SpotifyAnalysis == "",
 		}
 		jmAlbum.Songs = append(jmAlbum.Songs, dkSong)
 	}
 
-	// Publish album
-	pub, err := b.Publish(ctx, jmAlbum, false)
-	if err != nil {
-		return fmt.Errorf("publish: couldn't jamendo publish %s: %w", album.ID, err)
-	}
-	failed := map[string]struct{}{}
-	for i, song := range jmAlbum.Songs {
-		songID := pub.SongIDs[i]
-		storeID := songs[i].ID
-		if err := c.UpdateTrack(ctx, pub.AlbumID, jmAlbum.Title, jmAlbum.ReleaseDate, song, i+1, songID); err != nil {
-			failed[storeID] = struct{}{}
-			log.Printf("❌ jamendo: couldn't update track `%s` %s (%q #%d %q): %v\n", storeID, songID, jmAlbum.Title, i+1, song.Title, err)
-			continue
-		}
-	}
-	if len(failed) == len(jmAlbum.Songs) {
-		return fmt.Errorf("publish: all songs failed to publish %s", album.ID)
-	}
-
-	// Update songs
-	for i, s := range songs {
-		if _, ok := failed[s.ID]; ok {
-			continue
-		}
-		s.JamendoID = pub.SongIDs[i]
-		if err := store.SetSong(ctx, s); err != nil {
-			return fmt.Errorf("publish: couldn't set song %s %s: %w", s.ID, pub.SongIDs, err)
-		}
-	}
-
-	// Update album
-	album.JamendoID = pub.AlbumID
-	album.JamendoAt = time.Now().UTC()
-	album.State = storage.Used
-	if err := store.SetAlbum(ctx, album); err != nil {
-		return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, pub.AlbumID, err)
-	}
-
-	return nil
+	return jmAlbum, songs, nil
 }
 
 func sortTags(ms ...map[string]int) []string {
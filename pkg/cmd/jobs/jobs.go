@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/music"
+	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/igolaizola/musikai/pkg/suno"
+	"github.com/igolaizola/musikai/pkg/udio"
+)
+
+type Config struct {
+	Debug    bool
+	DBType   string
+	DBConn   string
+	Proxy    string
+	Account  string
+	Provider string
+	MinAge   time.Duration
+	Cancel   bool
+}
+
+// Run lists the in-flight generations of the provider account and optionally
+// cancels them.
+func Run(ctx context.Context, cfg *Config) error {
+	if cfg.Account == "" {
+		return errors.New("jobs: missing account")
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("jobs: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("jobs: couldn't start orm store: %w", err)
+	}
+
+	var generator interface {
+		music.Generator
+		music.JobManager
+	}
+	switch cfg.Provider {
+	case "suno":
+		generator = suno.New(&suno.Config{
+			Wait:        4 * time.Second,
+			Debug:       cfg.Debug,
+			Proxy:       cfg.Proxy,
+			CookieStore: store.NewCookieStore("suno", cfg.Account),
+		})
+	case "udio":
+		generator, err = udio.New(&udio.Config{
+			Wait:        4 * time.Second,
+			Debug:       cfg.Debug,
+			Proxy:       cfg.Proxy,
+			CookieStore: store.NewCookieStore("udio", cfg.Account),
+			NoCaptcha:   true,
+		})
+		if err != nil {
+			return fmt.Errorf("jobs: couldn't create udio client: %w", err)
+		}
+	default:
+		return fmt.Errorf("jobs: unknown provider: %s", cfg.Provider)
+	}
+	if err := generator.Start(ctx); err != nil {
+		return fmt.Errorf("jobs: couldn't start %s client: %w", cfg.Provider, err)
+	}
+	defer func() {
+		if err := generator.Stop(ctx); err != nil {
+			log.Printf("jobs: couldn't stop %s client: %v\n", cfg.Provider, err)
+		}
+	}()
+
+	jobs, err := generator.Jobs(ctx)
+	if err != nil {
+		return fmt.Errorf("jobs: couldn't list jobs: %w", err)
+	}
+
+	var ids []string
+	for _, j := range jobs {
+		age := time.Since(j.CreatedAt)
+		if age < cfg.MinAge {
+			continue
+		}
+		fmt.Printf("%s | %s | %s | %s\n", j.ID, j.Status, age.Round(time.Second), j.Title)
+		ids = append(ids, j.ID)
+	}
+	log.Printf("jobs: %d in-flight jobs found\n", len(ids))
+
+	if !cfg.Cancel || len(ids) == 0 {
+		return nil
+	}
+	if err := generator.Cancel(ctx, ids); err != nil {
+		return fmt.Errorf("jobs: couldn't cancel jobs: %w", err)
+	}
+	log.Printf("jobs: %d jobs cancelled\n", len(ids))
+	return nil
+}
@@ -0,0 +1,137 @@
+package mixdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type Config struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	FSType string
+	FSConn string
+	Proxy  string
+
+	ID     string
+	Output string
+	Fade   time.Duration
+	Format string
+}
+
+func (c *Config) validate() error {
+	if c.ID == "" {
+		return errors.New("mixdown: id is empty")
+	}
+	switch c.Format {
+	case "mp3", "wav", "flac":
+	default:
+		return fmt.Errorf("mixdown: unsupported format %s", c.Format)
+	}
+	if c.Fade < 0 {
+		return fmt.Errorf("mixdown: invalid fade %s", c.Fade)
+	}
+	return nil
+}
+
+// Run joins the songs of an album, in order, into a single file crossfading
+// consecutive songs. The file is written to the output folder named by the
+// album ID.
+func Run(ctx context.Context, cfg *Config) error {
+	log.Printf("mixdown: started\n")
+	defer func() {
+		log.Printf("mixdown: ended\n")
+	}()
+
+	debug := func(format string, args ...any) {
+		if !cfg.Debug {
+			return
+		}
+		format += "\n"
+		log.Printf(format, args...)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
+		return fmt.Errorf("mixdown: couldn't create output directory: %w", err)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("mixdown: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("mixdown: couldn't start orm store: %w", err)
+	}
+
+	fs, err := filestore.New(cfg.FSType, cfg.FSConn, cfg.Proxy, cfg.Debug, store)
+	if err != nil {
+		return fmt.Errorf("mixdown: couldn't create file storage: %w", err)
+	}
+
+	album, err := store.GetAlbum(ctx, cfg.ID)
+	if err != nil {
+		return fmt.Errorf("mixdown: couldn't get album: %w", err)
+	}
+	songs, err := store.ListSongs(ctx, 1, 1000, "\"order\" asc", storage.Where("album_id = ?", album.ID))
+	if err != nil {
+		return fmt.Errorf("mixdown: couldn't list songs: %w", err)
+	}
+	if len(songs) == 0 {
+		return fmt.Errorf("mixdown: album %s has no songs", album.ID)
+	}
+
+	// The fade can't be longer than any of the songs
+	if len(songs) > 1 {
+		for _, s := range songs {
+			if s.Generation == nil {
+				continue
+			}
+			d := time.Duration(float64(s.Generation.Duration) * float64(time.Second))
+			if d > 0 && cfg.Fade >= d {
+				return fmt.Errorf("mixdown: fade %s is longer than song %s (%s)", cfg.Fade, s.ID, d)
+			}
+		}
+	}
+
+	// Download the masters to a temporary directory
+	tmp, err := os.MkdirTemp("", "musikai-mixdown-")
+	if err != nil {
+		return fmt.Errorf("mixdown: couldn't create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	var inputs []string
+	for i, s := range songs {
+		if s.GenerationID == nil || s.Generation == nil {
+			return fmt.Errorf("mixdown: song %s has no generation", s.ID)
+		}
+		id := *s.GenerationID
+		ext := s.Generation.Format
+		input := filepath.Join(tmp, fmt.Sprintf("%02d-%s", i+1, filestore.Audio(id, ext)))
+		debug("mixdown: start download %s", id)
+		if err := fs.GetAudio(ctx, input, id, ext); err != nil {
+			return fmt.Errorf("mixdown: couldn't download master audio %s: %w", id, err)
+		}
+		debug("mixdown: end download %s", id)
+		inputs = append(inputs, input)
+	}
+
+	output := filepath.Join(cfg.Output, fmt.Sprintf("%s.%s", album.ID, cfg.Format))
+	if err := ffmpeg.Crossfade(ctx, inputs, output, cfg.Fade); err != nil {
+		return fmt.Errorf("mixdown: couldn't mix album %s: %w", album.ID, err)
+	}
+	log.Printf("mixdown: album %s (%s) mixed to %s (%d songs)\n", album.ID, album.Title, output, len(songs))
+	return nil
+}
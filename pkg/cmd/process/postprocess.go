@@ -0,0 +1,61 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// postProcessor runs an external command on the mastered audio, to apply
+// custom processing that isn't available in-tree.
+type postProcessor struct {
+	args []string
+}
+
+// newPostProcessor parses the command, whose arguments are separated by
+// spaces and must include the {input} and {output} placeholders.
+func newPostProcessor(cmd string) (*postProcessor, error) {
+	args := strings.Fields(cmd)
+	if len(args) == 0 {
+		return nil, errors.New("process: empty post process command")
+	}
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "{input}") || !strings.Contains(joined, "{output}") {
+		return nil, fmt.Errorf("process: post process command %q must include {input} and {output}", cmd)
+	}
+	return &postProcessor{args: args}, nil
+}
+
+// Run runs the command and replaces the file with its output.
+func (p *postProcessor) Run(ctx context.Context, path string) error {
+	ext := filepath.Ext(path)
+	output := fmt.Sprintf("%s.post%s", strings.TrimSuffix(path, ext), ext)
+	defer func() { _ = os.Remove(output) }()
+
+	var args []string
+	for _, a := range p.args {
+		a = strings.ReplaceAll(a, "{input}", path)
+		a = strings.ReplaceAll(a, "{output}", output)
+		args = append(args, a)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("process: post process command failed: %w: %s", err, data)
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		return fmt.Errorf("process: post process command didn't create output: %w", err)
+	}
+	if info.Size() == 0 {
+		return errors.New("process: post process command created an empty output")
+	}
+	if err := os.Rename(output, path); err != nil {
+		return fmt.Errorf("process: couldn't replace audio with post processed one: %w", err)
+	}
+	return nil
+}
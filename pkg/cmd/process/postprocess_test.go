@@ -0,0 +1,55 @@
+package process
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostProcessor(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	write := func() {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, cmd := range []string{"", "cp {input} out.mp3", "cp in.mp3 {output}"} {
+		if _, err := newPostProcessor(cmd); err == nil {
+			t.Errorf("%q: expected error", cmd)
+		}
+	}
+
+	// The output replaces the input
+	if err := os.WriteFile(path, []byte("b\na\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newPostProcessor("sort -o {output} {input}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "a\nb\n" {
+		t.Errorf("got %q, %v", b, err)
+	}
+
+	// Failed commands and missing outputs are errors and the input is kept
+	for _, cmd := range []string{"false {input} {output}", "true {input} {output}", "touch {output} {input}"} {
+		p, err := newPostProcessor(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write()
+		if err := p.Run(ctx, path); err == nil {
+			t.Errorf("%q: expected error", cmd)
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != "original" {
+			t.Errorf("%q: got %q, %v", cmd, b, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/igolaizola/musikai/pkg/sound"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// loudness is the loudness measured for the master preview.
+type loudness struct {
+	lufs     float64
+	truePeak float64
+	lra      string
+	levels   sound.Levels
+}
+
+// Preview masters the generation audio into a temporary file and logs the
+// loudness of the original and the mastered audio.
+// Nothing is stored in the database or the file storage.
+func (p *Processor) Preview(ctx context.Context, gen *storage.Generation) error {
+	b, err := download(ctx, p.client, gen.Audio)
+	if err != nil {
+		return fmt.Errorf("process: couldn't download gen audio: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "musikai-preview-")
+	if err != nil {
+		return fmt.Errorf("process: couldn't create temporary directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	original := filepath.Join(dir, "original.mp3")
+	if err := os.WriteFile(original, b, 0644); err != nil {
+		return fmt.Errorf("process: couldn't save gen audio: %w", err)
+	}
+
+	// Master the audio
+	mastered := filepath.Join(dir, "mastered.mp3")
+	p.debug("process: start master preview %s", gen.ID)
+	if err := func() error {
+		// Lock the phase limiter to avoid concurrent calls
+		p.phLock.Lock()
+		defer p.phLock.Unlock()
+		if ctx.Err() != nil {
+			return fmt.Errorf("process: %w", ctx.Err())
+		}
+		if err := p.ph.Master(ctx, original, mastered); err != nil {
+			return fmt.Errorf("process: couldn't master gen: %w", err)
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	if p.cfg.TargetLUFS != 0 {
+		if _, err := ffmpeg.Loudnorm(ctx, mastered, mastered, p.cfg.TargetLUFS, targetTP, targetLRA); err != nil {
+			return fmt.Errorf("process: couldn't normalize loudness: %w", err)
+		}
+	}
+	p.debug("process: end master preview %s", gen.ID)
+
+	// Compare the original and the mastered audio
+	in, err := measure(ctx, original)
+	if err != nil {
+		return err
+	}
+	out, err := measure(ctx, mastered)
+	if err != nil {
+		return err
+	}
+	log.Printf("process: master preview %s (%s): lufs %.2f -> %.2f, true peak %.2f -> %.2f dBTP, peak %.2f -> %.2f dBFS, dynamic range %.2f -> %.2f dB, lra %s -> %s LU\n",
+		gen.ID, gen.Song.Type, in.lufs, out.lufs, in.truePeak, out.truePeak,
+		in.levels.Peak, out.levels.Peak, in.levels.DynamicRange, out.levels.DynamicRange, in.lra, out.lra)
+	return nil
+}
+
+// measure obtains the loudness using ffmpeg and the sample levels using the
+// analyzer.
+func measure(ctx context.Context, path string) (*loudness, error) {
+	stats, err := ffmpeg.Loudness(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't measure loudness: %w", err)
+	}
+	lufs, err := stats.LUFS()
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't get loudness: %w", err)
+	}
+	tp, err := stats.TruePeak()
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't get true peak: %w", err)
+	}
+	analyzer, err := sound.NewAnalyzer(path)
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't create analyzer: %w", err)
+	}
+	return &loudness{
+		lufs:     lufs,
+		truePeak: tp,
+		lra:      stats.InputLRA,
+		levels:   analyzer.Levels(),
+	}, nil
+}
@@ -3,23 +3,30 @@ package process
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/maxruntime"
+	"github.com/igolaizola/musikai/pkg/pprof"
+	"github.com/igolaizola/musikai/pkg/progress"
+	"github.com/igolaizola/musikai/pkg/queue"
+	"github.com/igolaizola/musikai/pkg/shutdown"
 	"github.com/igolaizola/musikai/pkg/sound"
 	"github.com/igolaizola/musikai/pkg/sound/aubio"
 	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
 	"github.com/igolaizola/musikai/pkg/sound/phaselimiter"
 	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/igolaizola/musikai/pkg/timing"
+	"github.com/oklog/ulid/v2"
 )
 
 type Config struct {
@@ -31,22 +38,74 @@ type Config struct {
 	Timeout     time.Duration
 	Concurrency int
 	Limit       int
+	MaxRuntime  time.Duration
+	Profile     bool
+	PprofAddr   string
 	Proxy       string
 
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	// ProgressInterval is the time between progress logs.
+	// Zero disables them.
+	ProgressInterval time.Duration
+
 	Type         string
 	Reprocess    bool
 	SkipMaster   bool
 	Docker       bool
 	ShortFadeOut time.Duration
 	LongFadeOut  time.Duration
+	// NoFadeOnEnding skips the fade out of songs with a natural ending,
+	// only their trailing silence is cut.
+	NoFadeOnEnding bool
+	// FadeIn is the duration of the fade in applied at the start of the
+	// songs, zero disables it.
+	FadeIn time.Duration
+	// TrimLead removes the silence at the start of the songs, unless it is
+	// longer than TrimLeadMax (2s if zero), which is likely an intentional
+	// quiet intro.
+	TrimLead    bool
+	TrimLeadMax time.Duration
+	// PostProcessCmd is an external command run on the mastered audio, with
+	// {input} and {output} placeholders. Empty disables it.
+	PostProcessCmd string
+	// TargetLUFS normalizes the mastered audio to this integrated loudness.
+	// Zero disables the normalization.
+	TargetLUFS float64
+	// Format of the processed audio (mp3, flac or wav).
+	// Lossless formats are stored along with the mp3.
+	Format string
+
+	// Since limits the scan to generations created within this duration.
+	// Zero means a full scan.
+	Since time.Duration
+
+	// WaveThemes is a yaml or json file with the look of the wave images by
+	// song type.
+	WaveThemes string
+
+	// MasterPreview masters into temporary files and logs the loudness of the
+	// original and mastered audio, without storing anything.
+	MasterPreview bool
+
+	// AutoSelect selects the best generation of each song once all of them
+	// are processed, using SelectCriteria, a comma separated list of ends,
+	// flags and longest sorted by priority.
+	AutoSelect     bool
+	SelectCriteria string
 }
 
 // Run launches the gen generation process.
 func Run(ctx context.Context, cfg *Config) error {
 	var iteration int
 	action := "process"
-	if cfg.Reprocess {
+	switch {
+	case cfg.Reprocess:
 		action = "reprocess"
+	case cfg.MasterPreview:
+		action = "master preview"
 	}
 	log.Printf("process: %s started\n", action)
 	defer func() {
@@ -61,30 +120,9 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf(format, args...)
 	}
 
-	if cfg.ShortFadeOut == 0 {
-		return errors.New("process: short fade out is required")
-	}
-	if cfg.LongFadeOut == 0 {
-		return errors.New("process: long fade out is required")
-	}
-	if cfg.ShortFadeOut > cfg.LongFadeOut {
-		return errors.New("process: short fade out must be less than long fade out")
-	}
-
-	if _, err := aubio.Version(ctx); err != nil {
-		return fmt.Errorf("process: couldn't get aubio version: %w", err)
-	}
-
-	var ph *phaselimiter.PhaseLimiter
-	master := !cfg.SkipMaster
-	if master {
-		ph = phaselimiter.New(&phaselimiter.Config{
-			Docker: cfg.Docker,
-		})
-		if !cfg.Docker {
-			if _, err := ph.Version(ctx); err != nil {
-				return fmt.Errorf("process: couldn't get phaselimiter version: %w", err)
-			}
+	if cfg.PprofAddr != "" {
+		if err := pprof.Serve(ctx, cfg.PprofAddr); err != nil {
+			return fmt.Errorf("process: %w", err)
 		}
 	}
 
@@ -96,23 +134,10 @@ func Run(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("process: couldn't start orm store: %w", err)
 	}
 
-	fs, err := filestore.New(cfg.FSType, cfg.FSConn, cfg.Proxy, cfg.Debug, store)
+	prof := timing.New(cfg.Profile)
+	processor, err := NewProcessor(ctx, cfg, store, prof)
 	if err != nil {
-		return fmt.Errorf("download: couldn't create file storage: %w", err)
-	}
-	var tgLock sync.Mutex
-
-	httpClient := &http.Client{
-		Timeout: 2 * time.Minute,
-	}
-	if cfg.Proxy != "" {
-		u, err := url.Parse(cfg.Proxy)
-		if err != nil {
-			return fmt.Errorf("invalid proxy URL: %w", err)
-		}
-		httpClient.Transport = &http.Transport{
-			Proxy: http.ProxyURL(u),
-		}
+		return err
 	}
 
 	// Print time stats
@@ -120,9 +145,10 @@ func Run(ctx context.Context, cfg *Config) error {
 	defer func() {
 		total := time.Since(start)
 		log.Printf("process: total time %s, average time %s\n", total, total/time.Duration(iteration))
+		prof.Log("process")
 	}()
 
-	nErr := 0
+	streak := errstreak.New("process", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -132,9 +158,10 @@ func Run(ctx context.Context, cfg *Config) error {
 	defer ticker.Stop()
 
 	// Concurrency settings
+	// Zero means one process per CPU, as this step is CPU bound
 	concurrency := cfg.Concurrency
 	if concurrency == 0 {
-		concurrency = 1
+		concurrency = runtime.NumCPU()
 	}
 	errC := make(chan error, concurrency)
 	defer close(errC)
@@ -144,8 +171,36 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	// Phase limiter lock to avoid concurrent calls
-	var phLock sync.Mutex
+	maxRuntime, stopMaxRuntime := maxruntime.After(cfg.MaxRuntime)
+	defer stopMaxRuntime()
+
+	// Base filters
+	baseFilters := []storage.Filter{
+		storage.Where("processed = ?", cfg.Reprocess),
+	}
+	if cfg.Type != "" {
+		baseFilters = append(baseFilters, storage.Where("type LIKE ?", cfg.Type))
+	}
+	if cfg.Since > 0 {
+		// IDs are ULIDs, so filtering by a minimum ID scans only recent rows
+		// using the primary key.
+		var sinceID ulid.ULID
+		if err := sinceID.SetTime(ulid.Timestamp(time.Now().Add(-cfg.Since))); err != nil {
+			return fmt.Errorf("process: couldn't set since time: %w", err)
+		}
+		baseFilters = append(baseFilters, storage.Where("generations.id > ?", sinceID.String()))
+	}
+
+	// Progress of the pending generations
+	reporter := progress.New("process", cfg.ProgressInterval, cfg.Limit, func(ctx context.Context) (int64, error) {
+		return store.CountGenerations(ctx, baseFilters...)
+	})
+	stopProgress := reporter.Start(ctx)
+	defer func() {
+		// Log the last progress once the running items are done
+		wg.Wait()
+		stopProgress()
+	}()
 
 	var gens []*storage.Generation
 	var currID string
@@ -155,16 +210,21 @@ func Run(ctx context.Context, cfg *Config) error {
 			return fmt.Errorf("process: %w", ctx.Err())
 		case <-ticker.C:
 			return nil
-		case err := <-errC:
+		case <-maxRuntime:
+			remaining, err := store.CountGenerations(ctx, baseFilters...)
 			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
+				log.Println(err)
 			}
-
+			log.Printf("process: stopped due to max runtime with %d items remaining\n", remaining)
+			return fmt.Errorf("process: %w", maxruntime.ErrReached)
+		case err := <-errC:
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("process: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if shutdown.Requested(ctx) {
+				log.Printf("process: shutdown requested, waiting for in-flight work\n")
+				return fmt.Errorf("process: %w", shutdown.ErrStopped)
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -177,13 +237,9 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 
 			// Get next generation
-			filters := []storage.Filter{
-				storage.Where("processed = ?", cfg.Reprocess),
+			filters := append([]storage.Filter{
 				storage.Where("generations.id > ?", currID),
-			}
-			if cfg.Type != "" {
-				filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
-			}
+			}, baseFilters...)
 
 			// Get next image
 			if len(gens) == 0 {
@@ -193,7 +249,7 @@ func Run(ctx context.Context, cfg *Config) error {
 					return fmt.Errorf("process: couldn't get generation from database: %w", err)
 				}
 				if len(gens) == 0 {
-					return errors.New("process: no generations to process")
+					return fmt.Errorf("process: no generations to process: %w", queue.ErrEmpty)
 				}
 				currID = gens[len(gens)-1].ID
 			}
@@ -209,14 +265,18 @@ func Run(ctx context.Context, cfg *Config) error {
 				defer wg.Done()
 				debug("process: start %s", gen.ID)
 				var err error
-				if cfg.Reprocess {
-					err = reprocess(ctx, gen, debug, store, fs)
-				} else {
-					err = process(ctx, gen, debug, store, fs, &tgLock, httpClient, ph, &phLock, cfg.ShortFadeOut, cfg.LongFadeOut, master)
+				switch {
+				case cfg.Reprocess:
+					err = processor.Reprocess(ctx, gen)
+				case cfg.MasterPreview:
+					err = processor.Preview(ctx, gen)
+				default:
+					err = processor.Process(ctx, gen)
 				}
 				if err != nil {
 					log.Println(err)
 				}
+				reporter.Done(err)
 				debug("process: end %s", gen.ID)
 				errC <- err
 			}()
@@ -232,11 +292,18 @@ type flags struct {
 	BPMN     bool  `json:"bpm_n,omitempty"`
 }
 
-func process(ctx context.Context, gen *storage.Generation, debug func(string, ...any), store *storage.Store, fs *filestore.Store, tgLock *sync.Mutex,
-	client *http.Client, ph *phaselimiter.PhaseLimiter, phLock *sync.Mutex, shortFadeOut, longFadeOut time.Duration, master bool) error {
+// Loudness normalization targets for true peak (dBTP) and loudness range (LU)
+const (
+	targetTP  = -1.0
+	targetLRA = 11.0
+)
+
+func process(ctx context.Context, gen *storage.Generation, debug func(string, ...any), store *storage.Store, fs *filestore.Store,
+	client *http.Client, ph *phaselimiter.PhaseLimiter, phLock *sync.Mutex, shortFadeOut, longFadeOut time.Duration, noFadeOnEnding bool, fadeIn, trimLead time.Duration, master bool, post *postProcessor, targetLUFS float64, format string, themes map[string]sound.PlotOptions, prof *timing.Profile) error {
 
 	// Download the audio file
 	debug("process: start download %s", gen.ID)
+	stop := prof.Start("download")
 	b, err := download(ctx, client, gen.Audio)
 	if err != nil {
 		return fmt.Errorf("process: couldn't download gen audio: %w", err)
@@ -246,9 +313,20 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 	if err := os.WriteFile(original, b, 0644); err != nil {
 		return fmt.Errorf("process: couldn't save gen audio: %w", err)
 	}
+	stop()
 	debug("process: end download %s", gen.ID)
 
 	processed := original
+
+	// Lossless audio processed along with the mp3, which is still used to
+	// analyze the audio
+	var lossless string
+	if format != "mp3" {
+		lossless = filepath.Join(os.TempDir(), fmt.Sprintf("%s.%s", gen.ID, format))
+		defer func() { _ = os.Remove(lossless) }()
+	}
+
+	var lufs float32
 	if master {
 		// Create master folder if it doesn't exist
 		masterDir := filepath.Join(os.TempDir(), "master")
@@ -257,6 +335,12 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 		}
 		mastered := filepath.Join(masterDir, fmt.Sprintf("%s.mp3", gen.ID))
 
+		// Master directly to the lossless format if it is set
+		masterOutput := mastered
+		if lossless != "" {
+			masterOutput = lossless
+		}
+
 		// Master the gens
 		if _, err := os.Stat(mastered); err == nil {
 			if err := os.Remove(mastered); err != nil {
@@ -264,6 +348,7 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 			}
 		}
 		debug("process: start master %s", gen.ID)
+		stop := prof.Start("master")
 		if err := func() error {
 			// Lock the phase limiter to avoid concurrent calls
 			phLock.Lock()
@@ -272,23 +357,96 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 				return fmt.Errorf("process: %w", ctx.Err())
 			}
 
-			if err := ph.Master(ctx, original, mastered); err != nil {
+			if err := ph.Master(ctx, original, masterOutput); err != nil {
 				return fmt.Errorf("process: couldn't master gen: %w", err)
 			}
 			return nil
 		}(); err != nil {
 			return err
 		}
+		stop()
 		debug("process: end master %s", gen.ID)
 		processed = mastered
+
+		// Run the custom post processing
+		if post != nil {
+			debug("process: start post process %s", gen.ID)
+			stop := prof.Start("post process")
+			if err := post.Run(ctx, masterOutput); err != nil {
+				return err
+			}
+			stop()
+			debug("process: end post process %s", gen.ID)
+		}
+
+		// Normalize the loudness
+		if targetLUFS != 0 {
+			debug("process: start loudnorm %s", gen.ID)
+			stop := prof.Start("loudnorm")
+			stats, err := ffmpeg.Loudnorm(ctx, masterOutput, masterOutput, targetLUFS, targetTP, targetLRA)
+			if err != nil {
+				return fmt.Errorf("process: couldn't normalize loudness: %w", err)
+			}
+			v, err := stats.LUFS()
+			if err != nil {
+				return fmt.Errorf("process: couldn't get loudness: %w", err)
+			}
+			lufs = float32(v)
+			stop()
+			debug("process: end loudnorm %s (%.2f LUFS)", gen.ID, lufs)
+		}
+
+		// Encode the lossless master to mp3
+		if lossless != "" {
+			if err := ffmpeg.Encode(ctx, lossless, mastered, "mp3", ffmpeg.EncodeOpts{}); err != nil {
+				return fmt.Errorf("process: couldn't encode master to mp3: %w", err)
+			}
+		}
+	} else if lossless != "" {
+		if err := ffmpeg.Encode(ctx, original, lossless, format, ffmpeg.EncodeOpts{}); err != nil {
+			return fmt.Errorf("process: couldn't encode gen to %s: %w", format, err)
+		}
 	}
 
 	// Create analyzer to get silences
 	debug("process: start cut and fade out %s", gen.ID)
+	stop = prof.Start("cut and fade out")
 	analyzer, err := sound.NewAnalyzer(processed)
 	if err != nil {
 		return fmt.Errorf("process: couldn't create analyzer: %w", err)
 	}
+
+	// Remove leading silence
+	if trimLead > 0 {
+		lead, ok, err := analyzer.LeadingSilence(ctx)
+		if err != nil {
+			return fmt.Errorf("process: couldn't get leading silence: %w", err)
+		}
+		switch {
+		case !ok:
+		case lead.Final:
+			debug("process: silent audio, leading silence kept %s", gen.ID)
+		case lead.Duration > trimLead:
+			debug("process: leading silence of %s kept, longer than %s %s", lead.Duration, trimLead, gen.ID)
+		default:
+			if err := ffmpeg.Trim(ctx, processed, processed, lead.End, 0); err != nil {
+				return fmt.Errorf("process: couldn't trim leading silence: %w", err)
+			}
+			if lossless != "" {
+				if err := ffmpeg.Trim(ctx, lossless, lossless, lead.End, 0); err != nil {
+					return fmt.Errorf("process: couldn't trim leading silence: %w", err)
+				}
+			}
+			debug("process: leading silence of %s trimmed %s", lead.Duration, gen.ID)
+
+			// Analyze the trimmed audio to get the new duration and silences
+			analyzer, err = sound.NewAnalyzer(processed)
+			if err != nil {
+				return fmt.Errorf("process: couldn't create analyzer: %w", err)
+			}
+		}
+	}
+
 	silences, err := analyzer.Silences(ctx)
 	if err != nil {
 		return fmt.Errorf("process: couldn't get silences: %w", err)
@@ -296,39 +454,80 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 
 	fadeOut := longFadeOut
 	var ends bool
+	// cut is true if the trailing silence was cut, the song only has a
+	// natural ending that doesn't need a fade out in that case
+	var cut bool
 	duration := analyzer.Duration()
 
 	// Remove last silence
 	if len(silences) > 0 {
 		last := silences[len(silences)-1]
-		if last.Final || last.End > analyzer.Duration()-10*time.Second {
+		if isTrailing(last, analyzer.Duration()) {
 			// Cut the last silence
 			if err := ffmpeg.Cut(ctx, processed, processed, last.Start); err != nil {
 				return fmt.Errorf("process: couldn't cut last silence: %w", err)
 			}
+			if lossless != "" {
+				if err := ffmpeg.Cut(ctx, lossless, lossless, last.Start); err != nil {
+					return fmt.Errorf("process: couldn't cut last silence: %w", err)
+				}
+			}
 			duration = last.Start
+			cut = true
 		}
 		fadeOut = shortFadeOut
 		ends = true
 	}
 
+	// Keep the fades from overlapping on short songs
+	skipFadeOut := cut && noFadeOnEnding
+	if skipFadeOut {
+		fadeIn, _ = capFades(duration, fadeIn, 0)
+	} else {
+		fadeIn, fadeOut = capFades(duration, fadeIn, fadeOut)
+	}
+
+	// Apply fade in
+	if fadeIn > 0 {
+		if err := ffmpeg.FadeIn(ctx, processed, processed, fadeIn); err != nil {
+			return fmt.Errorf("process: couldn't fade in gen: %w", err)
+		}
+		if lossless != "" {
+			if err := ffmpeg.FadeIn(ctx, lossless, lossless, fadeIn); err != nil {
+				return fmt.Errorf("process: couldn't fade in gen: %w", err)
+			}
+		}
+		debug("process: fade in of %s applied %s", fadeIn, gen.ID)
+	}
+
 	// Apply fade out
-	if fadeOut < duration {
+	switch {
+	case skipFadeOut:
+		debug("process: already ends, no fade out %s", gen.ID)
+	case fadeOut < duration:
 		if err := ffmpeg.FadeOut(ctx, processed, processed, duration, fadeOut); err != nil {
 			return fmt.Errorf("process: couldn't fade out gen: %w", err)
 		}
-	} else {
+		if lossless != "" {
+			if err := ffmpeg.FadeOut(ctx, lossless, lossless, duration, fadeOut); err != nil {
+				return fmt.Errorf("process: couldn't fade out gen: %w", err)
+			}
+		}
+	default:
 		debug("process: too short to fade out %s", gen.ID)
 	}
+	stop()
 	debug("process: end cut and fade out %s", gen.ID)
 
+	stop = prof.Start("wave")
+
 	analyzer, err = sound.NewAnalyzer(processed)
 	if err != nil {
 		return fmt.Errorf("process: couldn't create analyzer: %w", err)
 	}
 
 	// process the wave image
-	waveBytes, err := analyzer.PlotWave(gen.Song.Style)
+	waveBytes, err := analyzer.PlotWaveOptions(waveOptions(themes, gen.Song.Type, gen.Song.Style))
 	if err != nil {
 		return fmt.Errorf("process: couldn't plot wave: %w", err)
 	}
@@ -337,42 +536,67 @@ func process(ctx context.Context, gen *storage.Generation, debug func(string, ..
 		return fmt.Errorf("process: couldn't write wave image: %w", err)
 	}
 	defer func() { _ = os.Remove(wavePath) }()
+	stop()
 
 	debug("process: start upload %s", gen.ID)
-	if err := func() error {
-		// Lock the tg store to avoid concurrent calls
-		tgLock.Lock()
-		defer tgLock.Unlock()
-		if ctx.Err() != nil {
-			return fmt.Errorf("process: %w", ctx.Err())
-		}
+	stop = prof.Start("upload")
+	// Upload the wave image
+	if err := fs.SetJPG(ctx, wavePath, gen.ID); err != nil {
+		return fmt.Errorf("process: couldn't save wave image to file storage: %w", err)
+	}
 
-		// Upload the wave image
-		if err := fs.SetJPG(ctx, wavePath, gen.ID); err != nil {
-			return fmt.Errorf("process: couldn't save wave image to telegram: %w", err)
-		}
+	// Upload the mastered audio
+	if err := fs.SetMP3(ctx, processed, gen.ID); err != nil {
+		return fmt.Errorf("process: couldn't save mastered audio to file storage: %w", err)
+	}
 
-		// Upload the mastered audio
-		if err := fs.SetMP3(ctx, processed, gen.ID); err != nil {
-			return fmt.Errorf("process: couldn't save mastered audio to telegram: %w", err)
+	// Upload the lossless audio
+	if lossless != "" {
+		if err := fs.SetAudio(ctx, lossless, gen.ID, format); err != nil {
+			return fmt.Errorf("process: couldn't save lossless audio to file storage: %w", err)
 		}
-
-		return nil
-	}(); err != nil {
-		return err
 	}
+	stop()
 	debug("process: end upload %s", gen.ID)
 
 	// Get the tempo
+	stop = prof.Start("tempo")
 	tempo, err := aubio.Tempo(ctx, processed)
 	if err != nil {
 		return fmt.Errorf("process: couldn't get tempo: %w", err)
 	}
-	return processFlags(ctx, gen, processed, ends, float32(tempo), master, analyzer, debug, store)
+	stop()
+
+	defer prof.Start("flags")()
+	var stored string
+	if lossless != "" {
+		stored = format
+	}
+	return processFlags(ctx, gen, processed, ends, float32(fadeIn.Seconds()), float32(tempo), lufs, master, stored, analyzer, debug, store)
+}
+
+// isTrailing returns whether the silence is at the end of the song, so it
+// can be cut.
+func isTrailing(silence sound.Fragment, duration time.Duration) bool {
+	return silence.Final || silence.End > duration-10*time.Second
+}
+
+// maxFadeFraction is the maximum fraction of the duration that each fade can
+// take when both are applied, so they never overlap.
+const maxFadeFraction = 1.0 / 3.0
+
+// capFades shortens the fade in and the fade out if together they are longer
+// than the song.
+func capFades(duration, fadeIn, fadeOut time.Duration) (time.Duration, time.Duration) {
+	if fadeIn <= 0 || fadeIn+fadeOut <= duration {
+		return fadeIn, fadeOut
+	}
+	limit := time.Duration(float64(duration) * maxFadeFraction)
+	return min(fadeIn, limit), min(fadeOut, limit)
 }
 
-func processFlags(ctx context.Context, gen *storage.Generation, processed string, ends bool,
-	tempo float32, master bool, analyzer *sound.Analyzer,
+func processFlags(ctx context.Context, gen *storage.Generation, processed string, ends bool, fadeIn float32,
+	tempo, lufs float32, master bool, format string, analyzer *sound.Analyzer,
 	debug func(string, ...any), store *storage.Store) error {
 
 	// Reload analyzer to process flags
@@ -422,6 +646,18 @@ func processFlags(ctx context.Context, gen *storage.Generation, processed string
 	}
 	f.BPMN = analyzer.FragmentBPMChange(beats, noises)
 
+	// Fingerprint to detect duplicates
+	fingerprint, err := analyzer.Fingerprint(ctx)
+	if err != nil {
+		log.Printf("process: couldn't get fingerprint of %s: %v\n", gen.ID, err)
+	}
+
+	// Musical key, empty if it is ambiguous
+	key := analyzer.Key()
+	if key == "" {
+		debug("process: ambiguous key %s", gen.ID)
+	}
+
 	flagsBytes, err := json.Marshal(f)
 	if err != nil {
 		return fmt.Errorf("process: couldn't marshal flags: %w", err)
@@ -442,12 +678,17 @@ func processFlags(ctx context.Context, gen *storage.Generation, processed string
 	// Update the gen
 	gen.Mastered = master
 	gen.Tempo = float32(tempo)
+	gen.LUFS = lufs
+	gen.Format = format
 	gen.Processed = true
 	gen.ProcessedAt = time.Now()
 	gen.Duration = float32(analyzer.Duration().Seconds())
 	gen.Ends = ends
+	gen.FadeIn = fadeIn
 	gen.Flags = flagJSON
 	gen.Flagged = flagJSON != ""
+	gen.Fingerprint = fingerprint
+	gen.Key = key
 
 	debug("flags: %s", flagJSON)
 
@@ -494,5 +735,5 @@ func reprocess(ctx context.Context, gen *storage.Generation, debug func(string,
 	if err != nil {
 		return fmt.Errorf("process: couldn't create analyzer: %w", err)
 	}
-	return processFlags(ctx, gen, processed, gen.Ends, gen.Tempo, gen.Mastered, analyzer, debug, store)
+	return processFlags(ctx, gen, processed, gen.Ends, gen.FadeIn, gen.Tempo, gen.LUFS, gen.Mastered, gen.Format, analyzer, debug, store)
 }
@@ -0,0 +1,54 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/sound"
+)
+
+func TestCapFades(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		duration, fadeIn, fadeOut time.Duration
+		wantIn, wantOut           time.Duration
+	}{
+		// Long songs keep both fades
+		{180 * s, 2 * s, 6 * s, 2 * s, 6 * s},
+		// Without fade in the fade out is untouched
+		{3 * s, 0, 6 * s, 0, 6 * s},
+		// Short songs cap each fade to a third of the duration
+		{6 * s, 3 * s, 6 * s, 2 * s, 2 * s},
+		{6 * s, 1 * s, 6 * s, 1 * s, 2 * s},
+		// Fade in without fade out
+		{3 * s, 6 * s, 0, 1 * s, 0},
+	}
+	for _, tt := range tests {
+		in, out := capFades(tt.duration, tt.fadeIn, tt.fadeOut)
+		if in != tt.wantIn || out != tt.wantOut {
+			t.Errorf("capFades(%s, %s, %s) = %s, %s, want %s, %s", tt.duration, tt.fadeIn, tt.fadeOut, in, out, tt.wantIn, tt.wantOut)
+		}
+		if in > 0 && out > 0 && in+out > tt.duration {
+			t.Errorf("capFades(%s, %s, %s) overlap", tt.duration, tt.fadeIn, tt.fadeOut)
+		}
+	}
+}
+
+func TestIsTrailing(t *testing.T) {
+	s := time.Second
+	tests := []struct {
+		silence sound.Fragment
+		want    bool
+	}{
+		{sound.Fragment{Start: 170 * s, End: 180 * s, Final: true}, true},
+		{sound.Fragment{Start: 165 * s, End: 175 * s}, true},
+		// Silences in the middle of the song aren't cut, so the song
+		// doesn't really end
+		{sound.Fragment{Start: 60 * s, End: 65 * s}, false},
+	}
+	for _, tt := range tests {
+		if got := isTrailing(tt.silence, 180*s); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.silence, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound"
+	"github.com/igolaizola/musikai/pkg/sound/aubio"
+	"github.com/igolaizola/musikai/pkg/sound/phaselimiter"
+	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/igolaizola/musikai/pkg/timing"
+)
+
+// Processor processes generations.
+// It is safe for concurrent use, so it can be shared by other commands to
+// process generations as soon as they are created.
+type Processor struct {
+	cfg    *Config
+	debug  func(string, ...any)
+	store  *storage.Store
+	fs     *filestore.Store
+	client *http.Client
+	ph     *phaselimiter.PhaseLimiter
+	master bool
+	// post runs the post process command, nil disables it
+	post   *postProcessor
+	format string
+	// trimLead is the longest leading silence trimmed, zero disables it
+	trimLead time.Duration
+	themes   map[string]sound.PlotOptions
+	prof     *timing.Profile
+	// selectCriteria are used to select the best generation of each song
+	// after processing, nil disables it
+	selectCriteria []string
+
+	// Phase limiter lock to avoid concurrent calls
+	phLock sync.Mutex
+}
+
+// Default fade out durations used if they aren't configured.
+const (
+	DefaultShortFadeOut = 1 * time.Second
+	DefaultLongFadeOut  = 6 * time.Second
+)
+
+// NewProcessor validates the configuration and creates a new processor.
+// The default fade outs are set in the configuration if they are zero.
+func NewProcessor(ctx context.Context, cfg *Config, store *storage.Store, prof *timing.Profile) (*Processor, error) {
+	if cfg.ShortFadeOut == 0 {
+		cfg.ShortFadeOut = DefaultShortFadeOut
+	}
+	if cfg.LongFadeOut == 0 {
+		cfg.LongFadeOut = DefaultLongFadeOut
+	}
+	if cfg.ShortFadeOut > cfg.LongFadeOut {
+		return nil, errors.New("process: short fade out must be less than long fade out")
+	}
+	if cfg.FadeIn < 0 {
+		return nil, errors.New("process: fade in can't be negative")
+	}
+
+	if cfg.MasterPreview {
+		if cfg.SkipMaster {
+			return nil, errors.New("process: master preview can't be used with skip master")
+		}
+		if cfg.Reprocess {
+			return nil, errors.New("process: master preview can't be used with reprocess")
+		}
+	}
+
+	format := cfg.Format
+	switch format {
+	case "":
+		format = "mp3"
+	case "mp3", "flac", "wav":
+	default:
+		return nil, fmt.Errorf("process: unsupported format %s", format)
+	}
+
+	if _, err := aubio.Version(ctx); err != nil {
+		return nil, fmt.Errorf("process: couldn't get aubio version: %w", err)
+	}
+
+	var ph *phaselimiter.PhaseLimiter
+	master := !cfg.SkipMaster
+	if master {
+		ph = phaselimiter.New(&phaselimiter.Config{
+			Docker: cfg.Docker,
+		})
+		if !cfg.Docker {
+			if _, err := ph.Version(ctx); err != nil {
+				return nil, fmt.Errorf("process: couldn't get phaselimiter version: %w", err)
+			}
+		}
+	}
+
+	var post *postProcessor
+	if cfg.PostProcessCmd != "" {
+		if !master {
+			return nil, errors.New("process: post process command can't be used with skip master")
+		}
+		var err error
+		post, err = newPostProcessor(cfg.PostProcessCmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var trimLead time.Duration
+	if cfg.TrimLead {
+		trimLead = cfg.TrimLeadMax
+		if trimLead == 0 {
+			trimLead = 2 * time.Second
+		}
+	}
+
+	var selectCriteria []string
+	if cfg.AutoSelect {
+		var err error
+		selectCriteria, err = parseSelectCriteria(cfg.SelectCriteria)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	themes, err := loadWaveThemes(cfg.WaveThemes)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := filestore.New(cfg.FSType, cfg.FSConn, cfg.Proxy, cfg.Debug, store)
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't create file storage: %w", err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: 2 * time.Minute,
+	}
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy: http.ProxyURL(u),
+		}
+	}
+
+	debug := func(format string, args ...any) {
+		if !cfg.Debug {
+			return
+		}
+		format += "\n"
+		log.Printf(format, args...)
+	}
+
+	return &Processor{
+		cfg:      cfg,
+		debug:    debug,
+		store:    store,
+		fs:       fs,
+		client:   httpClient,
+		ph:       ph,
+		master:   master,
+		post:     post,
+		format:   format,
+		trimLead: trimLead,
+		themes:   themes,
+		prof:     prof,
+
+		selectCriteria: selectCriteria,
+	}, nil
+}
+
+// Process downloads, masters, analyzes and uploads the generation audio.
+func (p *Processor) Process(ctx context.Context, gen *storage.Generation) error {
+	if err := process(ctx, gen, p.debug, p.store, p.fs, p.client, p.ph, &p.phLock,
+		p.cfg.ShortFadeOut, p.cfg.LongFadeOut, p.cfg.NoFadeOnEnding, p.cfg.FadeIn, p.trimLead, p.master, p.post, p.cfg.TargetLUFS, p.format, p.themes, p.prof); err != nil {
+		return err
+	}
+	return p.autoSelect(ctx, gen)
+}
+
+// Reprocess updates the flags of an already processed generation.
+func (p *Processor) Reprocess(ctx context.Context, gen *storage.Generation) error {
+	if err := reprocess(ctx, gen, p.debug, p.store, p.fs); err != nil {
+		return err
+	}
+	return p.autoSelect(ctx, gen)
+}
+
+// autoSelect selects the best generation of the song of the generation if
+// auto select is enabled.
+func (p *Processor) autoSelect(ctx context.Context, gen *storage.Generation) error {
+	if p.selectCriteria == nil || gen.SongID == nil {
+		return nil
+	}
+	return autoSelect(ctx, p.store, *gen.SongID, p.selectCriteria, p.debug)
+}
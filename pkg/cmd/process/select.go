@@ -0,0 +1,136 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// Criteria to choose the best generation of a song.
+const (
+	// SelectEnds prefers generations with a natural ending
+	SelectEnds = "ends"
+	// SelectFlags prefers generations with fewer flags
+	SelectFlags = "flags"
+	// SelectLongest prefers longer generations
+	SelectLongest = "longest"
+)
+
+// DefaultSelectCriteria are the criteria used if none are configured.
+const DefaultSelectCriteria = SelectEnds + "," + SelectFlags + "," + SelectLongest
+
+// parseSelectCriteria parses a comma separated list of criteria, sorted by
+// priority.
+func parseSelectCriteria(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		value = DefaultSelectCriteria
+	}
+	var criteria []string
+	seen := map[string]bool{}
+	for _, c := range strings.Split(value, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		switch c {
+		case "":
+			continue
+		case SelectEnds, SelectFlags, SelectLongest:
+		default:
+			return nil, fmt.Errorf("process: unknown select criteria %q", c)
+		}
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		criteria = append(criteria, c)
+	}
+	return criteria, nil
+}
+
+// flagCount returns the number of issues found in the generation.
+func flagCount(gen *storage.Generation) int {
+	if gen.Flags == "" {
+		return 0
+	}
+	var f flags
+	if err := json.Unmarshal([]byte(gen.Flags), &f); err != nil {
+		// Unknown flags are counted as a single issue
+		return 1
+	}
+	n := len(f.Silences)
+	for _, b := range []bool{f.Short, f.BPM2, f.BPM4, f.BPMN} {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// better compares two generations with the criteria in order and returns
+// whether a is better than b.
+func better(a, b *storage.Generation, criteria []string) bool {
+	for _, c := range criteria {
+		switch c {
+		case SelectEnds:
+			if a.Ends != b.Ends {
+				return a.Ends
+			}
+		case SelectFlags:
+			if fa, fb := flagCount(a), flagCount(b); fa != fb {
+				return fa < fb
+			}
+		case SelectLongest:
+			if a.Duration != b.Duration {
+				return a.Duration > b.Duration
+			}
+		}
+	}
+	return false
+}
+
+// bestGeneration returns the best generation according to the criteria.
+// Ties are resolved in favor of the first generation.
+func bestGeneration(gens []*storage.Generation, criteria []string) *storage.Generation {
+	var best *storage.Generation
+	for _, g := range gens {
+		if best == nil || better(g, best, criteria) {
+			best = g
+		}
+	}
+	return best
+}
+
+// autoSelect sets the best generation of the song once all its generations
+// are processed. Songs that have already been reviewed or whose generation
+// was selected by a user keep their selection.
+func autoSelect(ctx context.Context, store *storage.Store, songID string, criteria []string, debug func(string, ...any)) error {
+	song, err := store.GetSong(ctx, songID)
+	if err != nil {
+		return fmt.Errorf("process: couldn't get song %s: %w", songID, err)
+	}
+	if song.State != storage.Pending || song.Selected {
+		return nil
+	}
+	gens, err := store.ListGenerations(ctx, 1, 100, "generations.id", storage.Where("generations.song_id = ?", songID))
+	if err != nil {
+		return fmt.Errorf("process: couldn't list generations of song %s: %w", songID, err)
+	}
+	for _, g := range gens {
+		if !g.Processed {
+			debug("process: skip select %s, generation %s isn't processed", songID, g.ID)
+			return nil
+		}
+	}
+	best := bestGeneration(gens, criteria)
+	if best == nil || (song.GenerationID != nil && *song.GenerationID == best.ID) {
+		return nil
+	}
+	song.GenerationID = &best.ID
+	song.Generation = best
+	if err := store.SetSong(ctx, song); err != nil {
+		return fmt.Errorf("process: couldn't set song %s: %w", songID, err)
+	}
+	debug("process: generation %s selected for song %s", best.ID, songID)
+	return nil
+}
@@ -0,0 +1,137 @@
+package process
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestBestGeneration(t *testing.T) {
+	gens := []*storage.Generation{
+		{ID: "a", Duration: 200, Ends: false, Flags: ""},
+		{ID: "b", Duration: 180, Ends: true, Flags: `{"silences":[40,60]}`},
+		{ID: "c", Duration: 150, Ends: true, Flags: `{"short":true}`},
+		{ID: "d", Duration: 220, Ends: false, Flags: `{"bpm_2":true}`},
+	}
+	tests := []struct {
+		criteria string
+		want     string
+	}{
+		{"", "c"},
+		{"ends,flags,longest", "c"},
+		{"ends,longest", "b"},
+		{"flags,longest", "a"},
+		{"longest", "d"},
+		{"flags", "a"},
+	}
+	for _, tt := range tests {
+		criteria, err := parseSelectCriteria(tt.criteria)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bestGeneration(gens, criteria).ID; got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.criteria, got, tt.want)
+		}
+	}
+	if _, err := parseSelectCriteria("ends,shortest"); err == nil {
+		t.Error("expected error for unknown criteria")
+	}
+}
+
+func TestAutoSelect(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	debug := func(string, ...any) {}
+	criteria, err := parseSelectCriteria("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := "g1"
+	if err := store.SetSong(ctx, &storage.Song{ID: "s1", State: storage.Pending, GenerationID: &first}); err != nil {
+		t.Fatal(err)
+	}
+	song := "s1"
+	gens := []*storage.Generation{
+		{ID: "g1", SongID: &song, Processed: true, Duration: 200},
+		{ID: "g2", SongID: &song, Duration: 180, Ends: true},
+	}
+	for _, g := range gens {
+		if err := store.SetGeneration(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	selected := func() string {
+		t.Helper()
+		s, err := store.GetSong(ctx, "s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *s.GenerationID
+	}
+
+	// Nothing is selected until all generations are processed
+	if err := autoSelect(ctx, store, "s1", criteria, debug); err != nil {
+		t.Fatal(err)
+	}
+	if got := selected(); got != "g1" {
+		t.Fatalf("got %s, want g1", got)
+	}
+
+	gens[1].Processed = true
+	if err := store.SetGeneration(ctx, gens[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := autoSelect(ctx, store, "s1", criteria, debug); err != nil {
+		t.Fatal(err)
+	}
+	if got := selected(); got != "g2" {
+		t.Fatalf("got %s, want g2", got)
+	}
+
+	// Songs selected by a user keep their selection
+	s, err := store.GetSong(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GenerationID = &first
+	s.Generation = nil
+	s.Selected = true
+	if err := store.SetSong(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := autoSelect(ctx, store, "s1", criteria, debug); err != nil {
+		t.Fatal(err)
+	}
+	if got := selected(); got != "g1" {
+		t.Fatalf("got %s, want g1", got)
+	}
+
+	// Reviewed songs keep their selection
+	s, err = store.GetSong(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.State = storage.Approved
+	s.Selected = false
+	if err := store.SetSong(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := autoSelect(ctx, store, "s1", criteria, debug); err != nil {
+		t.Fatal(err)
+	}
+	if got := selected(); got != "g1" {
+		t.Fatalf("got %s, want g1", got)
+	}
+}
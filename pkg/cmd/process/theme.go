@@ -0,0 +1,70 @@
+package process
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/igolaizola/musikai/pkg/sound"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTheme is the key of the theme used for types without a theme.
+const defaultTheme = "default"
+
+// waveTheme is the look of the wave images of a type.
+type waveTheme struct {
+	Background string   `yaml:"background"`
+	Colors     []string `yaml:"colors"`
+	Gradient   bool     `yaml:"gradient"`
+	Width      int      `yaml:"width"`
+	Height     int      `yaml:"height"`
+}
+
+// loadWaveThemes reads a yaml or json file with the wave themes by song type.
+func loadWaveThemes(file string) (map[string]sound.PlotOptions, error) {
+	themes := map[string]sound.PlotOptions{}
+	if file == "" {
+		return themes, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("process: couldn't read wave themes: %w", err)
+	}
+	var raw map[string]waveTheme
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("process: couldn't parse wave themes: %w", err)
+	}
+	for typ, t := range raw {
+		opts := sound.PlotOptions{
+			Gradient: t.Gradient,
+			Width:    t.Width,
+			Height:   t.Height,
+		}
+		if t.Background != "" {
+			c, err := sound.ParseColor(t.Background)
+			if err != nil {
+				return nil, fmt.Errorf("process: invalid wave theme %s: %w", typ, err)
+			}
+			opts.Background = c
+		}
+		for _, v := range t.Colors {
+			c, err := sound.ParseColor(v)
+			if err != nil {
+				return nil, fmt.Errorf("process: invalid wave theme %s: %w", typ, err)
+			}
+			opts.Colors = append(opts.Colors, c)
+		}
+		themes[typ] = opts
+	}
+	return themes, nil
+}
+
+// waveOptions returns the plot options for the song type.
+func waveOptions(themes map[string]sound.PlotOptions, typ, title string) sound.PlotOptions {
+	opts, ok := themes[typ]
+	if !ok {
+		opts = themes[defaultTheme]
+	}
+	opts.Title = title
+	return opts
+}
@@ -0,0 +1,189 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/igolaizola/musikai/pkg/cmd/jamendo"
+	"github.com/igolaizola/musikai/pkg/distrokid"
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/soundcloud"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// newDryRunPublisher returns a function that prepares and validates the album
+// for the provider and reports it, without starting the provider client nor
+// updating the database.
+func newDryRunPublisher(cfg *Config, provider string, store *storage.Store, fs *filestore.Store) (func(context.Context, *storage.Album) error, func(), error) {
+	stop := func() {}
+	switch provider {
+	case "", storage.TargetDistrokid:
+		return func(ctx context.Context, album *storage.Album) error {
+			return dryRunDistrokid(ctx, cfg, store, fs, album)
+		}, stop, nil
+	case storage.TargetJamendo:
+		publisher := jamendo.NewDryRunPublisher(jamendoConfig(cfg), store, fs)
+		return func(ctx context.Context, album *storage.Album) error {
+			return dryRunJamendo(ctx, publisher, album)
+		}, stop, nil
+	case storage.TargetBandcamp:
+		return func(ctx context.Context, album *storage.Album) error {
+			return dryRunBandcamp(ctx, cfg, store, fs, album)
+		}, stop, nil
+	case storage.TargetSoundCloud:
+		return func(ctx context.Context, album *storage.Album) error {
+			return dryRunSoundCloud(ctx, store, fs, album)
+		}, stop, nil
+	default:
+		return nil, nil, fmt.Errorf("publish: unknown provider %s", provider)
+	}
+}
+
+func dryRunDistrokid(ctx context.Context, cfg *Config, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+	dkAlbum, err := distrokidAlbum(ctx, cfg, store, fs, album)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("primary genre: %s", genreReport(dkAlbum.PrimaryGenre)))
+	lines = append(lines, fmt.Sprintf("secondary genre: %s", genreReport(dkAlbum.SecondaryGenre)))
+	for i, s := range dkAlbum.Songs {
+		lines = append(lines, fmt.Sprintf("%d. %s (%s)", i+1, s.Title, s.File))
+	}
+	err = dkAlbum.Validate()
+	if err == nil {
+		err = checkGenres(dkAlbum.PrimaryGenre, dkAlbum.SecondaryGenre)
+	}
+	return report(storage.TargetDistrokid, album, lines, err)
+}
+
+func dryRunJamendo(ctx context.Context, publisher *jamendo.Publisher, album *storage.Album) error {
+	jmAlbum, err := publisher.Prepare(ctx, album)
+	if jmAlbum == nil {
+		return err
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("description: %s", jmAlbum.Description))
+	for i, s := range jmAlbum.Songs {
+		lines = append(lines, fmt.Sprintf("%d. %s (%s) isrc %q, genres %q, tags %q", i+1, s.Title, s.File, s.ISRC, s.Genres, s.Tags))
+	}
+	return report(storage.TargetJamendo, album, lines, err)
+}
+
+func dryRunBandcamp(ctx context.Context, cfg *Config, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+	bcAlbum, err := bandcampAlbum(ctx, cfg, store, fs, album)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	lines = append(lines, fmt.Sprintf("tags: %s", strings.Join(bcAlbum.Tags, ", ")))
+	for i, s := range bcAlbum.Songs {
+		lines = append(lines, fmt.Sprintf("%d. %s (%s)", i+1, s.Title, s.File))
+	}
+	return report(storage.TargetBandcamp, album, lines, bcAlbum.Validate())
+}
+
+func dryRunSoundCloud(ctx context.Context, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+	// Get songs pending to be uploaded
+	songs, err := store.ListSongs(ctx, 1, 100, "",
+		storage.Where("album_id = ?", album.ID),
+		storage.Where("sound_cloud_id = ?", ""),
+	)
+	if err != nil {
+		return fmt.Errorf("publish: couldn't get songs: %w", err)
+	}
+
+	// Download cover
+	cover := filepath.Join(os.TempDir(), filestore.JPG(album.ID))
+	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
+		return fmt.Errorf("publish: couldn't download cover: %w", err)
+	}
+	defer func() { _ = os.Remove(cover) }()
+
+	// Order songs by track number
+	sort.Slice(songs, func(i, j int) bool {
+		return songs[i].Order < songs[j].Order
+	})
+
+	tags := append([]string{album.Artist}, album.GenreTags()...)
+	lines := []string{fmt.Sprintf("tags: %s", strings.Join(tags, ", "))}
+	var validateErr error
+	for i, s := range songs {
+		// Download song, the conversion to wav is skipped
+		mp3 := filepath.Join(os.TempDir(), filestore.MP3(*s.GenerationID))
+		if err := fs.GetMP3(ctx, mp3, *s.GenerationID); err != nil {
+			return fmt.Errorf("publish: couldn't download song: %w", err)
+		}
+		track := &soundcloud.Track{
+			Title: s.Title,
+			Tags:  tags,
+			File:  mp3,
+			Cover: cover,
+		}
+		if err := track.Validate(); err != nil && validateErr == nil {
+			validateErr = fmt.Errorf("song %d: %w", i+1, err)
+		}
+		_ = os.Remove(mp3)
+		lines = append(lines, fmt.Sprintf("%d. %s (%s)", i+1, s.Title, mp3))
+	}
+	return report(storage.TargetSoundCloud, album, lines, validateErr)
+}
+
+// report logs the dry run report of the album and returns the validation
+// error if any.
+func report(provider string, album *storage.Album, lines []string, err error) error {
+	result := "ok"
+	if err != nil {
+		result = fmt.Sprintf("invalid: %v", err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "publish: dry run %s album %s %q by %s: %s\n", provider, album.ID, album.FullTitle(), album.Artist, result)
+	for _, l := range lines {
+		fmt.Fprintf(&b, "  %s\n", l)
+	}
+	log.Print(b.String())
+	if err != nil {
+		return fmt.Errorf("publish: dry run %s album %s is invalid: %w", provider, album.ID, err)
+	}
+	return nil
+}
+
+// genreReport returns the genre along with whether it is a known distrokid
+// genre.
+func genreReport(genre string) string {
+	switch {
+	case genre == "":
+		return "none"
+	case isDistrokidGenre(genre):
+		return genre
+	default:
+		return fmt.Sprintf("%s (unknown)", genre)
+	}
+}
+
+// checkGenres returns an error if any of the genres isn't a distrokid genre.
+func checkGenres(genres ...string) error {
+	for _, g := range genres {
+		if g == "" {
+			continue
+		}
+		if !isDistrokidGenre(g) {
+			return fmt.Errorf("unknown distrokid genre %q", g)
+		}
+	}
+	return nil
+}
+
+func isDistrokidGenre(genre string) bool {
+	for _, g := range distrokid.Genres {
+		if g == genre {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package publish
+
+import "testing"
+
+func TestCheckGenres(t *testing.T) {
+	tests := []struct {
+		genres []string
+		valid  bool
+	}{
+		{[]string{"Jazz", ""}, true},
+		{[]string{"Electronic:House", "Jazz"}, true},
+		{[]string{"Electronic:Jazz"}, false},
+		{[]string{"Jazz", "jazz"}, false},
+	}
+	for _, tt := range tests {
+		err := checkGenres(tt.genres...)
+		if (err == nil) != tt.valid {
+			t.Errorf("checkGenres(%q) = %v, want valid %v", tt.genres, err, tt.valid)
+		}
+	}
+}
@@ -2,19 +2,29 @@ package publish
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/bandcamp"
+	"github.com/igolaizola/musikai/pkg/cmd/jamendo"
 	"github.com/igolaizola/musikai/pkg/distrokid"
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/maxruntime"
+	"github.com/igolaizola/musikai/pkg/progress"
+	"github.com/igolaizola/musikai/pkg/queue"
+	"github.com/igolaizola/musikai/pkg/shutdown"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
+	"github.com/igolaizola/musikai/pkg/soundcloud"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -31,14 +41,56 @@ type Config struct {
 	WaitMin     time.Duration
 	WaitMax     time.Duration
 	Limit       int
+	MaxRuntime  time.Duration
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	// ProgressInterval is the time between progress logs.
+	// Zero disables them.
+	ProgressInterval time.Duration
 
 	Auto        bool
+	Provider    string
 	Account     string
 	Type        string
 	FirstName   string
 	LastName    string
 	RecordLabel string
 	Chrome      string
+
+	ScreenshotDir    string
+	UploadScreenshot bool
+	WaitBeforeClose  time.Duration
+	SubmitTimeout    time.Duration
+
+	// DistrokidMaxSongs is the maximum number of songs of a DistroKid album.
+	DistrokidMaxSongs int
+
+	// Bandcamp options
+	Price float64
+
+	// Jamendo options
+	JamendoArtistName string
+	JamendoArtistID   int
+	JamendoAttempts   int
+	JamendoMaxGenres  int
+	JamendoMaxTags    int
+	// JamendoConvertConcurrency is the number of songs downloaded and
+	// converted to wav at the same time.
+	JamendoConvertConcurrency int
+	// JamendoLoginRetry retries once with the cookies reloaded from the
+	// cookie store if the jamendo session has expired.
+	JamendoLoginRetry bool
+
+	// Resume starts after the last album published in order by a previous
+	// run with the same provider and type.
+	Resume bool
+
+	// DryRun prepares and validates the albums, downloading their files,
+	// and reports them without publishing or updating the database.
+	DryRun bool
 }
 
 // Run launches the song generation process.
@@ -82,20 +134,19 @@ func Run(ctx context.Context, cfg *Config) error {
 			Proxy: http.ProxyURL(u),
 		}
 	}
-	browser := distrokid.NewBrowser(&distrokid.BrowserConfig{
-		Wait:        4 * time.Second,
-		Proxy:       cfg.Proxy,
-		CookieStore: store.NewCookieStore("distrokid", cfg.Account),
-		BinPath:     cfg.Chrome,
-	})
-	if err := browser.Start(ctx); err != nil {
-		return fmt.Errorf("publish: couldn't start distrokid browser: %w", err)
-	}
-	defer func() {
-		if err := browser.Stop(); err != nil {
-			log.Printf("publish: couldn't stop distrokid browser: %v\n", err)
+	var publishAlbum func(context.Context, *storage.Album) error
+	if cfg.Provider == "targets" {
+		targets := newTargetPublisher(cfg, store, fs)
+		defer targets.stop()
+		publishAlbum = targets.publish
+	} else {
+		publish, stop, err := newPublisher(ctx, cfg, cfg.Provider, store, fs)
+		if err != nil {
+			return err
 		}
-	}()
+		defer stop()
+		publishAlbum = publish
+	}
 
 	// Print time stats
 	start := time.Now()
@@ -104,7 +155,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("publish: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("publish", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -126,24 +177,116 @@ func Run(ctx context.Context, cfg *Config) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	var albums []*storage.Album
+	maxRuntime, stopMaxRuntime := maxruntime.After(cfg.MaxRuntime)
+	defer stopMaxRuntime()
+
+	provider := cfg.Provider
+	if provider == "" {
+		provider = storage.TargetDistrokid
+	}
+
+	// Base filters
+	baseFilters := []storage.Filter{
+		storage.Where("state = ?", storage.Approved),
+		// Albums pending manual review already have a distrokid ID
+		storage.Where("distrokid_id = ?", ""),
+	}
+	if cfg.Provider == storage.TargetJamendo {
+		// Jamendo albums are published once they are released by distrokid,
+		// as in the jamendo command
+		baseFilters = []storage.Filter{
+			storage.Where("state = ?", storage.Used),
+			storage.Where("jamendo_id = ?", ""),
+		}
+	}
+	if cfg.Provider == "bandcamp" {
+		// Albums already published to other providers can still be
+		// published to bandcamp
+		baseFilters = []storage.Filter{
+			storage.Where("state IN (?)", []storage.State{storage.Approved, storage.Used}),
+			storage.Where("bandcamp_id = ?", ""),
+		}
+	}
+	if cfg.Provider == "soundcloud" {
+		// Albums with songs pending to be uploaded to soundcloud
+		baseFilters = []storage.Filter{
+			storage.Where("state IN (?)", []storage.State{storage.Approved, storage.Used}),
+			storage.Where("EXISTS (SELECT 1 FROM songs WHERE songs.album_id = albums.id AND songs.sound_cloud_id = '' AND songs.state != ?)", storage.Rejected),
+		}
+	}
+	if cfg.Provider == "targets" {
+		// Albums with any of their targets pending
+		baseFilters = []storage.Filter{
+			storage.Where("state IN (?)", []storage.State{storage.Approved, storage.Used}),
+			storage.Where("targets != ?", ""),
+			pendingTargets(),
+		}
+	} else {
+		// Skip albums that have targets but not this one
+		baseFilters = append(baseFilters, storage.AllowsTarget(provider))
+	}
+	if cfg.Type != "" {
+		baseFilters = append(baseFilters, storage.Where("type LIKE ?", cfg.Type))
+	}
+
+	// Progress of the pending albums
+	reporter := progress.New("publish", cfg.ProgressInterval, cfg.Limit, func(ctx context.Context) (int64, error) {
+		return store.CountAlbums(ctx, baseFilters...)
+	})
+	stopProgress := reporter.Start(ctx)
+	defer func() {
+		// Log the last progress once the running items are done
+		wg.Wait()
+		stopProgress()
+	}()
+
+	// Checkpoint of the albums published in order, so the batch can be
+	// resumed after a crash
+	checkpoint := store.NewCheckpoint("publish-"+provider, cfg.Type)
 	var currID string
+	if cfg.Resume {
+		currID, err = checkpoint.Get(ctx)
+		if err != nil {
+			return fmt.Errorf("publish: couldn't get checkpoint: %w", err)
+		}
+		if currID != "" {
+			log.Printf("publish: resuming after album %s\n", currID)
+		}
+	}
+	var completed bool
+	defer func() {
+		// Start the next batch from the beginning once all albums are done
+		wg.Wait()
+		if !completed || cfg.DryRun {
+			return
+		}
+		if err := checkpoint.Finish(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	var albums []*storage.Album
 	for {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("publish: %w", ctx.Err())
 		case <-ticker.C:
 			return nil
-		case err := <-errC:
+		case <-maxRuntime:
+			remaining, err := store.CountAlbums(ctx, baseFilters...)
 			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
+				log.Println(err)
 			}
-
+			log.Printf("publish: stopped due to max runtime with %d items remaining\n", remaining)
+			return fmt.Errorf("publish: %w", maxruntime.ErrReached)
+		case err := <-errC:
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("publish: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if shutdown.Requested(ctx) {
+				log.Printf("publish: shutdown requested, waiting for in-flight work\n")
+				return fmt.Errorf("publish: %w", shutdown.ErrStopped)
 			}
 			if cfg.Limit > 0 && iteration >= cfg.Limit {
 				return nil
@@ -156,39 +299,43 @@ func Run(ctx context.Context, cfg *Config) error {
 			}
 
 			// Get next albums
-			filters := []storage.Filter{
-				storage.Where("state = ?", storage.Approved),
+			filters := append([]storage.Filter{
 				storage.Where("id > ?", currID),
-			}
-			if cfg.Type != "" {
-				filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
-			}
+			}, baseFilters...)
 
 			// Get next image
 			if len(albums) == 0 {
 				// Get a albums from the database.
 				var err error
-				albums, err = store.ListAlbums(ctx, 1, 100, "", filters...)
+				albums, err = store.ListAlbums(ctx, 1, 100, "id", filters...)
 				if err != nil {
 					return fmt.Errorf("process: couldn't get album from database: %w", err)
 				}
 				if len(albums) == 0 {
-					return errors.New("process: no albums to process")
+					completed = true
+					return fmt.Errorf("publish: no albums to process: %w", queue.ErrEmpty)
 				}
 				currID = albums[len(albums)-1].ID
 			}
 			album := albums[0]
 			albums = albums[1:]
+			checkpoint.Start(album.ID)
 
 			// Launch publish in a goroutine
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 				debug("publish: start %s %s", album.ID, album.FullTitle())
-				err := publish(ctx, cfg, browser, store, fs, album)
+				err := publishAlbum(ctx, album)
 				if err != nil {
 					log.Println(err)
 				}
+				if !cfg.DryRun {
+					if err := checkpoint.Done(ctx, album.ID, err); err != nil {
+						log.Println(err)
+					}
+				}
+				reporter.Done(err)
 				debug("publish: end %s %s", album.ID, album.FullTitle())
 				errC <- err
 			}()
@@ -196,16 +343,160 @@ func Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
+// newPublisher starts the client of the provider and returns the function to
+// publish an album and the one to stop the client.
+func newPublisher(ctx context.Context, cfg *Config, provider string, store *storage.Store, fs *filestore.Store) (func(context.Context, *storage.Album) error, func(), error) {
+	if cfg.DryRun {
+		return newDryRunPublisher(cfg, provider, store, fs)
+	}
+	switch provider {
+	case "", storage.TargetDistrokid:
+		browser := distrokid.NewBrowser(&distrokid.BrowserConfig{
+			Wait:        4 * time.Second,
+			Proxy:       cfg.Proxy,
+			CookieStore: store.NewCookieStore("distrokid", cfg.Account),
+			BinPath:     cfg.Chrome,
+
+			ScreenshotDir:   cfg.ScreenshotDir,
+			WaitBeforeClose: cfg.WaitBeforeClose,
+			SubmitTimeout:   cfg.SubmitTimeout,
+		})
+		if err := browser.Start(ctx); err != nil {
+			return nil, nil, fmt.Errorf("publish: couldn't start distrokid browser: %w", err)
+		}
+		stop := func() {
+			if err := browser.Stop(); err != nil {
+				log.Printf("publish: couldn't stop distrokid browser: %v\n", err)
+			}
+		}
+		return func(ctx context.Context, album *storage.Album) error {
+			return publish(ctx, cfg, browser, store, fs, album)
+		}, stop, nil
+	case storage.TargetJamendo:
+		publisher, err := jamendo.NewPublisher(ctx, jamendoConfig(cfg), store, fs)
+		if err != nil {
+			return nil, nil, err
+		}
+		stop := func() {
+			if err := publisher.Stop(); err != nil {
+				log.Println(err)
+			}
+		}
+		return publisher.Publish, stop, nil
+	case storage.TargetBandcamp:
+		browser := bandcamp.NewBrowser(&bandcamp.BrowserConfig{
+			Wait:        4 * time.Second,
+			Proxy:       cfg.Proxy,
+			CookieStore: store.NewCookieStore("bandcamp", cfg.Account),
+			BinPath:     cfg.Chrome,
+		})
+		if err := browser.Start(ctx); err != nil {
+			return nil, nil, fmt.Errorf("publish: couldn't start bandcamp browser: %w", err)
+		}
+		stop := func() {
+			if err := browser.Stop(); err != nil {
+				log.Printf("publish: couldn't stop bandcamp browser: %v\n", err)
+			}
+		}
+		return func(ctx context.Context, album *storage.Album) error {
+			return publishBandcamp(ctx, cfg, browser, store, fs, album)
+		}, stop, nil
+	case storage.TargetSoundCloud:
+		client := soundcloud.New(&soundcloud.Config{
+			Wait:        4 * time.Second,
+			Debug:       cfg.Debug,
+			Proxy:       cfg.Proxy,
+			CookieStore: store.NewCookieStore("soundcloud", cfg.Account),
+		})
+		if err := client.Start(ctx); err != nil {
+			return nil, nil, fmt.Errorf("publish: couldn't start soundcloud client: %w", err)
+		}
+		stop := func() {
+			if err := client.Stop(context.Background()); err != nil {
+				log.Printf("publish: couldn't stop soundcloud client: %v\n", err)
+			}
+		}
+		return func(ctx context.Context, album *storage.Album) error {
+			return publishSoundCloud(ctx, cfg, client, store, fs, album)
+		}, stop, nil
+	default:
+		return nil, nil, fmt.Errorf("publish: unknown provider %s", provider)
+	}
+}
+
+// jamendoConfig returns the configuration of the jamendo publisher.
+func jamendoConfig(cfg *Config) *jamendo.Config {
+	return &jamendo.Config{
+		Debug:      cfg.Debug,
+		Proxy:      cfg.Proxy,
+		Chrome:     cfg.Chrome,
+		Account:    cfg.Account,
+		ArtistName: cfg.JamendoArtistName,
+		ArtistID:   cfg.JamendoArtistID,
+		Attempts:   cfg.JamendoAttempts,
+		MaxGenres:  cfg.JamendoMaxGenres,
+		MaxTags:    cfg.JamendoMaxTags,
+
+		ConvertConcurrency: cfg.JamendoConvertConcurrency,
+		LoginRetry:         cfg.JamendoLoginRetry,
+	}
+}
+
 func publish(ctx context.Context, cfg *Config, b *distrokid.Browser, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
-	// Get songs for album
-	songs, err := store.ListSongs(ctx, 1, 100, "", storage.Where("album_id = ?", album.ID))
+	dkAlbum, err := distrokidAlbum(ctx, cfg, store, fs, album)
 	if err != nil {
-		return fmt.Errorf("publish: couldn't get songs: %w", err)
+		return err
+	}
+
+	// Publish album
+	dkID, err := b.Publish(ctx, dkAlbum, cfg.Auto)
+
+	// Upload the screenshot even if publishing failed
+	if cfg.UploadScreenshot && dkAlbum.Screenshot != "" {
+		if err := fs.SetPNG(ctx, dkAlbum.Screenshot, fmt.Sprintf("screenshot_%s", album.ID)); err != nil {
+			log.Printf("publish: couldn't upload screenshot %s: %v\n", dkAlbum.Screenshot, err)
+		}
+	}
+	if dkAlbum.Outcome == distrokid.OutcomeNeedsManual {
+		// Save the distrokid ID so the album isn't submitted again and keep
+		// it approved until it is reviewed manually
+		album.DistrokidID = dkID
+		if err := store.SetAlbum(ctx, album); err != nil {
+			return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, dkID, err)
+		}
+		log.Printf("publish: album %s (%s) needs manual review\n", album.ID, dkID)
+	}
+	if err != nil {
+		return fmt.Errorf("publish: couldn't distrokid publish %s: %w", album.ID, err)
+	}
+
+	// Update album
+	album.DistrokidID = dkID
+	album.PublishedAt = time.Now().UTC()
+	album.State = storage.Used
+	if err := store.SetAlbum(ctx, album); err != nil {
+		return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, dkID, err)
+	}
+	return nil
+}
+
+// distrokidAlbum downloads the cover and songs of the album and returns the
+// distrokid album data.
+func distrokidAlbum(ctx context.Context, cfg *Config, store *storage.Store, fs *filestore.Store, album *storage.Album) (*distrokid.Album, error) {
+	// Get songs for album, listing one more than the maximum so oversized
+	// albums are detected
+	maxSongs := cfg.DistrokidMaxSongs
+	if maxSongs == 0 {
+		maxSongs = distrokid.DefaultMaxSongs
+	}
+	songs, err := store.ListSongs(ctx, 1, maxSongs+1, "", storage.Where("album_id = ?", album.ID))
+	if err != nil {
+		return nil, fmt.Errorf("publish: couldn't get songs: %w", err)
 	}
 	lookup := map[string]struct{}{}
 	for _, s := range songs {
 		if _, ok := lookup[s.Title]; ok {
-			return fmt.Errorf("publish: duplicated song %s", s.Title)
+			return nil, fmt.Errorf("publish: duplicated song %s", s.Title)
 		}
 		lookup[s.Title] = struct{}{}
 	}
@@ -214,7 +505,7 @@ func publish(ctx context.Context, cfg *Config, b *distrokid.Browser, store *stor
 	name := filestore.JPG(album.ID)
 	cover := filepath.Join(os.TempDir(), name)
 	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
-		return fmt.Errorf("publish: couldn't download cover: %w", err)
+		return nil, fmt.Errorf("publish: couldn't download cover: %w", err)
 	}
 
 	// Create album title
@@ -222,6 +513,7 @@ func publish(ctx context.Context, cfg *Config, b *distrokid.Browser, store *stor
 
 	// Create distrokid album data
 	dkAlbum := &distrokid.Album{
+		ID:             album.ID,
 		Artist:         album.Artist,
 		FirstName:      cfg.FirstName,
 		LastName:       cfg.LastName,
@@ -230,6 +522,7 @@ func publish(ctx context.Context, cfg *Config, b *distrokid.Browser, store *stor
 		Cover:          cover,
 		PrimaryGenre:   album.PrimaryGenre,
 		SecondaryGenre: album.SecondaryGenre,
+		MaxSongs:       cfg.DistrokidMaxSongs,
 	}
 
 	// Order songs by track number
@@ -243,28 +536,176 @@ func publish(ctx context.Context, cfg *Config, b *distrokid.Browser, store *stor
 		name := filestore.MP3(*s.GenerationID)
 		out := filepath.Join(os.TempDir(), name)
 		if err := fs.GetMP3(ctx, out, *s.GenerationID); err != nil {
-			return fmt.Errorf("publish: couldn't download song: %w", err)
+			return nil, fmt.Errorf("publish: couldn't download song: %w", err)
 		}
 		dkSong := &distrokid.Song{
 			Instrumental: s.Instrumental,
 			Title:        s.Title,
 			File:         out,
 		}
+		// Use the songwriter from the song credits if available
+		if fields := strings.Fields(s.Credit("songwriter")); len(fields) > 1 {
+			dkSong.FirstName = strings.Join(fields[:len(fields)-1], " ")
+			dkSong.LastName = fields[len(fields)-1]
+		}
 		dkAlbum.Songs = append(dkAlbum.Songs, dkSong)
 	}
+	return dkAlbum, nil
+}
+
+func publishBandcamp(ctx context.Context, cfg *Config, b *bandcamp.Browser, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+	bcAlbum, err := bandcampAlbum(ctx, cfg, store, fs, album)
+	if err != nil {
+		return err
+	}
 
 	// Publish album
-	dkID, err := b.Publish(ctx, dkAlbum, cfg.Auto)
+	u, err := b.Publish(ctx, bcAlbum, cfg.Auto)
 	if err != nil {
-		return fmt.Errorf("publish: couldn't distrokid publish %s: %w", album.ID, err)
+		return fmt.Errorf("publish: couldn't bandcamp publish %s: %w", album.ID, err)
 	}
 
 	// Update album
-	album.DistrokidID = dkID
-	album.PublishedAt = time.Now().UTC()
-	album.State = storage.Used
+	album.BandcampID = u
+	album.BandcampAt = time.Now().UTC()
 	if err := store.SetAlbum(ctx, album); err != nil {
-		return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, dkID, err)
+		return fmt.Errorf("publish: couldn't set album %s %s: %w", album.ID, u, err)
+	}
+	return nil
+}
+
+// bandcampAlbum downloads the cover and songs of the album and returns the
+// bandcamp album data.
+func bandcampAlbum(ctx context.Context, cfg *Config, store *storage.Store, fs *filestore.Store, album *storage.Album) (*bandcamp.Album, error) {
+	// Get songs for album
+	songs, err := store.ListSongs(ctx, 1, 100, "", storage.Where("album_id = ?", album.ID))
+	if err != nil {
+		return nil, fmt.Errorf("publish: couldn't get songs: %w", err)
+	}
+
+	// Download cover
+	name := filestore.JPG(album.ID)
+	cover := filepath.Join(os.TempDir(), name)
+	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
+		return nil, fmt.Errorf("publish: couldn't download cover: %w", err)
+	}
+
+	// Create bandcamp album data
+	bcAlbum := &bandcamp.Album{
+		Artist: album.Artist,
+		Title:  album.FullTitle(),
+		Cover:  cover,
+		Tags:   album.GenreTags(),
+		Price:  cfg.Price,
+	}
+
+	// Order songs by track number
+	sort.Slice(songs, func(i, j int) bool {
+		return songs[i].Order < songs[j].Order
+	})
+
+	// Create bandcamp song data
+	for _, s := range songs {
+		// Download song
+		name := filestore.MP3(*s.GenerationID)
+		out := filepath.Join(os.TempDir(), name)
+		if err := fs.GetMP3(ctx, out, *s.GenerationID); err != nil {
+			return nil, fmt.Errorf("publish: couldn't download song: %w", err)
+		}
+		bcAlbum.Songs = append(bcAlbum.Songs, &bandcamp.Song{
+			Title: s.Title,
+			File:  out,
+		})
+	}
+	return bcAlbum, nil
+}
+
+func publishSoundCloud(ctx context.Context, cfg *Config, c *soundcloud.Client, store *storage.Store, fs *filestore.Store, album *storage.Album) error {
+	// Get songs pending to be uploaded
+	songs, err := store.ListSongs(ctx, 1, 100, "",
+		storage.Where("album_id = ?", album.ID),
+		storage.Where("sound_cloud_id = ?", ""),
+	)
+	if err != nil {
+		return fmt.Errorf("publish: couldn't get songs: %w", err)
+	}
+
+	// Download cover
+	name := filestore.JPG(album.ID)
+	cover := filepath.Join(os.TempDir(), name)
+	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
+		return fmt.Errorf("publish: couldn't download cover: %w", err)
+	}
+	defer func() { _ = os.Remove(cover) }()
+
+	// Order songs by track number
+	sort.Slice(songs, func(i, j int) bool {
+		return songs[i].Order < songs[j].Order
+	})
+
+	tags := append([]string{album.Artist}, album.GenreTags()...)
+	for i, s := range songs {
+		// Wait for a random time between songs
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(randomWait(cfg.WaitMin, cfg.WaitMax)):
+			}
+		}
+
+		u, err := uploadSoundCloud(ctx, c, fs, album, s, tags, cover)
+		if err != nil {
+			return err
+		}
+
+		// Update song
+		s.SoundCloudID = u
+		if err := store.SetSong(ctx, s); err != nil {
+			return fmt.Errorf("publish: couldn't set song %s %s: %w", s.ID, u, err)
+		}
+		log.Printf("publish: song %s uploaded to soundcloud %s\n", s.ID, u)
 	}
 	return nil
 }
+
+// uploadSoundCloud converts the song to wav and uploads it to soundcloud,
+// removing the temporary files afterwards.
+func uploadSoundCloud(ctx context.Context, c *soundcloud.Client, fs *filestore.Store, album *storage.Album, s *storage.Song, tags []string, cover string) (string, error) {
+	// Download song
+	mp3 := filepath.Join(os.TempDir(), filestore.MP3(*s.GenerationID))
+	if err := fs.GetMP3(ctx, mp3, *s.GenerationID); err != nil {
+		return "", fmt.Errorf("publish: couldn't download song: %w", err)
+	}
+	defer func() { _ = os.Remove(mp3) }()
+
+	// Convert mp3 to wav
+	wav := filepath.Join(os.TempDir(), fmt.Sprintf("%s.wav", s.ID))
+	defer func() { _ = os.Remove(wav) }()
+	if err := ffmpeg.Convert(ctx, mp3, wav); err != nil {
+		return "", fmt.Errorf("publish: couldn't convert mp3 to wav: %w", err)
+	}
+
+	description := s.Description
+	if description == "" {
+		description = fmt.Sprintf("From the album %s by %s", album.FullTitle(), album.Artist)
+	}
+	u, err := c.Upload(ctx, &soundcloud.Track{
+		Title:       s.Title,
+		Description: description,
+		Tags:        tags,
+		File:        wav,
+		Cover:       cover,
+	})
+	if err != nil {
+		return "", fmt.Errorf("publish: couldn't soundcloud upload %s: %w", s.ID, err)
+	}
+	return u, nil
+}
+
+func randomWait(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return time.Duration(rand.Int63n(int64(max-min))) + min
+}
@@ -0,0 +1,139 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// targetPublisher publishes each album to its configured targets.
+// The client of each target is started the first time it is needed.
+type targetPublisher struct {
+	cfg   *Config
+	store *storage.Store
+	fs    *filestore.Store
+
+	lck        sync.Mutex
+	publishers map[string]func(context.Context, *storage.Album) error
+	stops      []func()
+}
+
+func newTargetPublisher(cfg *Config, store *storage.Store, fs *filestore.Store) *targetPublisher {
+	return &targetPublisher{
+		cfg:        cfg,
+		store:      store,
+		fs:         fs,
+		publishers: map[string]func(context.Context, *storage.Album) error{},
+	}
+}
+
+func (t *targetPublisher) get(ctx context.Context, target string) (func(context.Context, *storage.Album) error, error) {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+	if p, ok := t.publishers[target]; ok {
+		return p, nil
+	}
+	p, stop, err := newPublisher(ctx, t.cfg, target, t.store, t.fs)
+	if err != nil {
+		return nil, err
+	}
+	t.publishers[target] = p
+	t.stops = append(t.stops, stop)
+	return p, nil
+}
+
+func (t *targetPublisher) stop() {
+	t.lck.Lock()
+	defer t.lck.Unlock()
+	for _, stop := range t.stops {
+		stop()
+	}
+}
+
+// publish publishes the album to the targets where it isn't published yet.
+// A failed target doesn't prevent publishing to the next ones, except for
+// jamendo that waits until the album is released by distrokid and its UPC
+// and ISRCs are synced.
+func (t *targetPublisher) publish(ctx context.Context, album *storage.Album) error {
+	var errs []error
+	for _, target := range album.TargetList() {
+		pending, err := t.pending(ctx, album, target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !pending {
+			continue
+		}
+		if target == storage.TargetJamendo && album.HasTarget(storage.TargetDistrokid) && !releasedByDistrokid(album) {
+			log.Printf("publish: album %s waits for distrokid before publishing to jamendo\n", album.ID)
+			continue
+		}
+		publish, err := t.get(ctx, target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := publish(ctx, album); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("publish: album %s published to %s\n", album.ID, target)
+	}
+	return errors.Join(errs...)
+}
+
+// releasedByDistrokid returns whether the album has been released by
+// distrokid. The UPC is only set by sync-distrokid once the album is released
+// (along with the ISRCs of the songs), so it can't be inferred from the
+// state, which is updated as soon as the album is submitted.
+func releasedByDistrokid(album *storage.Album) bool {
+	return album.DistrokidID != "" && album.UPC != ""
+}
+
+// pending returns whether the album isn't published to the target yet.
+func (t *targetPublisher) pending(ctx context.Context, album *storage.Album, target string) (bool, error) {
+	switch target {
+	case storage.TargetDistrokid:
+		return album.DistrokidID == "", nil
+	case storage.TargetJamendo:
+		return album.JamendoID == "", nil
+	case storage.TargetBandcamp:
+		return album.BandcampID == "", nil
+	case storage.TargetSoundCloud:
+		n, err := t.store.CountSongs(ctx,
+			storage.Where("album_id = ?", album.ID),
+			storage.Where("sound_cloud_id = ?", ""),
+		)
+		if err != nil {
+			return false, fmt.Errorf("publish: couldn't count soundcloud pending songs: %w", err)
+		}
+		return n > 0, nil
+	default:
+		return false, fmt.Errorf("publish: unknown target %s", target)
+	}
+}
+
+// pendingTargets returns a filter that matches the albums with any of their
+// targets not published yet.
+func pendingTargets() storage.Filter {
+	var queries []string
+	var args []any
+	add := func(target, cond string, condArgs ...any) {
+		f := storage.HasTarget(target)
+		queries = append(queries, fmt.Sprintf("(%s AND %s)", f.Query, cond))
+		args = append(args, f.Args...)
+		args = append(args, condArgs...)
+	}
+	add(storage.TargetDistrokid, "albums.distrokid_id = ''")
+	add(storage.TargetJamendo, "albums.jamendo_id = ''")
+	add(storage.TargetBandcamp, "albums.bandcamp_id = ''")
+	add(storage.TargetSoundCloud, "EXISTS (SELECT 1 FROM songs WHERE songs.album_id = albums.id AND songs.sound_cloud_id = '' AND songs.state != ?)", storage.Rejected)
+	return storage.Where("("+strings.Join(queries, " OR ")+")", args...)
+}
@@ -0,0 +1,26 @@
+package publish
+
+import (
+	"testing"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func TestReleasedByDistrokid(t *testing.T) {
+	tests := []struct {
+		name  string
+		album *storage.Album
+		want  bool
+	}{
+		{"not submitted", &storage.Album{State: storage.Approved}, false},
+		{"submitted in this run", &storage.Album{State: storage.Used, DistrokidID: "dk"}, false},
+		{"synced", &storage.Album{State: storage.Used, DistrokidID: "dk", UPC: "123"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releasedByDistrokid(tt.album); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package setting
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/igolaizola/musikai/pkg/storage"
 )
@@ -36,12 +37,20 @@ func Run(ctx context.Context, cfg *Config) error {
 
 	switch cfg.Type {
 	case "cookie":
+	case "ratelimit":
+		d, err := time.ParseDuration(cfg.Value)
+		if err != nil {
+			return fmt.Errorf("setting: invalid ratelimit duration %q: %w", cfg.Value, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("setting: ratelimit must be positive: %s", cfg.Value)
+		}
 	default:
 		return fmt.Errorf("setting: unknown type: %s", cfg.Type)
 	}
 
 	switch cfg.Service {
-	case "distrokid", "suno", "discord", "udio", "jamendo":
+	case "distrokid", "suno", "discord", "udio", "jamendo", "youtube":
 	default:
 		return fmt.Errorf("setting: unknown service: %s", cfg.Service)
 	}
@@ -52,7 +61,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		Value: cfg.Value,
 	}
 	if err := store.SetSetting(ctx, &s); err != nil {
-		return fmt.Errorf("setting: couldn't save cookie: %w", err)
+		return fmt.Errorf("setting: couldn't save %s: %w", cfg.Type, err)
 	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"os"
 	"path/filepath"
@@ -41,6 +42,11 @@ type Config struct {
 	Overlay  string
 	Font     string
 	FontSize string
+
+	// CoverPairing is the strategy used to pick the cover of a song the first
+	// time it is processed (hash or random). The cover is then stored in the
+	// song so it is kept on later runs.
+	CoverPairing string
 }
 
 // Run launches the song generation process.
@@ -62,6 +68,13 @@ func Run(ctx context.Context, cfg *Config) error {
 	if cfg.ChannelID == "" {
 		return errors.New("single: channel ID is required")
 	}
+	switch cfg.CoverPairing {
+	case "":
+		cfg.CoverPairing = PairingHash
+	case PairingHash, PairingRandom:
+	default:
+		return fmt.Errorf("single: unknown cover pairing %q", cfg.CoverPairing)
+	}
 	if cfg.ChannelName == "" {
 		return errors.New("single: channel name is required")
 	}
@@ -202,7 +215,7 @@ func Run(ctx context.Context, cfg *Config) error {
 			go func() {
 				defer wg.Done()
 				debug("single: start %s %s", song.ID, song.Title)
-				err := process(ctx, browser, store, fs, ink, cfg.Font, cfg.FontSize, cfg.Overlay, song)
+				err := process(ctx, browser, store, fs, ink, cfg.Font, cfg.FontSize, cfg.Overlay, cfg.CoverPairing, song)
 				if err != nil {
 					log.Println(err)
 				}
@@ -213,7 +226,7 @@ func Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func process(ctx context.Context, b *youtube.Browser, store *storage.Store, fs *filestore.Store, ink *inkpic.Client, font, fontSize, overlay string, song *storage.Song) error {
+func process(ctx context.Context, b *youtube.Browser, store *storage.Store, fs *filestore.Store, ink *inkpic.Client, font, fontSize, overlay, pairing string, song *storage.Song) error {
 	// Choose a song title
 	if song.Title == "" {
 		// Get random title matching the type
@@ -233,20 +246,11 @@ func process(ctx context.Context, b *youtube.Browser, store *storage.Store, fs *
 		song.Title = resp[0].Title
 	}
 
-	// Choose a random cover
-	covers, err := store.ListCovers(ctx, 1, 1, "RANDOM()",
-		storage.Where("state = ?", storage.Approved),
-		storage.Where("type = ?", song.Type),
-		storage.Where("draft_id = ''"),
-		storage.Where("title = ''"),
-	)
+	// Get the cover paired with the song
+	bg, err := songCover(ctx, store, song, pairing)
 	if err != nil {
-		return fmt.Errorf("single: couldn't get cover from database: %w", err)
-	}
-	if len(covers) == 0 {
-		return errors.New("single: no cover found")
+		return err
 	}
-	bg := covers[0]
 
 	// Download background image
 	bgName := filestore.JPG(bg.ID)
@@ -290,3 +294,76 @@ func process(ctx context.Context, b *youtube.Browser, store *storage.Store, fs *
 	_ = b
 	return errors.New("single: not implemented yet")
 }
+
+const (
+	PairingHash   = "hash"
+	PairingRandom = "random"
+)
+
+// songCover returns the cover paired with the song. If the song doesn't have
+// a cover yet, an unused cover of the same type is chosen and saved to the
+// song, so the same cover is used on later runs.
+func songCover(ctx context.Context, store *storage.Store, song *storage.Song, pairing string) (*storage.Cover, error) {
+	if song.CoverID != "" {
+		cover, err := store.GetCover(ctx, song.CoverID)
+		switch {
+		case err == nil:
+			return cover, nil
+		case errors.Is(err, storage.ErrNotFound):
+			log.Printf("single: cover %s of song %s not found, choosing a new one\n", song.CoverID, song.ID)
+		default:
+			return nil, fmt.Errorf("single: couldn't get cover %s: %w", song.CoverID, err)
+		}
+	}
+
+	filters := []storage.Filter{
+		storage.Where("state = ?", storage.Approved),
+		storage.Where("type = ?", song.Type),
+		storage.Where("draft_id = ''"),
+		storage.Where("title = ''"),
+		storage.Where("NOT EXISTS (SELECT id FROM songs WHERE songs.cover_id = covers.id)"),
+		storage.Where("NOT EXISTS (SELECT id FROM albums WHERE albums.cover_id = covers.id)"),
+	}
+	var covers []*storage.Cover
+	switch pairing {
+	case PairingRandom:
+		var err error
+		covers, err = store.ListCovers(ctx, 1, 1, "RANDOM()", filters...)
+		if err != nil {
+			return nil, fmt.Errorf("single: couldn't get cover from database: %w", err)
+		}
+	default:
+		// Pick the cover using the hash of the song ID, so the same cover is
+		// chosen for the same set of candidates
+		n, err := store.CountCovers(ctx, filters...)
+		if err != nil {
+			return nil, fmt.Errorf("single: couldn't count covers: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+		page := int(pairingIndex(song.ID, n)) + 1
+		covers, err = store.ListCovers(ctx, page, 1, "id", filters...)
+		if err != nil {
+			return nil, fmt.Errorf("single: couldn't get cover from database: %w", err)
+		}
+	}
+	if len(covers) == 0 {
+		return nil, errors.New("single: no cover found")
+	}
+	cover := covers[0]
+
+	song.CoverID = cover.ID
+	if err := store.SetSong(ctx, song); err != nil {
+		return nil, fmt.Errorf("single: couldn't save song cover: %w", err)
+	}
+	return cover, nil
+}
+
+// pairingIndex returns the index of the cover for the song ID given the number
+// of candidates.
+func pairingIndex(songID string, n int64) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(songID))
+	return int64(h.Sum64() % uint64(n))
+}
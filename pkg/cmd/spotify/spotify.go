@@ -0,0 +1,310 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/igolaizola/musikai/pkg/errstreak"
+	"github.com/igolaizola/musikai/pkg/spotify"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type Config struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	Proxy  string
+
+	Timeout     time.Duration
+	Concurrency int
+	Limit       int
+	Type        string
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	SpotifyID     string
+	SpotifySecret string
+}
+
+// Run enriches the published songs without spotify analysis with the audio
+// features of their spotify tracks.
+// Tracks are matched by ISRC or, if the song doesn't have one, by title and
+// album artist.
+func Run(ctx context.Context, cfg *Config) error {
+	var iteration int
+	log.Println("spotify: process started")
+	defer func() {
+		log.Printf("spotify: process ended (%d)\n", iteration)
+	}()
+
+	debug := func(format string, args ...interface{}) {
+		if !cfg.Debug {
+			return
+		}
+		format += "\n"
+		log.Printf(format, args...)
+	}
+
+	if cfg.SpotifyID == "" || cfg.SpotifySecret == "" {
+		return errors.New("spotify: missing spotify credentials")
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("spotify: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("spotify: couldn't start orm store: %w", err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: 2 * time.Minute,
+	}
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy: http.ProxyURL(u),
+		}
+	}
+	spClient := spotify.New(&spotify.Config{
+		Wait:         1 * time.Second,
+		Debug:        cfg.Debug,
+		Client:       httpClient,
+		ClientID:     cfg.SpotifyID,
+		ClientSecret: cfg.SpotifySecret,
+	})
+	if err := spClient.Start(ctx); err != nil {
+		return fmt.Errorf("spotify: couldn't start spotify client: %w", err)
+	}
+
+	// Print time stats
+	start := time.Now()
+	defer func() {
+		total := time.Since(start)
+		log.Printf("spotify: total time %s, average time %s\n", total, total/time.Duration(iteration))
+	}()
+
+	streak := errstreak.New("spotify", cfg.MaxConsecutiveErrors)
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 24 * time.Hour
+	}
+	ticker := time.NewTicker(timeout)
+	last := time.Now()
+	defer ticker.Stop()
+
+	// Concurrency settings
+	concurrency := cfg.Concurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	errC := make(chan error, concurrency)
+	defer close(errC)
+	for i := 0; i < concurrency; i++ {
+		errC <- nil
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var songs []*storage.Song
+	var currID string
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("spotify: %w", ctx.Err())
+		case <-ticker.C:
+			return nil
+		case err := <-errC:
+			// Check exit conditions
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if cfg.Limit > 0 && iteration >= cfg.Limit {
+				return nil
+			}
+
+			iteration++
+			if time.Since(last) > 60*time.Minute {
+				last = time.Now()
+				log.Printf("spotify: iteration %d\n", iteration)
+			}
+
+			// Get next songs
+			filters := []storage.Filter{
+				storage.Where("songs.id > ?", currID),
+				storage.Where("songs.state = ?", storage.Used),
+				storage.Where("songs.spotify_analysis = ''"),
+				storage.Where("(songs.isrc != '' OR songs.album_id != '')"),
+			}
+			if cfg.Type != "" {
+				filters = append(filters, storage.Like("songs.type", cfg.Type))
+			}
+
+			if len(songs) == 0 {
+				// Get songs from the database.
+				var err error
+				songs, err = store.ListSongs(ctx, 1, 100, "songs.id", filters...)
+				if err != nil {
+					return fmt.Errorf("spotify: couldn't get songs from database: %w", err)
+				}
+				if len(songs) == 0 {
+					return errors.New("spotify: no songs to process")
+				}
+				currID = songs[len(songs)-1].ID
+			}
+			song := songs[0]
+			songs = songs[1:]
+
+			// Launch enrich in a goroutine
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				debug("spotify: start %s %s", song.ID, song.Title)
+				err := enrich(ctx, spClient, store, song)
+				if err != nil {
+					log.Println(err)
+				}
+				debug("spotify: end %s %s", song.ID, song.Title)
+				errC <- err
+			}()
+		}
+	}
+}
+
+var errNoMatch = errors.New("spotify: no matching track")
+
+func enrich(ctx context.Context, sp *spotify.Client, store *storage.Store, song *storage.Song) error {
+	// Find the spotify track
+	trackID := song.SpotifyID
+	if trackID == "" {
+		track, err := findTrack(ctx, sp, store, song)
+		if errors.Is(err, errNoMatch) {
+			// A missing track isn't a failure of the process
+			log.Printf("spotify: no track found for song %s (%s)\n", song.ID, song.Title)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		trackID = track.ID
+	}
+
+	// Get the audio features
+	analysis, err := sp.AudioFeatures(ctx, trackID)
+	if err != nil {
+		return fmt.Errorf("spotify: couldn't get song analysis (%s / %s): %w", song.ID, song.Title, err)
+	}
+	js, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("spotify: couldn't marshal song analysis: %w", err)
+	}
+	song.SpotifyID = trackID
+	song.SpotifyAnalysis = string(js)
+	if err := store.SetSong(ctx, song); err != nil {
+		return fmt.Errorf("spotify: couldn't set song: %w", err)
+	}
+	log.Printf("spotify: song enriched %s (%s) energy %.2f valence %.2f acousticness %.2f danceability %.2f tempo %.0f\n",
+		song.ID, song.Title, analysis.Energy, analysis.Valence, analysis.Acousticness, analysis.Danceability, analysis.Tempo)
+	return nil
+}
+
+// findTrack searches the spotify track of the song by ISRC or, if the song
+// doesn't have one, by title and album artist.
+func findTrack(ctx context.Context, sp *spotify.Client, store *storage.Store, song *storage.Song) (*spotify.Track, error) {
+	if song.ISRC != "" {
+		tracks, err := sp.SearchTracks(ctx, "isrc:"+song.ISRC)
+		if err != nil {
+			return nil, fmt.Errorf("spotify: couldn't search isrc %s: %w", song.ISRC, err)
+		}
+		if len(tracks) == 0 {
+			return nil, errNoMatch
+		}
+		return &tracks[0], nil
+	}
+
+	if song.Title == "" {
+		return nil, errNoMatch
+	}
+	album, err := store.GetAlbum(ctx, song.AlbumID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errNoMatch
+	}
+	if err != nil {
+		return nil, fmt.Errorf("spotify: couldn't get album %s: %w", song.AlbumID, err)
+	}
+	query := fmt.Sprintf("track:%q artist:%q", song.Title, album.Artist)
+	tracks, err := sp.SearchTracks(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("spotify: couldn't search %s: %w", query, err)
+	}
+	var duration time.Duration
+	if song.Generation != nil {
+		duration = time.Duration(song.Generation.Duration) * time.Second
+	}
+	track := matchTrack(tracks, song.Title, album.Artist, duration)
+	if track == nil {
+		return nil, errNoMatch
+	}
+	return track, nil
+}
+
+// matchTrack returns the first track with the same normalized title and
+// artist. If the duration is known, tracks that differ more than 10 seconds
+// are discarded.
+func matchTrack(tracks []spotify.Track, title, artist string, duration time.Duration) *spotify.Track {
+	title = normalize(title)
+	artist = normalize(artist)
+	for i := range tracks {
+		t := &tracks[i]
+		if normalize(t.Name) != title {
+			continue
+		}
+		var found bool
+		for _, a := range t.Artists {
+			if normalize(a) == artist {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		if duration > 0 && t.Duration > 0 {
+			diff := t.Duration - duration
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > 10*time.Second {
+				continue
+			}
+		}
+		return t
+	}
+	return nil
+}
+
+// normalize lowercases the text and removes everything but letters and
+// numbers, so punctuation and spacing differences are ignored.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
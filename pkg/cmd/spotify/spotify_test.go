@@ -0,0 +1,38 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igolaizola/musikai/pkg/spotify"
+)
+
+func TestMatchTrack(t *testing.T) {
+	tracks := []spotify.Track{
+		{ID: "1", Name: "Blue Moon", Artists: []string{"Other"}, Duration: 120 * time.Second},
+		{ID: "2", Name: "Blue Moon (Live)", Artists: []string{"Artist"}, Duration: 120 * time.Second},
+		{ID: "3", Name: "Blue Moon", Artists: []string{"Feat", "The Artist"}, Duration: 200 * time.Second},
+		{ID: "4", Name: "blue moon!", Artists: []string{"Feat", "The Artist"}, Duration: 125 * time.Second},
+	}
+	tests := []struct {
+		title    string
+		artist   string
+		duration time.Duration
+		want     string
+	}{
+		{"Blue Moon", "the artist", 120 * time.Second, "4"},
+		{"Blue Moon", "The Artist", 0, "3"},
+		{"Blue moon", "Artist", 0, ""},
+		{"Red Moon", "Other", 0, ""},
+	}
+	for _, tt := range tests {
+		got := matchTrack(tracks, tt.title, tt.artist, tt.duration)
+		var id string
+		if got != nil {
+			id = got.ID
+		}
+		if id != tt.want {
+			t.Errorf("matchTrack(%q, %q, %s) = %q, want %q", tt.title, tt.artist, tt.duration, id, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type ReviewConfig struct {
+	Debug  bool
+	DBType string
+	DBConn string
+
+	// Action is list, confirm or reject
+	Action string
+	Video  string
+	Song   string
+}
+
+// RunYoutubeReview lists the youtube matches pending of review and confirms
+// or rejects them.
+func RunYoutubeReview(ctx context.Context, cfg *ReviewConfig) error {
+	switch cfg.Action {
+	case "", "list":
+	case "confirm", "reject":
+		if cfg.Video == "" {
+			return fmt.Errorf("youtube-review: video is required to %s a match", cfg.Action)
+		}
+	default:
+		return fmt.Errorf("youtube-review: unknown action %q", cfg.Action)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("youtube-review: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("youtube-review: couldn't start orm store: %w", err)
+	}
+
+	switch cfg.Action {
+	case "confirm":
+		return confirmMatch(ctx, store, cfg.Video, cfg.Song)
+	case "reject":
+		return rejectMatch(ctx, store, cfg.Video)
+	}
+
+	// List pending matches, unmatched videos first
+	var matched, unmatched int
+	page := 1
+	for {
+		matches, err := store.ListYoutubeMatches(ctx, page, 100, "confidence, published_at",
+			storage.Where("state = ?", storage.Pending))
+		if err != nil {
+			return fmt.Errorf("youtube-review: couldn't list matches: %w", err)
+		}
+		for _, m := range matches {
+			date := m.PublishedAt.Format("2006-01-02")
+			if m.Song == nil {
+				unmatched++
+				fmt.Printf("❌ %s | %s | %s | unmatched\n", m.ID, date, m.Title)
+				continue
+			}
+			matched++
+			fmt.Printf("❓ %s | %s | %s | %s %q (%.2f)\n", m.ID, date, m.Title, m.Song.ID, m.Song.Title, m.Confidence)
+		}
+		if len(matches) < 100 {
+			break
+		}
+		page++
+	}
+	log.Printf("youtube-review: %d matches to review, %d unmatched videos\n", matched, unmatched)
+	return nil
+}
+
+// confirmMatch links the video to the song of the match or to the given song.
+func confirmMatch(ctx context.Context, store *storage.Store, video, songID string) error {
+	match, err := store.GetYoutubeMatch(ctx, video)
+	if err != nil {
+		return fmt.Errorf("youtube-review: couldn't get match %s: %w", video, err)
+	}
+	if songID == "" {
+		if match.SongID == nil {
+			return errors.New("youtube-review: video is unmatched, a song is required")
+		}
+		songID = *match.SongID
+	}
+	song, err := store.GetSong(ctx, songID)
+	if err != nil {
+		return fmt.Errorf("youtube-review: couldn't get song %s: %w", songID, err)
+	}
+	if song.YoutubeID != "" && song.YoutubeID != video {
+		log.Printf("youtube-review: replacing youtube id %q of song %q\n", song.YoutubeID, song.Title)
+	}
+	song.YoutubeID = video
+	if err := store.SetSong(ctx, song); err != nil {
+		return fmt.Errorf("youtube-review: couldn't update song: %w", err)
+	}
+
+	match.SongID = &song.ID
+	match.Song = nil
+	match.Confidence = 1
+	match.State = storage.Approved
+	if err := store.SetYoutubeMatch(ctx, match); err != nil {
+		return fmt.Errorf("youtube-review: couldn't save match: %w", err)
+	}
+	log.Printf("youtube-review: video %s linked to song %q\n", video, song.Title)
+	return nil
+}
+
+// rejectMatch rejects the match and unlinks the video from its song.
+func rejectMatch(ctx context.Context, store *storage.Store, video string) error {
+	match, err := store.GetYoutubeMatch(ctx, video)
+	if err != nil {
+		return fmt.Errorf("youtube-review: couldn't get match %s: %w", video, err)
+	}
+	if match.Song != nil && match.Song.YoutubeID == video {
+		song := match.Song
+		song.YoutubeID = ""
+		if err := store.SetSong(ctx, song); err != nil {
+			return fmt.Errorf("youtube-review: couldn't update song: %w", err)
+		}
+	}
+	match.Song = nil
+	match.State = storage.Rejected
+	if err := store.SetYoutubeMatch(ctx, match); err != nil {
+		return fmt.Errorf("youtube-review: couldn't save match: %w", err)
+	}
+	log.Printf("youtube-review: match of video %s rejected\n", video)
+	return nil
+}
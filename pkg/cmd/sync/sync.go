@@ -19,12 +19,21 @@ type Config struct {
 	Limit       int
 	Account     string
 
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
 	SpotifyID     string
 	SpotifySecret string
 
-	YoutubeKey string
-	Channels   string
-	From       string
+	YoutubeKey   string
+	YoutubeWait  time.Duration
+	YoutubeQuota int
+	// YoutubeMinConfidence is the minimum confidence to link a video to a
+	// song without review.
+	YoutubeMinConfidence float64
+	Channels             string
+	From                 string
 }
 
 func Run(ctx context.Context, cfg *Config) error {
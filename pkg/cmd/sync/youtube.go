@@ -2,6 +2,7 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/storage"
 	"github.com/igolaizola/musikai/pkg/youtube"
 )
@@ -29,7 +31,8 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 		log.Printf(format, args...)
 	}
 
-	from := time.Now().AddDate(-1, 0, 0)
+	// If from isn't set, each channel is synced from its stored cursor
+	var from time.Time
 	if cfg.From != "" {
 		var err error
 		from, err = time.Parse("2006-01-02", cfg.From)
@@ -57,11 +60,24 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 		return errors.New("sync-youtube: no songs with missing youtube id")
 	}
 
+	minConfidence := cfg.YoutubeMinConfidence
+	if minConfidence == 0 {
+		minConfidence = 0.9
+	}
+
 	// Create youtube client
-	client, err := youtube.New(ctx, cfg.YoutubeKey, cfg.Debug)
+	client, err := youtube.New(ctx, &youtube.Config{
+		Key:   cfg.YoutubeKey,
+		Debug: cfg.Debug,
+		Wait:  cfg.YoutubeWait,
+		Quota: cfg.YoutubeQuota,
+	})
 	if err != nil {
 		return fmt.Errorf("sync-youtube: couldn't create youtube client: %w", err)
 	}
+	defer func() {
+		log.Printf("sync-youtube: estimated quota used %d units\n", client.QuotaUsed())
+	}()
 
 	// Get channels
 	channels := strings.Split(cfg.Channels, ",")
@@ -79,7 +95,7 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 		log.Printf("sync-youtube: total time %s, average time %s\n", total, total/time.Duration(iteration))
 	}()
 
-	nErr := 0
+	streak := errstreak.New("sync-youtube", cfg.MaxConsecutiveErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -108,15 +124,13 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 		case <-ticker.C:
 			return nil
 		case err := <-errC:
-			if err != nil {
-				nErr += 1
-			} else {
-				nErr = 0
+			if errors.Is(err, youtube.ErrQuotaExceeded) {
+				log.Println("sync-youtube: quota exhausted, run again later to resume")
+				return nil
 			}
-
 			// Check exit conditions
-			if nErr > 10 {
-				return fmt.Errorf("sync-youtube: too many consecutive errors: %w", err)
+			if err := streak.Add(err); err != nil {
+				return err
 			}
 
 			iteration++
@@ -137,7 +151,7 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 			go func() {
 				defer wg.Done()
 				debug("sync-youtube: start %s", channel)
-				err := syncChannel(ctx, client, store, from, channel)
+				err := syncChannel(ctx, client, store, from, channel, minConfidence)
 				if err != nil {
 					log.Println(err)
 				}
@@ -148,34 +162,235 @@ func RunYoutube(ctx context.Context, cfg *Config) error {
 	}
 }
 
-func syncChannel(ctx context.Context, c *youtube.Client, store *storage.Store, from time.Time, channel string) error {
-	videos, err := c.GetVideos(ctx, channel, from)
+// youtubeProgress stores the sync progress of a channel so that an interrupted
+// run can be resumed without fetching the same videos again.
+type youtubeProgress struct {
+	// SyncedAt is the time until which all the videos have been synced.
+	SyncedAt time.Time `json:"synced_at"`
+	// Cursor is the publish time of the last synced video, it is used as the
+	// from date of the next run if it isn't set.
+	Cursor time.Time `json:"cursor,omitempty"`
+	// Before and Until are set when a sync is interrupted, videos published
+	// between them have already been synced.
+	Before time.Time `json:"before,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+}
+
+func youtubeProgressID(channel string) string {
+	return fmt.Sprintf("youtube/%s/progress", channel)
+}
+
+func getYoutubeProgress(ctx context.Context, store *storage.Store, channel string) (*youtubeProgress, error) {
+	var p youtubeProgress
+	setting, err := store.GetSetting(ctx, youtubeProgressID(channel))
+	if errors.Is(err, storage.ErrNotFound) {
+		return &p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sync-youtube: couldn't get progress: %w", err)
+	}
+	if err := json.Unmarshal([]byte(setting.Value), &p); err != nil {
+		return nil, fmt.Errorf("sync-youtube: couldn't unmarshal progress: %w", err)
+	}
+	return &p, nil
+}
+
+func setYoutubeProgress(ctx context.Context, store *storage.Store, channel string, p *youtubeProgress) error {
+	b, err := json.Marshal(p)
 	if err != nil {
-		return fmt.Errorf("sync-youtube: couldn't get videos: %w", err)
+		return fmt.Errorf("sync-youtube: couldn't marshal progress: %w", err)
 	}
+	if err := store.SetSetting(ctx, &storage.Setting{
+		ID:    youtubeProgressID(channel),
+		Value: string(b),
+	}); err != nil {
+		return fmt.Errorf("sync-youtube: couldn't set progress: %w", err)
+	}
+	return nil
+}
+
+func syncChannel(ctx context.Context, c *youtube.Client, store *storage.Store, from time.Time, channel string, minConfidence float64) error {
+	progress, err := getYoutubeProgress(ctx, store, channel)
+	if err != nil {
+		return err
+	}
+
+	// Resume from the stored cursor unless from is set
+	from = syncFrom(from, progress, time.Now().UTC())
+	before := progress.Before
+	until := time.Now().UTC()
+	if !progress.Before.IsZero() {
+		until = progress.Until
+		log.Printf("sync-youtube: resuming %s from %s to %s\n", channel, from.Format(time.DateOnly), before.Format(time.DateOnly))
+	}
+
+	videos, fetchErr := c.GetVideos(ctx, channel, from, before)
+	cursor := progress.Cursor
 	for _, video := range videos {
-		songs, err := store.ListSongs(ctx, 1, 1, "",
-			storage.Where("songs.title = ?", video.Title),
+		if err := syncVideo(ctx, store, channel, video, minConfidence); err != nil {
+			return err
+		}
+		if before.IsZero() || video.PublishedAt.Before(before) {
+			before = video.PublishedAt
+		}
+		if video.PublishedAt.After(cursor) {
+			cursor = video.PublishedAt
+		}
+	}
+	if fetchErr != nil {
+		// Save progress so that the next run can continue where this one
+		// stopped, the cursor isn't updated until the sync is complete
+		if err := setYoutubeProgress(ctx, store, channel, &youtubeProgress{
+			SyncedAt: progress.SyncedAt,
+			Cursor:   progress.Cursor,
+			Before:   before,
+			Until:    until,
+		}); err != nil {
+			return err
+		}
+		return fmt.Errorf("sync-youtube: couldn't get videos: %w", fetchErr)
+	}
+	return setYoutubeProgress(ctx, store, channel, &youtubeProgress{
+		SyncedAt: until,
+		Cursor:   cursor,
+	})
+}
+
+// defaultSyncDays is the number of days synced when there is no from date
+// nor stored cursor.
+const defaultSyncDays = 30
+
+// syncFrom returns the date to sync a channel from.
+// An explicit from date is used as is, otherwise the stored cursor is used,
+// falling back to the last sync time of progress stored by older versions
+// and to the last 30 days on the first run.
+func syncFrom(from time.Time, p *youtubeProgress, now time.Time) time.Time {
+	switch {
+	case !from.IsZero():
+		return from
+	case !p.Cursor.IsZero():
+		return p.Cursor
+	case !p.SyncedAt.IsZero():
+		return p.SyncedAt
+	default:
+		return now.AddDate(0, 0, -defaultSyncDays)
+	}
+}
+
+func syncVideo(ctx context.Context, store *storage.Store, channel string, video youtube.Video, minConfidence float64) error {
+	// Skip videos that have already been reviewed
+	match, err := store.GetYoutubeMatch(ctx, video.ID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		match = &storage.YoutubeMatch{ID: video.ID}
+	case err != nil:
+		return fmt.Errorf("sync-youtube: couldn't get match: %w", err)
+	case match.State != storage.Pending:
+		return nil
+	}
+	match.Channel = channel
+	match.Title = video.Title
+	match.Duration = float32(video.Duration.Seconds())
+	match.PublishedAt = video.PublishedAt
+
+	song, confidence, err := matchSong(ctx, store, video)
+	if err != nil {
+		return err
+	}
+	match.SongID = nil
+	match.Song = nil
+	match.Confidence = float32(confidence)
+	switch {
+	case song == nil:
+		log.Printf("sync-youtube: song not found %q\n", video.Title)
+	case song.YoutubeID != "" && song.YoutubeID != video.ID:
+		log.Printf("sync-youtube: song %q has different youtube id %q != %q\n", song.Title, song.YoutubeID, video.ID)
+		match.SongID = &song.ID
+	case confidence < minConfidence:
+		log.Printf("sync-youtube: low confidence match %q (%.2f)\n", video.Title, confidence)
+		match.SongID = &song.ID
+	default:
+		match.SongID = &song.ID
+		match.State = storage.Approved
+		if song.YoutubeID != video.ID {
+			song.YoutubeID = video.ID
+			if err := store.SetSong(ctx, song); err != nil {
+				return fmt.Errorf("sync-youtube: couldn't update song: %w", err)
+			}
+		}
+	}
+	if err := store.SetYoutubeMatch(ctx, match); err != nil {
+		return fmt.Errorf("sync-youtube: couldn't save match: %w", err)
+	}
+	return nil
+}
+
+// matchSong returns the used song that best matches the video and the
+// confidence of the match.
+func matchSong(ctx context.Context, store *storage.Store, video youtube.Video) (*storage.Song, float64, error) {
+	songs, err := store.ListSongs(ctx, 1, 10, "",
+		storage.Where("songs.title = ?", video.Title),
+		storage.Where("state = ?", storage.Used),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("sync-youtube: couldn't list songs: %w", err)
+	}
+	if len(songs) == 0 {
+		// Fallback to a case insensitive match
+		songs, err = store.ListSongs(ctx, 1, 10, "",
+			storage.Where("LOWER(songs.title) = ?", strings.ToLower(strings.TrimSpace(video.Title))),
 			storage.Where("state = ?", storage.Used),
 		)
 		if err != nil {
-			return fmt.Errorf("sync-youtube: couldn't list songs: %w", err)
+			return nil, 0, fmt.Errorf("sync-youtube: couldn't list songs: %w", err)
 		}
-		if len(songs) == 0 {
-			log.Printf("sync-youtube: song not found %q\n", video.Title)
-			continue
+	}
+	var best *storage.Song
+	var bestConfidence float64
+	for _, song := range songs {
+		var duration time.Duration
+		if song.Generation != nil {
+			duration = time.Duration(song.Generation.Duration * float32(time.Second))
 		}
-		song := songs[0]
-		if song.YoutubeID != "" {
-			if song.YoutubeID != video.ID {
-				log.Printf("sync-youtube: song %q has different youtube id %q != %q\n", song.Title, song.YoutubeID, video.ID)
-			}
-			continue
+		confidence := matchConfidence(video.Title, song.Title, video.Duration, duration)
+		// Prefer songs that aren't linked to another video
+		if song.YoutubeID != "" && song.YoutubeID != video.ID {
+			confidence /= 2
 		}
-		song.YoutubeID = video.ID
-		if err := store.SetSong(ctx, song); err != nil {
-			return fmt.Errorf("sync-youtube: couldn't update song: %w", err)
+		if best == nil || confidence > bestConfidence {
+			best = song
+			bestConfidence = confidence
 		}
 	}
-	return nil
+	return best, bestConfidence, nil
+}
+
+// matchConfidence returns the confidence (0 to 1) of a video matching a song
+// based on their titles and durations.
+// Zero durations are considered unknown.
+func matchConfidence(videoTitle, songTitle string, videoDuration, songDuration time.Duration) float64 {
+	var confidence float64
+	switch {
+	case videoTitle == songTitle:
+		confidence = 1
+	case strings.EqualFold(strings.TrimSpace(videoTitle), strings.TrimSpace(songTitle)):
+		confidence = 0.9
+	default:
+		return 0
+	}
+	if videoDuration == 0 || songDuration == 0 {
+		return confidence * 0.9
+	}
+	diff := videoDuration - songDuration
+	if diff < 0 {
+		diff = -diff
+	}
+	switch {
+	case diff <= 2*time.Second:
+	case diff <= 10*time.Second:
+		confidence *= 0.8
+	default:
+		confidence *= 0.4
+	}
+	return confidence
 }
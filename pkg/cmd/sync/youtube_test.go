@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchConfidence(t *testing.T) {
+	min := time.Minute
+	tests := []struct {
+		name          string
+		videoTitle    string
+		songTitle     string
+		videoDuration time.Duration
+		songDuration  time.Duration
+		want          float64
+	}{
+		{"exact", "Blue Sky", "Blue Sky", 3 * min, 3*min + time.Second, 1},
+		{"case", "blue sky ", "Blue Sky", 3 * min, 3 * min, 0.9},
+		{"different title", "Blue Sky", "Red Sky", 3 * min, 3 * min, 0},
+		{"unknown duration", "Blue Sky", "Blue Sky", 0, 3 * min, 0.9},
+		{"close duration", "Blue Sky", "Blue Sky", 3 * min, 3*min + 8*time.Second, 0.8},
+		{"different duration", "Blue Sky", "Blue Sky", 3 * min, 4 * min, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchConfidence(tt.videoTitle, tt.songTitle, tt.videoDuration, tt.songDuration)
+			if d := got - tt.want; d > 1e-9 || d < -1e-9 {
+				t.Errorf("got %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncFrom(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC)
+	synced := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from     time.Time
+		progress *youtubeProgress
+		want     time.Time
+	}{
+		{"first run", time.Time{}, &youtubeProgress{}, now.AddDate(0, 0, -defaultSyncDays)},
+		{"explicit from", from, &youtubeProgress{Cursor: cursor}, from},
+		{"cursor", time.Time{}, &youtubeProgress{SyncedAt: synced, Cursor: cursor}, cursor},
+		{"legacy progress", time.Time{}, &youtubeProgress{SyncedAt: synced}, synced},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syncFrom(tt.from, tt.progress, now); !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package title
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+type AuditConfig struct {
+	Debug       bool
+	DBType      string
+	DBConn      string
+	Type        string
+	BannedWords string
+	Reject      bool
+}
+
+// Audit scans the title pool and reports the titles that contain banned
+// words. If reject is set, those titles are marked as rejected.
+func Audit(ctx context.Context, cfg *AuditConfig) error {
+	var total, found int
+	log.Println("title-audit: started")
+	defer func() {
+		log.Printf("title-audit: ended (%d/%d)\n", found, total)
+	}()
+
+	banned, err := NewBanned(cfg.BannedWords)
+	if err != nil {
+		return err
+	}
+	if banned == nil {
+		return errors.New("title-audit: banned words not set")
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("title-audit: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("title-audit: couldn't start orm store: %w", err)
+	}
+
+	var currID string
+	for {
+		filters := []storage.Filter{
+			storage.Where("id > ?", currID),
+		}
+		if cfg.Type != "" {
+			filters = append(filters, storage.Where("type LIKE ?", cfg.Type))
+		}
+		titles, err := store.ListTitles(ctx, 1, 100, "id asc", filters...)
+		if err != nil {
+			return fmt.Errorf("title-audit: couldn't list titles: %w", err)
+		}
+		if len(titles) == 0 {
+			return nil
+		}
+		currID = titles[len(titles)-1].ID
+
+		for _, t := range titles {
+			total++
+			word, ok := banned.Match(t.Title)
+			if !ok {
+				continue
+			}
+			found++
+			fmt.Printf("%s\t%s\t%s\t%s\n", t.ID, t.Type, t.Title, word)
+			if !cfg.Reject || t.State == storage.Used {
+				continue
+			}
+			t.State = storage.Rejected
+			if err := store.SetTitle(ctx, t); err != nil {
+				return fmt.Errorf("title-audit: couldn't set title: %w", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package title
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode"
+
+	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/oklog/ulid/v2"
+)
+
+var autoNouns = []string{
+	"Dreams", "Nights", "Echoes", "Horizons", "Waves", "Memories", "Moments",
+	"Skies", "Lights", "Shadows", "Journey", "Reflections", "Whispers", "Tides",
+}
+
+// Auto generates a title from the keywords of the style (or the type if the
+// style is empty) and stores it as an approved title.
+// Titles already in the database or in exclude aren't repeated and titles
+// with banned words are skipped.
+func Auto(ctx context.Context, store *storage.Store, typ, style string, exclude []string, banned *Banned) (*storage.Title, error) {
+	keywords := strings.FieldsFunc(style, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(keywords) == 0 {
+		keywords = strings.FieldsFunc(typ, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+	}
+	if len(keywords) == 0 {
+		keywords = []string{"Untitled"}
+	}
+
+	excluded := map[string]struct{}{}
+	for _, e := range exclude {
+		excluded[e] = struct{}{}
+	}
+
+	var candidate string
+	for i := 0; i < 20; i++ {
+		keyword := keywords[rand.Intn(len(keywords))]
+		candidate = fmt.Sprintf("%s %s", capitalize(keyword), autoNouns[rand.Intn(len(autoNouns))])
+		if i >= 10 {
+			candidate = fmt.Sprintf("%s %d", candidate, i-8)
+		}
+		if _, ok := excluded[candidate]; ok {
+			continue
+		}
+		if _, ok := banned.Match(candidate); ok {
+			continue
+		}
+		existing, err := store.ListTitles(ctx, 1, 1, "", storage.Where("title = ?", candidate))
+		if err != nil {
+			return nil, fmt.Errorf("title: couldn't check title: %w", err)
+		}
+		if len(existing) > 0 {
+			continue
+		}
+		t := &storage.Title{
+			ID:    ulid.Make().String(),
+			Type:  typ,
+			Style: style,
+			Title: candidate,
+			State: storage.Approved,
+		}
+		if err := store.SetTitle(ctx, t); err != nil {
+			return nil, fmt.Errorf("title: couldn't set title: %w", err)
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("title: couldn't generate a unique title for %s", typ)
+}
+
+func capitalize(s string) string {
+	s = strings.ToLower(s)
+	rs := []rune(s)
+	rs[0] = unicode.ToUpper(rs[0])
+	return string(rs)
+}
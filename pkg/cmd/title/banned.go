@@ -0,0 +1,60 @@
+package title
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Banned matches titles containing banned words.
+// Matching is case-insensitive and whole-word.
+type Banned struct {
+	re *regexp.Regexp
+}
+
+// NewBanned returns a banned words matcher from a comma separated list or a
+// file with one word per line.
+// A nil matcher is returned if there are no words.
+func NewBanned(words string) (*Banned, error) {
+	if words == "" {
+		return nil, nil
+	}
+	sep := ","
+	if _, err := os.Stat(words); err == nil {
+		b, err := os.ReadFile(words)
+		if err != nil {
+			return nil, fmt.Errorf("title: couldn't read banned words: %w", err)
+		}
+		words = string(b)
+		sep = "\n"
+	}
+	var quoted []string
+	for _, w := range strings.Split(words, sep) {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		quoted = append(quoted, regexp.QuoteMeta(w))
+	}
+	if len(quoted) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile(fmt.Sprintf(`(?i)(?:^|[^\p{L}\p{N}])(%s)(?:$|[^\p{L}\p{N}])`, strings.Join(quoted, "|")))
+	if err != nil {
+		return nil, fmt.Errorf("title: couldn't compile banned words: %w", err)
+	}
+	return &Banned{re: re}, nil
+}
+
+// Match returns the first banned word found in the title.
+func (b *Banned) Match(title string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	m := b.re.FindStringSubmatch(title)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,33 @@
+package title
+
+import "testing"
+
+func TestBanned(t *testing.T) {
+	b, err := NewBanned("free, Spotify ,lo-fi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		title string
+		word  string
+		ok    bool
+	}{
+		{"Free Jazz", "Free", true},
+		{"Jazz for spotify", "spotify", true},
+		{"Lo-Fi Dreams", "Lo-Fi", true},
+		{"Freedom Nights", "", false},
+		{"Carefree", "", false},
+		{"Midnight Echoes", "", false},
+	}
+	for _, tt := range tests {
+		word, ok := b.Match(tt.title)
+		if ok != tt.ok || word != tt.word {
+			t.Errorf("%q: expected (%q, %v), got (%q, %v)", tt.title, tt.word, tt.ok, word, ok)
+		}
+	}
+
+	var empty *Banned
+	if _, ok := empty.Match("Free Jazz"); ok {
+		t.Error("nil matcher shouldn't match")
+	}
+}
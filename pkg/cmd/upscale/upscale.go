@@ -8,14 +8,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/errstreak"
 	"github.com/igolaizola/musikai/pkg/filestore"
 	"github.com/igolaizola/musikai/pkg/ratelimit"
 	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/igolaizola/musikai/pkg/timing"
 	"github.com/igolaizola/musikai/pkg/upscale"
 )
 
@@ -37,6 +40,12 @@ type Config struct {
 	UpscaleType       string
 	UpscaleBin        string
 	UploadConcurrency int
+	MaxAttempts       int
+	Profile           bool
+
+	// MaxConsecutiveErrors is the number of consecutive upscale or upload
+	// errors tolerated before aborting (5 if not positive).
+	MaxConsecutiveErrors int
 }
 
 // Run runs the upscale process.
@@ -67,9 +76,18 @@ func Run(ctx context.Context, cfg *Config) error {
 
 	// Print time stats
 	start := time.Now()
+	prof := timing.New(cfg.Profile)
 	defer func() {
 		total := time.Since(start)
 		log.Printf("upscale: total time %s, average time %s\n", total, total/time.Duration(iteration))
+		prof.Log("upscale")
+	}()
+
+	// Report covers that exceeded the maximum number of attempts
+	defer func() {
+		if err := reportFailed(ctx, store, cfg.Type); err != nil {
+			log.Println(err)
+		}
 	}()
 	var totalTime time.Duration
 	var upscaleTime time.Duration
@@ -81,7 +99,11 @@ func Run(ctx context.Context, cfg *Config) error {
 		log.Printf("upscale: sum time %s, upscale %s (%.2f%%)\n", totalTime, upscaleTime, float64(upscaleTime)/float64(totalTime)*100)
 	}()
 
-	var nErr int
+	maxErrors := cfg.MaxConsecutiveErrors
+	if maxErrors <= 0 {
+		maxErrors = 5
+	}
+	streak := errstreak.New("upscale", maxErrors)
 	timeout := cfg.Timeout
 	if timeout == 0 {
 		timeout = 24 * time.Hour
@@ -91,9 +113,10 @@ func Run(ctx context.Context, cfg *Config) error {
 	last := time.Now()
 
 	// Concurrency settings
+	// Zero means one process per CPU, as this step is CPU bound
 	concurrency := cfg.Concurrency
 	if concurrency == 0 {
-		concurrency = 1
+		concurrency = runtime.NumCPU()
 	}
 	errC := make(chan error, concurrency)
 	defer close(errC)
@@ -125,15 +148,12 @@ func Run(ctx context.Context, cfg *Config) error {
 			return nil
 		case err = <-errC:
 		}
-		if err != nil {
-			nErr++
-		} else {
-			nErr = 0
-		}
-
 		// Check exit conditions
-		if nErr > 5 || uploadErr > 5 {
-			return fmt.Errorf("upscale: too many consecutive errors: %w", err)
+		if err := streak.Add(err); err != nil {
+			return err
+		}
+		if n := atomic.LoadInt32(&uploadErr); int(n) > maxErrors {
+			return fmt.Errorf("upscale: too many consecutive upload errors (%d)", n)
 		}
 		if cfg.Limit > 0 && iteration >= cfg.Limit {
 			return nil
@@ -149,6 +169,7 @@ func Run(ctx context.Context, cfg *Config) error {
 		// Get next cover
 		filters := []storage.Filter{
 			storage.Where("upscaled = ?", false),
+			storage.Where("upscale_failed = ?", false),
 			storage.Where("state = ?", storage.Approved),
 			storage.Where("id > ?", currID),
 		}
@@ -177,16 +198,19 @@ func Run(ctx context.Context, cfg *Config) error {
 		go func() {
 			defer wg.Done()
 			rlimit := rlimits[iteration%len(rlimits)]
-			err := upscaleCover(ctx, cfg.Debug, &wg, store, fs, rlimit, upscaler, &uploads, &uploadErr, addTime, cover)
+			err := upscaleCover(ctx, cfg.Debug, &wg, store, fs, rlimit, upscaler, &uploads, &uploadErr, addTime, cfg.MaxAttempts, prof, cover)
 			if err != nil {
 				log.Println(err)
+				if err := setAttempt(ctx, store, cfg.MaxAttempts, cover); err != nil {
+					log.Println(err)
+				}
 			}
 			errC <- err
 		}()
 	}
 }
 
-func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *storage.Store, fs *filestore.Store, rlimit ratelimit.Lock, upscaler *upscale.Upscaler, uploads *int32, nErr *int32, addTime func(t, u time.Duration), cover *storage.Cover) error {
+func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *storage.Store, fs *filestore.Store, rlimit ratelimit.Lock, upscaler *upscale.Upscaler, uploads *int32, nErr *int32, addTime func(t, u time.Duration), maxAttempts int, prof *timing.Profile, cover *storage.Cover) error {
 	start := time.Now()
 	var upscaleTime time.Duration
 	defer func() {
@@ -204,6 +228,9 @@ func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *
 	// Obtain extension from cover URL
 	u := cover.URL()
 	ext := filepath.Ext(strings.Split(u, "?")[0])
+	if cover.Stored {
+		ext = ".png"
+	}
 
 	// Generate a temporary file name path using the cover id it must work on any OS
 	name := fmt.Sprintf("%s%s", cover.ID, ext)
@@ -211,9 +238,17 @@ func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *
 
 	// Download cover
 	debug("upscale: download start %s", name)
-	if err := download(ctx, isDebug, u, original); err != nil {
+	stop := prof.Start("download")
+	if cover.Stored {
+		// The original image is in the file storage as the provider URL
+		// expires
+		if err := fs.GetPNG(ctx, original, cover.ID); err != nil {
+			return fmt.Errorf("upscale: couldn't download cover: %w", err)
+		}
+	} else if err := download(ctx, isDebug, u, original); err != nil {
 		return fmt.Errorf("upscale: couldn't download cover: %w", err)
 	}
+	stop()
 	debug("upscale: download end %s", name)
 
 	// Create a upscale directory on a temporary directory
@@ -225,11 +260,13 @@ func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *
 	// Upscale cover
 	debug("upscale: upscale start %s", name)
 	upscaleStart := time.Now()
+	stop = prof.Start("upscale")
 	upscaled, err := upscaler.Upscale(ctx, original, upscaleDir)
 	if err != nil {
 		return fmt.Errorf("upscale: couldn't upscale cover: %w", err)
 	}
 	upscaleTime += time.Since(upscaleStart)
+	stop()
 	debug("upscale: upscale end %s", name)
 
 	// Remove original cover
@@ -268,11 +305,16 @@ func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *
 
 		// Upload upscaled cover
 		debug("upscale: upload start %s", name)
+		stop := prof.Start("upload")
 		if err := fs.SetJPG(ctx, upscaled, cover.ID); err != nil {
 			log.Println(fmt.Errorf("upscale: couldn't upload cover: %w", err))
 			atomic.AddInt32(nErr, 1)
+			if err := setAttempt(ctx, store, maxAttempts, cover); err != nil {
+				log.Println(err)
+			}
 			return
 		}
+		stop()
 		debug("upscale: upload end %s", name)
 
 		// Remove upscaled cover
@@ -293,6 +335,52 @@ func upscaleCover(ctx context.Context, isDebug bool, wg *sync.WaitGroup, store *
 	return nil
 }
 
+// setAttempt increases the number of failed upscale attempts of the cover and
+// marks it as failed if the maximum number of attempts is reached.
+func setAttempt(ctx context.Context, store *storage.Store, maxAttempts int, cover *storage.Cover) error {
+	cover.UpscaleAttempts++
+	if maxAttempts > 0 && cover.UpscaleAttempts >= maxAttempts {
+		cover.UpscaleFailed = true
+		log.Printf("upscale: cover %s failed %d times, it won't be retried\n", cover.ID, cover.UpscaleAttempts)
+	}
+	if err := store.SetCover(ctx, cover); err != nil {
+		return fmt.Errorf("upscale: couldn't update cover attempts: %w", err)
+	}
+	return nil
+}
+
+// reportFailed logs the covers that exceeded the maximum number of upscale
+// attempts so they can be regenerated.
+func reportFailed(ctx context.Context, store *storage.Store, typ string) error {
+	filters := []storage.Filter{
+		storage.Where("upscale_failed = ?", true),
+		storage.Where("upscaled = ?", false),
+	}
+	if typ != "" {
+		filters = append(filters, storage.Where("type LIKE ?", typ))
+	}
+	var failed []string
+	page := 1
+	for {
+		covers, err := store.ListAllCovers(ctx, page, 100, "", filters...)
+		if err != nil {
+			return fmt.Errorf("upscale: couldn't list failed covers: %w", err)
+		}
+		for _, c := range covers {
+			failed = append(failed, c.ID)
+		}
+		if len(covers) < 100 {
+			break
+		}
+		page++
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	log.Printf("upscale: %d covers exceeded max attempts: %s\n", len(failed), strings.Join(failed, ","))
+	return nil
+}
+
 var backoff = []time.Duration{
 	15 * time.Second,
 	30 * time.Second,
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// cacheResult is the result of a cache cleanup.
+type cacheResult struct {
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"`
+	Size    int64 `json:"size"`
+}
+
+// pruneCache removes the oldest files of the cache folder until its size is
+// at most maxSize bytes. If maxSize is zero all the files are removed.
+func pruneCache(dir string, maxSize int64) (*cacheResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("filter: couldn't read cache folder: %w", err)
+	}
+	type file struct {
+		path string
+		size int64
+		mod  int64
+	}
+	var files []file
+	res := &cacheResult{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// The file may have been removed meanwhile
+			continue
+		}
+		files = append(files, file{
+			path: filepath.Join(dir, e.Name()),
+			size: info.Size(),
+			mod:  info.ModTime().UnixNano(),
+		})
+		res.Size += info.Size()
+	}
+
+	// Remove the oldest files first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mod < files[j].mod
+	})
+	for _, f := range files {
+		if maxSize > 0 && res.Size <= maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return res, fmt.Errorf("filter: couldn't remove cached file: %w", err)
+		}
+		res.Removed++
+		res.Freed += f.size
+		res.Size -= f.size
+	}
+	return res, nil
+}
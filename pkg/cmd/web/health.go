@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// healthTimeout is the maximum time to check each dependency.
+const healthTimeout = 5 * time.Second
+
+// healthResponse is the response of the health endpoints.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthHandler returns a handler that responds 200 if all the checks pass
+// and 503 otherwise.
+// Only ok or error is returned for each check, as the endpoint isn't
+// authenticated, and the errors are logged.
+func healthHandler(checks map[string]func(ctx context.Context) error) http.HandlerFunc {
+	var names []string
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+		resp := &healthResponse{Status: "ok", Checks: map[string]string{}}
+		status := http.StatusOK
+		for _, name := range names {
+			if err := checks[name](ctx); err != nil {
+				logError(r, "health check failed", err, "check", name)
+				resp.Checks[name] = "error"
+				resp.Status = "error"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[name] = "ok"
+		}
+		writeHealth(w, r, status, resp)
+	}
+}
+
+// readyHandler returns a handler that responds 200 when the server is ready
+// to serve requests and 503 while it is starting or shutting down.
+func readyHandler(ready *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			writeHealth(w, r, http.StatusServiceUnavailable, &healthResponse{Status: "not ready"})
+			return
+		}
+		writeHealth(w, r, http.StatusOK, &healthResponse{Status: "ok"})
+	}
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, status int, resp *healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logError(r, "couldn't encode health response", err)
+	}
+}
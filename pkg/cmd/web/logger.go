@@ -0,0 +1,82 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// logger logs the messages of the requests with their request id.
+// By default messages are written as key=value pairs so they can be parsed,
+// in debug mode they are written in a human readable format.
+type logger struct {
+	debug bool
+	text  *slog.Logger
+}
+
+func newLogger(w io.Writer, debug bool) *logger {
+	return &logger{
+		debug: debug,
+		text:  slog.New(slog.NewTextHandler(w, nil)),
+	}
+}
+
+// defaultLogger is used for requests that don't have a logger.
+var defaultLogger = newLogger(os.Stderr, false)
+
+type loggerKey struct{}
+
+// middleware adds the logger to the context of the requests.
+func (l *logger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loggerKey{}, l)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestLogger returns the logger of the request.
+func requestLogger(r *http.Request) *logger {
+	if l, ok := r.Context().Value(loggerKey{}).(*logger); ok {
+		return l
+	}
+	return defaultLogger
+}
+
+// logError logs an error that happened while serving the request.
+func logError(r *http.Request, msg string, err error, args ...any) {
+	requestLogger(r).log(r, slog.LevelError, msg, append(args, "err", err)...)
+}
+
+// logInfo logs a message of the request.
+func logInfo(r *http.Request, msg string, args ...any) {
+	requestLogger(r).log(r, slog.LevelInfo, msg, args...)
+}
+
+func (l *logger) log(r *http.Request, level slog.Level, msg string, args ...any) {
+	id := middleware.GetReqID(r.Context())
+	if !l.debug {
+		args = append([]any{"req_id", id, "method", r.Method, "path", r.URL.Path}, args...)
+		l.text.Log(r.Context(), level, msg, args...)
+		return
+	}
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString(fmt.Sprintf("[%s] ", id))
+	}
+	sb.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "err" {
+			sb.WriteString(fmt.Sprintf(": %v", args[i+1]))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(" %v=%v", args[i], args[i+1]))
+	}
+	log.Println(sb.String())
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/igolaizola/musikai/pkg/cmd/process"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+// unprocessedError is the response returned when selecting a generation that
+// isn't processed yet.
+type unprocessedError struct {
+	Error string `json:"error"`
+	// Process is true if the generation can be processed from the web.
+	Process bool `json:"process"`
+}
+
+// processSelector processes generations in the background and selects them
+// once they are processed.
+type processSelector struct {
+	processor *process.Processor
+	store     *storage.Store
+
+	lck     sync.Mutex
+	running map[string]struct{}
+}
+
+func newProcessSelector(processor *process.Processor, store *storage.Store) *processSelector {
+	return &processSelector{
+		processor: processor,
+		store:     store,
+		running:   map[string]struct{}{},
+	}
+}
+
+// processAndSelect launches the processing of the generation and selects it
+// for the song if it succeeds.
+// Processing takes longer than a request, so it runs with the server context.
+// Generations already being processed are ignored.
+func (p *processSelector) processAndSelect(ctx context.Context, song *storage.Song, gen *storage.Generation) {
+	p.lck.Lock()
+	defer p.lck.Unlock()
+	if _, ok := p.running[gen.ID]; ok {
+		return
+	}
+	p.running[gen.ID] = struct{}{}
+	go func() {
+		defer func() {
+			p.lck.Lock()
+			delete(p.running, gen.ID)
+			p.lck.Unlock()
+		}()
+		selected, err := p.run(ctx, song, gen)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !selected {
+			log.Printf("filter: generation %s processed but not selected, song %s changed meanwhile\n", gen.ID, song.ID)
+			return
+		}
+		log.Printf("filter: generation %s processed and selected for song %s\n", gen.ID, song.ID)
+	}()
+}
+
+// run processes the generation and selects it for the song. The song isn't
+// updated if it changed while processing, because the user may have chosen
+// another generation or the song may be already published.
+func (p *processSelector) run(ctx context.Context, song *storage.Song, gen *storage.Generation) (bool, error) {
+	if err := p.processor.Process(ctx, gen); err != nil {
+		return false, fmt.Errorf("filter: couldn't process generation %s: %w", gen.ID, err)
+	}
+	gen, err := p.store.GetGeneration(ctx, gen.ID)
+	if err != nil {
+		return false, fmt.Errorf("filter: couldn't get processed generation: %w", err)
+	}
+	if !gen.Processed {
+		return false, fmt.Errorf("filter: generation %s wasn't processed", gen.ID)
+	}
+	current, err := p.store.GetSong(ctx, song.ID)
+	if err != nil {
+		return false, fmt.Errorf("filter: couldn't get song %s: %w", song.ID, err)
+	}
+	if changed(song, current) {
+		return false, nil
+	}
+	current.GenerationID = &gen.ID
+	current.Generation = gen
+	current.Selected = true
+	if err := p.store.SetSong(ctx, current); err != nil {
+		return false, fmt.Errorf("filter: couldn't set song %s: %w", song.ID, err)
+	}
+	return true, nil
+}
+
+// changed returns whether the selection or the state of the song changed, or
+// whether it can't be changed anymore because it is in an album or used.
+func changed(before, after *storage.Song) bool {
+	if after.AlbumID != "" || after.State == storage.Used {
+		return true
+	}
+	if after.State != before.State {
+		return true
+	}
+	if (before.GenerationID == nil) != (after.GenerationID == nil) {
+		return true
+	}
+	return before.GenerationID != nil && *before.GenerationID != *after.GenerationID
+}
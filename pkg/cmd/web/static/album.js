@@ -1,16 +1,15 @@
 window.app = function () {
     return {
-      newsong: "",
       speed: 1,
       asset: "albums",
       style: "",
       type: "",
-      size: 100,
+      size: 0,
       error: "",
       page: 1,
+      pages: 0,
       loading: false,
-      album: null,
-      images: [],
+      albums: [],
       nav: "home",
       pending: true,
       approved: false,
@@ -29,13 +28,14 @@ window.app = function () {
         this.error = "";
         this.loading = false;
       },
-      action: function (action, index, callback) {
+      action: function (action, albumIndex, index, callback) {
         this.error = "";
 
-        let apiURL = "/api/" + this.asset + "/" + this.album.id + "/" + action;
+        const album = this.albums[albumIndex];
+        let apiURL = "/api/" + this.asset + "/" + album.id + "/" + action;
         if (index >= 0) {
-          id = this.images[index].id;
-          apiURL = "/api/" + this.asset + "/" + this.album.id + "/songs/"+ id + "/" + action;
+          id = album.songs[index].id;
+          apiURL = "/api/" + this.asset + "/" + album.id + "/songs/"+ id + "/" + action;
         }
         this.fetch(apiURL, index, callback);
       },
@@ -61,35 +61,101 @@ window.app = function () {
             this.error = error.message;
           });
       },
-      addSong: function () {
-        id = this.newsong;
+      addSong: function (albumIndex) {
+        const album = this.albums[albumIndex];
+        id = album.newsong || "";
         if (id === "") {
           id = "-";
         }
-        this.fetch("/api/albums/" + this.album.id + "/songs/"+id+"/add", 0, () => {
+        this.fetch("/api/albums/" + album.id + "/songs/"+id+"/add", 0, () => {
           this.search(this.page);
         });
       },
-      deleteSong: function (index) {
-        this.action("delete", index, () => {
-          this.images.splice(index, 1);
+      deleteSong: function (albumIndex, index) {
+        this.action("delete", albumIndex, index, () => {
+          this.albums[albumIndex].songs.splice(index, 1);
         });
       },
-      approveImage: function (index) {
-        this.action("approve", index,  () => {
-          this.album.state = 2;
+      moveSong: function (albumIndex, index, delta) {
+        const album = this.albums[albumIndex];
+        const target = index + delta;
+        if (target < 0 || target >= album.songs.length) {
+          return;
+        }
+        const ids = album.songs.map((song) => song.id);
+        [ids[index], ids[target]] = [ids[target], ids[index]];
+        this.error = "";
+        fetch("/api/albums/" + album.id + "/reorder", {
+          method: "PUT",
+          headers: {
+            "Content-Type": "application/json",
+          },
+          body: JSON.stringify(ids),
+        })
+          .then((response) => {
+            if (response.ok) {
+              return response.json();
+            } else {
+              return response.text().then((text) => {
+                throw new Error(text || response.statusText);
+              });
+            }
+          })
+          .then((data) => {
+            album.songs = data.songs || [];
+          })
+          .catch((error) => {
+            this.error = error.message;
+          });
+      },
+      approveImage: function (albumIndex) {
+        this.action("approve", albumIndex, -1,  () => {
+          this.albums[albumIndex].state = 2;
         });
       },
-      disapproveImage: function (index) {
-        this.action("disapprove", index, () => {
-          this.album.state = 0;
+      disapproveImage: function (albumIndex) {
+        this.action("disapprove", albumIndex, -1, () => {
+          this.albums[albumIndex].state = 0;
         });
       },
-      deleteAlbum: function () {
-        this.action("delete", -1, () => {
+      deleteAlbum: function (albumIndex) {
+        this.action("delete", albumIndex, -1, () => {
           this.search(this.page);
         });
       },
+      editTargets: function (albumIndex) {
+        const album = this.albums[albumIndex];
+        const targets = prompt(
+          "Targets (distrokid, jamendo, bandcamp, soundcloud)",
+          album.targets || ""
+        );
+        if (targets === null) {
+          return;
+        }
+        this.error = "";
+        fetch("/api/albums/" + album.id + "/targets", {
+          method: "PUT",
+          headers: {
+            "Content-Type": "application/json",
+          },
+          body: JSON.stringify({ targets: targets }),
+        })
+          .then((response) => {
+            if (response.ok) {
+              return;
+            } else {
+              return response.text().then((text) => {
+                throw new Error(text || response.statusText);
+              });
+            }
+          })
+          .then(() => {
+            this.search(this.page);
+          })
+          .catch((error) => {
+            this.error = error.message;
+          });
+      },
       changeSpeed() {
         if (this.speed === 3) {
           this.speed = 1;
@@ -102,22 +168,15 @@ window.app = function () {
           audioElements[i].playbackRate = this.speed;
         }
       },
-      play(index) {
-        const audioElements = document.querySelectorAll("audio");
-        const audioElement = audioElements[index];
-        audioElement.playbackRate = this.speed;
-        if (!audioElement.paused) {
-          // Play the audio
-          audioElement.play();
-        }
+      play(event) {
+        event.target.playbackRate = this.speed;
       },
       search: function (page) {
         this.page = page;
         console.log("searching");
         this.error = "";
         this.loading = false;
-        this.images = [];
-        this.album = null;
+        this.albums = [];
   
         // URL encode the query string
         style = encodeURIComponent(this.style);
@@ -130,10 +189,13 @@ window.app = function () {
           style +
           "&type=" +
           type +
-          "&size=" +
-          this.size +
           "&page=" +
-          this.page;
+          this.page +
+          "&meta=true";
+        // The server page size is used if it isn't set
+        if (this.size > 0) {
+          apiURL += "&size=" + this.size;
+        }
   
         if (this.pending === true) {
           apiURL += "&pending=true";
@@ -181,13 +243,18 @@ window.app = function () {
           })
           .then((data) => {
             console.log(data);
-            this.album = data;
-            this.images = data.songs;
+            this.albums = (data.items || []).map((album) => {
+              album.songs = album.songs || [];
+              album.newsong = "";
+              return album;
+            });
+            this.pages = Math.ceil(data.total / data.size);
           })
           .catch((error) => {
             // Update the component's data properties with received error and empty summary
             this.error = error.message;
-            this.images = [];
+            this.albums = [];
+            this.pages = 0;
           })
           .finally(() => {
             this.loading = false;
@@ -4,9 +4,15 @@ window.app = function () {
     asset: "songs",
     style: "",
     type: "",
+    account: "",
+    provider: "",
+    tag: "",
+    tags: [],
+    newTags: {},
     size: 100,
     error: "",
     page: 1,
+    pages: 0,
     loading: false,
     images: [],
     nav: "home",
@@ -83,18 +89,141 @@ window.app = function () {
         this.images[index].state = 1;
       });
     },
-    selectImage: function (index) {
+    selectImage: function (index, process) {
       const gID = this.images[index].generation_id;
       const id = this.images[index].id;
-      this.action("select/"+gID, index, () => {
-        for (let i = 0; i < this.images.length; i++) {
-          if (this.images[i].id === id) {
-            this.images[i].selected = false;
+      this.error = "";
+      let apiURL = "/api/songs/" + id + "/select/" + gID;
+      if (process) {
+        apiURL += "?process=true";
+      }
+      fetch(apiURL, { method: "PUT" })
+        .then((response) => {
+          if (response.status === 409) {
+            // The generation isn't processed, offer to process it
+            return response.json().then((data) => {
+              if (!data.process) {
+                throw new Error(data.error + ", run the process command before selecting it");
+              }
+              if (confirm(data.error + ". Process it now? It will be selected once processed.")) {
+                this.selectImage(index, true);
+              }
+            });
+          }
+          if (response.status === 202) {
+            this.error = "Generation " + gID + " is being processed, it will be selected once processed";
+            return;
+          }
+          if (!response.ok) {
+            throw new Error(response.statusText);
+          }
+          for (let i = 0; i < this.images.length; i++) {
+            if (this.images[i].id === id) {
+              this.images[i].selected = false;
+            }
+          }
+          this.images[index].selected = true;
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
+    loadTags: function () {
+      fetch("/api/tags")
+        .then((response) => {
+          if (response.ok) {
+            return response.json();
+          } else {
+            throw new Error(response.statusText);
           }
+        })
+        .then((data) => {
+          this.tags = data;
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
+    tagAction: function (method, index, tag, callback) {
+      const id = this.images[index].id;
+      this.error = "";
+      fetch("/api/songs/" + id + "/tags/" + encodeURIComponent(tag), {
+        method: method,
+      })
+        .then((response) => {
+          if (response.ok) {
+            return;
+          } else {
+            throw new Error(response.statusText);
+          }
+        })
+        .then(() => {
+          // Tags are shared by all the generations of the song
+          for (let i = 0; i < this.images.length; i++) {
+            if (this.images[i].id === id) {
+              callback(this.images[i]);
+            }
+          }
+          this.loadTags();
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
+    addTag: function (index) {
+      const tag = (this.newTags[index] || "").trim().toLowerCase().split(/\s+/).join("-");
+      if (tag === "") {
+        return;
+      }
+      this.tagAction("PUT", index, tag, (img) => {
+        img.tags = img.tags || [];
+        if (!img.tags.includes(tag)) {
+          img.tags.push(tag);
+          img.tags.sort();
         }
-        this.images[index].selected = true;
+      });
+      this.newTags[index] = "";
+    },
+    removeTag: function (index, tag) {
+      this.tagAction("DELETE", index, tag, (img) => {
+        img.tags = (img.tags || []).filter((t) => t !== tag);
       });
     },
+    editCredits: function (index) {
+      const id = this.images[index].id;
+      const credits = prompt(
+        "Credits (e.g. Songwriter: Jane Doe; Mixing: John Doe)",
+        this.images[index].credits || ""
+      );
+      if (credits === null) {
+        return;
+      }
+      this.error = "";
+      fetch("/api/songs/" + id + "/credits", {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ credits: credits }),
+      })
+        .then((response) => {
+          if (response.ok) {
+            return;
+          } else {
+            throw new Error(response.statusText);
+          }
+        })
+        .then(() => {
+          for (let i = 0; i < this.images.length; i++) {
+            if (this.images[i].id === id) {
+              this.images[i].credits = credits.trim();
+            }
+          }
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
     changeSpeed() {
       if (this.speed === 3) {
         this.speed = 1;
@@ -116,59 +245,97 @@ window.app = function () {
         audioElement.play();
       }
     },
-    search: function (page) {
-      this.page = page;
-      console.log("searching");
-      this.error = "";
-      this.loading = false;
-      this.images = [];
-
+    query: function () {
       // URL encode the query string
       style = encodeURIComponent(this.style);
       type = encodeURIComponent(this.type);
+      account = encodeURIComponent(this.account);
+      provider = encodeURIComponent(this.provider);
+      tag = encodeURIComponent(this.tag);
 
-      apiURL =
-        "/api/" +
-        this.asset +
+      query =
         "?style=" +
         style +
         "&type=" +
         type +
+        "&account=" +
+        account +
+        "&provider=" +
+        provider +
+        "&tag=" +
+        tag +
         "&size=" +
         this.size +
         "&page=" +
         this.page;
 
       if (this.pending === true) {
-        apiURL += "&pending=true";
+        query += "&pending=true";
       }
       if (this.approved === true) {
-        apiURL += "&approved=true";
+        query += "&approved=true";
       }
       if (this.rejected === true) {
-        apiURL += "&rejected=true";
+        query += "&rejected=true";
       }
       if (this.flagged !== this.noflagged) {
         if (this.flagged) {
-          apiURL += "&flagged=true";
+          query += "&flagged=true";
         } else {
-          apiURL += "&flagged=false";
+          query += "&flagged=false";
         }
       }
       if (this.ends !== this.noends) {
         if (this.ends) {
-          apiURL += "&ends=true";
+          query += "&ends=true";
         } else {
-          apiURL += "&ends=false";
+          query += "&ends=false";
         }
       }
       if (this.liked !== this.noliked) {
         if (this.liked) {
-          apiURL += "&liked=true";
+          query += "&liked=true";
         } else {
-          apiURL += "&liked=false";
+          query += "&liked=false";
         }
       }
+      return query;
+    },
+    bulk: function (action) {
+      if (!confirm(action + " all the " + this.asset + " matching the current filter?")) {
+        return;
+      }
+      this.error = "";
+      fetch("/api/" + this.asset + "/bulk/" + action + this.query(), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+        },
+      })
+        .then((response) => {
+          if (response.ok) {
+            return response.json();
+          } else {
+            throw new Error(response.statusText);
+          }
+        })
+        .then((data) => {
+          alert(data.updated + " " + this.asset + " updated");
+          this.search(1);
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
+    search: function (page) {
+      this.page = page;
+      console.log("searching");
+      this.error = "";
+      this.loading = false;
+      this.images = [];
+      this.newTags = {};
+
+      apiURL = "/api/" + this.asset + this.query() + "&meta=true";
 
       this.loading = true;
       // Use fetch API to make a POST request to the API URL
@@ -185,12 +352,14 @@ window.app = function () {
         })
         .then((data) => {
           console.log(data);
-          this.images = data;
+          this.images = data.items || [];
+          this.pages = data.size > 0 ? Math.ceil(data.total / data.size) : 0;
         })
         .catch((error) => {
           // Update the component's data properties with received error and empty summary
           this.error = error.message;
           this.images = [];
+          this.pages = 0;
         })
         .finally(() => {
           this.loading = false;
@@ -7,6 +7,7 @@ window.app = function () {
     size: 100,
     error: "",
     page: 1,
+    pages: 0,
     loading: false,
     images: [],
     nav: "home",
@@ -73,20 +74,12 @@ window.app = function () {
         this.images[index].state = 1;
       });
     },
-    search: function (page) {
-      this.page = page;
-      console.log("searching");
-      this.error = "";
-      this.loading = false;
-      this.images = [];
-
+    query: function () {
       // URL encode the query string
       style = encodeURIComponent(this.style);
       type = encodeURIComponent(this.type);
 
-      apiURL =
-        "/api/" +
-        this.asset +
+      query =
         "?style=" +
         style +
         "&type=" +
@@ -97,28 +90,64 @@ window.app = function () {
         this.page;
 
       if (this.pending === true) {
-        apiURL += "&pending=true";
+        query += "&pending=true";
       }
       if (this.approved === true) {
-        apiURL += "&approved=true";
+        query += "&approved=true";
       }
       if (this.rejected === true) {
-        apiURL += "&rejected=true";
+        query += "&rejected=true";
       }
       if (this.background !== this.nobackground) {
         if (this.background) {
-          apiURL += "&background=true";
+          query += "&background=true";
         } else {
-          apiURL += "&background=false";
+          query += "&background=false";
         }
       }
       if (this.liked !== this.noliked) {
         if (this.liked) {
-          apiURL += "&liked=true";
+          query += "&liked=true";
         } else {
-          apiURL += "&liked=false";
+          query += "&liked=false";
         }
       }
+      return query;
+    },
+    bulk: function (action) {
+      if (!confirm(action + " all the " + this.asset + " matching the current filter?")) {
+        return;
+      }
+      this.error = "";
+      fetch("/api/" + this.asset + "/bulk/" + action + this.query(), {
+        method: "PUT",
+        headers: {
+          "Content-Type": "application/json",
+        },
+      })
+        .then((response) => {
+          if (response.ok) {
+            return response.json();
+          } else {
+            throw new Error(response.statusText);
+          }
+        })
+        .then((data) => {
+          alert(data.updated + " " + this.asset + " updated");
+          this.search(this.page);
+        })
+        .catch((error) => {
+          this.error = error.message;
+        });
+    },
+    search: function (page) {
+      this.page = page;
+      console.log("searching");
+      this.error = "";
+      this.loading = false;
+      this.images = [];
+
+      apiURL = "/api/" + this.asset + this.query() + "&meta=true";
 
       this.loading = true;
       // Use fetch API to make a POST request to the API URL
@@ -135,12 +164,14 @@ window.app = function () {
         })
         .then((data) => {
           console.log(data);
-          this.images = data;
+          this.images = data.items || [];
+          this.pages = data.size > 0 ? Math.ceil(data.total / data.size) : 0;
         })
         .catch((error) => {
           // Update the component's data properties with received error and empty summary
           this.error = error.message;
           this.images = [];
+          this.pages = 0;
         })
         .finally(() => {
           this.loading = false;
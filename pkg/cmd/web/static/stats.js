@@ -0,0 +1,46 @@
+window.app = function () {
+  return {
+    assets: ["songs", "covers", "albums", "drafts", "titles"],
+    states: ["pending", "approved", "used", "rejected"],
+    stats: {},
+    error: "",
+    loading: false,
+    load: function () {
+      this.error = "";
+      this.loading = true;
+      fetch("/api/stats")
+        .then((response) => {
+          if (response.ok) {
+            return response.json();
+          } else {
+            throw new Error(response.statusText);
+          }
+        })
+        .then((data) => {
+          this.stats = data;
+          this.loading = false;
+        })
+        .catch((error) => {
+          this.error = error.message;
+          this.loading = false;
+        });
+    },
+    types: function (asset) {
+      let types = new Set();
+      for (const state of Object.values(this.stats[asset] || {})) {
+        Object.keys(state.types).forEach((t) => types.add(t));
+      }
+      return Array.from(types).sort();
+    },
+    count: function (asset, state, type) {
+      let s = (this.stats[asset] || {})[state];
+      if (!s) {
+        return 0;
+      }
+      if (type === undefined) {
+        return s.total;
+      }
+      return s.types[type] || 0;
+    },
+  };
+};
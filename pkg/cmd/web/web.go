@@ -4,19 +4,29 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	iofs "io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/igolaizola/musikai/pkg/cmd/album"
+	"github.com/igolaizola/musikai/pkg/cmd/process"
+	"github.com/igolaizola/musikai/pkg/cmd/title"
 	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/image"
+	"github.com/igolaizola/musikai/pkg/pprof"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -28,9 +38,28 @@ type Config struct {
 	FSConn string
 	Proxy  string
 
-	Addr        string
-	Credentials map[string]string
-	Volumes     map[string]string
+	Addr          string
+	Credentials   map[string]string
+	Volumes       map[string]string
+	ThumbnailSize int
+	LikeApproves  bool
+	AutoTitle     bool
+	BannedWords   string
+	PprofAddr     string
+	// AlbumPageSize is the number of albums per page when the request
+	// doesn't set the size.
+	AlbumPageSize int
+
+	// CacheClear removes the cached files on shutdown. If CacheMaxSize is
+	// set, only the oldest files are removed until the cache size in MB is
+	// below it.
+	CacheClear   bool
+	CacheMaxSize int
+
+	// SelectProcess allows to process an unprocessed generation when it is
+	// selected, using the Process configuration.
+	SelectProcess bool
+	Process       process.Config
 }
 
 //go:embed static/*
@@ -53,6 +82,17 @@ func Serve(ctx context.Context, cfg *Config) error {
 	}
 	_ = debug
 
+	if cfg.PprofAddr != "" {
+		if err := pprof.Serve(ctx, cfg.PprofAddr); err != nil {
+			return fmt.Errorf("filter: %w", err)
+		}
+	}
+
+	banned, err := title.NewBanned(cfg.BannedWords)
+	if err != nil {
+		return fmt.Errorf("filter: %w", err)
+	}
+
 	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
 	if err != nil {
 		return fmt.Errorf("scrape: couldn't create orm store: %w", err)
@@ -66,6 +106,23 @@ func Serve(ctx context.Context, cfg *Config) error {
 		return fmt.Errorf("download: couldn't create file storage: %w", err)
 	}
 
+	// Processor used to process unprocessed generations before selecting them
+	var selector *processSelector
+	if cfg.SelectProcess {
+		pcfg := cfg.Process
+		pcfg.Debug = cfg.Debug
+		pcfg.DBType = cfg.DBType
+		pcfg.DBConn = cfg.DBConn
+		pcfg.FSType = cfg.FSType
+		pcfg.FSConn = cfg.FSConn
+		pcfg.Proxy = cfg.Proxy
+		processor, err := process.NewProcessor(ctx, &pcfg, store, nil)
+		if err != nil {
+			return fmt.Errorf("filter: couldn't create processor: %w", err)
+		}
+		selector = newProcessSelector(processor, store)
+	}
+
 	// Create static content
 	staticFS, err := iofs.Sub(staticContent, "static")
 	if err != nil {
@@ -76,17 +133,32 @@ func Serve(ctx context.Context, cfg *Config) error {
 	mux := chi.NewRouter()
 
 	// Add middleware
+	mux.Use(middleware.RequestID)
 	mux.Use(middleware.RealIP)
+	mux.Use(newLogger(os.Stderr, cfg.Debug).middleware)
 	mux.Use(middleware.Recoverer)
-	mux.Use(middleware.Timeout(60 * time.Second))
 
-	// Add BasicAuth middleware
-	if len(cfg.Credentials) > 0 {
-		mux.Use(middleware.BasicAuth("private", cfg.Credentials))
-	}
+	// Health endpoints don't require authentication so they can be used by
+	// load balancers and orchestrators
+	var ready atomic.Bool
+	mux.Get("/healthz", healthHandler(map[string]func(context.Context) error{
+		"db": store.Ping,
+		"fs": fs.Check,
+	}))
+	mux.Get("/readyz", readyHandler(&ready))
+
+	// Create subrouter for the app
+	app := mux.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+
+		// Add BasicAuth middleware
+		if len(cfg.Credentials) > 0 {
+			r.Use(middleware.BasicAuth("private", cfg.Credentials))
+		}
+	})
 
 	// Create subrouter for api endpoints
-	r := mux.Group(func(r chi.Router) {
+	r := app.Group(func(r chi.Router) {
 		if cfg.Debug {
 			r.Use(middleware.Logger)
 		}
@@ -122,7 +194,10 @@ func Serve(ctx context.Context, cfg *Config) error {
 	if cfg.FSType == "local" {
 		cache = cfg.FSConn
 	}
-	getMP3 := func(id string) string {
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		return fmt.Errorf("filter: couldn't create cache folder: %w", err)
+	}
+	getMP3 := func(r *http.Request, id string) string {
 		name := filestore.MP3(id)
 		u := fmt.Sprintf("/cache/%s", name)
 		if _, err := os.Stat(fmt.Sprintf("%s/%s", cache, name)); err == nil {
@@ -130,12 +205,12 @@ func Serve(ctx context.Context, cfg *Config) error {
 		}
 		out := fmt.Sprintf("%s/%s", cache, name)
 		if err := fs.GetMP3(ctx, out, id); err != nil {
-			log.Println("couldn't download mp3:", err)
+			logError(r, "couldn't download mp3", err)
 			return ""
 		}
 		return u
 	}
-	getJPG := func(id string) string {
+	getJPG := func(r *http.Request, id string) string {
 		name := filestore.JPG(id)
 		u := fmt.Sprintf("/cache/%s", name)
 		if _, err := os.Stat(fmt.Sprintf("%s/%s", cache, name)); err == nil {
@@ -143,26 +218,152 @@ func Serve(ctx context.Context, cfg *Config) error {
 		}
 		out := fmt.Sprintf("%s/%s", cache, name)
 		if err := fs.GetJPG(ctx, out, id); err != nil {
-			log.Println("couldn't download jpg:", err)
+			logError(r, "couldn't download jpg", err)
 			return ""
 		}
 		return u
 	}
+	thumbnailSize := cfg.ThumbnailSize
+	if thumbnailSize == 0 {
+		thumbnailSize = 300
+	}
+	// thumbnailURL returns the url of the cover thumbnail. Covers hosted on
+	// discord are resized by discord, the rest are resized locally when the
+	// thumbnail is requested, so listing covers doesn't wait for them.
+	thumbnailURL := func(cover *storage.Cover) string {
+		u := cover.URL()
+		if strings.Contains(u, "cdn.discordapp.com") {
+			u = strings.Replace(u, "cdn.discordapp.com", "media.discordapp.net", 1)
+			return fmt.Sprintf("%s?width=%d&height=%d", u, thumbnailSize, thumbnailSize)
+		}
+		return fmt.Sprintf("/api/covers/%s/thumbnail", cover.ID)
+	}
+
+	// coverLocks avoids downloading or resizing the same cover concurrently
+	var coverLocks sync.Map
+	lockCover := func(id string) func() {
+		v, _ := coverLocks.LoadOrStore(id, &sync.Mutex{})
+		lck := v.(*sync.Mutex)
+		lck.Lock()
+		return lck.Unlock
+	}
+
+	// getOriginal downloads the original cover to the cache if needed and
+	// returns its path.
+	getOriginal := func(cover *storage.Cover) (string, error) {
+		var original string
+		switch {
+		case cover.Upscaled:
+			original = fmt.Sprintf("%s/%s", cache, filestore.JPG(cover.ID))
+			if _, err := os.Stat(original); err != nil {
+				if err := fs.GetJPG(ctx, original, cover.ID); err != nil {
+					return "", fmt.Errorf("couldn't download cover: %w", err)
+				}
+			}
+		case cover.Stored:
+			original = fmt.Sprintf("%s/%s", cache, filestore.PNG(cover.ID))
+			if _, err := os.Stat(original); err != nil {
+				if err := fs.GetPNG(ctx, original, cover.ID); err != nil {
+					return "", fmt.Errorf("couldn't download cover: %w", err)
+				}
+			}
+		default:
+			u := cover.URL()
+			ext := ".png"
+			if parsed, err := url.Parse(u); err == nil && path.Ext(parsed.Path) != "" {
+				ext = path.Ext(parsed.Path)
+			}
+			original = fmt.Sprintf("%s/%s_original%s", cache, cover.ID, ext)
+			if _, err := os.Stat(original); err != nil {
+				if err := downloadFile(ctx, u, original); err != nil {
+					return "", fmt.Errorf("couldn't download cover: %w", err)
+				}
+			}
+		}
+		return original, nil
+	}
+
+	// getThumbnail generates the local thumbnail of the cover if needed and
+	// returns its path.
+	getThumbnail := func(cover *storage.Cover) (string, error) {
+		thumbnail := fmt.Sprintf("%s/%s_thumb.jpg", cache, cover.ID)
+		if _, err := os.Stat(thumbnail); err == nil {
+			return thumbnail, nil
+		}
+		original, err := getOriginal(cover)
+		if err != nil {
+			return "", err
+		}
+		if err := image.Resize(thumbnailSize, thumbnailSize, original, thumbnail); err != nil {
+			return "", fmt.Errorf("couldn't resize cover: %w", err)
+		}
+		return thumbnail, nil
+	}
+
+	// serveCover serves the local file returned by get for the requested
+	// cover, redirecting to the cover url if it fails.
+	serveCover := func(w http.ResponseWriter, r *http.Request, get func(*storage.Cover) (string, error)) {
+		id := chi.URLParam(r, "id")
+		cover, err := store.GetCover(ctx, id)
+		if err != nil {
+			logError(r, "couldn't get cover", err)
+			http.Error(w, fmt.Sprintf("couldn't get cover: %v", err), http.StatusNotFound)
+			return
+		}
+		unlock := lockCover(id)
+		file, err := get(cover)
+		unlock()
+		if err != nil {
+			logError(r, "couldn't get cover file", err)
+			http.Redirect(w, r, cover.URL(), http.StatusFound)
+			return
+		}
+		http.ServeFile(w, r, file)
+	}
 
 	// Handler to serve the static files
-	mux.Get("/*", http.StripPrefix("/", http.FileServer(http.FS(staticFS))).ServeHTTP)
+	app.Get("/*", http.StripPrefix("/", http.FileServer(http.FS(staticFS))).ServeHTTP)
 
 	// Handler to serve static files defined via volumes
 	if len(cfg.Volumes) > 0 {
 		for local, path := range cfg.Volumes {
 			path = strings.Trim(path, "/")
 			path = fmt.Sprintf("/%s/", path)
-			mux.Get(path+"*", http.StripPrefix(path, http.FileServer(http.Dir(local))).ServeHTTP)
+			app.Get(path+"*", http.StripPrefix(path, http.FileServer(http.Dir(local))).ServeHTTP)
 		}
 	}
 
 	// Handler to serve cached files "cache folder"
-	mux.Get("/cache/*", http.StripPrefix("/cache/", http.FileServer(http.Dir(cache))).ServeHTTP)
+	app.Get("/cache/*", http.StripPrefix("/cache/", http.FileServer(http.Dir(cache))).ServeHTTP)
+
+	// The local file storage is used as cache, so it must never be cleared
+	clearable := cfg.FSType != "local"
+	r.Post("/api/cache/clear", func(w http.ResponseWriter, r *http.Request) {
+		if !clearable {
+			http.Error(w, "cache is the local file storage and can't be cleared", http.StatusForbidden)
+			return
+		}
+		var maxSize int
+		if v := r.URL.Query().Get("max-size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("invalid max-size: %s", v), http.StatusBadRequest)
+				return
+			}
+			maxSize = n
+		}
+		res, err := pruneCache(cache, int64(maxSize)*1024*1024)
+		if err != nil {
+			logError(r, "couldn't clear cache", err)
+			http.Error(w, fmt.Sprintf("couldn't clear cache: %v", err), http.StatusInternalServerError)
+			return
+		}
+		logInfo(r, "cache cleared", "removed", res.Removed, "freed", res.Freed)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			logError(r, "couldn't encode cache result", err)
+		}
+	})
 
 	r.Get("/api/songs", func(w http.ResponseWriter, r *http.Request) {
 		// Obtain page from query params
@@ -174,49 +375,23 @@ func Serve(ctx context.Context, cfg *Config) error {
 		if err != nil {
 			size = 100
 		}
-		filters := []storage.Filter{
-			storage.Where("generations.processed = ?", true),
-		}
-		options := []string{"flagged", "ends"}
-		for _, o := range options {
-			if v := r.URL.Query().Get(o); v != "" {
-				b := v == "true"
-				filters = append(filters, storage.Where(fmt.Sprintf("%s = ?", o), b))
-			}
-		}
-		if v := r.URL.Query().Get("liked"); v != "" {
-			c := "="
-			b := v == "true"
-			if b {
-				c = ">"
-			}
-			filters = append(filters, storage.Where(fmt.Sprintf("likes %s 0", c)))
-		}
+		filters := songFilters(r.URL.Query())
 
-		var values []int
-		states := []string{"pending", "rejected", "approved"}
-		for i, s := range states {
-			if v := r.URL.Query().Get(s); v != "" {
-				if v == "true" {
-					values = append(values, i)
-				}
-			}
-		}
-		if len(values) > 0 {
-			filters = append(filters, storage.Where("state IN (?)", values))
+		generations, err := store.ListGenerations(ctx, page, size, "songs.id desc", filters...)
+		if err != nil {
+			logError(r, "couldn't list songs", err)
+			http.Error(w, fmt.Sprintf("couldn't list songs: %v", err), http.StatusInternalServerError)
+			return
 		}
 
-		queries := []string{"prompt", "style", "type"}
-		for _, q := range queries {
-			if v := r.URL.Query().Get(q); v != "" {
-				filters = append(filters, storage.Where(fmt.Sprintf("songs.%s LIKE '%s'", q, v)))
-			}
+		var ids []string
+		for _, g := range generations {
+			ids = append(ids, g.Song.ID)
 		}
-
-		generations, err := store.ListGenerations(ctx, page, size, "songs.id desc", filters...)
+		tags, err := store.ListSongTags(ctx, ids...)
 		if err != nil {
-			log.Println("couldn't list songs:", err)
-			http.Error(w, fmt.Sprintf("couldn't list songs: %v", err), http.StatusInternalServerError)
+			logError(r, "couldn't list tags", err)
+			http.Error(w, fmt.Sprintf("couldn't list tags: %v", err), http.StatusInternalServerError)
 			return
 		}
 
@@ -225,6 +400,12 @@ func Serve(ctx context.Context, cfg *Config) error {
 			s := g.Song
 			d := time.Duration(int(g.Duration)) * time.Second
 			p := fmt.Sprintf("%s %.f BPM %s", d, g.Tempo, s.Type)
+			if g.Key != "" {
+				p += " " + g.Key
+			}
+			if g.LUFS != 0 {
+				p += fmt.Sprintf(" %.1f LUFS", g.LUFS)
+			}
 			if s.Prompt != "" {
 				p += " | " + s.Prompt
 			}
@@ -237,27 +418,40 @@ func Serve(ctx context.Context, cfg *Config) error {
 
 			audioURL := g.Audio
 			if g.Processed {
-				audioURL = getMP3(g.ID)
+				audioURL = getMP3(r, g.ID)
 			}
-			waveURL := getJPG(g.ID)
+			waveURL := getJPG(r, g.ID)
 			assets = append(assets, &Song{
 				ID:           s.ID,
 				GenerationID: g.ID,
 				URL:          audioURL,
 				ThumbnailURL: waveURL,
 				Prompt:       p,
+				Account:      s.Account,
+				Provider:     s.Provider,
 				State:        s.State,
 				Liked:        s.Likes > 0,
 				Selected:     g.ID == *s.GenerationID,
+				Tags:         tags[s.ID],
+				Credits:      s.Credits,
 			})
 		}
-		if err := json.NewEncoder(w).Encode(assets); err != nil {
-			log.Println("couldn't encode songs:", err)
-			http.Error(w, fmt.Sprintf("couldn't encode songs: %v", err), http.StatusInternalServerError)
-			return
-		}
+		writeList(w, r, "songs", assets, page, size, func() (int64, error) {
+			return store.CountGenerations(ctx, filters...)
+		})
 	})
 
+	r.Put("/api/songs/bulk/approve", func(w http.ResponseWriter, r *http.Request) {
+		updateSongs(w, r, store, map[string]any{
+			"state": storage.Approved,
+		})
+	})
+	r.Put("/api/songs/bulk/reject", func(w http.ResponseWriter, r *http.Request) {
+		updateSongs(w, r, store, map[string]any{
+			"state": storage.Rejected,
+			"likes": 0,
+		})
+	})
 	r.Put("/api/songs/{id}/approve", func(w http.ResponseWriter, r *http.Request) {
 		updateSong(w, r, store, func(s *storage.Song) *storage.Song {
 			s.State = storage.Approved
@@ -273,7 +467,9 @@ func Serve(ctx context.Context, cfg *Config) error {
 	})
 	r.Put("/api/songs/{id}/like", func(w http.ResponseWriter, r *http.Request) {
 		updateSong(w, r, store, func(s *storage.Song) *storage.Song {
-			s.State = storage.Approved
+			if cfg.LikeApproves {
+				s.State = storage.Approved
+			}
 			s.Likes = 1
 			return s
 		})
@@ -292,97 +488,169 @@ func Serve(ctx context.Context, cfg *Config) error {
 		})
 	})
 	r.Put("/api/songs/{id}/select/{gid}", func(w http.ResponseWriter, r *http.Request) {
-		gid := chi.URLParam(r, "gid")
+		id := chi.URLParam(r, "id")
+		gen, err := store.GetGeneration(ctx, chi.URLParam(r, "gid"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("couldn't get generation: %v", err), http.StatusNotFound)
+			return
+		}
+		if gen.SongID == nil || *gen.SongID != id {
+			http.Error(w, fmt.Sprintf("generation %s doesn't belong to song %s", gen.ID, id), http.StatusBadRequest)
+			return
+		}
+		if gen.Processed {
+			updateSong(w, r, store, func(s *storage.Song) *storage.Song {
+				s.GenerationID = &gen.ID
+				s.Generation = gen
+				s.Selected = true
+				return s
+			})
+			return
+		}
+
+		// Unprocessed generations don't have a master, so they are only
+		// selected after processing them if requested
+		if r.URL.Query().Get("process") != "true" || selector == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			if err := json.NewEncoder(w).Encode(&unprocessedError{
+				Error:   fmt.Sprintf("generation %s isn't processed", gen.ID),
+				Process: selector != nil,
+			}); err != nil {
+				logError(r, "couldn't encode response", err)
+			}
+			return
+		}
+		song, err := store.GetSong(r.Context(), id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("couldn't get song: %v", err), http.StatusNotFound)
+			return
+		}
+		selector.processAndSelect(ctx, song, gen)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	r.Put("/api/songs/{id}/credits", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Credits string `json:"credits"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("couldn't decode request: %v", err), http.StatusBadRequest)
+			return
+		}
 		updateSong(w, r, store, func(s *storage.Song) *storage.Song {
-			s.Generation.ID = gid
+			s.Credits = strings.TrimSpace(req.Credits)
 			return s
 		})
 	})
-
-	r.Get("/api/covers", func(w http.ResponseWriter, r *http.Request) {
-		// Obtain page from query params
-		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	r.Put("/api/songs/{id}/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if _, err := store.GetSong(ctx, id); err != nil {
+			http.Error(w, fmt.Sprintf("couldn't get song: %v", err), http.StatusNotFound)
+			return
+		}
+		if err := store.AddSongTag(ctx, id, chi.URLParam(r, "tag")); err != nil {
+			logError(r, "couldn't add tag", err)
+			http.Error(w, fmt.Sprintf("couldn't add tag: %v", err), http.StatusBadRequest)
+			return
+		}
+	})
+	r.Delete("/api/songs/{id}/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := store.RemoveSongTag(ctx, id, chi.URLParam(r, "tag")); err != nil {
+			logError(r, "couldn't remove tag", err)
+			http.Error(w, fmt.Sprintf("couldn't remove tag: %v", err), http.StatusInternalServerError)
+			return
+		}
+	})
+	r.Get("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		tags, err := store.ListTags(ctx)
 		if err != nil {
-			page = 1
+			logError(r, "couldn't list tags", err)
+			http.Error(w, fmt.Sprintf("couldn't list tags: %v", err), http.StatusInternalServerError)
+			return
 		}
-		filters := []storage.Filter{}
-		draftFilters := []storage.Filter{}
-		typ := r.URL.Query().Get("type")
-		if typ != "" {
-			draftFilters = append(draftFilters, storage.Where(fmt.Sprintf("type LIKE '%s'", typ)))
+		if tags == nil {
+			tags = []string{}
+		}
+		if err := json.NewEncoder(w).Encode(tags); err != nil {
+			logError(r, "couldn't encode tags", err)
+			http.Error(w, fmt.Sprintf("couldn't encode tags: %v", err), http.StatusInternalServerError)
+			return
 		}
+	})
 
-		if v := r.URL.Query().Get("liked"); v != "" {
-			c := "="
-			b := v == "true"
-			if b {
-				c = ">"
+	r.Get("/api/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := map[string]map[string]*StateStats{}
+		for _, table := range []string{"songs", "covers", "albums", "drafts", "titles"} {
+			counts, err := store.CountByState(ctx, table, "type")
+			if err != nil {
+				logError(r, "couldn't count", err)
+				http.Error(w, fmt.Sprintf("couldn't count: %v", err), http.StatusInternalServerError)
+				return
 			}
-			filters = append(filters, storage.Where(fmt.Sprintf("likes %s 0", c)))
+			states := map[string]*StateStats{}
+			for _, c := range counts {
+				st, ok := states[c.State.String()]
+				if !ok {
+					st = &StateStats{Types: map[string]int64{}}
+					states[c.State.String()] = st
+				}
+				st.Total += c.Count
+				st.Types[c.Values[0]] += c.Count
+			}
+			stats[table] = states
 		}
-
-		var background bool
-		if v := r.URL.Query().Get("background"); v != "" {
-			background = v == "true"
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			logError(r, "couldn't encode stats", err)
+			http.Error(w, fmt.Sprintf("couldn't encode stats: %v", err), http.StatusInternalServerError)
+			return
 		}
+	})
 
-		var values []int
-		states := []string{"pending", "rejected", "approved"}
-		for i, s := range states {
-			if v := r.URL.Query().Get(s); v != "" {
-				if v == "true" {
-					values = append(values, i)
-				}
-			}
+	r.Get("/api/covers", func(w http.ResponseWriter, r *http.Request) {
+		// Obtain page from query params
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
 		}
-		if len(values) > 0 {
-			filters = append(filters, storage.Where("state IN (?)", values))
+		filters, draftTitle, err := coverFilters(ctx, store, r.URL.Query(), page)
+		if errors.Is(err, errNoDrafts) {
+			logInfo(r, "no drafts found")
+			http.Error(w, "Not drafts found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logError(r, "couldn't get cover filters", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		var coverPage int
 		coverLimit := 1000
-		var draftTitle string
-		if !background {
-			// Paginate by drafts
-			draftFilters = append(draftFilters, storage.Where("state = ?", storage.Approved))
-			drafts, err := store.ListDrafts(ctx, page, 1, "", draftFilters...)
-			if err != nil {
-				log.Println("couldn't list drafts:", err)
-				http.Error(w, fmt.Sprintf("couldn't list drafts: %v", err), http.StatusInternalServerError)
-				return
-			}
-			if len(drafts) == 0 {
-				log.Println("no drafts found")
-				http.Error(w, "Not drafts found", http.StatusNotFound)
-				return
-			}
-			draft := drafts[0]
-			draftTitle = draft.Title
-			filters = append(filters, storage.Where("draft_id = ?", draft.ID))
-		} else {
+		if r.URL.Query().Get("background") == "true" {
 			// Paginate by covers
 			coverPage = page
 			coverLimit = 50
-			if typ != "" {
-				filters = append(filters, storage.Where(fmt.Sprintf("type LIKE '%s'", typ)))
-			}
-			filters = append(filters, storage.Where("draft_id = ?", ""))
 		}
 
 		covers, err := store.ListAllCovers(ctx, coverPage, coverLimit, "", filters...)
 		if err != nil {
-			log.Println("couldn't list covers:", err)
+			logError(r, "couldn't list covers", err)
 			http.Error(w, fmt.Sprintf("couldn't list covers: %v", err), http.StatusInternalServerError)
 			return
 		}
 		var assets []*Asset
 		for _, cover := range covers {
-			thumbnail := strings.Replace(cover.URL(), "cdn.discordapp.com", "media.discordapp.net", 1)
-			thumbnail += "?width=300&height=300"
+			u := cover.URL()
+			if cover.Stored {
+				// Stored covers are downloaded when requested
+				u = fmt.Sprintf("/api/covers/%s/original", cover.ID)
+			}
 			assets = append(assets, &Asset{
 				ID:           cover.ID,
-				URL:          cover.URL(),
-				ThumbnailURL: thumbnail,
+				URL:          u,
+				ThumbnailURL: thumbnailURL(cover),
 				Prompt:       fmt.Sprintf("%s %s", cover.Type, cover.Title), //cover.Prompt,
 				State:        cover.State,
 				Liked:        false,
@@ -393,13 +661,40 @@ func Serve(ctx context.Context, cfg *Config) error {
 				Prompt: fmt.Sprintf("%s - No covers found", draftTitle),
 			})
 		}
-		if err := json.NewEncoder(w).Encode(assets); err != nil {
-			log.Println("couldn't encode covers:", err)
-			http.Error(w, fmt.Sprintf("couldn't encode covers: %v", err), http.StatusInternalServerError)
-			return
+
+		// Covers are paginated by drafts unless background covers are
+		// requested
+		size := 1
+		count := func() (int64, error) {
+			return store.CountDrafts(ctx, draftFilters(r.URL.Query())...)
 		}
+		if coverPage > 0 {
+			size = coverLimit
+			count = func() (int64, error) {
+				return store.CountAllCovers(ctx, filters...)
+			}
+		}
+		writeList(w, r, "covers", assets, page, size, count)
+	})
+
+	r.Get("/api/covers/{id}/thumbnail", func(w http.ResponseWriter, r *http.Request) {
+		serveCover(w, r, getThumbnail)
+	})
+	r.Get("/api/covers/{id}/original", func(w http.ResponseWriter, r *http.Request) {
+		serveCover(w, r, getOriginal)
 	})
 
+	r.Put("/api/covers/bulk/approve", func(w http.ResponseWriter, r *http.Request) {
+		updateCovers(w, r, store, map[string]any{
+			"state": storage.Approved,
+		})
+	})
+	r.Put("/api/covers/bulk/reject", func(w http.ResponseWriter, r *http.Request) {
+		updateCovers(w, r, store, map[string]any{
+			"state": storage.Rejected,
+			"likes": 0,
+		})
+	})
 	r.Put("/api/covers/{id}/approve", func(w http.ResponseWriter, r *http.Request) {
 		updateCover(w, r, store, func(c *storage.Cover) *storage.Cover {
 			c.State = storage.Approved
@@ -415,7 +710,9 @@ func Serve(ctx context.Context, cfg *Config) error {
 	})
 	r.Put("/api/covers/{id}/like", func(w http.ResponseWriter, r *http.Request) {
 		updateCover(w, r, store, func(c *storage.Cover) *storage.Cover {
-			c.State = storage.Approved
+			if cfg.LikeApproves {
+				c.State = storage.Approved
+			}
 			c.Likes = 1
 			return c
 		})
@@ -434,45 +731,9 @@ func Serve(ctx context.Context, cfg *Config) error {
 		})
 	})
 
-	r.Get("/api/albums", func(w http.ResponseWriter, r *http.Request) {
-		// Obtain page from query params
-		page, err := strconv.Atoi(r.URL.Query().Get("page"))
-		if err != nil {
-			page = 1
-		}
-		filters := []storage.Filter{}
-		var values []int
-		states := []string{"pending", "rejected", "approved"}
-		for i, s := range states {
-			if v := r.URL.Query().Get(s); v != "" {
-				if v == "true" {
-					values = append(values, i)
-				}
-			}
-		}
-		if len(values) > 0 {
-			filters = append(filters, storage.Where("state IN (?)", values))
-		}
-
-		queries := []string{"title", "type"}
-		for _, q := range queries {
-			if v := r.URL.Query().Get(q); v != "" {
-				filters = append(filters, storage.Where(fmt.Sprintf("albums.%s LIKE '%s'", q, v)))
-			}
-		}
-
-		albums, err := store.ListAlbums(ctx, page, 1, "", filters...)
-		if err != nil {
-			log.Println("couldn't list videos:", err)
-			http.Error(w, fmt.Sprintf("couldn't list videos: %v", err), http.StatusInternalServerError)
-			return
-		}
-		if len(albums) == 0 {
-			http.Error(w, "couldn't find albums", http.StatusNotFound)
-			return
-		}
-		a := albums[0]
-		coverURL := getJPG(a.ID)
+	// albumItem returns the album with its songs sorted by order
+	albumItem := func(r *http.Request, a *storage.Album) (*Album, error) {
+		coverURL := getJPG(r, a.ID)
 
 		title := a.Title
 		if a.Subtitle != "" {
@@ -482,19 +743,18 @@ func Serve(ctx context.Context, cfg *Config) error {
 			title = fmt.Sprintf("%s - Vol %d", title, a.Volume)
 		}
 
-		resp := &Album{
+		item := &Album{
 			ID:           a.ID,
 			URL:          coverURL,
 			ThumbnailURL: coverURL,
 			Prompt:       fmt.Sprintf("%s | %s | %s", title, a.Artist, a.Type),
 			State:        a.State,
+			Targets:      a.Targets,
 		}
 
 		songs, err := store.ListSongs(ctx, 1, 1000, "\"order\" asc", storage.Where("album_id = ?", a.ID))
 		if err != nil {
-			log.Println("couldn't list songs:", err)
-			http.Error(w, fmt.Sprintf("couldn't list songs: %v", err), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		for _, s := range songs {
 			g := s.Generation
@@ -503,11 +763,11 @@ func Serve(ctx context.Context, cfg *Config) error {
 
 			audioURL := g.Audio
 			if g.Processed {
-				audioURL = getMP3(g.ID)
+				audioURL = getMP3(r, g.ID)
 			}
-			waveURL := getJPG(g.ID)
+			waveURL := getJPG(r, g.ID)
 
-			resp.Songs = append(resp.Songs, &AlbumSong{
+			item.Songs = append(item.Songs, &AlbumSong{
 				ID:           s.ID,
 				URL:          audioURL,
 				ThumbnailURL: waveURL,
@@ -516,11 +776,37 @@ func Serve(ctx context.Context, cfg *Config) error {
 				Liked:        s.Likes > 0,
 			})
 		}
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			log.Println("couldn't encode songs:", err)
-			http.Error(w, fmt.Sprintf("couldn't encode songs: %v", err), http.StatusInternalServerError)
+		return item, nil
+	}
+
+	r.Get("/api/albums", func(w http.ResponseWriter, r *http.Request) {
+		// Obtain page and size from query params
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+		size := albumPageSize(r.URL.Query(), cfg.AlbumPageSize)
+		filters := albumFilters(r.URL.Query())
+
+		albums, err := store.ListAlbums(ctx, page, size, "", filters...)
+		if err != nil {
+			logError(r, "couldn't list albums", err)
+			http.Error(w, fmt.Sprintf("couldn't list albums: %v", err), http.StatusInternalServerError)
 			return
 		}
+		resp := []*Album{}
+		for _, a := range albums {
+			item, err := albumItem(r, a)
+			if err != nil {
+				logError(r, "couldn't list songs", err)
+				http.Error(w, fmt.Sprintf("couldn't list songs: %v", err), http.StatusInternalServerError)
+				return
+			}
+			resp = append(resp, item)
+		}
+		writeList(w, r, "albums", resp, page, size, func() (int64, error) {
+			return store.CountAlbums(ctx, filters...)
+		})
 	})
 	r.Put("/api/albums/{id}/delete", func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -554,6 +840,56 @@ func Serve(ctx context.Context, cfg *Config) error {
 			return a
 		})
 	})
+	r.Put("/api/albums/{id}/targets", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Targets string `json:"targets"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("couldn't decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		targets, err := storage.ParseTargets(req.Targets)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateAlbum(w, r, store, func(a *storage.Album) *storage.Album {
+			a.Targets = targets
+			return a
+		})
+	})
+	r.Put("/api/albums/{aid}/reorder", func(w http.ResponseWriter, r *http.Request) {
+		aid := chi.URLParam(r, "aid")
+		var ids []string
+		if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+			http.Error(w, fmt.Sprintf("couldn't decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		a, err := store.GetAlbum(ctx, aid)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("couldn't get album: %v", err), http.StatusNotFound)
+			return
+		}
+		if err := store.ReorderAlbumSongs(r.Context(), aid, ids); err != nil {
+			if errors.Is(err, storage.ErrInvalidOrder) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logError(r, "couldn't reorder songs", err)
+			http.Error(w, fmt.Sprintf("couldn't reorder songs: %v", err), http.StatusInternalServerError)
+			return
+		}
+		item, err := albumItem(r, a)
+		if err != nil {
+			logError(r, "couldn't list songs", err)
+			http.Error(w, fmt.Sprintf("couldn't list songs: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(item); err != nil {
+			logError(r, "couldn't encode album", err)
+		}
+	})
 	r.Put("/api/albums/{aid}/songs/{id}/delete", func(w http.ResponseWriter, r *http.Request) {
 		var title string
 		updateSong(w, r, store, func(s *storage.Song) *storage.Song {
@@ -596,7 +932,7 @@ func Serve(ctx context.Context, cfg *Config) error {
 		if id == "-" {
 			// Get random songs matching the type
 			songFilters := []storage.Filter{
-				storage.Where("type LIKE ?", album.Type),
+				storage.Like("type", album.Type),
 				storage.Where("state = ?", storage.Approved),
 			}
 			songs, err := store.ListSongs(ctx, 1, 1, "random()", songFilters...)
@@ -613,17 +949,43 @@ func Serve(ctx context.Context, cfg *Config) error {
 
 		// Get random titles matching the type
 		titleFilters := []storage.Filter{
-			storage.Where("type LIKE ?", album.Type),
+			storage.Like("type", album.Type),
 			storage.Where("state = ?", storage.Approved),
 		}
-		titles, err := store.ListTitles(ctx, 1, 1, "random()", titleFilters...)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("couldn't get titles: %v", err), http.StatusNotFound)
-			return
+		var titles []*storage.Title
+		for {
+			titles, err = store.ListTitles(ctx, 1, 1, "random()", titleFilters...)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("couldn't get titles: %v", err), http.StatusNotFound)
+				return
+			}
+			if len(titles) == 0 {
+				break
+			}
+			word, ok := banned.Match(titles[0].Title)
+			if !ok {
+				break
+			}
+			// Reject the title so it isn't picked again
+			logInfo(r, "title rejected due to banned word", "title", titles[0].Title, "word", word)
+			titles[0].State = storage.Rejected
+			if err := store.SetTitle(ctx, titles[0]); err != nil {
+				http.Error(w, fmt.Sprintf("couldn't set title: %v", err), http.StatusInternalServerError)
+				return
+			}
 		}
 		if len(titles) == 0 {
-			http.Error(w, "couldn't find titles", http.StatusNotFound)
-			return
+			if !cfg.AutoTitle {
+				http.Error(w, "couldn't find titles", http.StatusNotFound)
+				return
+			}
+			t, err := title.Auto(ctx, store, album.Type, "", nil, banned)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("couldn't generate title: %v", err), http.StatusInternalServerError)
+				return
+			}
+			logInfo(r, "no titles left, title generated", "type", album.Type, "title", t.Title)
+			titles = append(titles, t)
 		}
 		title := titles[0]
 
@@ -640,10 +1002,280 @@ func Serve(ctx context.Context, cfg *Config) error {
 		})
 	})
 
+	ready.Store(true)
 	<-ctx.Done()
+	ready.Store(false)
+
+	// Wait for the pending requests before cleaning the cache
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("filter: couldn't shutdown server: %v\n", err)
+	}
+	if cfg.CacheClear {
+		if !clearable {
+			log.Println("filter: cache is the local file storage, skipping clear")
+			return nil
+		}
+		res, err := pruneCache(cache, int64(cfg.CacheMaxSize)*1024*1024)
+		if err != nil {
+			return err
+		}
+		log.Printf("filter: cache cleared, removed %d files (%d bytes)\n", res.Removed, res.Freed)
+	}
 	return nil
 }
 
+// songFilters returns the song filters from the query params.
+func songFilters(query url.Values) []storage.Filter {
+	filters := []storage.Filter{
+		storage.Where("generations.processed = ?", true),
+	}
+	options := []string{"flagged", "ends"}
+	for _, o := range options {
+		if v := query.Get(o); v != "" {
+			b := v == "true"
+			filters = append(filters, storage.Where(fmt.Sprintf("%s = ?", o), b))
+		}
+	}
+	if v := query.Get("liked"); v != "" {
+		c := "="
+		b := v == "true"
+		if b {
+			c = ">"
+		}
+		filters = append(filters, storage.Where(fmt.Sprintf("likes %s 0", c)))
+	}
+
+	var values []int
+	states := []string{"pending", "rejected", "approved"}
+	for i, s := range states {
+		if v := query.Get(s); v != "" {
+			if v == "true" {
+				values = append(values, i)
+			}
+		}
+	}
+	if len(values) > 0 {
+		filters = append(filters, storage.Where("state IN (?)", values))
+	}
+
+	queries := []string{"prompt", "style"}
+	for _, q := range queries {
+		if v := query.Get(q); v != "" {
+			filters = append(filters, storage.Contains("songs."+q, v))
+		}
+	}
+	if v := query.Get("type"); v != "" {
+		filters = append(filters, storage.Like("songs.type", v))
+	}
+	if v := query.Get("account"); v != "" {
+		filters = append(filters, storage.Where("songs.account = ?", v))
+	}
+	if v := query.Get("provider"); v != "" {
+		filters = append(filters, storage.Where("songs.provider = ?", v))
+	}
+	if v := query.Get("tag"); v != "" {
+		filters = append(filters, storage.HasTag(v))
+	}
+	return filters
+}
+
+// maxAlbumPageSize limits the albums per page, as the songs of each album
+// are listed too.
+const maxAlbumPageSize = 100
+
+// albumPageSize returns the size query param, or the default size if it isn't
+// set or invalid. The default is 10 if not configured.
+func albumPageSize(query url.Values, def int) int {
+	size, err := strconv.Atoi(query.Get("size"))
+	if err != nil || size < 1 {
+		size = def
+	}
+	if size < 1 {
+		size = 10
+	}
+	return min(size, maxAlbumPageSize)
+}
+
+// albumFilters returns the album filters from the query params.
+func albumFilters(query url.Values) []storage.Filter {
+	filters := []storage.Filter{}
+	var values []int
+	states := []string{"pending", "rejected", "approved"}
+	for i, s := range states {
+		if v := query.Get(s); v != "" {
+			if v == "true" {
+				values = append(values, i)
+			}
+		}
+	}
+	if len(values) > 0 {
+		filters = append(filters, storage.Where("state IN (?)", values))
+	}
+
+	if v := query.Get("title"); v != "" {
+		filters = append(filters, storage.Contains("albums.title", v))
+	}
+	if v := query.Get("type"); v != "" {
+		filters = append(filters, storage.Like("albums.type", v))
+	}
+	return filters
+}
+
+var errNoDrafts = errors.New("no drafts found")
+
+// coverFilters returns the cover filters from the query params.
+// Covers are paginated by drafts unless background covers are requested, so
+// the filters are limited to the draft of the given page and its title is
+// returned.
+func coverFilters(ctx context.Context, store *storage.Store, query url.Values, page int) ([]storage.Filter, string, error) {
+	filters := []storage.Filter{}
+	typ := query.Get("type")
+
+	if v := query.Get("liked"); v != "" {
+		c := "="
+		b := v == "true"
+		if b {
+			c = ">"
+		}
+		filters = append(filters, storage.Where(fmt.Sprintf("likes %s 0", c)))
+	}
+
+	var background bool
+	if v := query.Get("background"); v != "" {
+		background = v == "true"
+	}
+
+	var values []int
+	states := []string{"pending", "rejected", "approved"}
+	for i, s := range states {
+		if v := query.Get(s); v != "" {
+			if v == "true" {
+				values = append(values, i)
+			}
+		}
+	}
+	if len(values) > 0 {
+		filters = append(filters, storage.Where("state IN (?)", values))
+	}
+
+	if background {
+		if typ != "" {
+			filters = append(filters, storage.Like("type", typ))
+		}
+		filters = append(filters, storage.Where("draft_id = ?", ""))
+		return filters, "", nil
+	}
+
+	// Paginate by drafts
+	drafts, err := store.ListDrafts(ctx, page, 1, "", draftFilters(query)...)
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't list drafts: %w", err)
+	}
+	if len(drafts) == 0 {
+		return nil, "", errNoDrafts
+	}
+	draft := drafts[0]
+	filters = append(filters, storage.Where("draft_id = ?", draft.ID))
+	return filters, draft.Title, nil
+}
+
+// draftFilters returns the filters of the drafts used to paginate the covers.
+func draftFilters(query url.Values) []storage.Filter {
+	filters := []storage.Filter{
+		storage.Where("state = ?", storage.Approved),
+	}
+	if typ := query.Get("type"); typ != "" {
+		filters = append(filters, storage.Like("type", typ))
+	}
+	return filters
+}
+
+// updateSongs updates all the songs matching the query params and writes
+// the number of songs updated.
+func updateSongs(w http.ResponseWriter, r *http.Request, store *storage.Store, values map[string]any) {
+	filters := songFilters(r.URL.Query())
+	n, err := store.UpdateGenerationSongs(r.Context(), values, filters...)
+	if err != nil {
+		logError(r, "couldn't update songs", err)
+		http.Error(w, fmt.Sprintf("couldn't update songs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeUpdated(w, r, n)
+}
+
+// updateCovers updates all the covers matching the query params and writes
+// the number of covers updated.
+func updateCovers(w http.ResponseWriter, r *http.Request, store *storage.Store, values map[string]any) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		page = 1
+	}
+	filters, _, err := coverFilters(r.Context(), store, r.URL.Query(), page)
+	if errors.Is(err, errNoDrafts) {
+		writeUpdated(w, r, 0)
+		return
+	}
+	if err != nil {
+		logError(r, "couldn't get cover filters", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	n, err := store.UpdateCovers(r.Context(), values, filters...)
+	if err != nil {
+		logError(r, "couldn't update covers", err)
+		http.Error(w, fmt.Sprintf("couldn't update covers: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeUpdated(w, r, n)
+}
+
+func writeUpdated(w http.ResponseWriter, r *http.Request, n int64) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int64{"updated": n}); err != nil {
+		logError(r, "couldn't encode response", err)
+	}
+}
+
+// listResponse is the response of the list endpoints with pagination
+// metadata.
+type listResponse struct {
+	Items any   `json:"items"`
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+// writeList writes the items of a list endpoint. If the meta query param is
+// set, the items are wrapped with the page, the size and the total count of
+// items, so the frontend can show the number of pages.
+func writeList(w http.ResponseWriter, r *http.Request, name string, items any, page, size int, count func() (int64, error)) {
+	var resp any = items
+	if r.URL.Query().Get("meta") == "true" {
+		total, err := count()
+		if err != nil {
+			logError(r, "couldn't count "+name, err)
+			http.Error(w, fmt.Sprintf("couldn't count %s: %v", name, err), http.StatusInternalServerError)
+			return
+		}
+		if page < 1 {
+			page = 1
+		}
+		resp = &listResponse{
+			Items: items,
+			Page:  page,
+			Size:  size,
+			Total: total,
+		}
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logError(r, "couldn't encode "+name, err)
+		http.Error(w, fmt.Sprintf("couldn't encode %s: %v", name, err), http.StatusInternalServerError)
+		return
+	}
+}
+
 func updateSong(w http.ResponseWriter, r *http.Request, store *storage.Store, update func(s *storage.Song) *storage.Song) {
 	id := chi.URLParam(r, "id")
 	updateSongWithID(w, r, store, id, update)
@@ -658,7 +1290,7 @@ func updateSongWithID(w http.ResponseWriter, r *http.Request, store *storage.Sto
 	}
 	song = update(song)
 	if err := store.SetSong(ctx, song); err != nil {
-		log.Println("couldn't set song:", err)
+		logError(r, "couldn't set song", err)
 		http.Error(w, fmt.Sprintf("couldn't set song: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -674,7 +1306,7 @@ func updateCover(w http.ResponseWriter, r *http.Request, store *storage.Store, u
 	}
 	cover = update(cover)
 	if err := store.SetCover(ctx, cover); err != nil {
-		log.Println("couldn't set cover:", err)
+		logError(r, "couldn't set cover", err)
 		http.Error(w, fmt.Sprintf("couldn't set cover: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -690,7 +1322,7 @@ func updateAlbum(w http.ResponseWriter, r *http.Request, store *storage.Store, u
 	}
 	album = update(album)
 	if err := store.SetAlbum(ctx, album); err != nil {
-		log.Println("couldn't set album:", err)
+		logError(r, "couldn't set album", err)
 		http.Error(w, fmt.Sprintf("couldn't set album: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -705,21 +1337,57 @@ func updateTitle(w http.ResponseWriter, r *http.Request, store *storage.Store, i
 	}
 	title = update(title)
 	if err := store.SetTitle(ctx, title); err != nil {
-		log.Println("couldn't set title:", err)
+		logError(r, "couldn't set title", err)
 		http.Error(w, fmt.Sprintf("couldn't set title: %v", err), http.StatusInternalServerError)
 		return
 	}
 }
 
+func downloadFile(ctx context.Context, u, output string) error {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("web: couldn't create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("web: couldn't download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("web: bad status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("web: couldn't read body: %w", err)
+	}
+	if err := os.WriteFile(output, body, 0644); err != nil {
+		return fmt.Errorf("web: couldn't write file: %w", err)
+	}
+	return nil
+}
+
 type Song struct {
 	ID           string        `json:"id"`
 	GenerationID string        `json:"generation_id"`
 	URL          string        `json:"url"`
 	ThumbnailURL string        `json:"thumbnail_url"`
 	Prompt       string        `json:"prompt"`
+	Account      string        `json:"account"`
+	Provider     string        `json:"provider"`
 	State        storage.State `json:"state"`
 	Liked        bool          `json:"liked"`
 	Selected     bool          `json:"selected"`
+	Tags         []string      `json:"tags"`
+	Credits      string        `json:"credits"`
+}
+
+// StateStats is the number of items with a state, in total and per type.
+type StateStats struct {
+	Total int64            `json:"total"`
+	Types map[string]int64 `json:"types"`
 }
 
 type Album struct {
@@ -728,6 +1396,7 @@ type Album struct {
 	ThumbnailURL string        `json:"thumbnail_url"`
 	Prompt       string        `json:"prompt"`
 	State        storage.State `json:"state"`
+	Targets      string        `json:"targets"`
 	Songs        []*AlbumSong  `json:"songs"`
 }
 
@@ -0,0 +1,528 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/storage"
+)
+
+func testStore(t *testing.T) *storage.Store {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// combinations returns all the query combinations of the given params, where
+// an empty value means the param isn't set.
+// The values of the state param are comma separated lists of states set to
+// true (e.g. pending,approved).
+func combinations(params map[string][]string) []url.Values {
+	queries := []url.Values{{}}
+	for k, vs := range params {
+		var next []url.Values
+		for _, q := range queries {
+			for _, v := range vs {
+				c := url.Values{}
+				for qk, qv := range q {
+					c[qk] = qv
+				}
+				switch {
+				case v == "":
+				case k == "state":
+					for _, s := range strings.Split(v, ",") {
+						c.Set(s, "true")
+					}
+				default:
+					c.Set(k, v)
+				}
+				next = append(next, c)
+			}
+		}
+		queries = next
+	}
+	return queries
+}
+
+func TestSongsTotal(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+
+	type fixture struct {
+		state     storage.State
+		processed bool
+		flagged   bool
+		liked     bool
+		typ       string
+		tag       string
+	}
+	var fixtures []fixture
+	for i := 0; i < 48; i++ {
+		f := fixture{
+			state:     []storage.State{storage.Pending, storage.Rejected, storage.Approved}[i%3],
+			processed: i%7 != 0,
+			flagged:   i%2 == 0,
+			liked:     i%5 < 2,
+			typ:       []string{"jazz", "rock"}[i%4/2],
+		}
+		if i%3 == 1 || i%4 == 0 {
+			f.tag = "calm"
+		}
+		fixtures = append(fixtures, f)
+		id := fmt.Sprintf("s%02d", i)
+		gid := fmt.Sprintf("g%02d", i)
+		likes := 0
+		if f.liked {
+			likes = 1
+		}
+		if err := store.SetSong(ctx, &storage.Song{ID: id, Type: f.typ, State: f.state, Likes: likes, GenerationID: &gid}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetGeneration(ctx, &storage.Generation{ID: gid, SongID: &id, Processed: f.processed, Flagged: f.flagged}); err != nil {
+			t.Fatal(err)
+		}
+		if f.tag != "" {
+			if err := store.AddSongTag(ctx, id, f.tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	queries := combinations(map[string][]string{
+		"state":   {"", "pending", "approved", "rejected", "pending,approved"},
+		"flagged": {"", "true", "false"},
+		"liked":   {"", "true", "false"},
+		"type":    {"", "jazz"},
+		"tag":     {"", "calm"},
+	})
+	for _, q := range queries {
+		t.Run(q.Encode(), func(t *testing.T) {
+			var want int64
+			for _, f := range fixtures {
+				states := map[string]storage.State{"pending": storage.Pending, "rejected": storage.Rejected, "approved": storage.Approved}
+				var stateSet, stateOK bool
+				for k, s := range states {
+					if q.Get(k) == "true" {
+						stateSet = true
+						stateOK = stateOK || f.state == s
+					}
+				}
+				switch {
+				case !f.processed:
+				case stateSet && !stateOK:
+				case q.Get("flagged") != "" && (q.Get("flagged") == "true") != f.flagged:
+				case q.Get("liked") != "" && (q.Get("liked") == "true") != f.liked:
+				case q.Get("type") != "" && q.Get("type") != f.typ:
+				case q.Get("tag") != "" && q.Get("tag") != f.tag:
+				default:
+					want++
+				}
+			}
+
+			filters := songFilters(q)
+			got, err := store.CountGenerations(ctx, filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("got %d, want %d", got, want)
+			}
+			list, err := store.ListGenerations(ctx, 1, 1000, "songs.id desc", filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(list)) != got {
+				t.Errorf("listed %d, counted %d", len(list), got)
+			}
+		})
+	}
+}
+
+func TestUpdateSongsWildcards(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+	for i, prompt := range []string{"100% jazz", "1000 jazz", "slow_jazz", "slow jazz"} {
+		id := fmt.Sprintf("s%d", i)
+		gid := fmt.Sprintf("g%d", i)
+		if err := store.SetSong(ctx, &storage.Song{ID: id, Prompt: prompt, GenerationID: &gid}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetGeneration(ctx, &storage.Generation{ID: gid, SongID: &id, Processed: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wildcards in the filters are matched literally
+	for _, prompt := range []string{"0% jazz", "w_jazz"} {
+		req := httptest.NewRequest(http.MethodPut, "/api/songs/bulk/approve?prompt="+url.QueryEscape(prompt), nil)
+		rec := httptest.NewRecorder()
+		updateSongs(rec, req, store, map[string]any{"state": storage.Approved})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	want := map[string]storage.State{"s0": storage.Approved, "s1": storage.Pending, "s2": storage.Approved, "s3": storage.Pending}
+	for id, state := range want {
+		s, err := store.GetSong(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.State != state {
+			t.Errorf("%s: got state %v, want %v", id, s.State, state)
+		}
+	}
+}
+
+func TestCoversTotal(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+
+	drafts := []struct {
+		id    string
+		typ   string
+		state storage.State
+	}{
+		{"d1", "jazz", storage.Approved},
+		{"d2", "jazz", storage.Approved},
+		{"d3", "rock", storage.Approved},
+		{"d4", "jazz", storage.Pending},
+		{"d5", "jazz", storage.Rejected},
+	}
+	for _, d := range drafts {
+		if err := store.SetDraft(ctx, &storage.Draft{ID: d.id, Type: d.typ, State: d.state}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 30; i++ {
+		c := &storage.Cover{
+			ID:    fmt.Sprintf("c%02d", i),
+			Type:  []string{"jazz", "rock"}[i%2],
+			State: []storage.State{storage.Pending, storage.Rejected, storage.Approved}[i%3],
+			Likes: i % 4 / 3,
+		}
+		if i%5 != 0 {
+			c.DraftID = drafts[i%len(drafts)].id
+		}
+		if err := store.SetCover(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queries := combinations(map[string][]string{
+		"state":      {"", "pending", "approved", "rejected", "pending,approved"},
+		"liked":      {"", "true", "false"},
+		"type":       {"", "jazz"},
+		"background": {"", "true"},
+	})
+	for _, q := range queries {
+		t.Run(q.Encode(), func(t *testing.T) {
+			if q.Get("background") == "true" {
+				filters, _, err := coverFilters(ctx, store, q, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := store.CountAllCovers(ctx, filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				list, err := store.ListAllCovers(ctx, 1, 1000, "", filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(list)) != got {
+					t.Errorf("listed %d, counted %d", len(list), got)
+				}
+				return
+			}
+
+			// Covers are paginated by drafts, so the total is the number of
+			// pages with a draft
+			want := 2
+			if q.Get("type") == "" {
+				want = 3
+			}
+			got, err := store.CountDrafts(ctx, draftFilters(q)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != int64(want) {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			for page := 1; page <= want; page++ {
+				if _, _, err := coverFilters(ctx, store, q, page); err != nil {
+					t.Fatalf("page %d: %v", page, err)
+				}
+			}
+			if _, _, err := coverFilters(ctx, store, q, want+1); !errors.Is(err, errNoDrafts) {
+				t.Errorf("page %d: got %v, want %v", want+1, err, errNoDrafts)
+			}
+		})
+	}
+}
+
+func TestAlbumsTotal(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+
+	for i := 0; i < 20; i++ {
+		if err := store.SetAlbum(ctx, &storage.Album{
+			ID:    fmt.Sprintf("a%02d", i),
+			Type:  []string{"jazz", "rock"}[i%2],
+			Title: []string{"Blue Night", "Red Morning", "Blue Morning"}[i%3],
+			State: []storage.State{storage.Pending, storage.Rejected, storage.Approved, storage.Used}[i%4],
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queries := combinations(map[string][]string{
+		"state": {"", "pending", "approved", "rejected", "pending,approved"},
+		"type":  {"", "jazz"},
+		"title": {"", "blue"},
+	})
+	for _, q := range queries {
+		t.Run(q.Encode(), func(t *testing.T) {
+			filters := albumFilters(q)
+			got, err := store.CountAlbums(ctx, filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			list, err := store.ListAlbums(ctx, 1, 1000, "", filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(list)) != got {
+				t.Errorf("listed %d, counted %d", len(list), got)
+			}
+			// The last page must have albums and the next one must be
+			// empty
+			if got == 0 {
+				return
+			}
+			for _, size := range []int{1, 3} {
+				pages := (int(got) + size - 1) / size
+				last, err := store.ListAlbums(ctx, pages, size, "", filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next, err := store.ListAlbums(ctx, pages+1, size, "", filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := int(got) - (pages-1)*size; len(last) != want || len(next) != 0 {
+					t.Errorf("size %d: got %d albums in last page (want %d) and %d in the next one", size, len(last), want, len(next))
+				}
+			}
+		})
+	}
+}
+
+func TestAlbumPageSize(t *testing.T) {
+	tests := []struct {
+		query string
+		def   int
+		want  int
+	}{
+		{"", 10, 10},
+		{"", 0, 10},
+		{"size=5", 10, 5},
+		{"size=0", 7, 7},
+		{"size=abc", 7, 7},
+		{"size=1000", 10, maxAlbumPageSize},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := albumPageSize(q, tt.def); got != tt.want {
+			t.Errorf("%q (default %d): got %d, want %d", tt.query, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		now := time.Now()
+		for i, name := range []string{"a.mp3", "b.jpg", "c.mp3"} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+				t.Fatal(err)
+			}
+			mod := now.Add(time.Duration(i-3) * time.Hour)
+			if err := os.Chtimes(path, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	// The oldest files are removed until the size is below the limit
+	dir := setup(t)
+	res, err := pruneCache(dir, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 2 || res.Freed != 200 || res.Size != 100 {
+		t.Errorf("got %+v, want 2 removed, 200 freed and 100 size", res)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.mp3")); err != nil {
+		t.Errorf("newest file was removed: %v", err)
+	}
+
+	// Without limit all the files are removed
+	dir = setup(t)
+	res, err = pruneCache(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 3 || res.Size != 0 {
+		t.Errorf("got %+v, want 3 removed and 0 size", res)
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	items := []string{"a", "b"}
+	count := func() (int64, error) { return 42, nil }
+
+	// Without meta the items are returned as they are
+	w := httptest.NewRecorder()
+	writeList(w, httptest.NewRequest("GET", "/api/songs?page=3", nil), "songs", items, 3, 2, count)
+	if got := strings.TrimSpace(w.Body.String()); got != `["a","b"]` {
+		t.Errorf("got %s", got)
+	}
+
+	// With meta the items are wrapped
+	w = httptest.NewRecorder()
+	writeList(w, httptest.NewRequest("GET", "/api/songs?page=3&meta=true", nil), "songs", items, 3, 2, count)
+	var resp struct {
+		Items []string `json:"items"`
+		Page  int      `json:"page"`
+		Size  int      `json:"size"`
+		Total int64    `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 2 || resp.Page != 3 || resp.Size != 2 || resp.Total != 42 {
+		t.Errorf("got %+v", resp)
+	}
+
+	// Count errors are returned
+	w = httptest.NewRecorder()
+	writeList(w, httptest.NewRequest("GET", "/api/songs?meta=true", nil), "songs", items, 1, 2, func() (int64, error) {
+		return 0, errors.New("boom")
+	})
+	if w.Code != 500 {
+		t.Errorf("got status %d, want 500", w.Code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	store := testStore(t)
+	fs, err := filestore.New("local", t.TempDir(), "", false, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := filestore.New("local", filepath.Join(t.TempDir(), "missing"), "", false, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(h http.HandlerFunc, want int) healthResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/healthz", nil))
+		if w.Code != want {
+			t.Fatalf("got status %d, want %d: %s", w.Code, want, w.Body.String())
+		}
+		var resp healthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	resp := check(healthHandler(map[string]func(context.Context) error{
+		"db": store.Ping,
+		"fs": fs.Check,
+	}), 200)
+	if resp.Status != "ok" || resp.Checks["db"] != "ok" || resp.Checks["fs"] != "ok" {
+		t.Errorf("got %+v", resp)
+	}
+	resp = check(healthHandler(map[string]func(context.Context) error{
+		"db": store.Ping,
+		"fs": missing.Check,
+	}), 503)
+	if resp.Status != "error" || resp.Checks["db"] != "ok" || resp.Checks["fs"] != "error" {
+		t.Errorf("got %+v", resp)
+	}
+
+	var ready atomic.Bool
+	check(readyHandler(&ready), 503)
+	ready.Store(true)
+	check(readyHandler(&ready), 200)
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, false)
+	h := middleware.RequestID(l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logError(r, "couldn't list songs", errors.New("boom"))
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/songs", nil))
+	got := buf.String()
+	for _, want := range []string{`level=ERROR`, `msg="couldn't list songs"`, `req_id=`, `method=GET`, `path=/api/songs`, `err=boom`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not found in %s", want, got)
+		}
+	}
+	if strings.Contains(got, "req_id= ") {
+		t.Errorf("empty request id in %s", got)
+	}
+}
+
+func TestSelectChanged(t *testing.T) {
+	g1, g2 := "g1", "g2"
+	before := &storage.Song{ID: "s", State: storage.Pending, GenerationID: &g1}
+	tests := []struct {
+		name  string
+		after storage.Song
+		want  bool
+	}{
+		{"unchanged", storage.Song{State: storage.Pending, GenerationID: &g1}, false},
+		{"other generation", storage.Song{State: storage.Pending, GenerationID: &g2}, true},
+		{"no generation", storage.Song{State: storage.Pending}, true},
+		{"approved", storage.Song{State: storage.Approved, GenerationID: &g1}, true},
+		{"in album", storage.Song{State: storage.Pending, GenerationID: &g1, AlbumID: "a"}, true},
+	}
+	for _, tt := range tests {
+		if got := changed(before, &tt.after); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/igolaizola/musikai/pkg/errstreak"
+	"github.com/igolaizola/musikai/pkg/filestore"
+	"github.com/igolaizola/musikai/pkg/sound/ffmpeg"
+	"github.com/igolaizola/musikai/pkg/storage"
+	"github.com/igolaizola/musikai/pkg/youtube"
+)
+
+type Config struct {
+	Debug  bool
+	DBType string
+	DBConn string
+	FSType string
+	FSConn string
+	Proxy  string
+
+	Timeout     time.Duration
+	Concurrency int
+	Limit       int
+	Type        string
+	Account     string
+
+	// MaxConsecutiveErrors is the number of consecutive errors tolerated
+	// before aborting (10 if zero).
+	MaxConsecutiveErrors int
+
+	// Privacy is the privacy status of the uploaded videos: public,
+	// unlisted or private.
+	Privacy      string
+	ClientID     string
+	ClientSecret string
+	Wait         time.Duration
+	Quota        int
+}
+
+// Run renders a video with the album cover of each approved song and uploads
+// it to youtube, storing the video ID in the song.
+func Run(ctx context.Context, cfg *Config) error {
+	var iteration int
+	log.Println("youtube-upload: process started")
+	defer func() {
+		log.Printf("youtube-upload: process ended (%d)\n", iteration)
+	}()
+
+	debug := func(format string, args ...interface{}) {
+		if !cfg.Debug {
+			return
+		}
+		format += "\n"
+		log.Printf(format, args...)
+	}
+
+	privacy := cfg.Privacy
+	if privacy == "" {
+		privacy = "private"
+	}
+	switch privacy {
+	case "public", "unlisted", "private":
+	default:
+		return fmt.Errorf("youtube-upload: invalid privacy status %q", privacy)
+	}
+
+	store, err := storage.New(cfg.DBType, cfg.DBConn, cfg.Debug)
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't create orm store: %w", err)
+	}
+	if err := store.Start(ctx); err != nil {
+		return fmt.Errorf("youtube-upload: couldn't start orm store: %w", err)
+	}
+
+	fs, err := filestore.New(cfg.FSType, cfg.FSConn, cfg.Proxy, cfg.Debug, store)
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't create file storage: %w", err)
+	}
+
+	// Create youtube client with the OAuth token of the account
+	token, err := store.NewCookieStore("youtube", cfg.Account).GetCookie(ctx)
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't get oauth token: %w", err)
+	}
+	client, err := youtube.New(ctx, &youtube.Config{
+		Token:        token,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Debug:        cfg.Debug,
+		Wait:         cfg.Wait,
+		Quota:        cfg.Quota,
+	})
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't create youtube client: %w", err)
+	}
+	defer func() {
+		log.Printf("youtube-upload: estimated quota used %d units\n", client.QuotaUsed())
+	}()
+
+	// Print time stats
+	start := time.Now()
+	defer func() {
+		total := time.Since(start)
+		log.Printf("youtube-upload: total time %s, average time %s\n", total, total/time.Duration(iteration))
+	}()
+
+	streak := errstreak.New("youtube-upload", cfg.MaxConsecutiveErrors)
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 24 * time.Hour
+	}
+	ticker := time.NewTicker(timeout)
+	last := time.Now()
+	defer ticker.Stop()
+
+	// Concurrency settings
+	concurrency := cfg.Concurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	errC := make(chan error, concurrency)
+	defer close(errC)
+	for i := 0; i < concurrency; i++ {
+		errC <- nil
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	states := []storage.State{storage.Approved, storage.Used}
+	var songs []*storage.Song
+	var currID string
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("youtube-upload: %w", ctx.Err())
+		case <-ticker.C:
+			return nil
+		case err := <-errC:
+			// Check exit conditions
+			if errors.Is(err, youtube.ErrQuotaExceeded) {
+				return fmt.Errorf("youtube-upload: %w", err)
+			}
+			if err := streak.Add(err); err != nil {
+				return err
+			}
+			if cfg.Limit > 0 && iteration >= cfg.Limit {
+				return nil
+			}
+
+			iteration++
+			if time.Since(last) > 60*time.Minute {
+				last = time.Now()
+				log.Printf("youtube-upload: iteration %d\n", iteration)
+			}
+
+			// Get next songs
+			filters := []storage.Filter{
+				storage.Where("songs.id > ?", currID),
+				storage.Where("songs.state IN (?)", states),
+				storage.Where("songs.youtube_id = ''"),
+				storage.Where("songs.title != ''"),
+				storage.Where("songs.album_id IN (SELECT id FROM albums WHERE state IN (?))", states),
+			}
+			if cfg.Type != "" {
+				filters = append(filters, storage.Like("songs.type", cfg.Type))
+			}
+
+			if len(songs) == 0 {
+				// Get songs from the database.
+				var err error
+				songs, err = store.ListSongs(ctx, 1, 100, "songs.id", filters...)
+				if err != nil {
+					return fmt.Errorf("youtube-upload: couldn't get songs from database: %w", err)
+				}
+				if len(songs) == 0 {
+					return errors.New("youtube-upload: no songs to upload")
+				}
+				currID = songs[len(songs)-1].ID
+			}
+			song := songs[0]
+			songs = songs[1:]
+
+			// Launch upload in a goroutine
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				debug("youtube-upload: start %s %s", song.ID, song.Title)
+				err := upload(ctx, client, store, fs, song, privacy)
+				if err != nil {
+					log.Println(err)
+				}
+				debug("youtube-upload: end %s %s", song.ID, song.Title)
+				errC <- err
+			}()
+		}
+	}
+}
+
+func upload(ctx context.Context, client *youtube.Client, store *storage.Store, fs *filestore.Store, song *storage.Song, privacy string) error {
+	album, err := store.GetAlbum(ctx, song.AlbumID)
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't get album %s: %w", song.AlbumID, err)
+	}
+
+	dir, err := os.MkdirTemp("", "musikai-youtube-*")
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Download cover and song
+	cover := filepath.Join(dir, filestore.JPG(album.ID))
+	if err := fs.GetJPG(ctx, cover, album.ID); err != nil {
+		return fmt.Errorf("youtube-upload: couldn't download cover: %w", err)
+	}
+	mp3 := filepath.Join(dir, filestore.MP3(*song.GenerationID))
+	if err := fs.GetMP3(ctx, mp3, *song.GenerationID); err != nil {
+		return fmt.Errorf("youtube-upload: couldn't download song: %w", err)
+	}
+
+	// Render the video
+	video := filepath.Join(dir, fmt.Sprintf("%s.mp4", song.ID))
+	if err := ffmpeg.StaticVideo(ctx, cover, mp3, video); err != nil {
+		return fmt.Errorf("youtube-upload: couldn't render video: %w", err)
+	}
+
+	// Upload the video
+	id, err := client.Upload(ctx, &youtube.Upload{
+		Title:       videoTitle(song.Title),
+		Description: videoDescription(song, album),
+		Tags:        videoTags(song, album),
+		CategoryID:  youtube.MusicCategory,
+		Privacy:     privacy,
+		File:        video,
+	})
+	if err != nil {
+		return fmt.Errorf("youtube-upload: couldn't upload song %s: %w", song.ID, err)
+	}
+
+	// Update song
+	song.YoutubeID = id
+	if err := store.SetSong(ctx, song); err != nil {
+		return fmt.Errorf("youtube-upload: couldn't set song %s %s: %w", song.ID, id, err)
+	}
+	log.Printf("youtube-upload: song %s uploaded to youtube %s\n", song.ID, id)
+	return nil
+}
+
+// removeBrackets removes the angle brackets, that youtube doesn't allow.
+var removeBrackets = strings.NewReplacer("<", "", ">", "")
+
+// videoTitle returns the song title without the characters youtube doesn't
+// allow, cut to 100 characters.
+func videoTitle(title string) string {
+	title = strings.TrimSpace(removeBrackets.Replace(title))
+	if utf8.RuneCountInString(title) > 100 {
+		title = strings.TrimSpace(string([]rune(title)[:100]))
+	}
+	return title
+}
+
+// videoDescription returns the song description or a default one with the
+// album, along with the musical key if it was detected.
+func videoDescription(song *storage.Song, album *storage.Album) string {
+	description := song.Description
+	if description == "" {
+		description = fmt.Sprintf("From the album %s by %s", album.FullTitle(), album.Artist)
+	}
+	if song.Generation != nil && song.Generation.Key != "" {
+		description += "\nKey: " + song.Generation.Key
+	}
+	description = removeBrackets.Replace(description)
+	if len(description) > 5000 {
+		description = strings.ToValidUTF8(description[:5000], "")
+	}
+	return description
+}
+
+// videoTags returns the artist, the album genres and the song type as tags.
+func videoTags(song *storage.Song, album *storage.Album) []string {
+	var tags []string
+	lookup := map[string]struct{}{}
+	for _, t := range append([]string{album.Artist}, append(album.GenreTags(), song.Type)...) {
+		key := strings.ToLower(strings.TrimSpace(t))
+		if key == "" {
+			continue
+		}
+		if _, ok := lookup[key]; ok {
+			continue
+		}
+		lookup[key] = struct{}{}
+		tags = append(tags, strings.TrimSpace(t))
+	}
+	return tags
+}
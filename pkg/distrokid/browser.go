@@ -30,6 +30,9 @@ type Browser struct {
 	profile          bool
 	cookieStore      CookieStore
 	binPath          string
+	screenshotDir    string
+	waitBeforeClose  time.Duration
+	submitTimeout    time.Duration
 }
 
 type BrowserConfig struct {
@@ -39,6 +42,15 @@ type BrowserConfig struct {
 	Profile     bool
 	CookieStore CookieStore
 	BinPath     string
+	// ScreenshotDir is the folder where publish screenshots are saved
+	// (default "logs").
+	ScreenshotDir string
+	// WaitBeforeClose is the time to wait after the album is submitted
+	// before closing the tab (default 1s).
+	WaitBeforeClose time.Duration
+	// SubmitTimeout is the maximum time to wait for the preview link in auto
+	// mode before flagging the album as needing manual review (default 5m).
+	SubmitTimeout time.Duration
 }
 
 func NewBrowser(cfg *BrowserConfig) *Browser {
@@ -46,13 +58,28 @@ func NewBrowser(cfg *BrowserConfig) *Browser {
 	if wait == 0 {
 		wait = 1 * time.Second
 	}
+	screenshotDir := cfg.ScreenshotDir
+	if screenshotDir == "" {
+		screenshotDir = "logs"
+	}
+	waitBeforeClose := cfg.WaitBeforeClose
+	if waitBeforeClose == 0 {
+		waitBeforeClose = 1 * time.Second
+	}
+	submitTimeout := cfg.SubmitTimeout
+	if submitTimeout == 0 {
+		submitTimeout = 5 * time.Minute
+	}
 	return &Browser{
-		remote:      cfg.Remote,
-		proxy:       cfg.Proxy,
-		profile:     cfg.Profile,
-		cookieStore: cfg.CookieStore,
-		rateLimit:   ratelimit.New(wait),
-		binPath:     cfg.BinPath,
+		remote:          cfg.Remote,
+		proxy:           cfg.Proxy,
+		profile:         cfg.Profile,
+		cookieStore:     cfg.CookieStore,
+		rateLimit:       ratelimit.New(wait),
+		binPath:         cfg.BinPath,
+		screenshotDir:   screenshotDir,
+		waitBeforeClose: waitBeforeClose,
+		submitTimeout:   submitTimeout,
 	}
 }
 
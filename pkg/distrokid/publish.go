@@ -2,18 +2,23 @@ package distrokid
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/chromedp/chromedp"
+	"github.com/igolaizola/musikai/pkg/image"
 )
 
 type Album struct {
+	// ID is the internal album id, used to name the screenshot
+	ID             string
 	Artist         string
 	FirstName      string
 	LastName       string
@@ -23,12 +28,60 @@ type Album struct {
 	SecondaryGenre string
 	Cover          string
 	Songs          []*Song
+	// MaxSongs is the maximum number of songs of the album, DefaultMaxSongs
+	// if zero.
+	MaxSongs int
+
+	// Screenshot is set by Publish with the path of the screenshot taken
+	// before submitting the album.
+	Screenshot string
+	// Outcome is set by Publish with the classification of the result.
+	Outcome Outcome
+}
+
+// Outcome classifies the result of a publication.
+type Outcome string
+
+const (
+	// OutcomeSuccess means the album was submitted and the preview page
+	// was reached.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeNeedsManual means the album may have been submitted but the
+	// preview page wasn't reached, so it must be reviewed manually.
+	OutcomeNeedsManual Outcome = "needs-manual"
+	// OutcomeError means the album couldn't be submitted.
+	OutcomeError Outcome = "error"
+)
+
+// MinCoverSize is the minimum width and height in pixels of the cover
+// allowed by DistroKid.
+const MinCoverSize = 1000
+
+// DefaultMaxSongs is the maximum number of songs of an album allowed by
+// DistroKid.
+const DefaultMaxSongs = 100
+
+// ErrNeedsManual is returned when the album must be reviewed manually.
+var ErrNeedsManual = errors.New("distrokid: album needs manual review")
+
+// knownModals are interstitial modals that may appear after submitting the
+// album. Each entry is the modal selector and the selector of the button that
+// dismisses it. Only modals identified by their id are listed, so that
+// unexpected dialogs (like errors) aren't dismissed silently.
+var knownModals = [][2]string{
+	{"#upsellModal", "#upsellModal .close"},
+	{"#leaveALegacyModal", "#leaveALegacyModal .close"},
+	{"#shazamModal", "#shazamModal .close"},
 }
 
 type Song struct {
 	Instrumental bool
 	Title        string
 	File         string
+	// Songwriter names of the song, the album first and last names are used
+	// if they are empty.
+	FirstName string
+	LastName  string
 }
 
 func (a *Album) Validate() error {
@@ -50,12 +103,22 @@ func (a *Album) Validate() error {
 	if len(a.Songs) == 0 {
 		return fmt.Errorf("distrokid: no songs")
 	}
+	maxSongs := a.MaxSongs
+	if maxSongs == 0 {
+		maxSongs = DefaultMaxSongs
+	}
+	if len(a.Songs) > maxSongs {
+		return fmt.Errorf("distrokid: album has %d songs, the maximum allowed is %d", len(a.Songs), maxSongs)
+	}
 	if a.Cover == "" {
 		return fmt.Errorf("distrokid: cover is empty")
 	}
 	if _, err := os.Stat(a.Cover); os.IsNotExist(err) {
 		return fmt.Errorf("distrokid: cover file doesn't exist: %s", a.Cover)
 	}
+	if err := image.ValidateCover(a.Cover, MinCoverSize); err != nil {
+		return fmt.Errorf("distrokid: invalid cover: %w", err)
+	}
 	for i, song := range a.Songs {
 		if song.Title == "" {
 			return fmt.Errorf("distrokid: song %d title is empty", i+1)
@@ -72,6 +135,8 @@ func (a *Album) Validate() error {
 
 // Publish publishes a new album
 func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (string, error) {
+	album.Outcome = OutcomeError
+
 	// Validate album
 	if err := album.Validate(); err != nil {
 		return "", err
@@ -281,10 +346,14 @@ func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (stri
 		}
 
 		// Set song writer
-		if err := setValue(ctx, fmt.Sprintf(`input[name=songwriter_real_name_first%d]`, n), album.FirstName); err != nil {
+		firstName, lastName := album.FirstName, album.LastName
+		if song.FirstName != "" && song.LastName != "" {
+			firstName, lastName = song.FirstName, song.LastName
+		}
+		if err := setValue(ctx, fmt.Sprintf(`input[name=songwriter_real_name_first%d]`, n), firstName); err != nil {
 			return "", err
 		}
-		if err := setValue(ctx, fmt.Sprintf(`input[name=songwriter_real_name_last%d]`, n), album.LastName); err != nil {
+		if err := setValue(ctx, fmt.Sprintf(`input[name=songwriter_real_name_last%d]`, n), lastName); err != nil {
 			return "", err
 		}
 		// Set song price
@@ -337,20 +406,23 @@ func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (stri
 		}
 	}
 
-	// Taking a screenshot.
+	// Taking a screenshot, quality 100 encodes it as png.
 	var buf []byte
-	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&buf, 90)); err != nil {
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&buf, 100)); err != nil {
 		return "", fmt.Errorf("distrokid: couldn't take screenshot: %w", err)
 	}
-	if _, err := os.Stat("logs"); os.IsNotExist(err) {
-		if err := os.Mkdir("logs", 0755); err != nil {
-			return "", fmt.Errorf("distrokid: couldn't create logs folder: %w", err)
-		}
+	if err := os.MkdirAll(c.screenshotDir, 0755); err != nil {
+		return "", fmt.Errorf("distrokid: couldn't create screenshot folder: %w", err)
 	}
-	out := fmt.Sprintf("logs/%s_%s.png", time.Now().Format("20060102150405"), albumUUID)
+	name := fmt.Sprintf("%s_%s.png", time.Now().Format("20060102150405"), albumUUID)
+	if album.ID != "" {
+		name = fmt.Sprintf("%s_%s_%s.png", time.Now().Format("20060102150405"), album.ID, albumUUID)
+	}
+	out := filepath.Join(c.screenshotDir, name)
 	if err := os.WriteFile(out, buf, 0644); err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("distrokid: couldn't write screenshot: %w", err)
 	}
+	album.Screenshot = out
 
 	if auto {
 		// Click on the submit button
@@ -360,6 +432,9 @@ func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (stri
 
 		// This will take more than 1 second, but the click will wait for it
 		time.Sleep(1 * time.Second)
+		if err := dismissModals(ctx); err != nil {
+			return "", err
+		}
 
 		// Click on the no mastering button
 		if err := click(ctx, "#noButton.masterMyAlbum"); err != nil {
@@ -368,17 +443,97 @@ func (c *Browser) Publish(parent context.Context, album *Album, auto bool) (stri
 	}
 
 	// Wait for the final page with the preview link
-	if err := chromedp.Run(ctx,
-		chromedp.WaitVisible("#pre-save-page,.share-hf-link", chromedp.ByQuery),
-	); err != nil {
-		return "", fmt.Errorf("distrokid: couldn't wait for preview link: %w", err)
+	waitCtx := ctx
+	if auto {
+		var waitCancel context.CancelFunc
+		waitCtx, waitCancel = context.WithTimeout(ctx, c.submitTimeout)
+		defer waitCancel()
 	}
+	if err := waitPreview(waitCtx); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("distrokid: couldn't wait for preview link: %w", err)
+		}
+		album.Outcome = OutcomeNeedsManual
+		return albumUUID, fmt.Errorf("%w: couldn't wait for preview link: %v", ErrNeedsManual, err)
+	}
+	album.Outcome = OutcomeSuccess
 
 	// Wait a bit more before closing
-	time.Sleep(1 * time.Second)
+	time.Sleep(c.waitBeforeClose)
 	return albumUUID, nil
 }
 
+// waitPreview waits for the preview link while dismissing any known modal
+// that shows up.
+func waitPreview(ctx context.Context) error {
+	for {
+		if err := dismissModals(ctx); err != nil {
+			return err
+		}
+		ok, err := isVisible(ctx, "#pre-save-page,.share-hf-link")
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+// dismissModals closes the known interstitial modals that are visible.
+func dismissModals(ctx context.Context) error {
+	for _, m := range knownModals {
+		ok, err := isVisible(ctx, m[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		var text string
+		if err := chromedp.Run(ctx,
+			chromedp.Evaluate(fmt.Sprintf(`document.querySelector('%s').innerText`, m[0]), &text),
+		); err != nil {
+			return fmt.Errorf("distrokid: couldn't get text of modal %s: %w", m[0], err)
+		}
+		log.Printf("distrokid: dismissing modal %s: %q\n", m[0], summary(text))
+		if err := click(ctx, m[1]); err != nil {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// summary returns the text collapsed to a single line and truncated to be
+// logged.
+func summary(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > 200 {
+		text = string(r[:200]) + "..."
+	}
+	return text
+}
+
+func isVisible(ctx context.Context, sel string) (bool, error) {
+	var visible bool
+	script := fmt.Sprintf(`(function() {
+		var e = document.querySelector('%s');
+		return e !== null && e.checkVisibility();
+	})()`, sel)
+	if err := chromedp.Run(ctx,
+		chromedp.Evaluate(script, &visible),
+	); err != nil {
+		return false, fmt.Errorf("distrokid: couldn't check visibility of %s: %w", sel, err)
+	}
+	return visible, nil
+}
+
 func getHTML(ctx context.Context, sel string) (*goquery.Document, error) {
 	// Obtain the document
 	var html string
@@ -0,0 +1,52 @@
+package errstreak
+
+import (
+	"fmt"
+	"log"
+)
+
+// DefaultMax is the number of consecutive errors tolerated if none is set.
+const DefaultMax = 10
+
+// Streak counts the consecutive errors of a command, logging the streak so
+// instability can be noticed before the command aborts.
+type Streak struct {
+	name string
+	max  int
+	n    int
+}
+
+// New creates a streak for the named command that fails when there are more
+// than max consecutive errors. If max is zero, DefaultMax is used.
+func New(name string, max int) *Streak {
+	if max <= 0 {
+		max = DefaultMax
+	}
+	return &Streak{name: name, max: max}
+}
+
+// Add records the result of an iteration and returns an error once the
+// maximum number of consecutive errors is exceeded. A nil error resets the
+// streak.
+func (s *Streak) Add(err error) error {
+	if err == nil {
+		if s.n > 1 {
+			log.Printf("%s: error streak ended after %d consecutive errors\n", s.name, s.n)
+		}
+		s.n = 0
+		return nil
+	}
+	s.n++
+	if s.n > s.max {
+		return fmt.Errorf("%s: too many consecutive errors: %w", s.name, err)
+	}
+	if s.n > 1 {
+		log.Printf("%s: %d consecutive errors (max %d)\n", s.name, s.n, s.max)
+	}
+	return nil
+}
+
+// Count returns the number of consecutive errors.
+func (s *Streak) Count() int {
+	return s.n
+}
@@ -0,0 +1,35 @@
+package errstreak
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStreak(t *testing.T) {
+	fail := errors.New("fail")
+
+	s := New("test", 2)
+	for i, err := range []error{fail, fail, nil, fail, fail} {
+		if got := s.Add(err); got != nil {
+			t.Fatalf("%d: unexpected error %v", i, got)
+		}
+	}
+	if s.Count() != 2 {
+		t.Fatalf("got %d consecutive errors, want 2", s.Count())
+	}
+	err := s.Add(fail)
+	if !errors.Is(err, fail) {
+		t.Fatalf("got %v, want %v", err, fail)
+	}
+
+	// Zero uses the default
+	s = New("test", 0)
+	for i := 0; i < DefaultMax; i++ {
+		if err := s.Add(fail); err != nil {
+			t.Fatalf("%d: unexpected error %v", i, err)
+		}
+	}
+	if err := s.Add(fail); err == nil {
+		t.Fatal("expected error after default max")
+	}
+}
@@ -1,6 +1,7 @@
 package fhttp
 
 import (
+	"sync"
 	"time"
 
 	http "github.com/bogdanfinn/fhttp"
@@ -16,6 +17,22 @@ type Client interface {
 
 type client struct {
 	tlsclient.HttpClient
+	// lck guards the transport, which is replaced when the proxy changes
+	lck sync.RWMutex
+}
+
+func (c *client) Do(req *http.Request) (*http.Response, error) {
+	c.lck.RLock()
+	defer c.lck.RUnlock()
+	return c.HttpClient.Do(req)
+}
+
+// SetProxy changes the proxy of the client, waiting for the requests in
+// progress to be sent.
+func (c *client) SetProxy(proxy string) error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	return c.HttpClient.SetProxy(proxy)
 }
 
 func NewClient(timeout time.Duration, useJar bool, proxy string) Client {
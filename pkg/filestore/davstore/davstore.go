@@ -0,0 +1,230 @@
+package davstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+// New returns a new WebDAV file store, e.g. a Nextcloud folder.
+// The base URL is the collection where the files are stored and it is
+// created if it doesn't exist.
+func New(baseURL, user, pass, proxy string, debug bool) (*Store, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("davstore: invalid url %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("davstore: invalid url scheme %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	client := &http.Client{
+		Timeout: 10 * time.Minute,
+	}
+	if proxy != "" {
+		p, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("davstore: invalid proxy %s: %w", proxy, err)
+		}
+		client.Transport = &http.Transport{
+			Proxy: http.ProxyURL(p),
+		}
+	}
+	return &Store{
+		client: client,
+		base:   u,
+		user:   user,
+		pass:   pass,
+		debug:  debug,
+	}, nil
+}
+
+type Store struct {
+	client *http.Client
+	base   *url.URL
+	user   string
+	pass   string
+	debug  bool
+}
+
+func (s *Store) Upload(ctx context.Context, path, name string) error {
+	target := s.path(name)
+	status, err := s.put(ctx, path, target)
+	if err != nil {
+		return err
+	}
+	// The parent collection doesn't exist, create it and try again
+	if status == http.StatusConflict || status == http.StatusNotFound {
+		if err := s.mkcol(ctx, pathpkg.Dir(target)); err != nil {
+			return err
+		}
+		status, err = s.put(ctx, path, target)
+		if err != nil {
+			return err
+		}
+	}
+	if !isSuccess(status) {
+		return fmt.Errorf("davstore: couldn't put %s: status %d", name, status)
+	}
+	if s.debug {
+		log.Println("davstore: uploaded", target)
+	}
+	return nil
+}
+
+func (s *Store) Download(ctx context.Context, path, name string) error {
+	resp, err := s.do(ctx, http.MethodGet, s.path(name), nil, 0)
+	if err != nil {
+		return fmt.Errorf("davstore: couldn't get %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("davstore: couldn't get %s: status %d", name, resp.StatusCode)
+	}
+
+	// Stream the file to a temporary file to avoid leaving partial files
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("davstore: couldn't create file %s: %w", tmp, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("davstore: couldn't download %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("davstore: couldn't close file %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("davstore: couldn't rename file %s: %w", tmp, err)
+	}
+	return nil
+}
+
+// put uploads the file to the target path and returns the response status
+// code.
+func (s *Store) put(ctx context.Context, path, target string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("davstore: couldn't open file %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("davstore: couldn't stat file %s: %w", path, err)
+	}
+	resp, err := s.do(ctx, http.MethodPut, target, f, info.Size())
+	if err != nil {
+		return 0, fmt.Errorf("davstore: couldn't put %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// mkcol creates the collection, creating its parents first if they don't
+// exist.
+func (s *Store) mkcol(ctx context.Context, dir string) error {
+	if dir == "/" || dir == "." {
+		return errors.New("davstore: couldn't create root collection")
+	}
+	status, err := s.mkcolOnce(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusConflict {
+		// The parent collection doesn't exist
+		if err := s.mkcol(ctx, pathpkg.Dir(dir)); err != nil {
+			return err
+		}
+		status, err = s.mkcolOnce(ctx, dir)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case isSuccess(status):
+		if s.debug {
+			log.Println("davstore: created collection", dir)
+		}
+		return nil
+	case status == http.StatusMethodNotAllowed:
+		// The collection already exists
+		return nil
+	default:
+		return fmt.Errorf("davstore: couldn't create collection %s: status %d", dir, status)
+	}
+}
+
+func (s *Store) mkcolOnce(ctx context.Context, dir string) (int, error) {
+	resp, err := s.do(ctx, "MKCOL", dir, nil, 0)
+	if err != nil {
+		return 0, fmt.Errorf("davstore: couldn't create collection %s: %w", dir, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// Check checks that the base collection is reachable.
+func (s *Store) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", s.base.String(), nil)
+	if err != nil {
+		return fmt.Errorf("davstore: couldn't create request: %w", err)
+	}
+	req.Header.Set("Depth", "0")
+	if s.user != "" || s.pass != "" {
+		req.SetBasicAuth(s.user, s.pass)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("davstore: couldn't stat %s: %w", s.base.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if !isSuccess(resp.StatusCode) {
+		return fmt.Errorf("davstore: couldn't stat %s: status %d", s.base.Redacted(), resp.StatusCode)
+	}
+	return nil
+}
+
+// do sends a request to the absolute path of the server.
+func (s *Store) do(ctx context.Context, method, path string, body io.Reader, size int64) (*http.Response, error) {
+	u := *s.base
+	var segments []string
+	for _, v := range strings.Split(path, "/") {
+		segments = append(segments, url.PathEscape(v))
+	}
+	u.Path = path
+	u.RawPath = strings.Join(segments, "/")
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if s.user != "" || s.pass != "" {
+		req.SetBasicAuth(s.user, s.pass)
+	}
+	return s.client.Do(req)
+}
+
+// path returns the absolute path of the name in the server.
+func (s *Store) path(name string) string {
+	return pathpkg.Join("/", s.base.Path, name)
+}
+
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
@@ -0,0 +1,82 @@
+package davstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func TestUploadDownload(t *testing.T) {
+	dav := &webdav.Handler{
+		Prefix:     "/remote.php/dav/files/user",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	// The musikai collection doesn't exist yet, it must be created
+	s, err := New(srv.URL+"/remote.php/dav/files/user/musikai/", "user", "pass", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("song data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"song.mp3", "masters/a b.flac", "song.mp3"} {
+		if err := s.Upload(ctx, src, name); err != nil {
+			t.Fatalf("upload %s: %v", name, err)
+		}
+		dst := filepath.Join(dir, "dst")
+		if err := s.Download(ctx, dst, name); err != nil {
+			t.Fatalf("download %s: %v", name, err)
+		}
+		data, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "song data" {
+			t.Errorf("expected %q, got %q", "song data", string(data))
+		}
+	}
+	if _, err := dav.FileSystem.Stat(ctx, "/musikai/masters/a b.flac"); err != nil {
+		t.Errorf("expected file in the server: %v", err)
+	}
+
+	if err := s.Download(ctx, filepath.Join(dir, "missing.mp3"), "missing.mp3"); err == nil {
+		t.Error("expected error downloading missing file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.mp3.tmp")); !os.IsNotExist(err) {
+		t.Error("expected no partial file")
+	}
+
+	if err := s.Check(ctx); err != nil {
+		t.Errorf("check: %v", err)
+	}
+
+	bad, err := New(srv.URL+"/remote.php/dav/files/user/musikai", "user", "wrong", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bad.Upload(ctx, src, "song.mp3"); err == nil {
+		t.Error("expected error with wrong credentials")
+	}
+	if err := bad.Check(ctx); err == nil {
+		t.Error("expected check error with wrong credentials")
+	}
+}
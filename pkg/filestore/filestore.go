@@ -3,9 +3,13 @@ package filestore
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/igolaizola/musikai/pkg/filestore/davstore"
+	"github.com/igolaizola/musikai/pkg/filestore/gcsstore"
 	"github.com/igolaizola/musikai/pkg/filestore/local"
 	"github.com/igolaizola/musikai/pkg/filestore/s3"
 	"github.com/igolaizola/musikai/pkg/filestore/tgstore"
@@ -17,28 +21,78 @@ type fs interface {
 	Download(ctx context.Context, path, name string) error
 }
 
+// checker is implemented by the file storages that can check whether they
+// are reachable.
+type checker interface {
+	Check(ctx context.Context) error
+}
+
 type Store struct {
 	fs fs
+	// uploadLock serializes the uploads of file storages that don't support
+	// concurrent uploads
+	uploadLock *sync.Mutex
+}
+
+func (s *Store) upload(ctx context.Context, path, name string) error {
+	if s.uploadLock != nil {
+		s.uploadLock.Lock()
+		defer s.uploadLock.Unlock()
+		if ctx.Err() != nil {
+			return fmt.Errorf("filestore: %w", ctx.Err())
+		}
+	}
+	return s.fs.Upload(ctx, path, name)
+}
+
+// Check checks that the file storage is reachable. File storages that can't
+// be checked are assumed to be reachable.
+func (s *Store) Check(ctx context.Context) error {
+	c, ok := s.fs.(checker)
+	if !ok {
+		return nil
+	}
+	if err := c.Check(ctx); err != nil {
+		return fmt.Errorf("filestore: %w", err)
+	}
+	return nil
 }
 
 func (s *Store) SetMP3(ctx context.Context, path, id string) error {
-	return s.fs.Upload(ctx, path, MP3(id))
+	return s.upload(ctx, path, MP3(id))
+}
+
+func (s *Store) SetAudio(ctx context.Context, path, id, ext string) error {
+	return s.upload(ctx, path, Audio(id, ext))
 }
 
 func (s *Store) SetJPG(ctx context.Context, path, id string) error {
-	return s.fs.Upload(ctx, path, JPG(id))
+	return s.upload(ctx, path, JPG(id))
+}
+
+func (s *Store) SetPNG(ctx context.Context, path, id string) error {
+	return s.upload(ctx, path, PNG(id))
 }
 
 func (s *Store) GetMP3(ctx context.Context, path, id string) error {
 	return s.fs.Download(ctx, path, MP3(id))
 }
 
+func (s *Store) GetAudio(ctx context.Context, path, id, ext string) error {
+	return s.fs.Download(ctx, path, Audio(id, ext))
+}
+
 func (s *Store) GetJPG(ctx context.Context, path, id string) error {
 	return s.fs.Download(ctx, path, JPG(id))
 }
 
+func (s *Store) GetPNG(ctx context.Context, path, id string) error {
+	return s.fs.Download(ctx, path, PNG(id))
+}
+
 func New(typ, conn, proxy string, debug bool, store *storage.Store) (*Store, error) {
 	var fs fs
+	var uploadLock *sync.Mutex
 	switch typ {
 	case "telegram":
 		split := strings.Split(conn, "@")
@@ -46,15 +100,40 @@ func New(typ, conn, proxy string, debug bool, store *storage.Store) (*Store, err
 			return nil, fmt.Errorf("filestore: invalid telegram connection string %q", conn)
 		}
 		token := split[0]
-		chat, err := strconv.ParseInt(split[1], 10, 64)
+
+		// Parse optional chunk parameters (chat_id?chunk-size=19&parallelism=2)
+		chatID, rawQuery, _ := strings.Cut(split[1], "?")
+		query, err := url.ParseQuery(rawQuery)
 		if err != nil {
-			return nil, fmt.Errorf("filestore: invalid telegram chat id %q: %w", split[1], err)
+			return nil, fmt.Errorf("filestore: invalid telegram parameters %q: %w", rawQuery, err)
+		}
+		var chunkSize int64
+		if v := query.Get("chunk-size"); v != "" {
+			mb, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("filestore: invalid telegram chunk size %q: %w", v, err)
+			}
+			chunkSize = mb * 1024 * 1024
 		}
-		candidate, err := tgstore.New(token, chat, proxy, debug, store)
+		var parallelism int
+		if v := query.Get("parallelism"); v != "" {
+			parallelism, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("filestore: invalid telegram parallelism %q: %w", v, err)
+			}
+		}
+
+		chat, err := strconv.ParseInt(chatID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filestore: invalid telegram chat id %q: %w", chatID, err)
+		}
+		candidate, err := tgstore.New(token, chat, proxy, debug, store, chunkSize, parallelism)
 		if err != nil {
 			return nil, fmt.Errorf("filestore: %w", err)
 		}
 		fs = candidate
+		// Telegram uploads are serialized to avoid hitting rate limits
+		uploadLock = &sync.Mutex{}
 	case "s3":
 		split := strings.Split(conn, "@")
 		if len(split) != 2 {
@@ -77,18 +156,61 @@ func New(typ, conn, proxy string, debug bool, store *storage.Store) (*Store, err
 			return nil, fmt.Errorf("filestore: %w", err)
 		}
 		fs = candidate
+	case "gcs":
+		split := strings.Split(conn, "@")
+		if len(split) != 2 {
+			return nil, fmt.Errorf("filestore: invalid gcs connection string %q", conn)
+		}
+		candidate, err := gcsstore.New(context.Background(), split[0], split[1], debug)
+		if err != nil {
+			return nil, fmt.Errorf("filestore: %w", err)
+		}
+		fs = candidate
+	case "webdav":
+		// The user and password are separated from the url by the last @
+		// before the scheme, so passwords may contain @
+		scheme := strings.Index(conn, "://")
+		if scheme < 0 {
+			return nil, fmt.Errorf("filestore: invalid webdav connection string %q", conn)
+		}
+		at := strings.LastIndex(conn[:scheme], "@")
+		if at < 0 {
+			return nil, fmt.Errorf("filestore: invalid webdav connection string %q", conn)
+		}
+		user, pass, ok := strings.Cut(conn[:at], ":")
+		if !ok {
+			return nil, fmt.Errorf("filestore: invalid webdav auth string %q", conn)
+		}
+		candidate, err := davstore.New(conn[at+1:], user, pass, proxy, debug)
+		if err != nil {
+			return nil, fmt.Errorf("filestore: %w", err)
+		}
+		fs = candidate
 	case "local":
 		fs = local.New(conn, debug)
 	default:
 		return nil, fmt.Errorf("filestore: unknown file storage type %q", typ)
 	}
-	return &Store{fs: fs}, nil
+	return &Store{fs: fs, uploadLock: uploadLock}, nil
 }
 
 func JPG(id string) string {
 	return id + ".jpg"
 }
 
+func PNG(id string) string {
+	return id + ".png"
+}
+
 func MP3(id string) string {
-	return id + ".mp3"
+	return Audio(id, "mp3")
+}
+
+// Audio returns the file name of an audio file with the given extension.
+// The mp3 extension is used if it is empty.
+func Audio(id, ext string) string {
+	if ext == "" {
+		ext = "mp3"
+	}
+	return id + "." + ext
 }
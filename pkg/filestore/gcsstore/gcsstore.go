@@ -0,0 +1,122 @@
+package gcsstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	gcs "google.golang.org/api/storage/v1"
+)
+
+// New returns a new Google Cloud Storage file store.
+// If keyFile is empty, application default credentials are used.
+func New(ctx context.Context, keyFile, bucket string, debug bool, opts ...option.ClientOption) (*Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("gcs: bucket is empty")
+	}
+	if keyFile != "" {
+		opts = append(opts, option.WithCredentialsFile(keyFile))
+	}
+	svc, err := gcs.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("gcs: couldn't create service: %w", err)
+	}
+	return &Store{
+		service: svc,
+		bucket:  bucket,
+		debug:   debug,
+	}, nil
+}
+
+type Store struct {
+	service *gcs.Service
+	bucket  string
+	debug   bool
+}
+
+// Check checks that the bucket is reachable.
+func (s *Store) Check(ctx context.Context) error {
+	if _, err := s.service.Buckets.Get(s.bucket).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("gcs: couldn't get bucket %s: %w", s.bucket, err)
+	}
+	return nil
+}
+
+func (s *Store) Upload(ctx context.Context, path, name string) error {
+	contentType, err := contentType(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("gcs: couldn't open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	obj := &gcs.Object{
+		Name:        name,
+		ContentType: contentType,
+	}
+	out, err := s.service.Objects.Insert(s.bucket, obj).
+		Media(f, googleapi.ContentType(contentType)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return fmt.Errorf("gcs: couldn't insert object %s: %w", name, err)
+	}
+	if s.debug {
+		log.Println("gcs: inserted object", out.Name, out.Size)
+	}
+	return nil
+}
+
+func (s *Store) Download(ctx context.Context, path, name string) error {
+	resp, err := s.service.Objects.Get(s.bucket, name).Context(ctx).Download()
+	if err != nil {
+		return fmt.Errorf("gcs: couldn't get object %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	// Stream the object to a temporary file to avoid leaving partial files
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("gcs: couldn't create file %s: %w", tmp, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("gcs: couldn't download object %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("gcs: couldn't close file %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("gcs: couldn't rename file %s: %w", tmp, err)
+	}
+	return nil
+}
+
+func contentType(name string) (string, error) {
+	ext := filepath.Ext(name)
+	switch ext {
+	case ".jpg", ".jpeg":
+		return "image/jpeg", nil
+	case ".png":
+		return "image/png", nil
+	case ".mp3":
+		return "audio/mpeg", nil
+	case ".wav":
+		return "audio/wav", nil
+	case ".flac":
+		return "audio/flac", nil
+	default:
+		return "", fmt.Errorf("gcs: unknown content type for extension %s", ext)
+	}
+}
@@ -0,0 +1,126 @@
+package gcsstore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+// fakeServer implements the subset of the GCS JSON API used by the store.
+type fakeServer struct {
+	sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		// First part is the metadata, second part is the media
+		meta, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(meta).Decode(&obj); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := obj.Name
+		f.objects[name] = data
+		f.contentTypes[name] = part.Header.Get("Content-Type")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"` + name + `"}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+		data, ok := f.objects[name]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	fake := &fakeServer{
+		objects:      map[string][]byte{},
+		contentTypes: map[string]string{},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := New(ctx, "", "bucket", false,
+		option.WithEndpoint(srv.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("song data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upload(ctx, src, "song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.contentTypes["song.mp3"]; got != "audio/mpeg" {
+		t.Errorf("expected content type audio/mpeg, got %q", got)
+	}
+
+	dst := filepath.Join(dir, "dst.mp3")
+	if err := s.Download(ctx, dst, "song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "song data" {
+		t.Errorf("expected %q, got %q", "song data", string(data))
+	}
+
+	if err := s.Download(ctx, filepath.Join(dir, "missing.mp3"), "missing.mp3"); err == nil {
+		t.Error("expected error downloading missing object")
+	}
+	if err := s.Upload(ctx, src, "file.txt"); err == nil {
+		t.Error("expected error uploading unknown extension")
+	}
+}
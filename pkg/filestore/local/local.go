@@ -32,6 +32,18 @@ func (s *store) Download(ctx context.Context, path, name string) error {
 	return nil
 }
 
+// Check checks that the root directory exists.
+func (s *store) Check(ctx context.Context) error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return fmt.Errorf("local: couldn't stat %q: %w", s.root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("local: %q isn't a directory", s.root)
+	}
+	return nil
+}
+
 func copyFile(src, dst string) error {
 	// Open the source file for reading
 	srcFile, err := os.Open(src)
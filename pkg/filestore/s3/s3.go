@@ -102,6 +102,17 @@ func (s *Store) start(ctx context.Context) error {
 	return nil
 }
 
+// Check checks that the bucket is reachable.
+func (s *Store) Check(ctx context.Context) error {
+	input := &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	}
+	if _, err := s.client.HeadBucket(ctx, input); err != nil {
+		return fmt.Errorf("s3: couldn't head bucket %s: %w", s.bucket, err)
+	}
+	return nil
+}
+
 func (s *Store) URL(ctx context.Context, name string) (string, error) {
 	client := s3.NewPresignClient(s.client)
 	input := &s3.GetObjectInput{
@@ -127,6 +138,8 @@ func (s *Store) Upload(ctx context.Context, path, name string) error {
 		contentType = "audio/mpeg"
 	case ".wav":
 		contentType = "audio/wav"
+	case ".flac":
+		contentType = "audio/flac"
 	default:
 		return fmt.Errorf("s3: unknown content type for extension %s", ext)
 	}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbot "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -18,15 +19,28 @@ import (
 )
 
 type Store struct {
-	bot    *tgbot.BotAPI
-	token  string
-	chat   int64
-	client *http.Client
-	debug  bool
-	store  *storage.Store
+	bot         *tgbot.BotAPI
+	token       string
+	chat        int64
+	client      *http.Client
+	debug       bool
+	store       *storage.Store
+	chunkSize   int64
+	parallelism int
 }
 
-func New(token string, chat int64, proxy string, debug bool, store *storage.Store) (*Store, error) {
+// Telegram bots can only download files up to 20 MB, so bigger files are
+// uploaded in chunks.
+const (
+	DefaultChunkSize   = 19 * 1024 * 1024
+	DefaultParallelism = 2
+
+	chunkedPrefix = "chunks:"
+)
+
+// New creates a telegram file store. Files bigger than chunkSize are split in
+// chunks that are uploaded and downloaded with the given parallelism.
+func New(token string, chat int64, proxy string, debug bool, store *storage.Store, chunkSize int64, parallelism int) (*Store, error) {
 	bot, err := tgbot.NewBotAPI(token)
 	if err != nil {
 		return nil, err
@@ -49,13 +63,21 @@ func New(token string, chat int64, proxy string, debug bool, store *storage.Stor
 			Proxy: http.ProxyURL(u),
 		}
 	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
 	return &Store{
-		bot:    bot,
-		token:  token,
-		chat:   chat,
-		client: client,
-		debug:  debug,
-		store:  store,
+		bot:         bot,
+		token:       token,
+		chat:        chat,
+		client:      client,
+		debug:       debug,
+		store:       store,
+		chunkSize:   chunkSize,
+		parallelism: parallelism,
 	}, nil
 }
 
@@ -64,7 +86,64 @@ func (s *Store) Start(ctx context.Context) error {
 }
 
 func (s *Store) Upload(ctx context.Context, path, name string) error {
-	doc := tgbot.NewDocumentUpload(s.chat, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("tgstore: couldn't stat %s: %w", path, err)
+	}
+
+	// Upload small files in a single message
+	if info.Size() <= s.chunkSize {
+		ref, err := s.send(ctx, path)
+		if err != nil {
+			return err
+		}
+		if err := s.store.SetFileRef(ctx, name, ref); err != nil {
+			return fmt.Errorf("tgstore: couldn't set file %s: %w", name, err)
+		}
+		return nil
+	}
+
+	// Upload big files in chunks
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("tgstore: couldn't open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	n := int((info.Size() + s.chunkSize - 1) / s.chunkSize)
+	refs := make([]string, n)
+	err = s.parallel(ctx, n, func(i int) error {
+		size := s.chunkSize
+		if rest := info.Size() - int64(i)*s.chunkSize; rest < size {
+			size = rest
+		}
+		b := make([]byte, size)
+		if _, err := f.ReadAt(b, int64(i)*s.chunkSize); err != nil {
+			return fmt.Errorf("tgstore: couldn't read chunk %d of %s: %w", i, path, err)
+		}
+		ref, err := s.send(ctx, tgbot.FileBytes{
+			Name:  chunkName(name, i),
+			Bytes: b,
+		})
+		if err != nil {
+			return fmt.Errorf("tgstore: couldn't send chunk %d of %s: %w", i, name, err)
+		}
+		refs[i] = ref
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ref := chunkedPrefix + strings.Join(refs, ",")
+	if err := s.store.SetFileRef(ctx, name, ref); err != nil {
+		return fmt.Errorf("tgstore: couldn't set file %s: %w", name, err)
+	}
+	return nil
+}
+
+// send uploads a file as a document, retrying on errors, and returns its ref.
+func (s *Store) send(ctx context.Context, file interface{}) (string, error) {
+	doc := tgbot.NewDocumentUpload(s.chat, file)
 
 	// Upload file
 	maxAttempts := 3
@@ -80,7 +159,7 @@ func (s *Store) Upload(ctx context.Context, path, name string) error {
 		// Increase attempts and check if we should stop
 		attempts++
 		if attempts >= maxAttempts {
-			return fmt.Errorf("tgstore: couldn't send file: %w", err)
+			return "", fmt.Errorf("tgstore: couldn't send file: %w", err)
 		}
 		idx := attempts - 1
 		if idx >= len(backoff) {
@@ -93,7 +172,7 @@ func (s *Store) Upload(ctx context.Context, path, name string) error {
 		}
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("tgstore: send file cancelled: %w", ctx.Err())
+			return "", fmt.Errorf("tgstore: send file cancelled: %w", ctx.Err())
 		case <-t.C:
 		}
 	}
@@ -112,16 +191,15 @@ func (s *Store) Upload(ctx context.Context, path, name string) error {
 	}
 	if fileID == "" {
 		js, _ := json.Marshal(msg)
-		return fmt.Errorf("tgstore: message doesn't contain file: %s", string(js))
+		return "", fmt.Errorf("tgstore: message doesn't contain file: %s", string(js))
 	}
-	ref := toRef(s.chat, msg.MessageID, fileID)
-	if err := s.store.SetFileRef(ctx, name, ref); err != nil {
-		return fmt.Errorf("tgstore: couldn't set file %s: %w", name, err)
-	}
-	return nil
+	return toRef(s.chat, msg.MessageID, fileID), nil
 }
 
 func (s *Store) Get(ctx context.Context, ref string) (string, error) {
+	if strings.HasPrefix(ref, chunkedPrefix) {
+		return "", fmt.Errorf("tgstore: chunked file %s has no single url", ref)
+	}
 	_, _, fileID, err := fromRef(ref)
 	if err != nil {
 		return "", err
@@ -138,16 +216,18 @@ func (s *Store) Get(ctx context.Context, ref string) (string, error) {
 }
 
 func (s *Store) Delete(ctx context.Context, ref string) error {
-	chat, msgID, _, err := fromRef(ref)
-	if err != nil {
-		return err
-	}
-	deleteConfig := tgbot.DeleteMessageConfig{
-		ChatID:    chat,
-		MessageID: msgID,
-	}
-	if _, err = s.bot.DeleteMessage(deleteConfig); err != nil {
-		return fmt.Errorf("tgstore: couldn't delete message: %w", err)
+	for _, r := range splitRef(ref) {
+		chat, msgID, _, err := fromRef(r)
+		if err != nil {
+			return err
+		}
+		deleteConfig := tgbot.DeleteMessageConfig{
+			ChatID:    chat,
+			MessageID: msgID,
+		}
+		if _, err = s.bot.DeleteMessage(deleteConfig); err != nil {
+			return fmt.Errorf("tgstore: couldn't delete message: %w", err)
+		}
 	}
 	return nil
 }
@@ -164,25 +244,87 @@ func (s *Store) Download(ctx context.Context, path, name string) error {
 		return fmt.Errorf("tgstore: couldn't get file %s: %w", name, err)
 	}
 
-	u, err := s.Get(ctx, ref)
+	if !strings.HasPrefix(ref, chunkedPrefix) {
+		b, err := s.downloadRef(ctx, ref)
+		if err != nil {
+			return err
+		}
+		// Write to output
+		if err := os.WriteFile(path, b, 0644); err != nil {
+			return fmt.Errorf("tgstore: couldn't write %s: %w", path, err)
+		}
+		return nil
+	}
+
+	// Download chunks to part files. Parts downloaded on previous attempts
+	// are kept so the download can be resumed.
+	refs := splitRef(ref)
+	err = s.parallel(ctx, len(refs), func(i int) error {
+		part := chunkName(path, i)
+		if _, err := os.Stat(part); err == nil {
+			return nil
+		}
+		b, err := s.downloadRef(ctx, refs[i])
+		if err != nil {
+			return err
+		}
+		tmp := part + ".tmp"
+		if err := os.WriteFile(tmp, b, 0644); err != nil {
+			return fmt.Errorf("tgstore: couldn't write %s: %w", tmp, err)
+		}
+		if err := os.Rename(tmp, part); err != nil {
+			return fmt.Errorf("tgstore: couldn't rename %s: %w", tmp, err)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
+	// Join the parts
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("tgstore: couldn't create %s: %w", path, err)
+	}
+	defer out.Close()
+	for i := range refs {
+		part := chunkName(path, i)
+		f, err := os.Open(part)
+		if err != nil {
+			return fmt.Errorf("tgstore: couldn't open %s: %w", part, err)
+		}
+		_, err = io.Copy(out, f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("tgstore: couldn't write %s: %w", path, err)
+		}
+	}
+	for i := range refs {
+		_ = os.Remove(chunkName(path, i))
+	}
+	return nil
+}
+
+// downloadRef downloads a single file, retrying on errors.
+func (s *Store) downloadRef(ctx context.Context, ref string) ([]byte, error) {
+	u, err := s.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
 	// Download file
 	maxAttempts := 3
 	attempts := 0
-	var b []byte
 	for {
-		b, err = s.download(ref, u)
+		b, err := s.download(ref, u)
 		if err == nil {
-			break
+			return b, nil
 		}
 
 		// Increase attempts and check if we should stop
 		attempts++
 		if attempts >= maxAttempts {
-			return err
+			return nil, err
 		}
 		idx := attempts - 1
 		if idx >= len(backoff) {
@@ -195,16 +337,10 @@ func (s *Store) Download(ctx context.Context, path, name string) error {
 		}
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
 		case <-t.C:
 		}
 	}
-
-	// Write to output
-	if err := os.WriteFile(path, b, 0644); err != nil {
-		return fmt.Errorf("tgstore: couldn't write %s: %w", path, err)
-	}
-	return nil
 }
 
 func (s *Store) download(ref, u string) ([]byte, error) {
@@ -220,6 +356,48 @@ func (s *Store) download(ref, u string) ([]byte, error) {
 	return b, nil
 }
 
+// parallel runs fn for each index using the store parallelism and returns
+// the first error.
+func (s *Store) parallel(ctx context.Context, n int, fn func(i int) error) error {
+	sem := make(chan struct{}, s.parallelism)
+	var wg sync.WaitGroup
+	var lck sync.Mutex
+	var firstErr error
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return fmt.Errorf("tgstore: %w", ctx.Err())
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(i); err != nil {
+				lck.Lock()
+				defer lck.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func chunkName(name string, i int) string {
+	return fmt.Sprintf("%s.part%03d", name, i)
+}
+
+func splitRef(ref string) []string {
+	if !strings.HasPrefix(ref, chunkedPrefix) {
+		return []string{ref}
+	}
+	return strings.Split(strings.TrimPrefix(ref, chunkedPrefix), ",")
+}
+
 func toRef(chat int64, msgID int, fileID string) string {
 	return fmt.Sprintf("%d/%d/%s", chat, msgID, fileID)
 }
@@ -6,6 +6,7 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"os"
 	"path/filepath"
 
 	"golang.org/x/image/webp"
@@ -48,3 +49,64 @@ func getEncoder(file string) (Encode, error) {
 	}
 	return encode, nil
 }
+
+type DecodeConfig func(io.Reader) (image.Config, error)
+
+func getConfigDecoder(file string) (DecodeConfig, error) {
+	inputExt := filepath.Ext(file)
+	var decode DecodeConfig
+	switch inputExt {
+	case ".png":
+		decode = png.DecodeConfig
+	case ".jpg", ".jpeg":
+		decode = jpeg.DecodeConfig
+	case ".webp":
+		decode = webp.DecodeConfig
+	default:
+		return nil, fmt.Errorf("image: unsupported extension: %s", inputExt)
+	}
+	return decode, nil
+}
+
+// Dimensions returns the width and height of the image without decoding the
+// whole file.
+func Dimensions(input string) (int, int, error) {
+	decode, err := getConfigDecoder(input)
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, err := decode(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("image: couldn't decode config: %w", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// ValidateCover checks that the cover is a square JPEG or PNG image of at
+// least minDim pixels per side. Only the header of the file is decoded.
+func ValidateCover(path string, minDim int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("image: couldn't open cover: %w", err)
+	}
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("image: couldn't decode cover %s: %w", path, err)
+	}
+	if format != "jpeg" && format != "png" {
+		return fmt.Errorf("image: cover format %s isn't supported, it must be jpeg or png", format)
+	}
+	if cfg.Width != cfg.Height {
+		return fmt.Errorf("image: cover isn't square (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if cfg.Width < minDim {
+		return fmt.Errorf("image: cover is %dx%d, the minimum is %dx%d", cfg.Width, cfg.Height, minDim, minDim)
+	}
+	return nil
+}
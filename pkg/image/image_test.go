@@ -2,7 +2,19 @@ package image
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
 )
 
 func TestAddText(t *testing.T) {
@@ -45,3 +57,201 @@ func TestAddOverlay(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePosition(t *testing.T) {
+	for p := range positionNames {
+		got, err := ParsePosition(p.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != p {
+			t.Errorf("got %s, want %s", got, p)
+		}
+	}
+	if _, err := ParsePosition("middle"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestFreePosition(t *testing.T) {
+	const size = 1000
+	logo := image.Rect(400, 400, 600, 600)
+	frame := image.Rect(0, 0, size, size)
+	tests := []struct {
+		name     string
+		want     Position
+		occupied []image.Rectangle
+		got      Position
+		ok       bool
+	}{
+		{"free", TopLeft, nil, TopLeft, true},
+		{"logo", Center, []image.Rectangle{logo}, TopCenter, true},
+		{"subtitle", BottomCenter, []image.Rectangle{logo, BottomLeft.Area(size, size)}, TopCenter, true},
+		{"top banner", TopCenter, []image.Rectangle{image.Rect(0, 0, size, 100)}, BottomCenter, true},
+		{"frame", TopRight, []image.Rectangle{frame}, TopRight, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FreePosition(tt.want, size, size, tt.occupied...)
+			if got != tt.got || ok != tt.ok {
+				t.Errorf("got %s %v, want %s %v", got, ok, tt.got, tt.ok)
+			}
+		})
+	}
+}
+
+func TestOverlayBounds(t *testing.T) {
+	overlay := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(overlay, image.Rect(10, 20, 30, 40), image.NewUniform(color.White), image.Point{}, draw.Src)
+	file := filepath.Join(t.TempDir(), "overlay.png")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, overlay); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	got, err := OverlayBounds(file, 200, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.Rect(60, 120, 80, 140)
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidateCover(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, w, h int) string {
+		file := filepath.Join(dir, name)
+		f, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	garbage := filepath.Join(dir, "garbage.jpg")
+	if err := os.WriteFile(garbage, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"valid", write("valid.png", 100, 100), false},
+		{"not square", write("wide.png", 200, 100), true},
+		{"too small", write("small.png", 50, 50), true},
+		{"not an image", garbage, true},
+		{"missing", filepath.Join(dir, "missing.jpg"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCover(tt.file, 100)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTextPixelsAverageColor(t *testing.T) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := noiseImage(800, 600)
+	tests := []struct {
+		label string
+		size  float64
+		x, y  int
+	}{
+		{"Dreams Collection: Vol 1", 32, 40, 80},
+		{"Ay", 120, 300, 200},
+		{"Clipped at the border", 64, 700, 590},
+		{"Outside", 24, -500, 100},
+	}
+	for _, tt := range tests {
+		face, err := newFace(f, tt.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := calculateTextPixelsAverageColor(img, tt.x, tt.y, tt.label, face)
+		want := fullTextPixelsAverageColor(img, tt.x, tt.y, tt.label, face)
+		if got != want {
+			t.Errorf("%q: got %v, want %v", tt.label, got, want)
+		}
+	}
+}
+
+func BenchmarkAddText(b *testing.B) {
+	dir := b.TempDir()
+	fnt := filepath.Join(dir, "font.ttf")
+	if err := os.WriteFile(fnt, goregular.TTF, 0644); err != nil {
+		b.Fatal(err)
+	}
+	faces, err := newFaceCache(fnt)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer faces.Close()
+	src := noiseImage(4096, 4096)
+	img := image.NewRGBA(src.Bounds())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		draw.Draw(img, img.Bounds(), src, image.Point{}, draw.Src)
+		if err := drawStringWithDynamicFontSize(img, "Dreams Collection\nVol 1", faces.get, BottomCenter, 12); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// fullTextPixelsAverageColor is the reference implementation that renders the
+// text mask over the whole image.
+func fullTextPixelsAverageColor(img image.Image, x, y int, label string, face font.Face) color.Color {
+	mask := image.NewAlpha(img.Bounds())
+	dr := &font.Drawer{
+		Dst:  mask,
+		Src:  image.NewUniform(color.Alpha{A: 255}),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(x << 6), Y: fixed.Int26_6(y << 6)},
+	}
+	dr.DrawString(label)
+	var rTotal, gTotal, bTotal, count uint32
+	for i := 0; i < mask.Bounds().Dx(); i++ {
+		for j := 0; j < mask.Bounds().Dy(); j++ {
+			if mask.AlphaAt(i, j).A > 0 {
+				r, g, b, _ := img.At(i, j).RGBA()
+				rTotal += r
+				gTotal += g
+				bTotal += b
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	return color.RGBA{
+		R: uint8(rTotal / count >> 8),
+		G: uint8(gTotal / count >> 8),
+		B: uint8(bTotal / count >> 8),
+		A: 255,
+	}
+}
+
+func noiseImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rnd := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+	}
+	return img
+}
@@ -69,3 +69,43 @@ func AddOverlay(overlay, input, output string) error {
 	// Encode the output image to the output file.
 	return encode(outputFile, outputImage)
 }
+
+// OverlayBounds returns the bounds of the visible pixels of the overlay once
+// it is centered over a width x height base image.
+// The rectangle is empty if the overlay is fully transparent.
+func OverlayBounds(overlay string, width, height int) (image.Rectangle, error) {
+	decode, err := getDecoder(overlay)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	f, err := os.Open(overlay)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer f.Close()
+	img, err := decode(f)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// Ignore almost transparent pixels such as soft shadows
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0x1000 {
+				continue
+			}
+			minX, minY = min(minX, x), min(minY, y)
+			maxX, maxY = max(maxX, x+1), max(maxY, y+1)
+		}
+	}
+	if minX >= maxX || minY >= maxY {
+		return image.Rectangle{}, nil
+	}
+	visible := image.Rect(minX, minY, maxX, maxY)
+
+	// Same offset used by AddOverlay
+	offset := image.Pt((width-b.Dx())/2-b.Min.X, (height-b.Dy())/2-b.Min.Y)
+	return visible.Add(offset).Intersect(image.Rect(0, 0, width, height)), nil
+}
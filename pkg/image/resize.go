@@ -0,0 +1,68 @@
+package image
+
+import (
+	"image"
+	"os"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize scales the input image to fit within the given width and height,
+// keeping the aspect ratio, and writes it to the output file.
+func Resize(width, height int, input, output string) error {
+	// Get encoder and decoder
+	decode, err := getDecoder(input)
+	if err != nil {
+		return err
+	}
+	encode, err := getEncoder(output)
+	if err != nil {
+		return err
+	}
+
+	// Open the input image file.
+	inputFile, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	// Decode the input image.
+	img, err := decode(inputFile)
+	if err != nil {
+		return err
+	}
+
+	// Calculate the output size keeping the aspect ratio.
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > width || h > height {
+		if w*height > h*width {
+			h = h * width / w
+			w = width
+		} else {
+			w = w * height / h
+			h = height
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	// Scale the image.
+	outputImage := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(outputImage, outputImage.Bounds(), img, bounds, draw.Over, nil)
+
+	// Create the output file.
+	outputFile, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	// Encode the output image to the output file.
+	return encode(outputFile, outputImage)
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"os"
 	"strings"
+	"sync"
 
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
@@ -41,9 +42,19 @@ func averageBackgroundColor(img image.Image, rect image.Rectangle) color.Color {
 */
 
 // calculateTextPixelsAverageColor calculates the average color of pixels that match with the text letters.
+// Only the area covered by the text is rendered and scanned, instead of the
+// whole image.
 func calculateTextPixelsAverageColor(img image.Image, x, y int, label string, face font.Face) color.Color {
+	// Obtain the area covered by the text, with a small padding in case
+	// antialiasing exceeds the bounds.
+	bounds, _ := font.BoundString(face, label)
+	rect := image.Rect(
+		x+bounds.Min.X.Floor()-2, y+bounds.Min.Y.Floor()-2,
+		x+bounds.Max.X.Ceil()+2, y+bounds.Max.Y.Ceil()+2,
+	).Intersect(img.Bounds())
+
 	// Create a mask image where the text will be drawn.
-	mask := image.NewAlpha(img.Bounds())
+	mask := image.NewAlpha(rect)
 
 	// Draw the text onto the mask.
 	dr := &font.Drawer{
@@ -59,8 +70,8 @@ func calculateTextPixelsAverageColor(img image.Image, x, y int, label string, fa
 
 	// Now calculate the average color of the pixels under the text in the original image.
 	var rTotal, gTotal, bTotal, count uint32
-	for i := 0; i < mask.Bounds().Dx(); i++ {
-		for j := 0; j < mask.Bounds().Dy(); j++ {
+	for j := rect.Min.Y; j < rect.Max.Y; j++ {
+		for i := rect.Min.X; i < rect.Max.X; i++ {
 			// Check if the pixel is part of the text.
 			if mask.AlphaAt(i, j).A > 0 {
 				r, g, b, _ := img.At(i, j).RGBA()
@@ -137,8 +148,15 @@ func pow(x, y float64) float64 {
 	return math.Pow(x, y)
 }
 
-// loadFont loads a TrueType font and returns a font.Face with the specified size.
-func loadFont(path string, size float64) (font.Face, error) {
+// fonts caches the parsed fonts by path.
+// Parsed fonts are safe for concurrent use, unlike font faces.
+var fonts sync.Map
+
+// parseFont parses a TrueType font or returns it from the cache.
+func parseFont(path string) (*opentype.Font, error) {
+	if f, ok := fonts.Load(path); ok {
+		return f.(*opentype.Font), nil
+	}
 	fontBytes, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -147,6 +165,20 @@ func loadFont(path string, size float64) (font.Face, error) {
 	if err != nil {
 		return nil, err
 	}
+	fonts.Store(path, fontType)
+	return fontType, nil
+}
+
+// loadFont loads a TrueType font and returns a font.Face with the specified size.
+func loadFont(path string, size float64) (font.Face, error) {
+	fontType, err := parseFont(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFace(fontType, size)
+}
+
+func newFace(fontType *opentype.Font, size float64) (font.Face, error) {
 	face, err := opentype.NewFace(fontType, &opentype.FaceOptions{
 		Size:    size,
 		DPI:     72,
@@ -158,6 +190,44 @@ func loadFont(path string, size float64) (font.Face, error) {
 	return face, nil
 }
 
+// faceCache returns the faces of a font by size, creating each size once.
+// Font faces aren't safe for concurrent use, so a cache must not be shared
+// between goroutines.
+type faceCache struct {
+	font  *opentype.Font
+	faces map[float64]font.Face
+}
+
+func newFaceCache(path string) (*faceCache, error) {
+	fontType, err := parseFont(path)
+	if err != nil {
+		return nil, err
+	}
+	return &faceCache{
+		font:  fontType,
+		faces: map[float64]font.Face{},
+	}, nil
+}
+
+func (c *faceCache) get(size float64) (font.Face, error) {
+	if face, ok := c.faces[size]; ok {
+		return face, nil
+	}
+	face, err := newFace(c.font, size)
+	if err != nil {
+		return nil, err
+	}
+	c.faces[size] = face
+	return face, nil
+}
+
+// Close closes all the faces of the cache.
+func (c *faceCache) Close() {
+	for _, face := range c.faces {
+		_ = face.Close()
+	}
+}
+
 // drawStringWithShadowAndContrast draws a string onto an image with a shadow for legibility and chooses a contrasting color based on the background.
 /*
 func drawStringWithShadowAndContrast(img draw.Image, label string, face font.Face, position Position) error {
@@ -376,6 +446,81 @@ const (
 	Center
 )
 
+var positionNames = map[Position]string{
+	TopLeft:      "top-left",
+	TopRight:     "top-right",
+	BottomLeft:   "bottom-left",
+	BottomRight:  "bottom-right",
+	TopCenter:    "top-center",
+	BottomCenter: "bottom-center",
+	Center:       "center",
+}
+
+func (p Position) String() string {
+	if name, ok := positionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("position(%d)", int(p))
+}
+
+// ParsePosition parses a position name like top-left or bottom-center.
+func ParsePosition(s string) (Position, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for p, v := range positionNames {
+		if v == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("image: invalid position %q", s)
+}
+
+// Area returns the area of a width x height image where text drawn at the
+// position may end up.
+// Text is at most half the image width and is placed with a 5% margin, so
+// each area is a quarter of the image height and half of its width.
+func (p Position) Area(width, height int) image.Rectangle {
+	var x, y int
+	switch p {
+	case TopLeft, BottomLeft:
+		x = 0
+	case TopRight, BottomRight:
+		x = width / 2
+	default:
+		x = width / 4
+	}
+	switch p {
+	case TopLeft, TopRight, TopCenter:
+		y = 0
+	case BottomLeft, BottomRight, BottomCenter:
+		y = height * 3 / 4
+	default:
+		y = height * 3 / 8
+	}
+	return image.Rect(x, y, x+width/2, y+height/4)
+}
+
+// FreePosition returns the first position, starting with the wanted one,
+// whose area doesn't overlap any of the occupied rectangles of a width x
+// height image.
+// If every position overlaps, the wanted one is returned along with false.
+func FreePosition(want Position, width, height int, occupied ...image.Rectangle) (Position, bool) {
+	candidates := []Position{want, TopCenter, TopLeft, TopRight, BottomCenter, BottomRight, BottomLeft, Center}
+	for _, p := range candidates {
+		area := p.Area(width, height)
+		free := true
+		for _, r := range occupied {
+			if area.Overlaps(r) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return p, true
+		}
+	}
+	return want, false
+}
+
 // AddText opens an image, adds text to it with shadow and contrast adjustment, and saves the result.
 func AddText(text string, position Position, fnt, input, output string) error {
 	// Get encoder and decoder
@@ -427,9 +572,12 @@ func AddText(text string, position Position, fnt, input, output string) error {
 	*/
 
 	// Draw the text with dynamic font size
-	if err := drawStringWithDynamicFontSize(rgba, text, func(size float64) (font.Face, error) {
-		return loadFont(fnt, size)
-	}, position, 12); err != nil {
+	faces, err := newFaceCache(fnt)
+	if err != nil {
+		return err
+	}
+	defer faces.Close()
+	if err := drawStringWithDynamicFontSize(rgba, text, faces.get, position, 12); err != nil {
 		return err
 	}
 
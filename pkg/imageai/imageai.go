@@ -6,10 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	fhttp "github.com/Danny-Dasilva/fhttp"
@@ -18,6 +22,7 @@ import (
 	"github.com/igolaizola/bulkai/pkg/ai/midjourney"
 	"github.com/igolaizola/bulkai/pkg/discord"
 	"github.com/igolaizola/bulkai/pkg/http"
+	"github.com/igolaizola/musikai/pkg/image"
 	"github.com/igolaizola/musikai/pkg/storage"
 )
 
@@ -29,6 +34,20 @@ type Config struct {
 	ReplicateToken string  `yaml:"replicate-token"`
 	SessionFile    string  `yaml:"session"`
 	Session        Session `yaml:"-"`
+
+	// Variants are the grid cells to upscale (0 top-left, 1 top-right,
+	// 2 bottom-left, 3 bottom-right). If empty, random cells are picked.
+	Variants []int `yaml:"variants"`
+	// Aspect is the expected aspect ratio of the upscaled cells (e.g. 1:1).
+	// Cells that don't match are discarded. If empty, no validation is done.
+	Aspect string `yaml:"aspect"`
+}
+
+// Image is an upscaled cell of a generated grid.
+type Image struct {
+	Variant int
+	DsURL   string
+	MjURL   string
 }
 
 type Session struct {
@@ -49,9 +68,20 @@ type Generator struct {
 	discordClient *discord.Client
 	httpClient    *fhttp.Client
 	stop          func() error
+	requests      int64
 }
 
 func New(cfg *Config, store *storage.Store) (*Generator, error) {
+	for _, v := range cfg.Variants {
+		if v < 0 || v > 3 {
+			return nil, fmt.Errorf("generator: invalid variant %d", v)
+		}
+	}
+	if cfg.Aspect != "" {
+		if _, err := parseAspect(cfg.Aspect); err != nil {
+			return nil, err
+		}
+	}
 	return &Generator{
 		cfg:   cfg,
 		store: store,
@@ -204,16 +234,30 @@ func (g *Generator) HTTPClient() *fhttp.Client {
 	return g.httpClient
 }
 
-func (g *Generator) Generate(ctx context.Context, text string) ([][]string, error) {
+// Requests returns the number of requests (imagine and upscale) issued to the
+// discord bot since the generator was created.
+func (g *Generator) Requests() int {
+	return int(atomic.LoadInt64(&g.requests))
+}
+
+// Generate imagines the prompt and upscales the selected grid cells.
+// The cells are picked from the configured variants or randomly if none are
+// configured. If an aspect ratio is configured, upscaled cells that don't
+// match it are discarded.
+func (g *Generator) Generate(ctx context.Context, text string) ([]*Image, error) {
+	atomic.AddInt64(&g.requests, 1)
 	preview, err := g.client.Imagine(ctx, text)
 	if err != nil {
 		return nil, fmt.Errorf("generator: couldn't imagine: %w", err)
 	}
 
-	indexes := indexes()
+	indexes := g.cfg.Variants
+	if len(indexes) == 0 {
+		indexes = randomIndexes()
+	}
 
-	urlC := make(chan []string)
-	defer close(urlC)
+	imgC := make(chan *Image)
+	defer close(imgC)
 
 	var wg sync.WaitGroup
 	wg.Add(len(indexes))
@@ -225,34 +269,85 @@ func (g *Generator) Generate(ctx context.Context, text string) ([][]string, erro
 		time.Sleep(time.Duration(rand.Intn(1300)+800) * time.Millisecond)
 		go func() {
 			defer wg.Done()
+			var img *Image
+			atomic.AddInt64(&g.requests, 1)
 			u, err := g.client.Upscale(ctx, preview, i)
 			if err != nil {
 				log.Println(fmt.Errorf("generator: couldn't upscale: %w", err))
 			}
+			if len(u) > 1 {
+				img = &Image{Variant: i, DsURL: u[0], MjURL: u[1]}
+			}
 			select {
 			case <-ctx.Done():
 				return
-			case urlC <- u:
+			case imgC <- img:
 			}
 		}()
 	}
 
-	var urls [][]string
+	var imgs []*Image
 	for range indexes {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case url := <-urlC:
-			if len(url) == 0 {
+		case img := <-imgC:
+			if img == nil {
 				continue
 			}
-			urls = append(urls, url)
+			if g.cfg.Aspect != "" {
+				if err := g.validateAspect(ctx, img); err != nil {
+					log.Println(err)
+					continue
+				}
+			}
+			imgs = append(imgs, img)
 		}
 	}
-	return urls, nil
+	return imgs, nil
+}
+
+// validateAspect downloads the image and checks that its aspect ratio matches
+// the configured one.
+func (g *Generator) validateAspect(ctx context.Context, img *Image) error {
+	want, err := parseAspect(g.cfg.Aspect)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(strings.Split(img.DsURL, "?")[0])
+	output := filepath.Join(os.TempDir(), fmt.Sprintf("aspect_%d_%d%s", time.Now().UnixNano(), img.Variant, ext))
+	if err := g.Download(ctx, img.DsURL, output); err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(output) }()
+	w, h, err := image.Dimensions(output)
+	if err != nil {
+		return fmt.Errorf("generator: couldn't get dimensions: %w", err)
+	}
+	got := float64(w) / float64(h)
+	if math.Abs(got-want) > 0.01 {
+		return fmt.Errorf("generator: variant %d aspect %dx%d doesn't match %s", img.Variant, w, h, g.cfg.Aspect)
+	}
+	return nil
+}
+
+func parseAspect(v string) (float64, error) {
+	split := strings.Split(v, ":")
+	if len(split) != 2 {
+		return 0, fmt.Errorf("generator: invalid aspect %q", v)
+	}
+	w, err := strconv.Atoi(split[0])
+	if err != nil || w <= 0 {
+		return 0, fmt.Errorf("generator: invalid aspect width %q", v)
+	}
+	h, err := strconv.Atoi(split[1])
+	if err != nil || h <= 0 {
+		return 0, fmt.Errorf("generator: invalid aspect height %q", v)
+	}
+	return float64(w) / float64(h), nil
 }
 
-func indexes() []int {
+func randomIndexes() []int {
 	// Create a slice of integers.
 	indexes := []int{0, 1, 2, 3}
 
@@ -3,9 +3,15 @@ package jamendo
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -48,6 +54,7 @@ type trackResponse struct {
 	ID              int    `json:"id"`
 	Name            string `json:"name"`
 	Filename        string `json:"filename"`
+	Hash            string `json:"hash"`
 	Albums          []struct {
 		TrackNo int `json:"trackNo"`
 	} `json:"albums"`
@@ -121,6 +128,11 @@ type trackRequest struct {
 }
 
 func (c *Client) Upload(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("jamendo: couldn't stat file: %w", err)
+	}
+	size := info.Size()
 	filename := filepath.Base(path)
 	name := strings.TrimSuffix(filename, filepath.Ext(filename))
 	trackReq := &trackRequest{
@@ -129,11 +141,11 @@ func (c *Client) Upload(ctx context.Context, path string) error {
 		Filename:             filename,
 		Status:               "uploading",
 		ClientPosition:       1,
-		ClientSize:           30168842,
+		ClientSize:           size,
 		ClientType:           "audio/wav",
 		ArtistID:             c.id,
 		ArtistName:           c.name,
-		Hash:                 "e14n8br3l3n1711233391938",
+		Hash:                 trackHash(c.id, filename, size),
 		AlbumHash:            "this_is_the_singles_hash",
 		OnProCut:             "no",
 		OnProFlow:            "no",
@@ -141,29 +153,205 @@ func (c *Client) Upload(ctx context.Context, path string) error {
 		LicenseJurisdication: "int",
 		AllowCommercial:      "n",
 		AllowModifications:   "n",
-		Size:                 30168842,
+		Size:                 size,
 		Type:                 "audio/wav",
 		LastModified:         1711233380156,
 		LastModifiedDate:     time.Unix(0, 1711233380156*int64(time.Millisecond)).Format("2024-03-23T22:36:20.156Z"),
 	}
-	var trackResp trackResponse
-	if _, err := c.do(ctx, "POST", fmt.Sprintf("trackmanager/tracks/%d/%s/json", c.id, c.name), trackReq, &trackResp); err != nil {
-		return fmt.Errorf("jamendo: couldn't set track: %w", err)
+	// Create the track, reusing it if it was already created by a previous
+	// attempt or run
+	if _, err := c.createTrack(ctx, trackReq); err != nil {
+		return err
 	}
 
 	// Get ticket
-	u := fmt.Sprintf("artist/%d/%s/manager/getticket?format=json", c.id, c.name)
-	var ticket ticketResponse
-	if _, err := c.do(ctx, "GET", u, nil, &ticket); err != nil {
-		return fmt.Errorf("jamendo: couldn't get ticket: %w", err)
+	getTicket := func(ctx context.Context) (*ticketResponse, error) {
+		u := fmt.Sprintf("artist/%d/%s/manager/getticket?format=json", c.id, c.name)
+		var ticket ticketResponse
+		if _, err := c.do(ctx, "GET", u, nil, &ticket); err != nil {
+			return nil, fmt.Errorf("jamendo: couldn't get ticket: %w", err)
+		}
+		return &ticket, nil
+	}
+	ticket, err := getTicket(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Open file
+	reader, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("jamendo: couldn't open file: %w", err)
+	}
+	defer reader.Close()
+
+	// Upload file in chunks
+	uploadURL := "https://uploadserver.jamendo.com/audio/index.php"
+	send := func(ctx context.Context, f *form) error {
+		_, err := c.do(ctx, "POST", uploadURL, f, nil)
+		return err
+	}
+	return uploadChunks(ctx, reader, filename, size, c.id, chunkSize, ticket, getTicket, send)
+}
+
+// trackHash returns a deterministic hash for the track, used as idempotency
+// key to detect if the track was already created.
+func trackHash(artistID int, filename string, size int64) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%d/%s/%d", artistID, filename, size)))
+	return hex.EncodeToString(sum[:12])
+}
+
+// createTrack creates the track unless a track with the same hash already
+// exists. If the request fails, the tracks are checked before retrying, so a
+// request that reached the server doesn't create a duplicate.
+func (c *Client) createTrack(ctx context.Context, req *trackRequest) (*trackResponse, error) {
+	find := func(ctx context.Context) (*trackResponse, error) {
+		tracks, err := c.tracks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return matchTrack(tracks, req.Hash, req.Filename), nil
+	}
+	existing, err := find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		log.Printf("jamendo: track %s already created (%d)\n", req.Filename, existing.ID)
+		return existing, nil
 	}
 
-	// TODO: Upload in chunks
+	var resp trackResponse
+	reconcile := func(ctx context.Context) (bool, error) {
+		existing, err := find(ctx)
+		if err != nil {
+			return false, err
+		}
+		if existing == nil {
+			return false, nil
+		}
+		log.Printf("jamendo: track %s created by a previous attempt (%d)\n", req.Filename, existing.ID)
+		resp = *existing
+		return true, nil
+	}
+	u := fmt.Sprintf("trackmanager/tracks/%d/%s/json", c.id, c.name)
+	if _, err := c.doReconcile(ctx, "POST", u, req, &resp, reconcile); err != nil {
+		return nil, fmt.Errorf("jamendo: couldn't set track: %w", err)
+	}
+	return &resp, nil
+}
 
+// tracksResponse is the list of tracks of the artist. The list is decoded
+// both from an array and from an object with a tracks field.
+type tracksResponse []trackResponse
+
+func (t *tracksResponse) UnmarshalJSON(b []byte) error {
+	var list []trackResponse
+	if err := json.Unmarshal(b, &list); err == nil {
+		*t = list
+		return nil
+	}
+	var obj struct {
+		Tracks []trackResponse `json:"tracks"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	*t = obj.Tracks
+	return nil
+}
+
+func (c *Client) tracks(ctx context.Context) ([]trackResponse, error) {
+	var resp tracksResponse
+	if _, err := c.do(ctx, "GET", fmt.Sprintf("trackmanager/tracks/%d/%s/json", c.id, c.name), nil, &resp); err != nil {
+		return nil, fmt.Errorf("jamendo: couldn't get tracks: %w", err)
+	}
+	return resp, nil
+}
+
+// matchTrack returns the track with the given hash. Tracks without hash are
+// matched by filename.
+func matchTrack(tracks []trackResponse, hash, filename string) *trackResponse {
+	for i := range tracks {
+		t := &tracks[i]
+		if t.Hash != "" {
+			if t.Hash == hash {
+				return t
+			}
+			continue
+		}
+		if t.Filename == filename {
+			return t
+		}
+	}
+	return nil
+}
+
+// chunkSize is the size of each uploaded chunk.
+var chunkSize int64 = 5 * 1024 * 1024
+
+// maxTicketRefreshes is the number of times a ticket is requested again when
+// the server rejects its signature.
+const maxTicketRefreshes = 3
+
+// uploadChunks uploads the file in chunks of the given size using the
+// content-range header. Each chunk is retried by the client, and if the
+// server rejects the ticket signature a new ticket is requested and the chunk
+// is sent again.
+func uploadChunks(ctx context.Context, r io.ReaderAt, filename string, total int64, artistID int, size int64,
+	ticket *ticketResponse, getTicket func(context.Context) (*ticketResponse, error), send func(context.Context, *form) error) error {
+	boundary := fmt.Sprintf("----WebKitFormBoundary%s", webkitID(16))
+	var refreshes int
+	for _, rng := range chunkRanges(total, size) {
+		from, to := rng[0], rng[1]
+		data := make([]byte, to-from+1)
+		if _, err := r.ReadAt(data, from); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("jamendo: couldn't read chunk %d-%d: %w", from, to, err)
+		}
+		for {
+			f, err := newChunkForm(boundary, filename, artistID, ticket, data, from, to, total)
+			if err != nil {
+				return err
+			}
+			err = send(ctx, f)
+			if err == nil {
+				break
+			}
+			if !isSignatureError(err) || refreshes >= maxTicketRefreshes {
+				return fmt.Errorf("jamendo: couldn't upload chunk %d-%d/%d: %w", from, to, total, err)
+			}
+			refreshes++
+			log.Printf("jamendo: ticket rejected, requesting a new one: %v\n", err)
+			ticket, err = getTicket(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunkRanges returns the inclusive byte ranges of each chunk.
+func chunkRanges(total, size int64) [][2]int64 {
+	if size <= 0 {
+		size = total
+	}
+	var ranges [][2]int64
+	for from := int64(0); from < total; from += size {
+		to := from + size - 1
+		if to >= total {
+			to = total - 1
+		}
+		ranges = append(ranges, [2]int64{from, to})
+	}
+	return ranges
+}
+
+func newChunkForm(boundary, filename string, artistID int, ticket *ticketResponse, data []byte, from, to, total int64) (*form, error) {
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
-	if err := writer.SetBoundary(fmt.Sprintf("----WebKitFormBoundary%s", webkitID(16))); err != nil {
-		return fmt.Errorf("jamendo: couldn't set boundary: %w", err)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, fmt.Errorf("jamendo: couldn't set boundary: %w", err)
 	}
 
 	// Add fields
@@ -172,7 +360,7 @@ func (c *Client) Upload(ctx context.Context, path string) error {
 		value string
 	}{
 		{key: "user_id", value: fmt.Sprintf("%d", 0)}, // TODO: obtain user ID
-		{key: "artist_id", value: fmt.Sprintf("%d", c.id)},
+		{key: "artist_id", value: fmt.Sprintf("%d", artistID)},
 		{key: "secret", value: ""},                    // TODO: obtain secret
 		{key: "file_id", value: fmt.Sprintf("%d", 0)}, // TODO: obtain file ID
 		{key: "ticket_data", value: ticket.Datas.TicketData},
@@ -180,42 +368,39 @@ func (c *Client) Upload(ctx context.Context, path string) error {
 	}
 	for _, kv := range kvs {
 		if err := writer.WriteField(kv.key, kv.value); err != nil {
-			return fmt.Errorf("jamendo: couldn't write field %s: %w", kv.key, err)
+			return nil, fmt.Errorf("jamendo: couldn't write field %s: %w", kv.key, err)
 		}
 	}
 
-	part, err := writer.CreateFormFile("file", filepath.Base(path))
+	part, err := writer.CreateFormFile("file", filename)
 	if err != nil {
-		return fmt.Errorf("jamendo: couldn't create form file: %w", err)
-	}
-
-	// Open file
-	reader, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("jamendo: couldn't open file: %w", err)
+		return nil, fmt.Errorf("jamendo: couldn't create form file: %w", err)
 	}
-	defer reader.Close()
-
-	// Copy file to part
-	if _, err := io.Copy(part, reader); err != nil {
-		return fmt.Errorf("jamendo: couldn't copy file to part: %w", err)
+	if _, err := part.Write(data); err != nil {
+		return nil, fmt.Errorf("jamendo: couldn't write chunk to part: %w", err)
 	}
 
 	// Close writer
 	if err := writer.Close(); err != nil {
-		return fmt.Errorf("jamendo: couldn't close writer: %w", err)
+		return nil, fmt.Errorf("jamendo: couldn't close writer: %w", err)
 	}
-
-	// Upload file
-	f := &form{
+	return &form{
 		writer: writer,
 		data:   &buf,
+		from:   from,
+		to:     to,
+		total:  total,
+	}, nil
+}
+
+// isSignatureError returns true if the upload server rejected the ticket.
+func isSignatureError(err error) bool {
+	var errStatus errStatusCode
+	if errors.As(err, &errStatus) && int(errStatus) == http.StatusForbidden {
+		return true
 	}
-	uploadURL := "https://uploadserver.jamendo.com/audio/index.php"
-	if _, err := c.do(ctx, "POST", uploadURL, f, nil); err != nil {
-		return err
-	}
-	return nil
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "signature") || strings.Contains(msg, "ticket")
 }
 
 type updateTrackRequest struct {
@@ -230,6 +415,7 @@ type updateTrackRequest struct {
 	ProCodeTrack      string    `json:"proCodeTrack"`
 	VoiceInstrumental int       `json:"voice_instrumental"`
 	Description       string    `json:"description"`
+	Credits           string    `json:"credits,omitempty"`
 	AcousticElectric  string    `json:"acoustic_electric"`
 	Speed             string    `json:"speed"`
 	Energy            string    `json:"energy"`
@@ -300,9 +486,11 @@ func (c *Client) UpdateTrack(ctx context.Context, albumID, albumTitle string, re
 
 	// Select acoustic or electric
 	var acousticElectric string
-	if song.Acousticness < 0.4 {
+	switch {
+	case song.NoAnalysis:
+	case song.Acousticness < 0.4:
 		acousticElectric = "-1"
-	} else if song.Acousticness > 0.6 {
+	case song.Acousticness > 0.6:
 		acousticElectric = "1"
 	}
 
@@ -359,6 +547,7 @@ func (c *Client) UpdateTrack(ctx context.Context, albumID, albumTitle string, re
 		ProCodeTrack:      "",
 		VoiceInstrumental: voiceInstrumental,
 		Description:       song.Description,
+		Credits:           song.Credits,
 		AcousticElectric:  acousticElectric,
 		Speed:             speed,
 		Energy:            energy,
@@ -0,0 +1,126 @@
+package jamendo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+func TestUploadChunks(t *testing.T) {
+	data := make([]byte, 12*1024+7)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	total := int64(len(data))
+
+	var ranges []string
+	var uploaded bytes.Buffer
+	var tickets int
+	rejected := false
+	send := func(ctx context.Context, f *form) error {
+		// Reject the signature of the second chunk once
+		if f.from > 0 && !rejected {
+			rejected = true
+			return fmt.Errorf("jamendo: upload failed: %w", errStatusCode(http.StatusForbidden))
+		}
+		ranges = append(ranges, fmt.Sprintf("bytes %d-%d/%d", f.from, f.to, f.total))
+
+		// Extract the file part
+		r := multipart.NewReader(bytes.NewReader(f.data.Bytes()), f.writer.Boundary())
+		for {
+			p, err := r.NextPart()
+			if errors.Is(err, io.EOF) {
+				return errors.New("file part not found")
+			}
+			if err != nil {
+				return err
+			}
+			if p.FormName() == "ticket_signature" {
+				b, _ := io.ReadAll(p)
+				if f.from > 0 && string(b) != "sig2" {
+					return fmt.Errorf("unexpected signature %s", string(b))
+				}
+				continue
+			}
+			if p.FormName() != "file" {
+				continue
+			}
+			b, err := io.ReadAll(p)
+			if err != nil {
+				return err
+			}
+			if int64(len(b)) != f.to-f.from+1 {
+				return fmt.Errorf("unexpected chunk size %d", len(b))
+			}
+			uploaded.Write(b)
+			return nil
+		}
+	}
+	getTicket := func(ctx context.Context) (*ticketResponse, error) {
+		tickets++
+		var ticket ticketResponse
+		ticket.Datas.TicketSignature = "sig2"
+		return &ticket, nil
+	}
+	ticket := &ticketResponse{}
+	ticket.Datas.TicketSignature = "sig1"
+
+	err := uploadChunks(context.Background(), bytes.NewReader(data), "song.wav", total, 1, 5*1024, ticket, getTicket, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		fmt.Sprintf("bytes 0-5119/%d", total),
+		fmt.Sprintf("bytes 5120-10239/%d", total),
+		fmt.Sprintf("bytes 10240-%d/%d", total-1, total),
+	}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("expected ranges %v, got %v", want, ranges)
+	}
+	if !bytes.Equal(uploaded.Bytes(), data) {
+		t.Error("uploaded data doesn't match the file")
+	}
+	if tickets != 1 {
+		t.Errorf("expected 1 ticket refresh, got %d", tickets)
+	}
+}
+
+func TestMatchTrack(t *testing.T) {
+	var tracks tracksResponse
+	if err := json.Unmarshal([]byte(`{"tracks":[{"id":1,"filename":"a.wav","hash":"h1"},{"id":2,"filename":"b.wav"}]}`), &tracks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+	tests := []struct {
+		hash     string
+		filename string
+		want     int
+	}{
+		{"h1", "a.wav", 1},
+		{"h2", "a.wav", 0},
+		{"h2", "b.wav", 2},
+		{"h3", "c.wav", 0},
+	}
+	for _, tt := range tests {
+		got := matchTrack(tracks, tt.hash, tt.filename)
+		var id int
+		if got != nil {
+			id = got.ID
+		}
+		if id != tt.want {
+			t.Errorf("matchTrack(%s, %s) = %d, want %d", tt.hash, tt.filename, id, tt.want)
+		}
+	}
+	if trackHash(1, "a.wav", 10) != trackHash(1, "a.wav", 10) {
+		t.Error("trackHash isn't deterministic")
+	}
+}
@@ -3,12 +3,15 @@ package jamendo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chromedp/cdproto/network"
@@ -31,10 +34,16 @@ type Browser struct {
 	profile          bool
 	cookieStore      CookieStore
 	binPath          string
+	loginRetry       bool
 
 	userID     int
 	artistID   int
 	artistName string
+
+	// albums are the IDs of the albums created by title, so a retried
+	// publication reuses the album instead of creating a duplicate
+	albumsLck sync.Mutex
+	albums    map[string]string
 }
 
 type BrowserConfig struct {
@@ -44,8 +53,14 @@ type BrowserConfig struct {
 	Profile     bool
 	CookieStore CookieStore
 	BinPath     string
+	// LoginRetry reloads the cookies from the cookie store and retries the
+	// publication once if jamendo redirects to the login page.
+	LoginRetry bool
 }
 
+// ErrNotAuthenticated is returned when jamendo redirects to the login page.
+var ErrNotAuthenticated = errors.New("jamendo: not authenticated")
+
 func NewBrowser(cfg *BrowserConfig) *Browser {
 	wait := cfg.Wait
 	if wait == 0 {
@@ -58,22 +73,17 @@ func NewBrowser(cfg *BrowserConfig) *Browser {
 		cookieStore: cfg.CookieStore,
 		rateLimit:   ratelimit.New(wait),
 		binPath:     cfg.BinPath,
+		loginRetry:  cfg.LoginRetry,
+		albums:      map[string]string{},
 	}
 }
 
 func (b *Browser) Start(parent context.Context) error {
 	// Obtain the cookie
-	rawCookies, err := b.cookieStore.GetCookie(parent)
+	cookies, err := b.getCookies(parent)
 	if err != nil {
 		return err
 	}
-	if rawCookies == "" {
-		return fmt.Errorf("jamendo: cookie is empty")
-	}
-	cookies, err := session.UnmarshalCookies(rawCookies, nil)
-	if err != nil {
-		return fmt.Errorf("jamendo: couldn't parse cookie: %w", err)
-	}
 
 	var browserContext, allocatorContext context.Context
 	var browserCancel, allocatorCancel context.CancelFunc
@@ -141,28 +151,7 @@ func (b *Browser) Start(parent context.Context) error {
 	}
 
 	// Actions to set the cookie and navigate
-	if err := chromedp.Run(browserContext,
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			for _, cookie := range cookies {
-				for _, domain := range []string{"artists.jamendo.com", "uploadserver.jamendo.com"} {
-					if domain == "uploadserver.jamendo.com" {
-						switch cookie.Name {
-						case "jamsession", "jamuserid", "jamlang":
-						default:
-							continue
-						}
-					}
-					if err := network.SetCookie(cookie.Name, cookie.Value).
-						WithDomain(domain).
-						//WithHTTPOnly(true).
-						Do(ctx); err != nil {
-						return fmt.Errorf("jamendo: could not set cookie: %w", err)
-					}
-				}
-			}
-			return nil
-		}),
-	); err != nil {
+	if err := chromedp.Run(browserContext, setCookies(cookies)); err != nil {
 		return fmt.Errorf("jamendo: could not set cookie and navigate: %w", err)
 	}
 
@@ -231,6 +220,69 @@ func (b *Browser) Start(parent context.Context) error {
 	return nil
 }
 
+// getCookies obtains the cookies from the cookie store.
+func (b *Browser) getCookies(ctx context.Context) ([]*http.Cookie, error) {
+	rawCookies, err := b.cookieStore.GetCookie(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rawCookies == "" {
+		return nil, fmt.Errorf("jamendo: cookie is empty")
+	}
+	cookies, err := session.UnmarshalCookies(rawCookies, nil)
+	if err != nil {
+		return nil, fmt.Errorf("jamendo: couldn't parse cookie: %w", err)
+	}
+	return cookies, nil
+}
+
+// setCookies returns the action that sets the cookies in the browser.
+func setCookies(cookies []*http.Cookie) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, cookie := range cookies {
+			for _, domain := range []string{"artists.jamendo.com", "uploadserver.jamendo.com"} {
+				if domain == "uploadserver.jamendo.com" {
+					switch cookie.Name {
+					case "jamsession", "jamuserid", "jamlang":
+					default:
+						continue
+					}
+				}
+				if err := network.SetCookie(cookie.Name, cookie.Value).
+					WithDomain(domain).
+					//WithHTTPOnly(true).
+					Do(ctx); err != nil {
+					return fmt.Errorf("jamendo: could not set cookie: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// refreshCookies reloads the cookies from the cookie store into the browser,
+// in case they have been updated since the browser was started.
+func (b *Browser) refreshCookies(ctx context.Context) error {
+	cookies, err := b.getCookies(ctx)
+	if err != nil {
+		return err
+	}
+	if err := chromedp.Run(b.browserContext, setCookies(cookies)); err != nil {
+		return fmt.Errorf("jamendo: couldn't refresh cookies: %w", err)
+	}
+	return nil
+}
+
+// isLoginURL returns whether the URL is the jamendo login page.
+func isLoginURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(parsed.Path)
+	return strings.Contains(path, "login") || strings.Contains(path, "signin")
+}
+
 // Stop closes the browser.
 func (c *Browser) Stop() error {
 	defer func() {
@@ -0,0 +1,42 @@
+package jamendo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestIsLoginURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://artists.jamendo.com/en/artist/123/name/manager", false},
+		{"https://artists.jamendo.com/en/login?redirect=%2Fmanager", true},
+		{"https://www.jamendo.com/signin", true},
+		{"https://artists.jamendo.com/", false},
+	}
+	for _, tt := range tests {
+		if got := isLoginURL(tt.url); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestUploadedTracks(t *testing.T) {
+	html := `<ul id="singlesList">
+<li class="track" data-jam-track-id="1" data-jam-track-status="uploaded"><div title="song-a"></div></li>
+<li class="track" data-jam-track-id="2" data-jam-track-status="uploaderror"><div title="song-b"></div></li>
+<li class="track" data-jam-track-id="3" data-jam-track-status="uploaded"><div></div></li>
+</ul>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := uploadedTracks(doc)
+	if want := map[string]string{"song-a": "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -28,6 +28,7 @@ type Client struct {
 	cookieStore CookieStore
 	name        string
 	id          int
+	attempts    int
 }
 
 type Config struct {
@@ -37,6 +38,8 @@ type Config struct {
 	CookieStore CookieStore
 	Name        string
 	ID          int
+	// Attempts is the maximum number of attempts of each request.
+	Attempts int
 }
 
 type cookieStore struct {
@@ -75,6 +78,10 @@ func New(cfg *Config) *Client {
 		wait = 1 * time.Second
 	}
 	client := fhttp.NewClient(2*time.Minute, true, cfg.Proxy)
+	attempts := cfg.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
 
 	return &Client{
 		client:      client,
@@ -83,6 +90,7 @@ func New(cfg *Config) *Client {
 		cookieStore: cfg.CookieStore,
 		name:        cfg.Name,
 		id:          cfg.ID,
+		attempts:    attempts,
 	}
 }
 
@@ -139,12 +147,29 @@ var backoff = []time.Duration{
 }
 
 func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
-	maxAttempts := 3
+	return c.doReconcile(ctx, method, path, in, out, nil)
+}
+
+// doReconcile is like do, but before each retry it calls reconcile to check
+// if the previous attempt reached the server. This is used for requests that
+// aren't idempotent, so a retry after a timeout doesn't create duplicates.
+// If reconcile returns true, the request isn't retried.
+func (c *Client) doReconcile(ctx context.Context, method, path string, in, out any, reconcile func(context.Context) (bool, error)) ([]byte, error) {
+	maxAttempts := c.attempts
 	attempts := 0
 	var err error
 	for {
 		if err != nil {
 			log.Println("retrying...", err)
+			if reconcile != nil {
+				ok, rErr := reconcile(ctx)
+				if rErr != nil {
+					return nil, fmt.Errorf("jamendo: couldn't reconcile after %v: %w", err, rErr)
+				}
+				if ok {
+					return nil, nil
+				}
+			}
 		}
 		var b []byte
 		b, err = c.doAttempt(ctx, method, path, in, out)
@@ -239,7 +264,8 @@ func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any
 		reqBody = strings.NewReader(f.Encode())
 		contentType = "application/x-www-form-urlencoded; charset=UTF-8"
 	} else if f, ok := in.(*form); ok {
-		reqBody = f.data
+		// Use a new reader so the form can be sent again when retrying
+		reqBody = bytes.NewReader(f.data.Bytes())
 		contentType = f.writer.FormDataContentType()
 		contentRange = fmt.Sprintf("bytes %d-%d/%d", f.from, f.to, f.total)
 	} else if in != nil {
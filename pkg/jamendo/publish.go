@@ -2,6 +2,7 @@ package jamendo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,8 +14,13 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
+	"github.com/igolaizola/musikai/pkg/image"
 )
 
+// MinCoverSize is the minimum width and height in pixels of the cover
+// allowed by Jamendo.
+const MinCoverSize = 1000
+
 type Album struct {
 	Artist      string
 	Title       string
@@ -29,6 +35,7 @@ type Song struct {
 	Instrumental bool
 	Title        string
 	Description  string
+	Credits      string
 	Genres       []string
 	Tags         []string
 	ISRC         string
@@ -37,6 +44,9 @@ type Song struct {
 	Energy       float32
 	Mood         float32
 	Acousticness float32
+	// NoAnalysis skips the energy, mood and acousticness fields when the
+	// song couldn't be analyzed.
+	NoAnalysis bool
 }
 
 func (a *Album) Validate() error {
@@ -61,6 +71,9 @@ func (a *Album) Validate() error {
 	if _, err := os.Stat(a.Cover); os.IsNotExist(err) {
 		return fmt.Errorf("jamendo: cover file doesn't exist: %s", a.Cover)
 	}
+	if err := image.ValidateCover(a.Cover, MinCoverSize); err != nil {
+		return fmt.Errorf("jamendo: invalid cover: %w", err)
+	}
 	for i, song := range a.Songs {
 		if song.Title == "" {
 			return fmt.Errorf("jamendo: song %d title is empty", i+1)
@@ -87,14 +100,16 @@ func (a *Album) Validate() error {
 				return fmt.Errorf("jamendo: song %d tag %q is invalid", i+1, v)
 			}
 		}
-		if song.Acousticness == 0 {
-			return fmt.Errorf("jamendo: song %d acousticness is empty", i+1)
-		}
-		if song.Mood == 0 {
-			return fmt.Errorf("jamendo: song %d mood is empty", i+1)
-		}
-		if song.Energy == 0 {
-			return fmt.Errorf("jamendo: song %d energy is empty", i+1)
+		if !song.NoAnalysis {
+			if song.Acousticness == 0 {
+				return fmt.Errorf("jamendo: song %d acousticness is empty", i+1)
+			}
+			if song.Mood == 0 {
+				return fmt.Errorf("jamendo: song %d mood is empty", i+1)
+			}
+			if song.Energy == 0 {
+				return fmt.Errorf("jamendo: song %d energy is empty", i+1)
+			}
 		}
 		if song.Description == "" {
 			return fmt.Errorf("jamendo: song %d description is empty", i+1)
@@ -114,13 +129,28 @@ type Publication struct {
 	SongIDs []string
 }
 
-// Publish publishes a new album
+// Publish publishes a new album.
+// If jamendo redirects to the login page, ErrNotAuthenticated is returned
+// unless LoginRetry is enabled, which reloads the cookies from the cookie
+// store and retries once.
 func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool) (*Publication, error) {
 	// Validate album
 	if err := album.Validate(); err != nil {
 		return nil, err
 	}
 
+	pub, err := c.publish(parent, album, editTracks)
+	if !errors.Is(err, ErrNotAuthenticated) || !c.loginRetry {
+		return pub, err
+	}
+	log.Println("jamendo: redirected to login, retrying with refreshed cookies")
+	if err := c.refreshCookies(parent); err != nil {
+		return nil, err
+	}
+	return c.publish(parent, album, editTracks)
+}
+
+func (c *Browser) publish(parent context.Context, album *Album, editTracks bool) (*Publication, error) {
 	// Create a new tab based on client context
 	ctx, cancel := chromedp.NewContext(c.browserContext)
 	defer cancel()
@@ -141,6 +171,9 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 	); err != nil {
 		return nil, fmt.Errorf("jamendo: couldn't navigate to url: %w", err)
 	}
+	if err := checkLogin(ctx); err != nil {
+		return nil, err
+	}
 
 	// List existing albums
 	doc, err := getHTML(ctx, "#albumsList")
@@ -162,39 +195,52 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 	}
 	time.Sleep(1000 * time.Millisecond)
 
-	// Click on the new album button
-	if err := click(ctx, "#addAlbum"); err != nil {
-		return nil, err
-	}
-	time.Sleep(1000 * time.Millisecond)
+	// Reuse the album created by a previous attempt, so retries don't
+	// create duplicates
+	albumID := c.createdAlbum(album.Title, albumLookup)
+	if albumID != "" {
+		log.Printf("jamendo: album %s created by a previous attempt (%s)\n", album.Title, albumID)
+	} else {
+		// Click on the new album button
+		if err := click(ctx, "#addAlbum"); err != nil {
+			return nil, err
+		}
+		time.Sleep(1000 * time.Millisecond)
 
-	// Set the album title
-	if err := setValue(ctx, "#edit_album_form #name", album.Title); err != nil {
-		return nil, err
-	}
+		// Set the album title
+		if err := setValue(ctx, "#edit_album_form #name", album.Title); err != nil {
+			return nil, err
+		}
 
-	// Click on OK
-	if err := click(ctx, "#edit_album_form #submit"); err != nil {
-		return nil, err
-	}
+		// Click on OK
+		if err := click(ctx, "#edit_album_form #submit"); err != nil {
+			return nil, err
+		}
 
-	time.Sleep(1000 * time.Millisecond)
+		time.Sleep(1000 * time.Millisecond)
+		if err := checkLogin(ctx); err != nil {
+			return nil, err
+		}
 
-	// List existing albums
-	doc, err = getHTML(ctx, "#albumsList")
-	if err != nil {
-		return nil, err
-	}
-	var albumID string
-	doc.Find("li.album").Each(func(i int, s *goquery.Selection) {
-		id, ok := s.Attr("data-jam-album-id")
-		if !ok {
-			return
+		// List existing albums
+		doc, err = getHTML(ctx, "#albumsList")
+		if err != nil {
+			return nil, err
 		}
-		if _, ok := albumLookup[id]; !ok {
-			albumID = id
+		doc.Find("li.album").Each(func(i int, s *goquery.Selection) {
+			id, ok := s.Attr("data-jam-album-id")
+			if !ok {
+				return
+			}
+			if _, ok := albumLookup[id]; !ok {
+				albumID = id
+			}
+		})
+		if albumID == "" {
+			return nil, fmt.Errorf("jamendo: couldn't find created album %s", album.Title)
 		}
-	})
+		c.setCreatedAlbum(album.Title, albumID)
+	}
 	log.Println("album id", albumID)
 
 	// Click on the open album button
@@ -271,6 +317,9 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 		return nil, err
 	}
 	time.Sleep(1000 * time.Millisecond)
+	if err := checkLogin(ctx); err != nil {
+		return nil, err
+	}
 
 	// Click on singles
 	if err := click(ctx, "#singlesTab"); err != nil {
@@ -290,14 +339,26 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 		}
 		singleLookup[id] = struct{}{}
 	})
+	uploaded := uploadedTracks(doc)
 
 	songIDs := make([]string, len(album.Songs))
 	for i := len(album.Songs) - 1; i >= 0; i-- {
 		song := album.Songs[i]
-
-		// Upload song
 		name := filepath.Base(song.File)
 		name = strings.TrimSuffix(name, filepath.Ext(name))
+
+		// Reuse the track uploaded by a previous attempt, so retries don't
+		// create duplicates
+		if songID, ok := uploaded[name]; ok {
+			log.Printf("jamendo: track %s uploaded by a previous attempt (%s)\n", name, songID)
+			songIDs[i] = songID
+			if err := click(ctx, fmt.Sprintf(`li[data-jam-track-id="%s"] input.js-batch-actions`, songID)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Upload song
 		waitSel := fmt.Sprintf(`li[data-jam-track-status="uploaderror"] div[title="%s"], li[data-jam-track-status="uploaded"] div[title="%s"]`, name, name)
 		if err := upload(ctx, `#trackFileUpload`, song.File, waitSel); err != nil {
 			return nil, err
@@ -383,6 +444,9 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 			wait = 5 * time.Second
 		}
 		time.Sleep(wait)
+		if err := checkLogin(ctx); err != nil {
+			return nil, err
+		}
 
 		// Move missing ones
 
@@ -432,6 +496,9 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 				wait = 5 * time.Second
 			}
 			time.Sleep(wait)
+			if err := checkLogin(ctx); err != nil {
+				return nil, err
+			}
 		}
 
 		if err := c.EditTracks(ctx, album, albumID, songIDs); err != nil {
@@ -446,6 +513,44 @@ func (c *Browser) Publish(parent context.Context, album *Album, editTracks bool)
 	}, nil
 }
 
+// createdAlbum returns the ID of the album with the title created by a
+// previous attempt if it is still in the list of albums.
+func (c *Browser) createdAlbum(title string, albums map[string]struct{}) string {
+	c.albumsLck.Lock()
+	defer c.albumsLck.Unlock()
+	id, ok := c.albums[title]
+	if !ok {
+		return ""
+	}
+	if _, ok := albums[id]; !ok {
+		return ""
+	}
+	return id
+}
+
+func (c *Browser) setCreatedAlbum(title, id string) {
+	c.albumsLck.Lock()
+	defer c.albumsLck.Unlock()
+	c.albums[title] = id
+}
+
+// uploadedTracks returns the IDs of the uploaded singles by name.
+func uploadedTracks(doc *goquery.Document) map[string]string {
+	tracks := map[string]string{}
+	doc.Find(`li.track[data-jam-track-status="uploaded"]`).Each(func(i int, s *goquery.Selection) {
+		id, ok := s.Attr("data-jam-track-id")
+		if !ok {
+			return
+		}
+		name, ok := s.Find("div[title]").First().Attr("title")
+		if !ok || name == "" {
+			return
+		}
+		tracks[name] = id
+	})
+	return tracks
+}
+
 func (c *Browser) EditTracks(ctx context.Context, album *Album, albumID string, songIDs []string) error {
 	// Refresh the page
 	u := fmt.Sprintf("https://artists.jamendo.com/en/artist/%d/%s/manager", c.artistID, c.artistName)
@@ -455,6 +560,9 @@ func (c *Browser) EditTracks(ctx context.Context, album *Album, albumID string,
 	); err != nil {
 		return fmt.Errorf("jamendo: couldn't navigate to url: %w", err)
 	}
+	if err := checkLogin(ctx); err != nil {
+		return err
+	}
 
 	// Click on the album tab
 	if err := click(ctx, "#albumsTab"); err != nil {
@@ -570,12 +678,14 @@ func (c *Browser) EditTracks(ctx context.Context, album *Album, albumID string,
 		}
 
 		// Select acoustic or electric
-		if song.Acousticness < 0.4 {
+		switch {
+		case song.NoAnalysis:
+		case song.Acousticness < 0.4:
 			// Click on electric
 			if err := click(ctx, `label[for="acoustic_electric--1"]`); err != nil {
 				return err
 			}
-		} else if song.Acousticness > 0.6 {
+		case song.Acousticness > 0.6:
 			// Click on acoustic
 			if err := click(ctx, `label[for="acoustic_electric-1"]`); err != nil {
 				return err
@@ -680,6 +790,9 @@ func (c *Browser) EditTracks(ctx context.Context, album *Album, albumID string,
 			return err
 		}
 		time.Sleep(2000 * time.Millisecond)
+		if err := checkLogin(ctx); err != nil {
+			return err
+		}
 		log.Println("song", song.Title, "done")
 	}
 	return nil
@@ -715,6 +828,19 @@ func toSpeed(bpm float32) int {
 	}
 }
 
+// checkLogin returns ErrNotAuthenticated if jamendo redirected to the login
+// page, as the session can expire at any step of the publication.
+func checkLogin(ctx context.Context) error {
+	var current string
+	if err := chromedp.Run(ctx, chromedp.Location(&current)); err != nil {
+		return fmt.Errorf("jamendo: couldn't get location: %w", err)
+	}
+	if isLoginURL(current) {
+		return fmt.Errorf("%w: redirected to %s", ErrNotAuthenticated, current)
+	}
+	return nil
+}
+
 func getHTML(ctx context.Context, sel string) (*goquery.Document, error) {
 	// Obtain the document
 	var html string
@@ -0,0 +1,89 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultBackups = 3
+
+// Writer is a concurrency safe file writer that rotates the file when it
+// reaches the max size, keeping a few backups (file.1, file.2, ...).
+type Writer struct {
+	lck     sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+// New opens the log file in append mode. If maxSize is zero, the file is
+// never rotated.
+func New(path string, maxSize int64) (*Writer, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("logfile: couldn't create directory: %w", err)
+		}
+	}
+	w := &Writer{
+		path:    path,
+		maxSize: maxSize,
+		backups: defaultBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("logfile: couldn't open file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("logfile: couldn't stat file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it first if needed.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.lck.Lock()
+	defer w.lck.Unlock()
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("logfile: couldn't close file: %w", err)
+	}
+	for i := w.backups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return fmt.Errorf("logfile: couldn't rotate file: %w", err)
+	}
+	return w.open()
+}
+
+// Close closes the file.
+func (w *Writer) Close() error {
+	w.lck.Lock()
+	defer w.lck.Unlock()
+	return w.file.Close()
+}
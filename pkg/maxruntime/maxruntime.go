@@ -0,0 +1,21 @@
+package maxruntime
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReached is returned by commands that stopped because the max runtime
+// was reached before all the work was done.
+var ErrReached = errors.New("max runtime reached")
+
+// After returns a channel that fires once the duration has elapsed and a
+// function to release the timer. If the duration is zero, the channel never
+// fires.
+func After(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		return nil, func() {}
+	}
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
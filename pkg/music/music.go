@@ -1,6 +1,14 @@
 package music
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAccountUnavailable is returned by generators when the account can't be
+// used for a while (e.g. it ran out of credits or it isn't authorized).
+var ErrAccountUnavailable = errors.New("music: account unavailable")
 
 type Song struct {
 	ID           string  `json:"id"`
@@ -20,3 +28,30 @@ type Generator interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
 }
+
+// Job is a generation that hasn't finished on the provider side.
+type Job struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// JobManager is implemented by generators that can list and cancel their
+// in-flight generations.
+type JobManager interface {
+	Jobs(ctx context.Context) ([]Job, error)
+	Cancel(ctx context.Context, ids []string) error
+}
+
+// CreditChecker is implemented by generators that can report the credits left
+// on the account.
+type CreditChecker interface {
+	Credits(ctx context.Context) (int, error)
+}
+
+// ProxySetter is implemented by generators that can change their proxy, so
+// it can be rotated between generations.
+type ProxySetter interface {
+	SetProxy(proxy string) error
+}
@@ -0,0 +1,142 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// refreshEvery is the number of reports between total refreshes.
+const refreshEvery = 10
+
+// Reporter periodically logs the progress of a command: processed and total
+// items, rate, ETA and errors.
+// It is safe for concurrent use.
+type Reporter struct {
+	lck       sync.Mutex
+	name      string
+	interval  time.Duration
+	limit     int
+	remaining func(ctx context.Context) (int64, error)
+	now       func() time.Time
+
+	start     time.Time
+	processed int64
+	errors    int64
+	total     int64
+}
+
+// New creates a new reporter that logs every interval using the name as
+// prefix. If interval is zero nothing is logged.
+// The remaining function returns the number of items left to process and is
+// used to derive the total, which is capped by the limit if set. If remaining
+// is nil the total is the limit, or unknown if there is no limit.
+func New(name string, interval time.Duration, limit int, remaining func(ctx context.Context) (int64, error)) *Reporter {
+	return &Reporter{
+		name:      name,
+		interval:  interval,
+		limit:     limit,
+		remaining: remaining,
+		now:       time.Now,
+		total:     -1,
+	}
+}
+
+// Start obtains the total and launches the periodic report. It returns a
+// function that stops the report and logs a last line.
+func (r *Reporter) Start(ctx context.Context) func() {
+	r.lck.Lock()
+	r.start = r.now()
+	r.lck.Unlock()
+	if r.interval <= 0 {
+		return func() {}
+	}
+	r.refresh(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		var n int
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n++
+				if n%refreshEvery == 0 {
+					r.refresh(ctx)
+				}
+				log.Println(r.Line())
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		log.Println(r.Line())
+	}
+}
+
+// Done marks an item as processed, counting it as an error if err isn't nil.
+func (r *Reporter) Done(err error) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	r.processed++
+	if err != nil {
+		r.errors++
+	}
+}
+
+// refresh updates the total using the remaining items.
+func (r *Reporter) refresh(ctx context.Context) {
+	total := int64(-1)
+	if r.remaining != nil {
+		remaining, err := r.remaining(ctx)
+		if err != nil {
+			log.Printf("%s: couldn't refresh progress total: %v\n", r.name, err)
+			return
+		}
+		r.lck.Lock()
+		total = r.processed + remaining
+		r.lck.Unlock()
+	}
+	if r.limit > 0 && (total < 0 || total > int64(r.limit)) {
+		total = int64(r.limit)
+	}
+	r.lck.Lock()
+	r.total = total
+	r.lck.Unlock()
+}
+
+// Line returns the current progress as a log line.
+func (r *Reporter) Line() string {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+	elapsed := r.now().Sub(r.start)
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(r.processed) / elapsed.Minutes()
+	}
+	if r.total < 0 {
+		return fmt.Sprintf("%s: progress %d, %.2f/min, %d errors", r.name, r.processed, rate, r.errors)
+	}
+	total := r.total
+	if total < r.processed {
+		total = r.processed
+	}
+	var pct float64
+	if total > 0 {
+		pct = 100 * float64(r.processed) / float64(total)
+	}
+	eta := "unknown"
+	if rate > 0 {
+		d := time.Duration(float64(total-r.processed) / rate * float64(time.Minute))
+		eta = d.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s: progress %d/%d (%.1f%%), %.2f/min, eta %s, %d errors", r.name, r.processed, total, pct, rate, eta, r.errors)
+}
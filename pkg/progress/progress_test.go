@@ -0,0 +1,69 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReporter(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	r := New("test", 0, 0, func(ctx context.Context) (int64, error) {
+		return 90, nil
+	})
+	r.now = func() time.Time { return now }
+	r.Start(ctx)
+	r.refresh(ctx)
+
+	// Mark items as done concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i < 2 {
+				err = errors.New("fail")
+			}
+			r.Done(err)
+		}(i)
+	}
+	wg.Wait()
+
+	now = start.Add(5 * time.Minute)
+	want := "test: progress 10/90 (11.1%), 2.00/min, eta 40m0s, 2 errors"
+	if got := r.Line(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Processed items aren't remaining anymore
+	r.refresh(ctx)
+	want = "test: progress 10/100 (10.0%), 2.00/min, eta 45m0s, 2 errors"
+	if got := r.Line(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Total is capped by the limit
+	r.limit = 50
+	r.refresh(ctx)
+	want = "test: progress 10/50 (20.0%), 2.00/min, eta 20m0s, 2 errors"
+	if got := r.Line(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Unknown total
+	r = New("test", 0, 0, nil)
+	r.now = func() time.Time { return now }
+	r.Start(ctx)
+	r.refresh(ctx)
+	r.Done(nil)
+	want = "test: progress 1, 0.00/min, 0 errors"
+	if got := r.Line(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
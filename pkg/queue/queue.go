@@ -0,0 +1,7 @@
+package queue
+
+import "errors"
+
+// ErrEmpty is returned by commands that stopped because there was nothing
+// left to process.
+var ErrEmpty = errors.New("queue is empty")
@@ -0,0 +1,243 @@
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const baseURL = "https://api.replicate.com/v1/"
+
+// DefaultModel is the text to image model used if none is configured.
+const DefaultModel = "black-forest-labs/flux-schnell"
+
+// ErrFailed is returned when the prediction ends with a failed or canceled
+// status, for example when the prompt is flagged as unsafe.
+var ErrFailed = errors.New("replicate: prediction failed")
+
+type Client struct {
+	client       *http.Client
+	baseURL      string
+	token        string
+	model        string
+	debug        bool
+	pollInterval time.Duration
+}
+
+type Config struct {
+	Token string
+	// Model is the model used to generate images as owner/name.
+	Model string
+	Proxy string
+	Debug bool
+	// PollInterval is the time between prediction status requests.
+	PollInterval time.Duration
+}
+
+func New(cfg *Config) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("replicate: token is required")
+	}
+	model := cfg.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	if len(strings.Split(model, "/")) != 2 {
+		return nil, fmt.Errorf("replicate: invalid model %q, expected owner/name", model)
+	}
+	pollInterval := cfg.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 2 * time.Second
+	}
+	client := &http.Client{
+		Timeout: 2 * time.Minute,
+	}
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("replicate: couldn't parse proxy URL %q: %w", cfg.Proxy, err)
+		}
+		client.Transport = &http.Transport{
+			Proxy: http.ProxyURL(u),
+		}
+	}
+	return &Client{
+		client:       client,
+		baseURL:      baseURL,
+		token:        cfg.Token,
+		model:        model,
+		debug:        cfg.Debug,
+		pollInterval: pollInterval,
+	}, nil
+}
+
+type predictionRequest struct {
+	Input imageInput `json:"input"`
+}
+
+type imageInput struct {
+	Prompt       string `json:"prompt"`
+	NumOutputs   int    `json:"num_outputs,omitempty"`
+	AspectRatio  string `json:"aspect_ratio,omitempty"`
+	OutputFormat string `json:"output_format,omitempty"`
+}
+
+type prediction struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Output json.RawMessage `json:"output"`
+	Error  any             `json:"error"`
+}
+
+// Generate creates a prediction with the prompt and waits until it finishes.
+// It returns the URLs of the generated images.
+// The context cancels the wait, and the prediction is canceled too so it
+// doesn't keep consuming credits.
+func (c *Client) Generate(ctx context.Context, prompt string, n int, aspect string) ([]string, error) {
+	req := &predictionRequest{
+		Input: imageInput{
+			Prompt:       prompt,
+			NumOutputs:   n,
+			AspectRatio:  aspect,
+			OutputFormat: "png",
+		},
+	}
+	var p prediction
+	if err := c.do(ctx, "POST", fmt.Sprintf("models/%s/predictions", c.model), req, &p); err != nil {
+		return nil, fmt.Errorf("replicate: couldn't create prediction: %w", err)
+	}
+	c.log("replicate: prediction %s created (%s)", p.ID, p.Status)
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		switch p.Status {
+		case "succeeded":
+			return parseOutput(p.Output)
+		case "failed", "canceled":
+			return nil, fmt.Errorf("%w: %s %v", ErrFailed, p.Status, p.Error)
+		}
+		select {
+		case <-ctx.Done():
+			c.cancel(p.ID)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		if err := c.do(ctx, "GET", fmt.Sprintf("predictions/%s", p.ID), nil, &p); err != nil {
+			if ctx.Err() != nil {
+				c.cancel(p.ID)
+			}
+			return nil, fmt.Errorf("replicate: couldn't get prediction %s: %w", p.ID, err)
+		}
+		c.log("replicate: prediction %s %s", p.ID, p.Status)
+	}
+}
+
+// Download downloads a generated image to the output file.
+// The output URLs expire, so images must be downloaded soon after the
+// prediction finishes.
+func (c *Client) Download(ctx context.Context, u, output string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("replicate: couldn't create request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("replicate: couldn't download %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("replicate: couldn't download %s: invalid status code %d", u, resp.StatusCode)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("replicate: couldn't create file: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return fmt.Errorf("replicate: couldn't write file: %w", err)
+	}
+	return nil
+}
+
+// cancel cancels a prediction, using a new context as the original one may
+// be already canceled.
+func (c *Client) cancel(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.do(ctx, "POST", fmt.Sprintf("predictions/%s/cancel", id), nil, nil); err != nil {
+		log.Printf("replicate: couldn't cancel prediction %s: %v\n", id, err)
+	}
+}
+
+// parseOutput returns the image URLs of the output, which can be a single URL
+// or a list of them depending on the model.
+func parseOutput(raw json.RawMessage) ([]string, error) {
+	var urls []string
+	if err := json.Unmarshal(raw, &urls); err == nil {
+		return urls, nil
+	}
+	var u string
+	if err := json.Unmarshal(raw, &u); err != nil {
+		return nil, fmt.Errorf("replicate: couldn't parse output %s: %w", raw, err)
+	}
+	if u == "" {
+		return nil, nil
+	}
+	return []string{u}, nil
+}
+
+func (c *Client) log(format string, args ...any) {
+	if !c.debug {
+		return
+	}
+	format += "\n"
+	log.Printf(format, args...)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("couldn't marshal request body: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("couldn't create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't do request: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("invalid status code %d: %s", resp.StatusCode, b)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("couldn't unmarshal response body %s: %w", b, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := New(&Config{Token: "token", PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = srv.URL + "/"
+	return c
+}
+
+func TestGenerate(t *testing.T) {
+	var polls int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/models/"+DefaultModel+"/predictions":
+			fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+		case r.Method == "GET" && r.URL.Path == "/predictions/p1":
+			if atomic.AddInt32(&polls, 1) < 3 {
+				fmt.Fprint(w, `{"id":"p1","status":"processing"}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":"p1","status":"succeeded","output":["https://a/1.png","https://a/2.png"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	urls, err := c.Generate(context.Background(), "prompt", 2, "1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 2 || urls[1] != "https://a/2.png" {
+		t.Errorf("unexpected urls %v", urls)
+	}
+}
+
+func TestGenerateFailed(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"p1","status":"failed","error":"NSFW content detected"}`)
+	})
+	if _, err := c.Generate(context.Background(), "prompt", 4, "1:1"); !errors.Is(err, ErrFailed) {
+		t.Errorf("expected failed error, got %v", err)
+	}
+}
+
+func TestGenerateCancel(t *testing.T) {
+	canceled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predictions/p1/cancel":
+			close(canceled)
+			fmt.Fprint(w, `{"id":"p1","status":"canceled"}`)
+		case "/predictions/p1":
+			cancel()
+			fmt.Fprint(w, `{"id":"p1","status":"processing"}`)
+		default:
+			fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+		}
+	})
+	if _, err := c.Generate(ctx, "prompt", 4, "1:1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("prediction wasn't canceled")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/output/1.png" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "image data")
+	})
+	output := filepath.Join(t.TempDir(), "1.png")
+	if err := c.Download(context.Background(), c.baseURL+"output/1.png", output); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "image data" {
+		t.Errorf("got %q", b)
+	}
+
+	// Expired URLs fail
+	if err := c.Download(context.Background(), c.baseURL+"output/2.png", output); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,89 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// ErrStopped is returned by commands that stopped scheduling new work
+// because a shutdown was requested.
+var ErrStopped = errors.New("shutdown requested")
+
+type ctxKey struct{}
+
+type state struct {
+	lck      sync.Mutex
+	grace    time.Duration
+	stopping chan struct{}
+}
+
+// Notify returns a context that is canceled on interrupt.
+// If a grace period is set with WithGrace, the first interrupt only requests
+// a shutdown so commands can finish their in-flight work, and the context is
+// canceled on a second interrupt or once the grace period is over.
+func Notify(parent context.Context) (context.Context, context.CancelFunc) {
+	s := &state{stopping: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.WithValue(parent, ctxKey{}, s))
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt)
+	go func() {
+		defer signal.Stop(sigC)
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigC:
+		}
+		s.lck.Lock()
+		grace := s.grace
+		s.lck.Unlock()
+		if grace <= 0 {
+			cancel()
+			return
+		}
+		log.Printf("shutdown: finishing in-flight work for up to %s, interrupt again to force exit\n", grace)
+		close(s.stopping)
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-sigC:
+			log.Println("shutdown: forcing exit")
+		case <-timer.C:
+			log.Println("shutdown: grace period is over, forcing exit")
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// WithGrace sets the grace period of the context created by Notify.
+// Zero means the context is canceled on the first interrupt.
+func WithGrace(ctx context.Context, grace time.Duration) {
+	s, ok := ctx.Value(ctxKey{}).(*state)
+	if !ok {
+		return
+	}
+	s.lck.Lock()
+	defer s.lck.Unlock()
+	s.grace = grace
+}
+
+// Requested returns whether a shutdown was requested, in which case no new
+// work should be scheduled.
+func Requested(ctx context.Context) bool {
+	s, ok := ctx.Value(ctxKey{}).(*state)
+	if !ok {
+		return false
+	}
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
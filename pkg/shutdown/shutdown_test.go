@@ -0,0 +1,71 @@
+package shutdown
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func interrupt(t *testing.T) {
+	t.Helper()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGrace(t *testing.T) {
+	ctx, cancel := Notify(context.Background())
+	defer cancel()
+	WithGrace(ctx, time.Minute)
+
+	// First interrupt requests the shutdown without canceling the context
+	interrupt(t)
+	deadline := time.Now().Add(5 * time.Second)
+	for !Requested(ctx) {
+		if time.Now().After(deadline) {
+			t.Fatal("shutdown not requested")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("context canceled on first interrupt")
+	}
+
+	// Second interrupt cancels the context
+	interrupt(t)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled on second interrupt")
+	}
+}
+
+func TestNoGrace(t *testing.T) {
+	ctx, cancel := Notify(context.Background())
+	defer cancel()
+
+	interrupt(t)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled on interrupt")
+	}
+}
+
+func TestGraceExpired(t *testing.T) {
+	ctx, cancel := Notify(context.Background())
+	defer cancel()
+	WithGrace(ctx, 50*time.Millisecond)
+
+	interrupt(t)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled after the grace period")
+	}
+	if !Requested(ctx) {
+		t.Error("expected shutdown requested")
+	}
+}
@@ -6,12 +6,28 @@ import (
 	"time"
 )
 
+// Default fade out detection parameters
+const (
+	DefaultFadeOutWindow    = 500 * time.Millisecond
+	DefaultFadeOutThreshold = 0.001
+)
+
 func (a *Analyzer) HasFadeOut() bool {
-	rmsWindow := 100 * time.Millisecond
-	fadeOutWindow := 1 * time.Second
-	analysisWindow := int(fadeOutWindow.Seconds() / rmsWindow.Seconds())
-	rms := a.RMS(50 * time.Millisecond)
+	window := a.fadeOutWindow
+	if window <= 0 {
+		window = DefaultFadeOutWindow
+	}
+	threshold := a.fadeOutThreshold
+	if threshold <= 0 {
+		threshold = DefaultFadeOutThreshold
+	}
+	rmsWindow := 50 * time.Millisecond
+	analysisWindow := int(window / rmsWindow)
+	rms := a.RMS(rmsWindow)
 
+	if analysisWindow > len(rms) {
+		analysisWindow = len(rms)
+	}
 	rms = rms[len(rms)-analysisWindow:]
 
 	// Check for a consistent decrease in RMS values
@@ -19,7 +35,7 @@ func (a *Analyzer) HasFadeOut() bool {
 	for i := 1; i < len(rms); i++ {
 		// Calculate the increment
 		inc := rms[i] - rms[i-1]
-		if inc < 0 && inc*-1.0 > 0.001 {
+		if inc < 0 && inc*-1.0 > threshold {
 			// If any window is louder than the previous, it's not a consistent fade out
 			count++
 		}
@@ -0,0 +1,69 @@
+package sound
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// synthetic creates an analyzer with a 400Hz sine wave using the given
+// amplitude envelope.
+func synthetic(duration time.Duration, envelope func(t float64) float64, opts ...Option) *Analyzer {
+	rate := 8000
+	n := int(duration.Seconds() * float64(rate))
+	mono := make([]float64, n)
+	for i := range mono {
+		t := float64(i) / float64(rate)
+		mono[i] = envelope(t) * math.Sin(2*math.Pi*400*t)
+	}
+	a := &Analyzer{
+		mono:     mono,
+		rate:     rate,
+		duration: duration,
+
+		fadeOutWindow:    DefaultFadeOutWindow,
+		fadeOutThreshold: DefaultFadeOutThreshold,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func TestFadeOutSynthetic(t *testing.T) {
+	// Fade out to silence during 5 seconds, then a silent tail
+	fadeOut := func(t float64) float64 {
+		if t > 5 {
+			return 0.0001
+		}
+		return 0.5 * (1 - t/5)
+	}
+	// Natural decay that is still audible at the end
+	decay := func(t float64) float64 {
+		if t < 3 {
+			return 0.8
+		}
+		return 0.8 * math.Exp(-(t - 3))
+	}
+
+	tests := []struct {
+		name     string
+		envelope func(float64) float64
+		opts     []Option
+		want     bool
+	}{
+		{"fade-out", fadeOut, nil, true},
+		{"natural-decay", decay, nil, false},
+		{"natural-decay-threshold", decay, []Option{WithFadeOut(0, 0.003)}, true},
+		{"natural-decay-window", decay, []Option{WithFadeOut(100*time.Millisecond, 0)}, true},
+		{"fade-out-zero-options", fadeOut, []Option{WithFadeOut(0, 0)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := synthetic(6*time.Second, tt.envelope, tt.opts...)
+			if got := a.HasFadeOut(); got != tt.want {
+				t.Errorf("HasFadeOut() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
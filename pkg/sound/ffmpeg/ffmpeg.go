@@ -2,10 +2,14 @@ package ffmpeg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -42,6 +46,39 @@ func FadeOut(ctx context.Context, input, output string, totalDuration, fadeOutDu
 	return nil
 }
 
+// FadeIn fades in the start of the audio during the given duration.
+func FadeIn(ctx context.Context, input, output string, duration time.Duration) error {
+	// Use a temporary file if the input and output are the same
+	tmp := output
+	if input == output {
+		tmp = fmt.Sprintf("%s.tmp%s", input, filepath.Ext(input))
+	}
+
+	cmd := exec.CommandContext(ctx, BinPath, fadeInArgs(input, tmp, duration)...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		if tmp != output {
+			_ = os.Remove(tmp)
+		}
+		msg := string(data)
+		return fmt.Errorf("ffmpeg: couldn't fade in: %w: %s", err, msg)
+	}
+
+	// Move the temporary file to the output path
+	if tmp != output {
+		_ = os.Remove(output)
+		if err := os.Rename(tmp, output); err != nil {
+			return fmt.Errorf("ffmpeg: couldn't rename temporary file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func fadeInArgs(input, output string, duration time.Duration) []string {
+	return []string{"-y", "-i", input, "-b:a", "320k", "-af", fmt.Sprintf("afade=t=in:st=0:d=%f", duration.Seconds()), output}
+}
+
 func Cut(ctx context.Context, input, output string, end time.Duration) error {
 	// Use a temporary file if the input and output are the same
 	tmp := output
@@ -70,6 +107,45 @@ func Cut(ctx context.Context, input, output string, end time.Duration) error {
 	return nil
 }
 
+// Trim keeps the audio between start and end, or until the end of the audio
+// if end is zero.
+// Unlike Cut, positions aren't rounded to seconds.
+func Trim(ctx context.Context, input, output string, start, end time.Duration) error {
+	// Use a temporary file if the input and output are the same
+	tmp := output
+	if input == output {
+		tmp = fmt.Sprintf("%s.tmp%s", input, filepath.Ext(input))
+	}
+
+	cmd := exec.CommandContext(ctx, BinPath, trimArgs(input, tmp, start, end)...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		if tmp != output {
+			_ = os.Remove(tmp)
+		}
+		msg := string(data)
+		return fmt.Errorf("ffmpeg: couldn't trim: %w: %s", err, msg)
+	}
+
+	// Move the temporary file to the output path
+	if tmp != output {
+		_ = os.Remove(output)
+		if err := os.Rename(tmp, output); err != nil {
+			return fmt.Errorf("ffmpeg: couldn't rename temporary file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func trimArgs(input, output string, start, end time.Duration) []string {
+	args := []string{"-y", "-i", input, "-ss", toSeconds(start)}
+	if end > 0 {
+		args = append(args, "-to", toSeconds(end))
+	}
+	return append(args, "-acodec", "copy", output)
+}
+
 func Convert(ctx context.Context, input, output string) error {
 	cmd := exec.CommandContext(ctx, BinPath, "-y", "-i", input, "-b:a", "320k", output)
 	data, err := cmd.CombinedOutput()
@@ -81,20 +157,383 @@ func Convert(ctx context.Context, input, output string) error {
 	return nil
 }
 
-func StaticVideo(ctx context.Context, image, music, output string) error {
-	// See https://superuser.com/questions/1041816/combine-one-image-one-audio-file-to-make-one-video-using-ffmpeg/1041820#1041820
-	cmd := exec.CommandContext(ctx, BinPath, "-y", "-r", "1", "-loop", "1", "-i", image, "-i", music, "-acodec", "copy", "-r", "1", "-shortest", "-vf", "scale=1080:1080", output)
+// Transcode converts the input audio to the format of the output extension
+// (wav, flac or mp3). Sample rate, bit depth and bitrate are optional and
+// only applied when they are supported by the output format.
+func Transcode(ctx context.Context, input, output string, sampleRate, bitDepth int, bitrate string) error {
+	codec := strings.TrimPrefix(filepath.Ext(output), ".")
+	return Encode(ctx, input, output, codec, EncodeOpts{
+		SampleRate: sampleRate,
+		BitDepth:   bitDepth,
+		Bitrate:    bitrate,
+	})
+}
+
+// EncodeOpts are the optional encoding parameters.
+type EncodeOpts struct {
+	SampleRate int
+	BitDepth   int
+	// Bitrate is only used by mp3, 320k by default.
+	Bitrate  string
+	Channels int
+}
+
+// Encode encodes the input audio to the output using the given codec (mp3,
+// flac or wav).
+func Encode(ctx context.Context, input, output, codec string, opts EncodeOpts) error {
+	args, err := encodeArgs(input, output, codec, opts)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, BinPath, args...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := string(data)
+		return fmt.Errorf("ffmpeg: couldn't encode %s to %s: %w: %s", input, output, err, msg)
+	}
+	return nil
+}
+
+func encodeArgs(input, output, codec string, opts EncodeOpts) ([]string, error) {
+	args := []string{"-y", "-i", input}
+	if opts.SampleRate > 0 {
+		args = append(args, "-ar", fmt.Sprintf("%d", opts.SampleRate))
+	}
+	if opts.Channels > 0 {
+		args = append(args, "-ac", fmt.Sprintf("%d", opts.Channels))
+	}
+	codecArgs, err := codecArgs(codec, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, codecArgs...)
+	return append(args, output), nil
+}
+
+// codecArgs returns the arguments to encode with the codec.
+func codecArgs(codec string, opts EncodeOpts) ([]string, error) {
+	var args []string
+	switch codec {
+	case "wav":
+		switch opts.BitDepth {
+		case 0:
+		case 16, 24, 32:
+			args = append(args, "-c:a", fmt.Sprintf("pcm_s%dle", opts.BitDepth))
+		default:
+			return nil, fmt.Errorf("ffmpeg: unsupported wav bit depth %d", opts.BitDepth)
+		}
+	case "flac":
+		args = append(args, "-c:a", "flac")
+		switch opts.BitDepth {
+		case 0:
+		case 16:
+			args = append(args, "-sample_fmt", "s16")
+		case 24:
+			args = append(args, "-sample_fmt", "s32", "-bits_per_raw_sample", "24")
+		default:
+			return nil, fmt.Errorf("ffmpeg: unsupported flac bit depth %d", opts.BitDepth)
+		}
+	case "mp3":
+		bitrate := opts.Bitrate
+		if bitrate == "" {
+			bitrate = "320k"
+		}
+		args = append(args, "-c:a", "libmp3lame", "-b:a", bitrate)
+	default:
+		return nil, fmt.Errorf("ffmpeg: unsupported output format %s", codec)
+	}
+	return args, nil
+}
+
+// Crossfade joins the inputs in order into the output, overlapping each pair
+// of consecutive inputs with a crossfade of the given duration. If the fade
+// is zero the inputs are joined without gaps.
+// The codec is chosen using the output extension (wav, flac or mp3).
+// A single input is copied, or encoded if the format is different.
+func Crossfade(ctx context.Context, inputs []string, output string, fade time.Duration) error {
+	codec := strings.TrimPrefix(filepath.Ext(output), ".")
+	if len(inputs) == 1 {
+		if filepath.Ext(inputs[0]) == filepath.Ext(output) {
+			if err := copyFile(inputs[0], output); err != nil {
+				return fmt.Errorf("ffmpeg: couldn't copy %s to %s: %w", inputs[0], output, err)
+			}
+			return nil
+		}
+		return Encode(ctx, inputs[0], output, codec, EncodeOpts{})
+	}
+	args, err := crossfadeArgs(inputs, output, codec, fade)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, BinPath, args...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := string(data)
+		return fmt.Errorf("ffmpeg: couldn't crossfade to %s: %w: %s", output, err, msg)
+	}
+	return nil
+}
+
+func crossfadeArgs(inputs []string, output, codec string, fade time.Duration) ([]string, error) {
+	if len(inputs) < 2 {
+		return nil, fmt.Errorf("ffmpeg: at least two inputs are needed to crossfade")
+	}
+	args := []string{"-y"}
+	for _, in := range inputs {
+		args = append(args, "-i", in)
+	}
+
+	var filter string
+	if fade <= 0 {
+		// Join the inputs without gaps
+		for i := range inputs {
+			filter += fmt.Sprintf("[%d:a]", i)
+		}
+		filter += fmt.Sprintf("concat=n=%d:v=0:a=1[out]", len(inputs))
+	} else {
+		// Chain the crossfades, each one takes the result of the previous
+		prev := "[0:a]"
+		var filters []string
+		for i := 1; i < len(inputs); i++ {
+			next := fmt.Sprintf("[a%d]", i)
+			if i == len(inputs)-1 {
+				next = "[out]"
+			}
+			filters = append(filters, fmt.Sprintf("%s[%d:a]acrossfade=d=%g:c1=tri:c2=tri%s", prev, i, fade.Seconds(), next))
+			prev = next
+		}
+		filter = strings.Join(filters, ";")
+	}
+	args = append(args, "-filter_complex", filter, "-map", "[out]")
+
+	codecArgs, err := codecArgs(codec, EncodeOpts{})
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, codecArgs...)
+	return append(args, output), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// StaticVideo renders a video with a still image and the audio, encoded with
+// H.264 and AAC so it can be uploaded to video platforms like YouTube.
+func StaticVideo(ctx context.Context, image, audio, output string) error {
+	cmd := exec.CommandContext(ctx, BinPath, staticVideoArgs(image, audio, output)...)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := string(data)
+		return fmt.Errorf("ffmpeg: couldn't create static video %s: %w: %s", output, err, msg)
+	}
+	return nil
+}
+
+func staticVideoArgs(image, audio, output string) []string {
+	return []string{
+		"-y",
+		"-loop", "1", "-framerate", "1", "-i", image,
+		"-i", audio,
+		// Dimensions must be even for yuv420p
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-c:v", "libx264", "-tune", "stillimage", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "320k",
+		"-shortest", "-movflags", "+faststart",
+		output,
+	}
+}
+
+// Tags is the metadata written to an mp3 file.
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+	Track  int
+	Year   int
+	Genre  string
+	// Cover is the path of a JPG image attached as the front cover.
+	Cover string
+}
+
+// WriteTags writes the tags to the mp3 file as ID3v2.3 metadata, replacing
+// any existing metadata. The audio stream is copied without re-encoding.
+func WriteTags(ctx context.Context, path string, t Tags) error {
+	tmp := fmt.Sprintf("%s.tmp%s", path, filepath.Ext(path))
+	cmd := exec.CommandContext(ctx, BinPath, tagArgs(path, tmp, t)...)
 	data, err := cmd.CombinedOutput()
 	if err != nil {
+		_ = os.Remove(tmp)
 		msg := string(data)
-		return fmt.Errorf("ffmpeg: couldn't create static video: %w: %s", err, msg)
+		return fmt.Errorf("ffmpeg: couldn't write tags: %w: %s", err, msg)
+	}
+
+	// Move the temporary file to the output path
+	_ = os.Remove(path)
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ffmpeg: couldn't rename temporary file: %w", err)
 	}
 	return nil
 }
 
+func tagArgs(input, output string, t Tags) []string {
+	args := []string{"-y", "-i", input}
+	if t.Cover != "" {
+		args = append(args, "-i", t.Cover)
+	}
+	args = append(args, "-map", "0:a")
+	if t.Cover != "" {
+		args = append(args, "-map", "1:v", "-c:v", "copy", "-disposition:v", "attached_pic",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)")
+	}
+	args = append(args, "-c:a", "copy", "-map_metadata", "-1", "-id3v2_version", "3")
+	metadata := [][2]string{
+		{"title", t.Title},
+		{"artist", t.Artist},
+		{"album_artist", t.Artist},
+		{"album", t.Album},
+		{"genre", t.Genre},
+	}
+	if t.Track > 0 {
+		metadata = append(metadata, [2]string{"track", strconv.Itoa(t.Track)})
+	}
+	if t.Year > 0 {
+		metadata = append(metadata, [2]string{"date", strconv.Itoa(t.Year)})
+	}
+	for _, kv := range metadata {
+		if kv[1] == "" {
+			continue
+		}
+		args = append(args, "-metadata", kv[0]+"="+kv[1])
+	}
+	return append(args, output)
+}
+
+// LoudnormStats are the loudness values measured by the loudnorm filter.
+type LoudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// LUFS returns the measured integrated loudness.
+func (s *LoudnormStats) LUFS() (float64, error) {
+	v, err := strconv.ParseFloat(s.InputI, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg: couldn't parse integrated loudness %q: %w", s.InputI, err)
+	}
+	return v, nil
+}
+
+// TruePeak returns the measured true peak in dBTP.
+func (s *LoudnormStats) TruePeak() (float64, error) {
+	v, err := strconv.ParseFloat(s.InputTP, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg: couldn't parse true peak %q: %w", s.InputTP, err)
+	}
+	return v, nil
+}
+
+// Loudness measures the loudness of the input without modifying it.
+func Loudness(ctx context.Context, input string) (*LoudnormStats, error) {
+	return measureLoudness(ctx, input, "")
+}
+
+func measureLoudness(ctx context.Context, input, target string) (*LoudnormStats, error) {
+	filter := "loudnorm=print_format=json"
+	if target != "" {
+		filter = fmt.Sprintf("loudnorm=%s:print_format=json", target)
+	}
+	cmd := exec.CommandContext(ctx, BinPath, "-hide_banner", "-i", input, "-af", filter, "-f", "null", "-")
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := string(data)
+		return nil, fmt.Errorf("ffmpeg: couldn't measure loudness: %w: %s", err, msg)
+	}
+	return parseLoudnorm(string(data))
+}
+
+// Loudnorm normalizes the loudness of the input to the target integrated
+// loudness (LUFS), true peak (dBTP) and loudness range (LU) using a two-pass
+// loudnorm filter. It returns the stats measured in the first pass.
+func Loudnorm(ctx context.Context, input, output string, targetLUFS, targetTP, targetLRA float64) (*LoudnormStats, error) {
+	// First pass to measure the loudness
+	target := fmt.Sprintf("I=%.1f:TP=%.1f:LRA=%.1f", targetLUFS, targetTP, targetLRA)
+	stats, err := measureLoudness(ctx, input, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use a temporary file if the input and output are the same
+	tmp := output
+	if input == output {
+		tmp = fmt.Sprintf("%s.tmp%s", input, filepath.Ext(input))
+	}
+
+	// Second pass to apply the gain using the measured values.
+	// The filter resamples to 192kHz, so the sample rate is set back to 44.1kHz.
+	filter := fmt.Sprintf("loudnorm=%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		target, stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh, stats.TargetOffset)
+	cmd := exec.CommandContext(ctx, BinPath, "-y", "-i", input, "-b:a", "320k", "-ar", "44100", "-af", filter, tmp)
+	data, err := cmd.CombinedOutput()
+	if err != nil {
+		if tmp != output {
+			_ = os.Remove(tmp)
+		}
+		msg := string(data)
+		return nil, fmt.Errorf("ffmpeg: couldn't normalize loudness: %w: %s", err, msg)
+	}
+
+	// Move the temporary file to the output path
+	if tmp != output {
+		_ = os.Remove(output)
+		if err := os.Rename(tmp, output); err != nil {
+			return nil, fmt.Errorf("ffmpeg: couldn't rename temporary file: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// parseLoudnorm parses the JSON stats printed by the loudnorm filter at the
+// end of the ffmpeg output.
+func parseLoudnorm(out string) (*LoudnormStats, error) {
+	start := strings.LastIndex(out, "{")
+	end := strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("ffmpeg: couldn't find loudnorm stats: %s", out)
+	}
+	var stats LoudnormStats
+	if err := json.Unmarshal([]byte(out[start:end+1]), &stats); err != nil {
+		return nil, fmt.Errorf("ffmpeg: couldn't unmarshal loudnorm stats: %w", err)
+	}
+	if stats.InputI == "" {
+		return nil, fmt.Errorf("ffmpeg: loudnorm stats are empty: %s", out[start:end+1])
+	}
+	return &stats, nil
+}
+
 func toText(d time.Duration) string {
 	h := int(d.Hours())
 	m := int(d.Minutes()) % 60
 	s := int(d.Seconds()) % 60
 	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
 }
+
+func toSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,150 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLoudnorm(t *testing.T) {
+	out := `Input #0, mp3, from 'song.mp3':
+  Duration: 00:02:10.34, start: 0.025057, bitrate: 320 kb/s
+[Parsed_loudnorm_0 @ 0x55d7c0a1b2c0]
+{
+	"input_i" : "-9.81",
+	"input_tp" : "0.42",
+	"input_lra" : "4.30",
+	"input_thresh" : "-19.93",
+	"output_i" : "-14.04",
+	"output_tp" : "-1.00",
+	"output_lra" : "3.90",
+	"output_thresh" : "-24.13",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.04"
+}
+`
+	stats, err := parseLoudnorm(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.InputTP != "0.42" || stats.InputLRA != "4.30" || stats.InputThresh != "-19.93" || stats.TargetOffset != "0.04" {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	lufs, err := stats.LUFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lufs != -9.81 {
+		t.Errorf("expected -9.81, got %f", lufs)
+	}
+
+	if _, err := parseLoudnorm("no stats here"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestEncodeArgs(t *testing.T) {
+	tests := []struct {
+		codec string
+		opts  EncodeOpts
+		want  string
+	}{
+		{"mp3", EncodeOpts{}, "-y -i in -c:a libmp3lame -b:a 320k out"},
+		{"mp3", EncodeOpts{Bitrate: "192k", Channels: 2}, "-y -i in -ac 2 -c:a libmp3lame -b:a 192k out"},
+		{"flac", EncodeOpts{}, "-y -i in -c:a flac out"},
+		{"flac", EncodeOpts{SampleRate: 48000, BitDepth: 24}, "-y -i in -ar 48000 -c:a flac -sample_fmt s32 -bits_per_raw_sample 24 out"},
+		{"wav", EncodeOpts{BitDepth: 16}, "-y -i in -c:a pcm_s16le out"},
+	}
+	for _, tt := range tests {
+		args, err := encodeArgs("in", "out", tt.codec, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("encodeArgs(%s, %+v) = %q, want %q", tt.codec, tt.opts, got, tt.want)
+		}
+	}
+
+	if _, err := encodeArgs("in", "out", "ogg", EncodeOpts{}); err == nil {
+		t.Error("expected error for unsupported codec")
+	}
+	if _, err := encodeArgs("in", "out", "flac", EncodeOpts{BitDepth: 32}); err == nil {
+		t.Error("expected error for unsupported bit depth")
+	}
+}
+
+func TestCrossfadeArgs(t *testing.T) {
+	tests := []struct {
+		inputs []string
+		fade   time.Duration
+		want   string
+	}{
+		{[]string{"a", "b"}, 0, "-y -i a -i b -filter_complex [0:a][1:a]concat=n=2:v=0:a=1[out] -map [out] -c:a flac out"},
+		{[]string{"a", "b"}, 3 * time.Second, "-y -i a -i b -filter_complex [0:a][1:a]acrossfade=d=3:c1=tri:c2=tri[out] -map [out] -c:a flac out"},
+		{[]string{"a", "b", "c"}, 1500 * time.Millisecond, "-y -i a -i b -i c -filter_complex [0:a][1:a]acrossfade=d=1.5:c1=tri:c2=tri[a1];[a1][2:a]acrossfade=d=1.5:c1=tri:c2=tri[out] -map [out] -c:a flac out"},
+	}
+	for _, tt := range tests {
+		args, err := crossfadeArgs(tt.inputs, "out", "flac", tt.fade)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("crossfadeArgs(%v, %s) = %q, want %q", tt.inputs, tt.fade, got, tt.want)
+		}
+	}
+
+	if _, err := crossfadeArgs([]string{"a"}, "out", "flac", time.Second); err == nil {
+		t.Error("expected error for a single input")
+	}
+}
+
+func TestStaticVideoArgs(t *testing.T) {
+	want := "-y -loop 1 -framerate 1 -i cover.jpg -i song.mp3 -vf scale=trunc(iw/2)*2:trunc(ih/2)*2 -c:v libx264 -tune stillimage -preset veryfast -pix_fmt yuv420p -c:a aac -b:a 320k -shortest -movflags +faststart out.mp4"
+	if got := strings.Join(staticVideoArgs("cover.jpg", "song.mp3", "out.mp4"), " "); got != want {
+		t.Errorf("staticVideoArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestTagArgs(t *testing.T) {
+	tests := []struct {
+		tags Tags
+		want string
+	}{
+		{Tags{Title: "Old"}, "-y -i in.mp3 -map 0:a -c:a copy -map_metadata -1 -id3v2_version 3 -metadata title=Old out.mp3"},
+		{
+			Tags{Title: "Café del Mar", Artist: "Jazz-o-matic", Album: "Nights", Track: 3, Year: 2024, Genre: "Jazz", Cover: "cover.jpg"},
+			"-y -i in.mp3 -i cover.jpg -map 0:a -map 1:v -c:v copy -disposition:v attached_pic -metadata:s:v title=Album cover -metadata:s:v comment=Cover (front) " +
+				"-c:a copy -map_metadata -1 -id3v2_version 3 -metadata title=Café del Mar -metadata artist=Jazz-o-matic -metadata album_artist=Jazz-o-matic " +
+				"-metadata album=Nights -metadata genre=Jazz -metadata track=3 -metadata date=2024 out.mp3",
+		},
+	}
+	for _, tt := range tests {
+		got := strings.Join(tagArgs("in.mp3", "out.mp3", tt.tags), " ")
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFadeInArgs(t *testing.T) {
+	want := "-y -i in.mp3 -b:a 320k -af afade=t=in:st=0:d=1.500000 out.mp3"
+	if got := strings.Join(fadeInArgs("in.mp3", "out.mp3", 1500*time.Millisecond), " "); got != want {
+		t.Errorf("fadeInArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestTrimArgs(t *testing.T) {
+	tests := []struct {
+		start, end time.Duration
+		want       string
+	}{
+		{500 * time.Millisecond, 0, "-y -i in.mp3 -ss 0.500 -acodec copy out.mp3"},
+		{1250 * time.Millisecond, 2*time.Minute + 5*time.Second, "-y -i in.mp3 -ss 1.250 -to 125.000 -acodec copy out.mp3"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(trimArgs("in.mp3", "out.mp3", tt.start, tt.end), " ")
+		if got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
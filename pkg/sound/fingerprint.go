@@ -0,0 +1,94 @@
+package sound
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+const (
+	fingerprintWindow = 500 * time.Millisecond
+	fingerprintFrames = 128
+)
+
+// Fingerprint returns a cheap spectral hash of the audio.
+// The first 64 seconds are split in 500ms frames and two bits are computed
+// for each pair of consecutive frames: whether the RMS increases and whether
+// the zero crossing rate (a rough estimation of the spectral centroid)
+// increases. The bits are returned hex encoded.
+func (a *Analyzer) Fingerprint(ctx context.Context) (string, error) {
+	windowLength := int(float64(a.rate) * fingerprintWindow.Seconds())
+	if windowLength == 0 {
+		return "", errors.New("sound: invalid sample rate")
+	}
+	var rms, zcr []float64
+	for i := 0; i+windowLength <= len(a.mono) && len(rms) <= fingerprintFrames; i += windowLength {
+		window := a.mono[i : i+windowLength]
+		rms = append(rms, calculateRMS(window))
+		zcr = append(zcr, zeroCrossingRate(window))
+	}
+	if len(rms) < 2 {
+		return "", errors.New("sound: audio too short to fingerprint")
+	}
+
+	var bs []byte
+	var b byte
+	var n int
+	push := func(v bool) {
+		b <<= 1
+		if v {
+			b |= 1
+		}
+		n++
+		if n%8 == 0 {
+			bs = append(bs, b)
+			b = 0
+		}
+	}
+	for i := 1; i < len(rms); i++ {
+		push(rms[i] > rms[i-1])
+		push(zcr[i] > zcr[i-1])
+	}
+	if n%8 != 0 {
+		bs = append(bs, b<<(8-n%8))
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+func zeroCrossingRate(samples []float64) float64 {
+	var n int
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] >= 0) != (samples[i] >= 0) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(samples))
+}
+
+// Similarity returns the ratio of matching bits between two fingerprints,
+// comparing only the length of the shortest one.
+func Similarity(a, b string) (float64, error) {
+	ab, err := hex.DecodeString(a)
+	if err != nil {
+		return 0, fmt.Errorf("sound: invalid fingerprint %q: %w", a, err)
+	}
+	bb, err := hex.DecodeString(b)
+	if err != nil {
+		return 0, fmt.Errorf("sound: invalid fingerprint %q: %w", b, err)
+	}
+	n := len(ab)
+	if len(bb) < n {
+		n = len(bb)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	var diff int
+	for i := 0; i < n; i++ {
+		diff += bits.OnesCount8(ab[i] ^ bb[i])
+	}
+	return 1 - float64(diff)/float64(n*8), nil
+}
@@ -0,0 +1,180 @@
+package sound
+
+import (
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// Key estimation parameters
+const (
+	keyFrameSize = 8192
+	keyMaxFrames = 300
+	keyMinFreq   = 55.0
+	keyMaxFreq   = 2000.0
+	// keyMinCorrelation is the minimum correlation of the chromagram with the
+	// key profile to consider the key detected.
+	keyMinCorrelation = 0.5
+	// keyMinMargin is the minimum correlation difference between the best
+	// key and the next one that isn't its relative key.
+	keyMinMargin = 0.01
+	// keyMinContrast is the minimum coefficient of variation of the
+	// chromagram, as the correlation of an almost flat chromagram is noise.
+	keyMinContrast = 0.15
+)
+
+var pitchClasses = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// Krumhansl-Kessler key profiles starting at the tonic
+var (
+	majorProfile = []float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = []float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// Key estimates the musical key (e.g. "A minor") correlating the chromagram
+// of the audio with the major and minor key profiles.
+// An empty string is returned if the key is ambiguous.
+func (a *Analyzer) Key() string {
+	chroma := a.chromagram()
+	if chroma == nil || contrast(chroma) < keyMinContrast {
+		return ""
+	}
+	type candidate struct {
+		tonic int
+		minor bool
+		corr  float64
+	}
+	var candidates []candidate
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, minor := range []bool{false, true} {
+			profile := majorProfile
+			if minor {
+				profile = minorProfile
+			}
+			rotated := make([]float64, 12)
+			for i := range rotated {
+				rotated[(i+tonic)%12] = profile[i]
+			}
+			candidates = append(candidates, candidate{tonic: tonic, minor: minor, corr: correlation(chroma, rotated)})
+		}
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.corr > best.corr {
+			best = c
+		}
+	}
+	if best.corr < keyMinCorrelation {
+		return ""
+	}
+
+	// The relative key shares the notes, so it is always close
+	relative := (best.tonic + 3) % 12
+	if best.minor {
+		relative = (best.tonic + 9) % 12
+	}
+	for _, c := range candidates {
+		if c == best || (c.tonic == relative && c.minor != best.minor) {
+			continue
+		}
+		if best.corr-c.corr < keyMinMargin {
+			return ""
+		}
+	}
+	mode := "major"
+	if best.minor {
+		mode = "minor"
+	}
+	return pitchClasses[best.tonic] + " " + mode
+}
+
+// chromagram returns the energy of each pitch class, starting at C, of
+// evenly spaced frames of the audio. It returns nil if the audio is silent or
+// too short.
+func (a *Analyzer) chromagram() []float64 {
+	n := len(a.mono) / keyFrameSize
+	if n == 0 || a.rate == 0 {
+		return nil
+	}
+	step := 1
+	if n > keyMaxFrames {
+		step = n / keyMaxFrames
+	}
+
+	// Hann window
+	window := make([]float64, keyFrameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(keyFrameSize-1))
+	}
+
+	fft := fourier.NewFFT(keyFrameSize)
+	frame := make([]float64, keyFrameSize)
+	coeffs := make([]complex128, keyFrameSize/2+1)
+	chroma := make([]float64, 12)
+	for f := 0; f < n; f += step {
+		samples := a.mono[f*keyFrameSize : (f+1)*keyFrameSize]
+		for i, v := range samples {
+			frame[i] = v * window[i]
+		}
+		coeffs = fft.Coefficients(coeffs, frame)
+		for i, c := range coeffs {
+			freq := fft.Freq(i) * float64(a.rate)
+			if freq < keyMinFreq || freq > keyMaxFreq {
+				continue
+			}
+			// Pitch class relative to C, where A4 is 440Hz
+			midi := 69 + 12*math.Log2(freq/440)
+			pc := (int(math.Round(midi))%12 + 12) % 12
+			chroma[pc] += cmplx.Abs(c)
+		}
+	}
+	var total float64
+	for _, v := range chroma {
+		total += v
+	}
+	if total == 0 {
+		return nil
+	}
+	return chroma
+}
+
+// contrast returns the coefficient of variation of the values.
+func contrast(v []float64) float64 {
+	var mean float64
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+	if mean == 0 {
+		return 0
+	}
+	var variance float64
+	for _, x := range v {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(v))
+	return math.Sqrt(variance) / mean
+}
+
+// correlation returns the Pearson correlation of x and y.
+func correlation(x, y []float64) float64 {
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
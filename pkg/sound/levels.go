@@ -0,0 +1,74 @@
+package sound
+
+import (
+	"math"
+)
+
+// Levels are the sample levels of the audio in dBFS.
+type Levels struct {
+	// Peak is the maximum absolute sample value of any channel.
+	Peak float64
+	// RMS is the root mean square of the mono signal.
+	RMS float64
+	// DynamicRange is the crest factor, the difference between the peak and
+	// the RMS in dB. Heavy limiting reduces it.
+	DynamicRange float64
+}
+
+// Levels returns the peak, RMS and dynamic range of the audio.
+func (a *Analyzer) Levels() Levels {
+	var peak float64
+	channels := a.stereo[:]
+	if len(a.stereo[0]) == 0 {
+		channels = [][]float64{a.mono}
+	}
+	for _, samples := range channels {
+		for _, v := range samples {
+			if v = math.Abs(v); v > peak {
+				peak = v
+			}
+		}
+	}
+	var rms float64
+	if len(a.mono) > 0 {
+		rms = calculateRMS(a.mono)
+	}
+	l := Levels{
+		Peak: toDB(peak),
+		RMS:  toDB(rms),
+	}
+	if peak > 0 && rms > 0 {
+		l.DynamicRange = l.Peak - l.RMS
+	}
+	return l
+}
+
+// toDB converts an amplitude to dBFS, silence is returned as -Inf.
+func toDB(v float64) float64 {
+	return 20 * math.Log10(v)
+}
+
+// Brightness estimates the spectral centroid of the audio in Hz using the
+// zero crossing rate of non silent 50ms windows. Bright sounds (cymbals,
+// distorted guitars) have higher values than dark ones (pads, bass).
+func (a *Analyzer) Brightness() float64 {
+	windowLength := int(float64(a.rate) * 0.05)
+	if windowLength == 0 {
+		return 0
+	}
+	var sum float64
+	var n int
+	for i := 0; i+windowLength <= len(a.mono); i += windowLength {
+		window := a.mono[i : i+windowLength]
+		if calculateRMS(window) < 0.001 {
+			continue
+		}
+		sum += zeroCrossingRate(window)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	// Each period of a sine wave crosses zero twice
+	return sum / float64(n) * float64(a.rate) / 2
+}
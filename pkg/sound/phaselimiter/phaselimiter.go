@@ -117,7 +117,7 @@ func (p *PhaseLimiter) Master(ctx context.Context, input string, output string)
 	if input == output {
 		tmp = fmt.Sprintf("%s.tmp%s", input, filepath.Ext(input))
 	}
-	// Encode the wav file to mp3
+	// Encode the wav file to the output format
 	if err := encode(ctx, wav, tmp); err != nil {
 		return fmt.Errorf("phaselimiter: couldn't encode: %w", err)
 	}
@@ -145,14 +145,7 @@ func encode(ctx context.Context, input, output string) error {
 	if ext := filepath.Ext(input); ext != ".wav" {
 		return fmt.Errorf("ffmpeg: input file must be a wav file: %s", ext)
 	}
-	if ext := filepath.Ext(output); ext != ".mp3" {
-		return fmt.Errorf("ffmpeg: output file must be a mp3 file: %s", ext)
-	}
-	cmd := exec.CommandContext(ctx, ffmpeg.BinPath, "-y", "-i", input, "-codec:a", "libmp3lame", "-b:a", "320k", "-ac", "2", output)
-	data, err := cmd.CombinedOutput()
-	if err != nil {
-		msg := string(data)
-		return fmt.Errorf("ffmpeg: couldn't encode: %w: %s", err, msg)
-	}
-	return nil
+	// The output format is taken from the extension (mp3, flac or wav)
+	codec := strings.TrimPrefix(filepath.Ext(output), ".")
+	return ffmpeg.Encode(ctx, input, output, codec, ffmpeg.EncodeOpts{Channels: 2})
 }
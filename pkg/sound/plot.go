@@ -0,0 +1,163 @@
+package sound
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// PlotOptions configures the look of the wave plot.
+type PlotOptions struct {
+	// Title of the plot, the duration is appended to it.
+	Title string
+	// Background color, white by default.
+	Background color.Color
+	// Colors of the waveform, black by default. If Gradient is set the colors
+	// are interpolated along the time axis, otherwise the first one is used.
+	Colors   []color.Color
+	Gradient bool
+	// Width and Height of the image in pixels, 384 by default.
+	Width  int
+	Height int
+}
+
+// gradientSegments is the number of segments used to draw a gradient.
+const gradientSegments = 64
+
+// PlotWave plots the waveform with the default look.
+func (a *Analyzer) PlotWave(name string) ([]byte, error) {
+	return a.PlotWaveOptions(PlotOptions{Title: name})
+}
+
+// PlotWaveOptions plots the waveform using the given options.
+// The waveform always fills the whole width of the image and a thin center
+// line is drawn, so silent sections are still visible.
+func (a *Analyzer) PlotWaveOptions(opts PlotOptions) ([]byte, error) {
+	window := 50 * time.Millisecond
+	data := a.Resample(window)
+	d := time.Duration(float64(len(data))*window.Seconds()*0.5) * time.Second
+
+	// Very short clips need at least two points to draw a line
+	for len(data) < 2 {
+		data = append(data, 0)
+	}
+
+	colors := opts.Colors
+	if len(colors) == 0 {
+		colors = []color.Color{color.Black}
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = 384
+	}
+	height := opts.Height
+	if height <= 0 {
+		height = 384
+	}
+
+	p := plot.New()
+	if opts.Background != nil {
+		p.BackgroundColor = opts.Background
+	}
+	p.Title.Text = fmt.Sprintf("%s %s", opts.Title, d)
+	p.X.Label.Text = "time"
+	p.Y.Label.Text = "data"
+	p.Y.Min = -1
+	p.Y.Max = 1
+	p.X.Min = 0
+	p.X.Max = float64(len(data) - 1)
+
+	// Center line
+	center := plotter.NewFunction(func(x float64) float64 { return 0 })
+	center.Color = colors[0]
+	center.Width = vg.Points(0.5)
+	p.Add(center)
+
+	// Waveform
+	pts := makePoints(data)
+	if !opts.Gradient || len(colors) < 2 {
+		l, err := plotter.NewLine(pts)
+		if err != nil {
+			return nil, fmt.Errorf("sound: couldn't create line plotter: %w", err)
+		}
+		l.LineStyle.Width = vg.Points(1)
+		l.LineStyle.Color = colors[0]
+		p.Add(l)
+	} else {
+		size := (len(pts) + gradientSegments - 1) / gradientSegments
+		if size < 2 {
+			size = 2
+		}
+		for i := 0; i < len(pts)-1; i += size - 1 {
+			end := i + size
+			if end > len(pts) {
+				end = len(pts)
+			}
+			l, err := plotter.NewLine(pts[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("sound: couldn't create line plotter: %w", err)
+			}
+			l.LineStyle.Width = vg.Points(1)
+			l.LineStyle.Color = gradient(colors, float64(i)/float64(len(pts)-1))
+			p.Add(l)
+		}
+	}
+
+	// Save the plot
+	c, err := p.WriterTo(pixels(width), pixels(height), "jpeg")
+	if err != nil {
+		return nil, fmt.Errorf("sound: couldn't create plot: %w", err)
+	}
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("sound: couldn't write plot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pixels converts pixels to a plot length using the default 96 DPI.
+func pixels(v int) vg.Length {
+	return vg.Length(v) * vg.Inch / 96
+}
+
+// gradient returns the color at position t (0 to 1) interpolating the colors.
+func gradient(colors []color.Color, t float64) color.Color {
+	if t <= 0 {
+		return colors[0]
+	}
+	if t >= 1 {
+		return colors[len(colors)-1]
+	}
+	pos := t * float64(len(colors)-1)
+	i := int(pos)
+	f := pos - float64(i)
+	r0, g0, b0, a0 := colors[i].RGBA()
+	r1, g1, b1, a1 := colors[i+1].RGBA()
+	lerp := func(a, b uint32) uint8 {
+		return uint8((float64(a)*(1-f) + float64(b)*f) / 257)
+	}
+	return color.RGBA{R: lerp(r0, r1), G: lerp(g0, g1), B: lerp(b0, b1), A: lerp(a0, a1)}
+}
+
+// ParseColor parses a hex color like #rrggbb or #rrggbbaa.
+func ParseColor(v string) (color.Color, error) {
+	s := strings.TrimPrefix(v, "#")
+	if len(s) != 6 && len(s) != 8 {
+		return nil, fmt.Errorf("sound: invalid color %q", v)
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("sound: invalid color %q: %w", v, err)
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
@@ -26,9 +26,31 @@ type Analyzer struct {
 	rate     int
 	duration time.Duration
 	source   string
+
+	fadeOutWindow    time.Duration
+	fadeOutThreshold float64
 }
 
-func NewAnalyzer(u string) (*Analyzer, error) {
+// Option configures the analyzer.
+type Option func(*Analyzer)
+
+// WithFadeOut sets the fade out detection parameters.
+// The window is the duration at the end of the audio that is analyzed and the
+// threshold is the minimum RMS decrease between consecutive 50ms windows that
+// is considered a drop.
+// Zero values keep the defaults.
+func WithFadeOut(window time.Duration, threshold float64) Option {
+	return func(a *Analyzer) {
+		if window > 0 {
+			a.fadeOutWindow = window
+		}
+		if threshold > 0 {
+			a.fadeOutThreshold = threshold
+		}
+	}
+}
+
+func NewAnalyzer(u string, opts ...Option) (*Analyzer, error) {
 	decoder, src, err := toDecoder(u)
 	if err != nil {
 		return nil, fmt.Errorf("sound: couldn't create decoder: %w", err)
@@ -61,13 +83,20 @@ func NewAnalyzer(u string) (*Analyzer, error) {
 	}
 
 	duration := time.Duration(float64(len(mono)) / float64(decoder.SampleRate()) * float64(time.Second))
-	return &Analyzer{
+	a := &Analyzer{
 		source:   src,
 		stereo:   stereo,
 		mono:     mono,
 		rate:     decoder.SampleRate(),
 		duration: duration,
-	}, nil
+
+		fadeOutWindow:    DefaultFadeOutWindow,
+		fadeOutThreshold: DefaultFadeOutThreshold,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
 }
 
 func (a *Analyzer) Source() string {
@@ -140,6 +169,20 @@ func (a *Analyzer) Silences(ctx context.Context) ([]Fragment, error) {
 	return a.fragments(ctx, true, 1*time.Second)
 }
 
+// LeadingSilence returns the silence at the start of the audio, if any.
+// Unlike Silences, silences shorter than a second are also detected, as the
+// ones left at the start of the songs are usually shorter.
+func (a *Analyzer) LeadingSilence(ctx context.Context) (Fragment, bool, error) {
+	silences, err := a.fragments(ctx, true, 100*time.Millisecond)
+	if err != nil {
+		return Fragment{}, false, err
+	}
+	if len(silences) == 0 || silences[0].Start > 0 {
+		return Fragment{}, false, nil
+	}
+	return silences[0], true, nil
+}
+
 func (a *Analyzer) Noises(ctx context.Context) ([]Fragment, error) {
 	return a.fragments(ctx, false, 10*time.Second)
 }
@@ -236,12 +279,6 @@ func (a *Analyzer) PlotRMS() ([]byte, error) {
 	return createPlot("rms", rms, 0, 1, window.Seconds(), 0.01)
 }
 
-func (a *Analyzer) PlotWave(name string) ([]byte, error) {
-	window := 50 * time.Millisecond
-	resampled := a.Resample(window)
-	return createPlot(name, resampled, -1, 1, window.Seconds(), 0.00)
-}
-
 func createPlot(name string, data []float64, min, max float64, window float64, line float64) ([]byte, error) {
 	// Create a new plot
 	p := plot.New()
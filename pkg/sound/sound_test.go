@@ -1,7 +1,13 @@
 package sound
 
 import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/jpeg"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestFadeOut(t *testing.T) {
@@ -30,3 +36,140 @@ func TestFadeOut(t *testing.T) {
 	}
 
 }
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(file string) string {
+		t.Helper()
+		a, err := NewAnalyzer(file)
+		if err != nil {
+			t.Fatalf("NewAnalyzer(%q) err = %v; want nil", file, err)
+		}
+		fp, err := a.Fingerprint(context.Background())
+		if err != nil {
+			t.Fatalf("Fingerprint(%q) err = %v; want nil", file, err)
+		}
+		return fp
+	}
+	a := fingerprint("data/finish.mp3")
+	if got := fingerprint("data/finish.mp3"); got != a {
+		t.Fatalf("Fingerprint is not deterministic: %s != %s", got, a)
+	}
+	b := fingerprint("data/not-finish.mp3")
+	sim, err := Similarity(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim >= 0.9 {
+		t.Errorf("Similarity(finish, not-finish) = %f; want < 0.9", sim)
+	}
+	sim, err = Similarity(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim != 1 {
+		t.Errorf("Similarity(finish, finish) = %f; want 1", sim)
+	}
+}
+
+func TestPlotWaveOptions(t *testing.T) {
+	a, err := NewAnalyzer("data/finish.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bg, err := ParseColor("#1a1a2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fg1, _ := ParseColor("#e94560")
+	fg2, _ := ParseColor("#0f3460")
+	b, err := a.PlotWaveOptions(PlotOptions{
+		Title:      "test",
+		Background: bg,
+		Colors:     []color.Color{fg1, fg2},
+		Gradient:   true,
+		Width:      800,
+		Height:     200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 800 || cfg.Height != 200 {
+		t.Errorf("got %dx%d, want 800x200", cfg.Width, cfg.Height)
+	}
+
+	// Very short clips are still plotted
+	short := &Analyzer{rate: a.rate, mono: a.mono[:10]}
+	if _, err := short.PlotWave("short"); err != nil {
+		t.Errorf("PlotWave(short) err = %v; want nil", err)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	// A sine wave with half amplitude peaks at -6 dBFS and its crest factor
+	// is 3 dB
+	a := synthetic(2*time.Second, func(t float64) float64 { return 0.5 })
+	l := a.Levels()
+	if math.Abs(l.Peak-(-6.02)) > 0.1 {
+		t.Errorf("expected peak -6.02, got %.2f", l.Peak)
+	}
+	if math.Abs(l.DynamicRange-3.01) > 0.1 {
+		t.Errorf("expected dynamic range 3.01, got %.2f", l.DynamicRange)
+	}
+
+	silent := synthetic(time.Second, func(t float64) float64 { return 0 })
+	if l := silent.Levels(); !math.IsInf(l.Peak, -1) || l.DynamicRange != 0 {
+		t.Errorf("unexpected silent levels: %+v", l)
+	}
+}
+
+func TestBrightness(t *testing.T) {
+	// The synthetic audio is a 400Hz sine wave
+	a := synthetic(2*time.Second, func(t float64) float64 { return 0.5 })
+	if got := a.Brightness(); math.Abs(got-400) > 20 {
+		t.Errorf("expected brightness 400, got %.2f", got)
+	}
+
+	silent := synthetic(time.Second, func(t float64) float64 { return 0 })
+	if got := silent.Brightness(); got != 0 {
+		t.Errorf("expected silent brightness 0, got %.2f", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	// chord creates an analyzer with the sum of the notes, as midi numbers
+	chord := func(notes ...int) *Analyzer {
+		rate := 22050
+		n := 5 * rate
+		mono := make([]float64, n)
+		for i := range mono {
+			x := float64(i) / float64(rate)
+			for _, note := range notes {
+				freq := 440 * math.Pow(2, float64(note-69)/12)
+				mono[i] += 0.2 * math.Sin(2*math.Pi*freq*x)
+			}
+		}
+		return &Analyzer{mono: mono, rate: rate, duration: 5 * time.Second}
+	}
+	tests := []struct {
+		notes []int
+		want  string
+	}{
+		// C major: C E G with the tonic doubled
+		{[]int{48, 60, 64, 67}, "C major"},
+		// A minor: A C E with the tonic doubled
+		{[]int{45, 57, 60, 64}, "A minor"},
+		// All the notes, so there is no key
+		{[]int{60, 61, 62, 63, 64, 65, 66, 67, 68, 69, 70, 71}, ""},
+		// Silence
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := chord(tt.notes...).Key(); got != tt.want {
+			t.Errorf("Key(%v) = %q, want %q", tt.notes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package soundcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/igolaizola/musikai/pkg/fhttp"
+	"github.com/igolaizola/musikai/pkg/ratelimit"
+)
+
+type Client struct {
+	client      fhttp.Client
+	debug       bool
+	ratelimit   ratelimit.Lock
+	cookieStore CookieStore
+	token       string
+}
+
+type Config struct {
+	Wait        time.Duration
+	Debug       bool
+	Proxy       string
+	CookieStore CookieStore
+}
+
+// CookieStore stores the OAuth token of the account.
+type CookieStore interface {
+	GetCookie(context.Context) (string, error)
+	SetCookie(context.Context, string) error
+}
+
+func New(cfg *Config) *Client {
+	wait := cfg.Wait
+	if wait == 0 {
+		wait = 1 * time.Second
+	}
+	client := fhttp.NewClient(10*time.Minute, false, cfg.Proxy)
+
+	return &Client{
+		client:      client,
+		ratelimit:   ratelimit.New(wait),
+		debug:       cfg.Debug,
+		cookieStore: cfg.CookieStore,
+	}
+}
+
+func (c *Client) Start(ctx context.Context) error {
+	// Create log folder if it doesn't exist
+	if _, err := os.Stat("logs"); os.IsNotExist(err) {
+		if err := os.Mkdir("logs", 0755); err != nil {
+			return fmt.Errorf("soundcloud: couldn't create logs folder: %w", err)
+		}
+	}
+
+	// Get OAuth token
+	token, err := c.cookieStore.GetCookie(ctx)
+	if err != nil {
+		return err
+	}
+	token = strings.TrimSpace(strings.TrimPrefix(token, "OAuth "))
+	if token == "" {
+		return fmt.Errorf("soundcloud: oauth token is empty")
+	}
+	c.token = token
+
+	// Check the token is valid
+	var me struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}
+	if _, err := c.do(ctx, "GET", "me", nil, &me); err != nil {
+		return fmt.Errorf("soundcloud: couldn't get user: %w", err)
+	}
+	c.log("soundcloud: logged in as %s (%d)", me.Username, me.ID)
+	return nil
+}
+
+func (c *Client) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (c *Client) log(format string, args ...interface{}) {
+	if c.debug {
+		format += "\n"
+		log.Printf(format, args...)
+	}
+}
+
+var backoff = []time.Duration{
+	30 * time.Second,
+	1 * time.Minute,
+	2 * time.Minute,
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]byte, error) {
+	maxAttempts := 3
+	attempts := 0
+	var err error
+	for {
+		if err != nil {
+			log.Println("retrying...", err)
+		}
+		var b []byte
+		b, err = c.doAttempt(ctx, method, path, in, out)
+		if err == nil {
+			return b, nil
+		}
+		// Increase attempts and check if we should stop
+		attempts++
+		if attempts >= maxAttempts {
+			return nil, err
+		}
+		// If the error is temporary retry
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+
+		// Check if we should retry after waiting
+		var retry bool
+		var wait bool
+
+		// Check status code
+		var errStatus errStatusCode
+		if errors.As(err, &errStatus) {
+			switch int(errStatus) {
+			case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusTooManyRequests, 520:
+				// Retry on these status codes
+				retry = true
+				wait = true
+			default:
+				return nil, err
+			}
+		}
+		if !retry {
+			return nil, err
+		}
+
+		// Wait before retrying
+		if wait {
+			idx := attempts - 1
+			if idx >= len(backoff) {
+				idx = len(backoff) - 1
+			}
+			waitTime := backoff[idx]
+			c.log("soundcloud: rate limited, waiting %s before retrying\n", waitTime)
+			t := time.NewTimer(waitTime)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+}
+
+type errStatusCode int
+
+func (e errStatusCode) Error() string {
+	return fmt.Sprintf("%d", e)
+}
+
+type form struct {
+	writer *multipart.Writer
+	data   *bytes.Buffer
+}
+
+func (c *Client) doAttempt(ctx context.Context, method, path string, in, out any) ([]byte, error) {
+	var body []byte
+	var reqBody io.Reader
+	var contentType string
+	if f, ok := in.(*form); ok {
+		// Use a new reader so the form can be sent again when retrying
+		reqBody = bytes.NewReader(f.data.Bytes())
+		contentType = f.writer.FormDataContentType()
+	} else if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("soundcloud: couldn't marshal request body: %w", err)
+		}
+		reqBody = bytes.NewReader(body)
+		contentType = "application/json"
+	}
+	logBody := string(body)
+	c.log("soundcloud: do %s %s %s", method, path, logBody)
+
+	// Check if path is absolute
+	u := fmt.Sprintf("https://api.soundcloud.com/%s", path)
+	if strings.HasPrefix(path, "http") {
+		u = path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("soundcloud: couldn't create request: %w", err)
+	}
+	req.Header.Set("accept", "application/json; charset=utf-8")
+	req.Header.Set("authorization", fmt.Sprintf("OAuth %s", c.token))
+	if contentType != "" {
+		req.Header.Set("content-type", contentType)
+	}
+
+	unlock := c.ratelimit.Lock(ctx)
+	defer unlock()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("soundcloud: couldn't %s %s: %w", method, u, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("soundcloud: couldn't read response body: %w", err)
+	}
+	c.log("soundcloud: response %s %s %d %s", method, path, resp.StatusCode, string(respBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errMessage := string(respBody)
+		if len(errMessage) > 100 {
+			errMessage = errMessage[:100] + "..."
+		}
+		_ = os.WriteFile(fmt.Sprintf("logs/debug_%s.json", time.Now().Format("20060102_150405")), respBody, 0644)
+		return nil, fmt.Errorf("soundcloud: %s %s returned (%s): %w", method, u, errMessage, errStatusCode(resp.StatusCode))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			// Write response body to file for debugging.
+			_ = os.WriteFile(fmt.Sprintf("logs/debug_%s.json", time.Now().Format("20060102_150405")), respBody, 0644)
+			return nil, fmt.Errorf("soundcloud: couldn't unmarshal response body (%T): %w", out, err)
+		}
+	}
+	return respBody, nil
+}
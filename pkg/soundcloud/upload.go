@@ -0,0 +1,120 @@
+package soundcloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Track struct {
+	Title       string
+	Description string
+	Tags        []string
+	File        string
+	Cover       string
+}
+
+func (t *Track) Validate() error {
+	if t.Title == "" {
+		return errors.New("soundcloud: missing title")
+	}
+	if t.File == "" {
+		return errors.New("soundcloud: missing file")
+	}
+	return nil
+}
+
+type trackResponse struct {
+	ID           int    `json:"id"`
+	Permalink    string `json:"permalink"`
+	PermalinkURL string `json:"permalink_url"`
+}
+
+// Upload uploads a track and returns its permalink URL.
+func (c *Client) Upload(ctx context.Context, track *Track) (string, error) {
+	if err := track.Validate(); err != nil {
+		return "", err
+	}
+
+	f, err := newTrackForm(track)
+	if err != nil {
+		return "", err
+	}
+	var resp trackResponse
+	if _, err := c.do(ctx, "POST", "tracks", f, &resp); err != nil {
+		return "", fmt.Errorf("soundcloud: couldn't upload track %s: %w", track.Title, err)
+	}
+	if resp.PermalinkURL == "" {
+		return "", fmt.Errorf("soundcloud: empty permalink for track %s (%d)", track.Title, resp.ID)
+	}
+	return resp.PermalinkURL, nil
+}
+
+func newTrackForm(track *Track) (*form, error) {
+	data := &bytes.Buffer{}
+	writer := multipart.NewWriter(data)
+
+	fields := [][2]string{
+		{"track[title]", track.Title},
+		{"track[description]", track.Description},
+		{"track[tag_list]", tagList(track.Tags)},
+		{"track[sharing]", "public"},
+		{"track[downloadable]", "false"},
+	}
+	for _, kv := range fields {
+		if err := writer.WriteField(kv[0], kv[1]); err != nil {
+			return nil, fmt.Errorf("soundcloud: couldn't write field %s: %w", kv[0], err)
+		}
+	}
+	if err := writeFile(writer, "track[asset_data]", track.File); err != nil {
+		return nil, err
+	}
+	if track.Cover != "" {
+		if err := writeFile(writer, "track[artwork_data]", track.Cover); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("soundcloud: couldn't close writer: %w", err)
+	}
+	return &form{writer: writer, data: data}, nil
+}
+
+func writeFile(writer *multipart.Writer, field, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("soundcloud: couldn't open file: %w", err)
+	}
+	defer file.Close()
+	part, err := writer.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("soundcloud: couldn't create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("soundcloud: couldn't copy file: %w", err)
+	}
+	return nil
+}
+
+// tagList returns the tags separated by spaces, quoting the ones that
+// contain spaces as soundcloud expects.
+func tagList(tags []string) string {
+	var list []string
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ReplaceAll(t, `"`, ""))
+		if t == "" {
+			continue
+		}
+		if strings.Contains(t, " ") {
+			t = fmt.Sprintf("%q", t)
+		}
+		list = append(list, t)
+	}
+	return strings.Join(list, " ")
+}
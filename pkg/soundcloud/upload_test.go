@@ -0,0 +1,11 @@
+package soundcloud
+
+import "testing"
+
+func TestTagList(t *testing.T) {
+	got := tagList([]string{"jazz", " lo fi ", "", `smooth "jazz"`, "piano"})
+	want := `jazz "lo fi" "smooth jazz" piano`
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
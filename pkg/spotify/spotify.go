@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/zmb3/spotify/v2"
 )
@@ -25,9 +26,42 @@ func (c *Client) SearchTrack(ctx context.Context, query string) ([]string, error
 }
 
 type Track struct {
-	Name   string
-	ID     string
-	Number int
+	Name     string
+	ID       string
+	Number   int
+	Artists  []string
+	Duration time.Duration
+}
+
+// SearchTracks searches tracks using the spotify query syntax
+// (e.g. isrc:USRC17607839 or track:name artist:name).
+func (c *Client) SearchTracks(ctx context.Context, query string) ([]Track, error) {
+	if err := c.Auth(ctx); err != nil {
+		return nil, err
+	}
+	var resp spotify.SearchResult
+	u := "search?q=" + url.QueryEscape(query) + "&type=track"
+	if _, err := c.do(ctx, "GET", u, nil, &resp); err != nil {
+		return nil, fmt.Errorf("spotify: couldn't search tracks: %w", err)
+	}
+	if resp.Tracks == nil {
+		return nil, nil
+	}
+	var tracks []Track
+	for _, t := range resp.Tracks.Tracks {
+		var artists []string
+		for _, a := range t.Artists {
+			artists = append(artists, a.Name)
+		}
+		tracks = append(tracks, Track{
+			Name:     t.Name,
+			ID:       t.ID.String(),
+			Number:   t.TrackNumber,
+			Artists:  artists,
+			Duration: time.Duration(t.Duration) * time.Millisecond,
+		})
+	}
+	return tracks, nil
 }
 
 func (c *Client) AlbumTracks(ctx context.Context, id string) ([]Track, error) {
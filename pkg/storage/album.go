@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -31,8 +32,14 @@ type Album struct {
 	AppleID     string `gorm:"not null;default:''"`
 	JamendoID   string `gorm:"not null;default:''"`
 	JamendoAt   time.Time
+	BandcampID  string `gorm:"not null;default:''"`
+	BandcampAt  time.Time
 	PublishedAt time.Time
 
+	// Targets is the comma separated list of platforms the album is
+	// published to. If empty, each publish command decides.
+	Targets string `gorm:"not null;default:''"`
+
 	State State `gorm:"index"`
 }
 
@@ -47,6 +54,26 @@ func (a *Album) FullTitle() string {
 	return title
 }
 
+// GenreTags returns the album genres and subgenres as lowercase tags.
+func (a *Album) GenreTags() []string {
+	var tags []string
+	lookup := map[string]struct{}{}
+	for _, g := range []string{a.PrimaryGenre, a.SecondaryGenre} {
+		for _, t := range strings.Split(g, ":") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t == "" {
+				continue
+			}
+			if _, ok := lookup[t]; ok {
+				continue
+			}
+			lookup[t] = struct{}{}
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
 func (s *Store) GetAlbum(ctx context.Context, id string) (*Album, error) {
 	var v Album
 	if err := s.db.First(&v, "id = ?", id).Error; err != nil {
@@ -76,6 +103,11 @@ func (s *Store) DeleteAlbum(ctx context.Context, id string) error {
 }
 
 func (s *Store) ListAlbums(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Album, error) {
+	filter = append(filter, Where("state != ?", Rejected))
+	return s.ListAllAlbums(ctx, page, size, orderBy, filter...)
+}
+
+func (s *Store) ListAllAlbums(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Album, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -83,7 +115,6 @@ func (s *Store) ListAlbums(ctx context.Context, page, size int, orderBy string,
 	vs := []*Album{}
 
 	q := s.db.Offset(offset).Limit(size)
-	q = q.Where("state != ?", Rejected)
 	for _, f := range filter {
 		q = q.Where(f.Query, f.Args...)
 	}
@@ -97,6 +128,29 @@ func (s *Store) ListAlbums(ctx context.Context, page, size int, orderBy string,
 	return vs, nil
 }
 
+func (s *Store) CountAlbums(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Album{})
+	q = q.Where("state != ?", Rejected)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count albums: %w", err)
+	}
+	return n, nil
+}
+
+// ListArtists returns the distinct artists of the albums.
+func (s *Store) ListArtists(ctx context.Context) ([]string, error) {
+	var artists []string
+	q := s.db.Model(&Album{}).Where("state != ? AND artist != ''", Rejected)
+	if err := q.Distinct("artist").Order("artist").Pluck("artist", &artists).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list artists: %w", err)
+	}
+	return artists, nil
+}
+
 func (s *Store) NextAlbum(ctx context.Context, filter ...Filter) (*Album, error) {
 	var v Album
 	q := s.db.Where("state != ?", Rejected)
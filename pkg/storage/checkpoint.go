@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Checkpoint persists the last item of a batch processed in ID order, so a
+// restarted batch resumes right after it.
+// Items can finish out of order when they are processed concurrently, so the
+// checkpoint only advances when all the previous items have finished.
+// Failed items don't block the checkpoint, so the items done after them aren't
+// attempted again when resuming. They are retried by the next batch, which
+// starts from the beginning once the checkpoint is removed by Finish.
+// Items interrupted by a context cancellation aren't finished, so they block
+// the checkpoint and are attempted again when resuming.
+// It is safe for concurrent use.
+type Checkpoint struct {
+	store *Store
+	id    string
+
+	lck   sync.Mutex
+	items []*checkpointItem
+}
+
+type checkpointItem struct {
+	id   string
+	done bool
+}
+
+// NewCheckpoint returns the checkpoint of the service batch with the given
+// name, which is "all" if empty.
+func (s *Store) NewCheckpoint(service, name string) *Checkpoint {
+	if name == "" {
+		name = "all"
+	}
+	return &Checkpoint{
+		store: s,
+		id:    fmt.Sprintf("%s/%s/checkpoint", service, name),
+	}
+}
+
+// Get returns the last item ID stored or empty if there is none.
+func (c *Checkpoint) Get(ctx context.Context) (string, error) {
+	setting, err := c.store.GetSetting(ctx, c.id)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// Start registers an item, items must be started in ID order.
+func (c *Checkpoint) Start(id string) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.items = append(c.items, &checkpointItem{id: id})
+}
+
+// Done marks the item as finished, successfully or not, and stores the new
+// checkpoint if all the items started before it have finished.
+// Items whose error is a context cancellation aren't marked as finished.
+func (c *Checkpoint) Done(ctx context.Context, id string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	for _, item := range c.items {
+		if item.id == id && !item.done {
+			item.done = true
+			break
+		}
+	}
+
+	// Advance over the finished items
+	var last string
+	for len(c.items) > 0 && c.items[0].done {
+		last = c.items[0].id
+		c.items = c.items[1:]
+	}
+	if last == "" {
+		return nil
+	}
+	if err := c.store.SetSetting(ctx, &Setting{ID: c.id, Value: last}); err != nil {
+		return fmt.Errorf("storage: couldn't set checkpoint %s: %w", c.id, err)
+	}
+	return nil
+}
+
+// Finish removes the checkpoint if all the items have finished, so the next
+// batch starts from the beginning and retries the failed items.
+// It must be called once the batch has processed all the items.
+func (c *Checkpoint) Finish(ctx context.Context) error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	if len(c.items) > 0 {
+		return nil
+	}
+	if err := c.store.DeleteSetting(ctx, c.id); err != nil {
+		return fmt.Errorf("storage: couldn't delete checkpoint %s: %w", c.id, err)
+	}
+	return nil
+}
@@ -21,14 +21,20 @@ type Cover struct {
 	Template string `gorm:"not null;default:''"`
 	DsURL    string `gorm:"not null;default:''"`
 	MjURL    string `gorm:"not null;default:''"`
+	Variant  int    `gorm:"not null;default:0"`
+	// Stored is set when the original image is stored in the file storage as
+	// a PNG, because the URL of the provider expires.
+	Stored bool `gorm:"not null;default:false"`
 
 	DraftID string `gorm:"not null;default:''"`
 
 	State State `gorm:"not null;default:0"`
 	Likes int   `gorm:"not null;default:0"`
 
-	UpscaleAt time.Time
-	Upscaled  bool `gorm:"not null;default:false"`
+	UpscaleAt       time.Time
+	Upscaled        bool `gorm:"not null;default:false"`
+	UpscaleAttempts int  `gorm:"not null;default:0"`
+	UpscaleFailed   bool `gorm:"not null;default:false"`
 }
 
 func (c *Cover) URL() string {
@@ -93,6 +99,28 @@ func (s *Store) SetCover(ctx context.Context, v *Cover) error {
 	return nil
 }
 
+// UpdateCovers updates the covers matching the filters with a single update
+// statement and returns the number of covers updated.
+func (s *Store) UpdateCovers(ctx context.Context, values map[string]any, filter ...Filter) (int64, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Model(&Cover{})
+		for _, f := range filter {
+			q = q.Where(f.Query, f.Args...)
+		}
+		res := q.Updates(values)
+		if res.Error != nil {
+			return res.Error
+		}
+		n = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("storage: failed to update covers: %w", err)
+	}
+	return n, nil
+}
+
 func (s *Store) DeleteCover(ctx context.Context, id string) error {
 	if err := s.db.Delete(&Cover{ID: id}, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -108,6 +136,33 @@ func (s *Store) ListCovers(ctx context.Context, page, size int, orderBy string,
 	return s.ListAllCovers(ctx, page, size, orderBy, filter...)
 }
 
+func (s *Store) CountCovers(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Cover{})
+	q = q.Where("state != ?", Rejected)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count covers: %w", err)
+	}
+	return n, nil
+}
+
+// CountAllCovers counts the covers matching the filters, including the
+// rejected ones like ListAllCovers.
+func (s *Store) CountAllCovers(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Cover{})
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count covers: %w", err)
+	}
+	return n, nil
+}
+
 func (s *Store) ListAllCovers(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Cover, error) {
 	if page < 1 {
 		page = 1
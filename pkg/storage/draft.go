@@ -54,6 +54,11 @@ func (s *Store) DeleteDraft(ctx context.Context, id string) error {
 }
 
 func (s *Store) ListDrafts(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Draft, error) {
+	filter = append(filter, Where("state != ?", Rejected))
+	return s.ListAllDrafts(ctx, page, size, orderBy, filter...)
+}
+
+func (s *Store) ListAllDrafts(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Draft, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -61,7 +66,6 @@ func (s *Store) ListDrafts(ctx context.Context, page, size int, orderBy string,
 	vs := []*Draft{}
 
 	q := s.db.Offset(offset).Limit(size)
-	q = q.Where("state != ?", Rejected)
 	for _, f := range filter {
 		q = q.Where(f.Query, f.Args...)
 	}
@@ -75,6 +79,19 @@ func (s *Store) ListDrafts(ctx context.Context, page, size int, orderBy string,
 	return vs, nil
 }
 
+func (s *Store) CountDrafts(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Draft{})
+	q = q.Where("state != ?", Rejected)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count drafts: %w", err)
+	}
+	return n, nil
+}
+
 func (s *Store) NextDraft(ctx context.Context, filter ...Filter) (*Draft, error) {
 	var v Draft
 	q := s.db.Where("state != ?", Rejected)
@@ -130,6 +147,44 @@ func (s *Store) ListDraftCovers(ctx context.Context, min, page, size int, orderB
 	return vs, nil
 }
 
+// ListDraftCoverStatus returns approved drafts with the number of approved and
+// upscaled covers that match their title.
+func (s *Store) ListDraftCoverStatus(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*DraftCovers, error) {
+	vs := []*DraftCovers{}
+
+	// Getting DB column names
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(&Draft{}); err != nil {
+		return nil, fmt.Errorf("storage: couldn't parse draft: %w", err)
+	}
+	columns := []string{}
+	for _, dbField := range stmt.Schema.DBNames {
+		columns = append(columns, fmt.Sprintf("drafts.%s", dbField))
+	}
+
+	q := s.db.Model(&Draft{}).Select(strings.Join(append(columns, "count(covers.id) as covers"), ",")).
+		Joins("LEFT JOIN covers on drafts.title = covers.title AND covers.state = ? AND covers.upscaled = ?", Approved, true).
+		Where("drafts.state = ?", Approved).
+		Group(strings.Join(columns, ","))
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	// Order by
+	if orderBy != "" {
+		q = q.Order(orderBy)
+	}
+	// Paginate
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * size
+	q = q.Offset(offset).Limit(size)
+	if err := q.Scan(&vs).Error; err != nil {
+		return nil, fmt.Errorf("storage: couldn't list draft cover status: %w", err)
+	}
+	return vs, nil
+}
+
 func (s *Store) NextDraftCandidate(ctx context.Context, min int, orderBy string, filter ...Filter) (*Draft, error) {
 	var v Draft
 	q := s.db.Where("state != ?", Rejected)
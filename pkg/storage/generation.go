@@ -26,7 +26,18 @@ type Generation struct {
 
 	Duration float32 `gorm:"not null;default:0"`
 	Tempo    float32 `gorm:"not null;default:0"`
+	LUFS     float32 `gorm:"not null;default:0"`
 	Flags    string  `gorm:"not null;default:''"`
+	// Format of the lossless audio stored along with the mp3 (flac or wav).
+	// Empty if only the mp3 is stored.
+	Format string `gorm:"not null;default:''"`
+	// Fingerprint is the audio fingerprint used to detect duplicates.
+	Fingerprint string `gorm:"not null;default:''"`
+	// Key is the musical key (e.g. "A minor"), empty if it is ambiguous.
+	Key string `gorm:"not null;default:''"`
+	// FadeIn is the duration in seconds of the fade in applied when the
+	// generation was processed.
+	FadeIn float32 `gorm:"not null;default:0"`
 
 	ProcessedAt time.Time
 	Processed   bool `gorm:"index"`
@@ -93,6 +104,43 @@ func (s *Store) ListGenerations(ctx context.Context, page, size int, orderBy str
 	return vs, nil
 }
 
+// ListGenerationRows lists the generations without loading their songs.
+// Generations without a song are included, unlike ListGenerations.
+func (s *Store) ListGenerationRows(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Generation, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * size
+	vs := []*Generation{}
+
+	q := s.db.Joins("LEFT JOIN songs ON songs.id = generations.song_id")
+	q = q.Offset(offset).Limit(size)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	// Order by
+	if orderBy != "" {
+		q = q.Order(orderBy)
+	}
+	if err := q.Find(&vs).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list generation rows: %w", err)
+	}
+	return vs, nil
+}
+
+func (s *Store) CountGenerations(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Generation{})
+	q = q.Joins("INNER JOIN songs ON songs.id = generations.song_id")
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count generations: %w", err)
+	}
+	return n, nil
+}
+
 func (s *Store) NextGeneration(ctx context.Context, filter ...Filter) (*Generation, error) {
 	var v Generation
 
@@ -111,3 +159,27 @@ func (s *Store) NextGeneration(ctx context.Context, filter ...Filter) (*Generati
 	}
 	return &v, nil
 }
+
+// UpdateGenerationSongs updates the songs of the generations matching the
+// filters with a single update statement and returns the number of songs
+// updated.
+func (s *Store) UpdateGenerationSongs(ctx context.Context, values map[string]any, filter ...Filter) (int64, error) {
+	sub := s.db.Model(&Generation{}).Select("generations.song_id")
+	sub = sub.Joins("INNER JOIN songs ON songs.id = generations.song_id")
+	for _, f := range filter {
+		sub = sub.Where(f.Query, f.Args...)
+	}
+	var n int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Song{}).Where("id IN (?)", sub).Updates(values)
+		if res.Error != nil {
+			return res.Error
+		}
+		n = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("storage: failed to update generation songs: %w", err)
+	}
+	return n, nil
+}
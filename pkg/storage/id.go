@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Strategies to generate the IDs of new items
+const (
+	// IDULID generates sortable ULIDs (default)
+	IDULID = "ulid"
+	// IDNanoID generates short IDs sorted by creation time
+	IDNanoID = "nanoid"
+	// IDExternal uses IDs supplied by an external source
+	IDExternal = "external"
+)
+
+// NewID returns a new ULID, the default ID of the items.
+func NewID() string {
+	return ulid.Make().String()
+}
+
+// IDGenerator generates the IDs of new items using a strategy.
+type IDGenerator struct {
+	strategy string
+}
+
+// NewIDGenerator creates a new ID generator, an empty strategy means ULID.
+func NewIDGenerator(strategy string) (*IDGenerator, error) {
+	switch strategy {
+	case "":
+		strategy = IDULID
+	case IDULID, IDNanoID, IDExternal:
+	default:
+		return nil, fmt.Errorf("storage: unknown id strategy %q", strategy)
+	}
+	return &IDGenerator{strategy: strategy}, nil
+}
+
+// NewID returns a new ID.
+// The external ID is only used by the external strategy, where it is required.
+func (g *IDGenerator) NewID(external string) (string, error) {
+	switch g.strategy {
+	case IDNanoID:
+		return nanoID(time.Now(), nanoIDRandom)
+	case IDExternal:
+		if external == "" {
+			return "", errors.New("storage: external id is required")
+		}
+		if err := ValidateID(external); err != nil {
+			return "", err
+		}
+		return external, nil
+	default:
+		return NewID(), nil
+	}
+}
+
+// maxIDLength is the maximum length of an ID, so it can be indexed by all
+// the supported databases.
+const maxIDLength = 64
+
+// ValidateID checks that the ID can be stored in the database and used in
+// file names.
+func ValidateID(id string) error {
+	if id == "" {
+		return errors.New("storage: empty id")
+	}
+	if len(id) > maxIDLength {
+		return fmt.Errorf("storage: id %q is longer than %d characters", id, maxIDLength)
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return fmt.Errorf("storage: id %q contains invalid character %q", id, r)
+		}
+	}
+	return nil
+}
+
+const (
+	// nanoIDRandom is the number of random characters of a nanoid, the
+	// IDs are nanoIDRandom plus the ULID timestamp characters long.
+	nanoIDRandom   = 8
+	nanoIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_"
+)
+
+// nanoID returns a short ID made of the ULID timestamp followed by n random
+// characters of the nanoid alphabet.
+// The timestamp prefix sorts the IDs by creation time like ULIDs, because the
+// scans paginated by ID, the checkpoints and the since filters rely on it.
+// The alphabet has 64 characters so each random byte is masked without bias.
+func nanoID(t time.Time, n int) (string, error) {
+	var u ulid.ULID
+	if err := u.SetTime(ulid.Timestamp(t)); err != nil {
+		return "", fmt.Errorf("storage: couldn't set id time: %w", err)
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("storage: couldn't generate random id: %w", err)
+	}
+	for i := range b {
+		b[i] = nanoIDAlphabet[b[i]&63]
+	}
+	return u.String()[:ulidTimeLength] + string(b), nil
+}
+
+// ulidTimeLength is the number of characters of the ULID timestamp.
+const ulidTimeLength = 10
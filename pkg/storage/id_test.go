@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestIDGenerator(t *testing.T) {
+	if _, err := NewIDGenerator("uuid"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+
+	g, err := NewIDGenerator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := g.NewID("external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 26 {
+		t.Errorf("expected ulid, got %q", id)
+	}
+
+	g, err = NewIDGenerator(IDNanoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id, err := g.NewID("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != ulidTimeLength+nanoIDRandom {
+			t.Fatalf("expected %d characters, got %q", ulidTimeLength+nanoIDRandom, id)
+		}
+		if err := ValidateID(id); err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicated id %q", id)
+		}
+		seen[id] = true
+	}
+
+	g, err = NewIDGenerator(IDExternal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := g.NewID("cat-001"); err != nil || id != "cat-001" {
+		t.Errorf("expected external id, got %q %v", id, err)
+	}
+	for _, external := range []string{"", "a b", "a'b", strings.Repeat("a", maxIDLength+1)} {
+		if _, err := g.NewID(external); err == nil {
+			t.Errorf("expected error for external id %q", external)
+		}
+	}
+}
+
+func TestNanoIDOrder(t *testing.T) {
+	now := time.Now()
+	prev := ulid.MustNew(ulid.Timestamp(now.Add(-time.Millisecond)), nil).String()
+	for i := 0; i < 100; i++ {
+		id, err := nanoID(now.Add(time.Duration(i)*time.Millisecond), nanoIDRandom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Nanoids sort after the older ids of both strategies
+		if id <= prev {
+			t.Fatalf("%q isn't sorted after %q", id, prev)
+		}
+		prev = id
+	}
+}
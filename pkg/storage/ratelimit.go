@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetRateLimit returns the wait time between requests configured for the
+// service account, or the default value if it isn't set.
+// Non-positive values are rejected, as they would disable the rate limit.
+func (s *Store) GetRateLimit(ctx context.Context, service, account string, def time.Duration) (time.Duration, error) {
+	id := fmt.Sprintf("%s/%s/ratelimit", service, account)
+	setting, err := s.GetSetting(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return def, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(setting.Value)
+	if err != nil {
+		return 0, fmt.Errorf("storage: invalid rate limit %s %q: %w", id, setting.Value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("storage: rate limit %s must be positive: %s", id, setting.Value)
+	}
+	return d, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -40,6 +41,53 @@ func (s *Store) SetSetting(ctx context.Context, v *Setting) error {
 	return nil
 }
 
+// GetCounter returns the current value of a numeric setting, zero if it
+// doesn't exist.
+func (s *Store) GetCounter(ctx context.Context, id string) (int, error) {
+	v, err := s.GetSetting(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(v.Value)
+	if err != nil {
+		return 0, fmt.Errorf("storage: invalid counter %s value %q: %w", id, v.Value, err)
+	}
+	return n, nil
+}
+
+// ReserveCounter increments a numeric setting by n and returns the first
+// value of the reserved range, counters start at 1.
+// The update only succeeds if the value hasn't changed since it was read, so
+// concurrent reservations never get overlapping ranges.
+func (s *Store) ReserveCounter(ctx context.Context, id string, n int) (int, error) {
+	if n < 1 {
+		return 0, fmt.Errorf("storage: invalid counter %s reservation %d", id, n)
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Setting{ID: id, Value: "0"}).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to create counter %s: %w", id, err)
+	}
+	for i := 0; i < 100; i++ {
+		curr, err := s.GetCounter(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		res := s.db.Model(&Setting{}).Where("id = ? AND value = ?", id, strconv.Itoa(curr)).Updates(map[string]any{
+			"value":      strconv.Itoa(curr + n),
+			"updated_at": time.Now().UTC(),
+		})
+		if res.Error != nil {
+			return 0, fmt.Errorf("storage: failed to reserve counter %s: %w", id, res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return curr + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("storage: couldn't reserve counter %s, too many concurrent updates", id)
+}
+
 func (s *Store) DeleteSetting(ctx context.Context, id string) error {
 	if err := s.db.Delete(&Setting{ID: id}, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,12 +21,27 @@ const (
 	Used     State = 3
 )
 
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Rejected:
+		return "rejected"
+	case Approved:
+		return "approved"
+	case Used:
+		return "used"
+	default:
+		return fmt.Sprintf("state%d", int(s))
+	}
+}
+
 type Song struct {
 	ID        string `gorm:"primarykey"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
-	Type  string `gorm:"not null;default:''"`
+	Type  string `gorm:"index;not null;default:''"`
 	Notes string `gorm:"not null;default:''"`
 
 	Prompt       string `gorm:"not null;default:''"`
@@ -35,29 +51,59 @@ type Song struct {
 
 	GenerationID *string
 	Generation   *Generation `gorm:"foreignKey:GenerationID"`
+	// Selected is true if the generation was chosen by a user, so it isn't
+	// replaced by the automatic selection.
+	Selected bool `gorm:"not null;default:false"`
 
 	Provider string `gorm:"not null;default:''"`
 	Account  string `gorm:"not null;default:''"`
 
 	Title           string `gorm:"not null;default:''"`
 	AlbumID         string `gorm:"index,not null;default:''"`
+	CoverID         string `gorm:"not null;default:''"`
 	Order           int    `gorm:"not null;default:0"`
 	ISRC            string `gorm:"not null;default:''"`
 	YoutubeID       string `gorm:"not null;default:''"`
 	SpotifyID       string `gorm:"not null;default:''"`
 	SpotifyAnalysis string `gorm:"not null;default:''"`
 	JamendoID       string `gorm:"not null;default:''"`
+	SoundCloudID    string `gorm:"not null;default:''"`
 	Disabled        bool   `gorm:"not null;default:false"`
 
 	Classification string `gorm:"not null;default:''"`
 	Classified     bool   `gorm:"not null;default:false"`
 	Description    string `gorm:"not null;default:''"`
 	Described      bool   `gorm:"not null;default:false"`
+	// ClassificationProvider is the provider that generated the
+	// classification (e.g. sonoteller or local).
+	ClassificationProvider string `gorm:"not null;default:''"`
+
+	// Credits are the track liner notes as "role: name" entries separated by
+	// new lines or semicolons (e.g. "Songwriter: Jane Doe; Mixing: John Doe").
+	Credits string `gorm:"not null;default:''"`
 
 	Likes int   `gorm:"not null;default:0"`
 	State State `gorm:"not null;default:0"`
 }
 
+// Credit returns the name credited for the role, case insensitive, or an
+// empty string if the role isn't in the credits.
+func (s *Song) Credit(role string) string {
+	entries := strings.FieldsFunc(s.Credits, func(r rune) bool {
+		return r == '\n' || r == ';'
+	})
+	for _, e := range entries {
+		k, v, ok := strings.Cut(e, ":")
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(k), role) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
 func (s *Store) GetSong(ctx context.Context, id string) (*Song, error) {
 	// Process song
 	q := s.db.Preload("Generation")
@@ -79,6 +125,44 @@ func (s *Store) SetSong(ctx context.Context, v *Song) error {
 	return nil
 }
 
+// ErrInvalidOrder is returned when the songs to reorder don't match the songs
+// of the album.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ReorderAlbumSongs sets the order of the songs of the album to the order of
+// the ids, starting at 1. The ids must be exactly the songs of the album.
+func (s *Store) ReorderAlbumSongs(ctx context.Context, albumID string, ids []string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current []string
+		if err := tx.Model(&Song{}).Where("album_id = ?", albumID).Pluck("id", &current).Error; err != nil {
+			return err
+		}
+		if len(ids) != len(current) {
+			return fmt.Errorf("%w: got %d songs, album has %d", ErrInvalidOrder, len(ids), len(current))
+		}
+		pending := map[string]bool{}
+		for _, id := range current {
+			pending[id] = true
+		}
+		for _, id := range ids {
+			if !pending[id] {
+				return fmt.Errorf("%w: song %s isn't in the album or is repeated", ErrInvalidOrder, id)
+			}
+			delete(pending, id)
+		}
+		for i, id := range ids {
+			if err := tx.Model(&Song{}).Where("id = ?", id).Update("order", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("storage: failed to reorder songs of album %s: %w", albumID, err)
+	}
+	return nil
+}
+
 func (s *Store) DeleteSong(ctx context.Context, id string) error {
 	if err := s.db.Delete(&Song{ID: id}, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -94,6 +178,20 @@ func (s *Store) ListSongs(ctx context.Context, page, size int, orderBy string, f
 	return s.ListAllSongs(ctx, page, size, orderBy, filter...)
 }
 
+func (s *Store) CountSongs(ctx context.Context, filter ...Filter) (int64, error) {
+	var n int64
+	q := s.db.Model(&Song{})
+	q = q.Joins("INNER JOIN generations ON songs.generation_id = generations.id")
+	q = q.Where("state != ?", Rejected)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	if err := q.Count(&n).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to count songs: %w", err)
+	}
+	return n, nil
+}
+
 func (s *Store) ListAllSongs(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Song, error) {
 	if page < 1 {
 		page = 1
@@ -119,6 +217,30 @@ func (s *Store) ListAllSongs(ctx context.Context, page, size int, orderBy string
 	return vs, nil
 }
 
+// ListSongRows lists the songs without loading their generations. Songs
+// without a generation are included, unlike ListAllSongs.
+func (s *Store) ListSongRows(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Song, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * size
+	vs := []*Song{}
+
+	q := s.db.Joins("LEFT JOIN generations ON songs.generation_id = generations.id")
+	q = q.Offset(offset).Limit(size)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	// Order by
+	if orderBy != "" {
+		q = q.Order(orderBy)
+	}
+	if err := q.Find(&vs).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list song rows: %w", err)
+	}
+	return vs, nil
+}
+
 func (s *Store) NextSong(ctx context.Context, filter ...Filter) (*Song, error) {
 	var v Song
 
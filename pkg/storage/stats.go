@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StateCount is the number of rows with a state and the values of the
+// grouping columns.
+type StateCount struct {
+	State  State
+	Values []string
+	Count  int64
+}
+
+var columnRegex = regexp.MustCompile(`^[a-z_]+$`)
+
+// CountByState counts the rows of the table grouped by state and the given
+// columns. The values of the columns are returned in the same order.
+func (s *Store) CountByState(ctx context.Context, table string, groupBy ...string) ([]*StateCount, error) {
+	cols := append([]string{"state"}, groupBy...)
+	for _, c := range append([]string{table}, cols...) {
+		if !columnRegex.MatchString(c) {
+			return nil, fmt.Errorf("storage: invalid identifier %q", c)
+		}
+	}
+	group := strings.Join(cols, ", ")
+	rows, err := s.db.WithContext(ctx).Table(table).Select(group + ", COUNT(*)").Group(group).Order(group).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to count %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var counts []*StateCount
+	for rows.Next() {
+		var state sql.NullInt64
+		values := make([]sql.NullString, len(groupBy))
+		var n int64
+		dest := []any{&state}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &n)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("storage: failed to scan %s count: %w", table, err)
+		}
+		c := &StateCount{
+			State: State(state.Int64),
+			Count: n,
+		}
+		for _, v := range values {
+			c.Values = append(c.Values, v.String)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("storage: failed to count %s: %w", table, err)
+	}
+	return counts, nil
+}
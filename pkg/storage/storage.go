@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/glebarez/sqlite"
-	"github.com/oklog/ulid/v2"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -76,6 +76,21 @@ func (s *Store) Start(ctx context.Context) error {
 	return nil
 }
 
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return errors.New("storage: database not started")
+	}
+	db, err := s.db.DB()
+	if err != nil {
+		return fmt.Errorf("storage: couldn't get database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("storage: couldn't ping database: %w", err)
+	}
+	return nil
+}
+
 type seed struct {
 	ID string `gorm:"primaryKey"`
 }
@@ -113,12 +128,14 @@ func (s *Store) Migrate(ctx context.Context) error {
 		&Album{},
 		&Setting{},
 		&File{},
+		&YoutubeMatch{},
+		&SongTag{},
 	); err != nil {
 		return fmt.Errorf("storage: failed to migrate database: %w", err)
 	}
 
 	// Custom post migrations
-	if err := s.postMigrate(m.Version); err != nil {
+	if err := s.postMigrate(ctx, m.Version); err != nil {
 		return err
 	}
 
@@ -130,7 +147,7 @@ func (s *Store) Migrate(ctx context.Context) error {
 	return nil
 }
 
-const lastVersion = 2
+const lastVersion = 3
 
 func (s *Store) currentMigration(init bool) (*Migration, error) {
 	var migration Migration
@@ -138,7 +155,7 @@ func (s *Store) currentMigration(init bool) (*Migration, error) {
 		if err := s.db.Migrator().CreateTable(&Migration{}); err != nil {
 			return nil, fmt.Errorf("storage: failed to create table migrations: %w", err)
 		}
-		migration.ID = ulid.Make().String()
+		migration.ID = NewID()
 		if init {
 			migration.Version = lastVersion
 		}
@@ -178,14 +195,14 @@ func (s *Store) preMigrate(version int) error {
 			if err := s.db.Migrator().RenameColumn(&Generation{}, "suno_history", "history"); err != nil {
 				return fmt.Errorf("storage: migration %d: %w", i, err)
 			}
-		case 3:
+		case 4:
 			// TODO: Next migration here and update lastVersion
 		}
 	}
 	return nil
 }
 
-func (s *Store) postMigrate(version int) error {
+func (s *Store) postMigrate(ctx context.Context, version int) error {
 	// Custom migrations
 	for i := version + 1; i <= lastVersion; i++ {
 		switch i {
@@ -206,6 +223,14 @@ func (s *Store) postMigrate(version int) error {
 				return fmt.Errorf("storage: migration %d: %w", i, err)
 			}
 		case 3:
+			// Convert inline hashtags of the notes to song tags
+			log.Println("storage: migration 3: convert note hashtags to song tags")
+			n, err := s.migrateNoteTags(ctx)
+			if err != nil {
+				return fmt.Errorf("storage: migration %d: %w", i, err)
+			}
+			log.Printf("storage: migration 3: %d tags added\n", n)
+		case 4:
 			// TODO: Next migration here and update lastVersion
 		}
 	}
@@ -223,3 +248,21 @@ func Where(query interface{}, args ...interface{}) Filter {
 		Args:  args,
 	}
 }
+
+// likeEscaper escapes LIKE wildcards using '!' as escape character, which
+// doesn't need quoting in any of the supported databases.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Like returns a filter that matches the column against the given value.
+// The value is passed as a bound parameter and its LIKE wildcards are escaped,
+// so it is matched literally.
+func Like(column, value string) Filter {
+	return Where(fmt.Sprintf("%s LIKE ? ESCAPE '!'", column), likeEscaper.Replace(value))
+}
+
+// Contains returns a filter that matches the column if it contains the given
+// value.
+// The value is escaped in the same way as in Like.
+func Contains(column, value string) Filter {
+	return Where(fmt.Sprintf("%s LIKE ? ESCAPE '!'", column), "%"+likeEscaper.Replace(value)+"%")
+}
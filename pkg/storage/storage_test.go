@@ -0,0 +1,494 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLike(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	titles := []string{"rock", "rock'n'roll", "100% jazz", "100 jazz", "a_b", "axb", "a!b", ""}
+	for i, title := range titles {
+		if err := store.SetAlbum(ctx, &Album{
+			ID:    string(rune('a' + i)),
+			Title: title,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"literal", Like("title", "rock"), []string{"rock"}},
+		{"quote", Like("title", "rock'n'roll"), []string{"rock'n'roll"}},
+		{"injection", Like("title", "' OR '1'='1"), nil},
+		{"percent", Like("title", "100%"), nil},
+		{"percent contains", Contains("title", "100%"), []string{"100% jazz"}},
+		{"underscore", Like("title", "a_b"), []string{"a_b"}},
+		{"escape char", Like("title", "a!b"), []string{"a!b"}},
+		{"empty", Like("title", ""), []string{""}},
+		{"contains", Contains("title", "jazz"), []string{"100 jazz", "100% jazz"}},
+		{"contains quote", Contains("title", "'n'"), []string{"rock'n'roll"}},
+		{"contains empty", Contains("title", ""), titles},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			albums, err := store.ListAllAlbums(ctx, 1, 100, "", tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range albums {
+				got = append(got, a.Title)
+			}
+			want := append([]string{}, tt.want...)
+			sort.Strings(got)
+			sort.Strings(want)
+			if len(got) != len(want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSongTags(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		id, gid := id, id+"g"
+		if err := store.SetSong(ctx, &Song{ID: id, GenerationID: &gid}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetGeneration(ctx, &Generation{ID: gid, SongID: &id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tag := range []string{"Calm Piano", "calm-piano", "sleep"} {
+		if err := store.AddSongTag(ctx, "a", tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.AddSongTag(ctx, "b", "sleep"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddSongTag(ctx, "b", " "); err == nil {
+		t.Fatal("expected error for empty tag")
+	}
+	if err := store.RemoveSongTag(ctx, "b", "sleep"); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := store.ListSongTags(ctx, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tags["a"], ","); got != "calm-piano,sleep" {
+		t.Errorf("got tags %q for a", got)
+	}
+	if len(tags["b"]) != 0 {
+		t.Errorf("got tags %q for b", tags["b"])
+	}
+
+	songs, err := store.ListSongs(ctx, 1, 100, "", HasTag("Calm piano"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].ID != "a" {
+		t.Errorf("got %d songs with tag", len(songs))
+	}
+
+	if got := noteTags("nice intro #Calm and #lo-fi_2, not a#tag?"); strings.Join(got, ",") != "calm,lo-fi_2" {
+		t.Errorf("got note tags %q", got)
+	}
+}
+
+func TestSongCredit(t *testing.T) {
+	s := &Song{Credits: "Songwriter: Jane  Doe; mixing:John Doe\nNotes without role\nMastering: "}
+	tests := []struct {
+		role string
+		want string
+	}{
+		{"songwriter", "Jane  Doe"},
+		{"Mixing", "John Doe"},
+		{"mastering", ""},
+		{"producer", ""},
+	}
+	for _, tt := range tests {
+		if got := s.Credit(tt.role); got != tt.want {
+			t.Errorf("Credit(%q) = %q, want %q", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestCountByState(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	albums := []*Album{
+		{ID: "a", Type: "jazz", State: Approved},
+		{ID: "b", Type: "jazz", State: Approved},
+		{ID: "c", Type: "jazz", State: Pending},
+		{ID: "d", Type: "rock", State: Used},
+	}
+	for _, a := range albums {
+		if err := store.SetAlbum(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts, err := store.CountByState(ctx, "albums", "type")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range counts {
+		got = append(got, fmt.Sprintf("%s/%s/%d", c.State, strings.Join(c.Values, ","), c.Count))
+	}
+	if want := "pending/jazz/1 approved/jazz/2 used/rock/1"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	if _, err := store.CountByState(ctx, "albums; DROP TABLE albums", "type"); err == nil {
+		t.Error("expected error with invalid table")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	got, err := ParseTargets(" Jamendo,distrokid,, jamendo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "distrokid,jamendo" {
+		t.Errorf("got targets %q", got)
+	}
+	if _, err := ParseTargets("distrokid,spotify"); err == nil {
+		t.Error("expected error with unknown target")
+	}
+
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	targets := map[string]string{
+		"a": "",
+		"b": "distrokid",
+		"c": "distrokid,jamendo",
+		"d": "jamendo,bandcamp",
+		"e": "bandcamp",
+	}
+	for id, v := range targets {
+		if err := store.SetAlbum(ctx, &Album{ID: id, Targets: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"has distrokid", HasTarget(TargetDistrokid), "b,c"},
+		{"has jamendo", HasTarget(TargetJamendo), "c,d"},
+		{"has soundcloud", HasTarget(TargetSoundCloud), ""},
+		{"allows jamendo", AllowsTarget(TargetJamendo), "a,c,d"},
+		{"allows bandcamp", AllowsTarget(TargetBandcamp), "a,d,e"},
+	}
+	for _, tt := range tests {
+		albums, err := store.ListAlbums(ctx, 1, 100, "id", tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, a := range albums {
+			ids = append(ids, a.ID)
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	get := func(c *Checkpoint) string {
+		t.Helper()
+		v, err := c.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	c := store.NewCheckpoint("publish-test", "")
+	for _, id := range []string{"a", "b", "c", "d"} {
+		c.Start(id)
+	}
+	// Out of order items don't advance the checkpoint
+	if err := c.Done(ctx, "b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c); got != "" {
+		t.Errorf("got checkpoint %q, want empty", got)
+	}
+	if err := c.Done(ctx, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c); got != "b" {
+		t.Errorf("got checkpoint %q, want b", got)
+	}
+	// Failed items don't block the checkpoint, so later successes are
+	// recorded
+	if err := c.Done(ctx, "c", errors.New("failed")); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c); got != "c" {
+		t.Errorf("got checkpoint %q, want c", got)
+	}
+	// Interrupted items block the checkpoint and are kept by finish
+	c.Start("e")
+	if err := c.Done(ctx, "e", fmt.Errorf("publish: %w", context.Canceled)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Done(ctx, "d", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c); got != "d" {
+		t.Errorf("got checkpoint %q, want d", got)
+	}
+	if err := c.Finish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(store.NewCheckpoint("publish-test", "all")); got != "d" {
+		t.Errorf("got checkpoint %q, want d", got)
+	}
+
+	// The checkpoint is removed once all the items are finished, even if
+	// some failed, so the next batch retries them
+	c = store.NewCheckpoint("publish-test", "")
+	c.Start("e")
+	c.Start("f")
+	if err := c.Done(ctx, "e", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Done(ctx, "f", errors.New("failed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Finish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(c); got != "" {
+		t.Errorf("got checkpoint %q after finish, want empty", got)
+	}
+}
+
+func TestGetRateLimit(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default is used if the setting isn't set
+	d, err := store.GetRateLimit(ctx, "suno", "a", 4*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 4*time.Second {
+		t.Errorf("got %s, want default", d)
+	}
+
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"6s", 6 * time.Second, false},
+		{"0s", 0, true},
+		{"-1s", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		if err := store.SetSetting(ctx, &Setting{ID: "suno/a/ratelimit", Value: tt.value}); err != nil {
+			t.Fatal(err)
+		}
+		d, err := store.GetRateLimit(ctx, "suno", "a", 4*time.Second)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.value, err)
+		}
+		if d != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.value, d, tt.want)
+		}
+	}
+}
+
+func TestReserveCounter(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent reservations get disjoint ranges
+	var wg sync.WaitGroup
+	var lck sync.Mutex
+	reserved := map[int]bool{}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first, err := store.ReserveCounter(ctx, "isrc/test/counter", 3)
+			if err != nil {
+				errs <- err
+				return
+			}
+			lck.Lock()
+			defer lck.Unlock()
+			for n := first; n < first+3; n++ {
+				if reserved[n] {
+					errs <- fmt.Errorf("value %d reserved twice", n)
+				}
+				reserved[n] = true
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(reserved) != 30 || !reserved[1] || !reserved[30] {
+		t.Errorf("expected values 1 to 30 reserved, got %v", reserved)
+	}
+	n, err := store.GetCounter(ctx, "isrc/test/counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 30 {
+		t.Errorf("expected counter 30, got %d", n)
+	}
+}
+
+func TestReorderAlbumSongs(t *testing.T) {
+	ctx := context.Background()
+	store, err := New("sqlite", filepath.Join(t.TempDir(), "test.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if err := store.SetSong(ctx, &Song{ID: id, AlbumID: "album", Order: i + 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SetSong(ctx, &Song{ID: "other", AlbumID: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	order := func() string {
+		t.Helper()
+		var songs []*Song
+		if err := store.db.Order("\"order\" asc").Find(&songs, "album_id = ?", "album").Error; err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range songs {
+			ids = append(ids, fmt.Sprintf("%s%d", s.ID, s.Order))
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if err := store.ReorderAlbumSongs(ctx, "album", []string{"c", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := order(), "c1,a2,b3"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// The ids must match the songs of the album
+	for _, ids := range [][]string{{"a", "b"}, {"a", "b", "b"}, {"a", "b", "other"}, {"a", "b", "c", "other"}} {
+		err := store.ReorderAlbumSongs(ctx, "album", ids)
+		if !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("%v: got %v, want invalid order", ids, err)
+		}
+	}
+	if got, want := order(), "c1,a2,b3"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SongTag is a tag assigned to a song, used to organize the catalog beyond
+// the song state.
+type SongTag struct {
+	SongID    string `gorm:"primarykey"`
+	Tag       string `gorm:"primarykey;index"`
+	CreatedAt time.Time
+}
+
+// NormalizeTag returns the tag in lower case with spaces replaced by dashes.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+func (s *Store) AddSongTag(ctx context.Context, songID, tag string) error {
+	tag = NormalizeTag(tag)
+	if tag == "" {
+		return errors.New("storage: empty tag")
+	}
+	v := &SongTag{SongID: songID, Tag: tag}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(v).Error; err != nil {
+		return fmt.Errorf("storage: failed to add tag %s to song %s: %w", tag, songID, err)
+	}
+	return nil
+}
+
+func (s *Store) RemoveSongTag(ctx context.Context, songID, tag string) error {
+	tag = NormalizeTag(tag)
+	if err := s.db.Delete(&SongTag{}, "song_id = ? AND tag = ?", songID, tag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("storage: failed to remove tag %s from song %s: %w", tag, songID, err)
+	}
+	return nil
+}
+
+// ListSongTags returns the tags of the given songs indexed by song id.
+func (s *Store) ListSongTags(ctx context.Context, songIDs ...string) (map[string][]string, error) {
+	tags := map[string][]string{}
+	if len(songIDs) == 0 {
+		return tags, nil
+	}
+	var vs []*SongTag
+	if err := s.db.Where("song_id IN (?)", songIDs).Order("tag").Find(&vs).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list song tags: %w", err)
+	}
+	for _, v := range vs {
+		tags[v.SongID] = append(tags[v.SongID], v.Tag)
+	}
+	return tags, nil
+}
+
+// ListTags returns all the distinct tags in use.
+func (s *Store) ListTags(ctx context.Context) ([]string, error) {
+	var tags []string
+	if err := s.db.Model(&SongTag{}).Distinct("tag").Order("tag").Pluck("tag", &tags).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// HasTag returns a filter that matches the songs with the given tag.
+func HasTag(tag string) Filter {
+	return Where("songs.id IN (SELECT song_id FROM song_tags WHERE tag = ?)", NormalizeTag(tag))
+}
+
+var noteTagRegex = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+
+// noteTags returns the hashtags (e.g. #calm) written inline in song notes.
+func noteTags(notes string) []string {
+	var tags []string
+	for _, m := range noteTagRegex.FindAllStringSubmatch(notes, -1) {
+		tags = append(tags, NormalizeTag(m[1]))
+	}
+	return tags
+}
+
+// migrateNoteTags converts the inline hashtags of the song notes to tags.
+func (s *Store) migrateNoteTags(ctx context.Context) (int, error) {
+	var n int
+	var currID string
+	for {
+		var songs []*Song
+		q := s.db.Select("id", "notes").Where("id > ? AND notes LIKE ?", currID, "%#%").Order("id").Limit(100)
+		if err := q.Find(&songs).Error; err != nil {
+			return n, fmt.Errorf("storage: failed to list songs with notes: %w", err)
+		}
+		if len(songs) == 0 {
+			return n, nil
+		}
+		currID = songs[len(songs)-1].ID
+		for _, song := range songs {
+			for _, tag := range noteTags(song.Notes) {
+				if err := s.AddSongTag(ctx, song.ID, tag); err != nil {
+					return n, err
+				}
+				n++
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Publish targets of an album
+const (
+	TargetDistrokid  = "distrokid"
+	TargetJamendo    = "jamendo"
+	TargetBandcamp   = "bandcamp"
+	TargetSoundCloud = "soundcloud"
+)
+
+// Targets are the available publish targets in the order they are published.
+// Distrokid goes first as it assigns the UPC used by the rest.
+var Targets = []string{TargetDistrokid, TargetJamendo, TargetBandcamp, TargetSoundCloud}
+
+// ParseTargets validates a comma separated list of targets and returns it
+// without duplicates and in publish order.
+func ParseTargets(v string) (string, error) {
+	lookup := map[string]struct{}{}
+	for _, t := range strings.Split(v, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		var found bool
+		for _, target := range Targets {
+			if t == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("storage: unknown target %q", t)
+		}
+		lookup[t] = struct{}{}
+	}
+	var targets []string
+	for _, t := range Targets {
+		if _, ok := lookup[t]; ok {
+			targets = append(targets, t)
+		}
+	}
+	return strings.Join(targets, ","), nil
+}
+
+// TargetList returns the targets of the album.
+func (a *Album) TargetList() []string {
+	if a.Targets == "" {
+		return nil
+	}
+	return strings.Split(a.Targets, ",")
+}
+
+// HasTarget returns whether the album has the target.
+func (a *Album) HasTarget(target string) bool {
+	for _, t := range a.TargetList() {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// targetQuery returns the condition that matches the albums with the target.
+func targetQuery(target string) (string, []any) {
+	return "(albums.targets = ? OR albums.targets LIKE ? OR albums.targets LIKE ? OR albums.targets LIKE ?)",
+		[]any{target, target + ",%", "%," + target, "%," + target + ",%"}
+}
+
+// HasTarget returns a filter that matches the albums with the target.
+func HasTarget(target string) Filter {
+	q, args := targetQuery(target)
+	return Where(q, args...)
+}
+
+// AllowsTarget returns a filter that matches the albums with the target or
+// without targets.
+func AllowsTarget(target string) Filter {
+	q, args := targetQuery(target)
+	return Where("(albums.targets = '' OR "+q+")", args...)
+}
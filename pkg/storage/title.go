@@ -49,6 +49,11 @@ func (s *Store) DeleteTitle(ctx context.Context, id string) error {
 }
 
 func (s *Store) ListTitles(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Title, error) {
+	filter = append(filter, Where("state != ?", Rejected))
+	return s.ListAllTitles(ctx, page, size, orderBy, filter...)
+}
+
+func (s *Store) ListAllTitles(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*Title, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -56,7 +61,6 @@ func (s *Store) ListTitles(ctx context.Context, page, size int, orderBy string,
 	vs := []*Title{}
 
 	q := s.db.Offset(offset).Limit(size)
-	q = q.Where("state != ?", Rejected)
 	for _, f := range filter {
 		q = q.Where(f.Query, f.Args...)
 	}
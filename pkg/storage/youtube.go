@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// YoutubeMatch is the match between a youtube video and a song.
+// Matches with a low confidence stay pending until they are reviewed, and
+// videos without a matching song are stored with an empty song id.
+type YoutubeMatch struct {
+	// ID is the youtube video ID
+	ID        string `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	SongID *string
+	Song   *Song `gorm:"foreignKey:SongID"`
+
+	Channel     string  `gorm:"not null;default:''"`
+	Title       string  `gorm:"not null;default:''"`
+	Duration    float32 `gorm:"not null;default:0"`
+	PublishedAt time.Time
+	Confidence  float32 `gorm:"not null;default:0"`
+
+	State State `gorm:"index"`
+}
+
+func (s *Store) GetYoutubeMatch(ctx context.Context, id string) (*YoutubeMatch, error) {
+	var v YoutubeMatch
+	if err := s.db.Preload("Song").First(&v, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("storage: failed to get youtube match %s: %w", id, err)
+	}
+	return &v, nil
+}
+
+func (s *Store) SetYoutubeMatch(ctx context.Context, v *YoutubeMatch) error {
+	if err := s.db.Save(v).Error; err != nil {
+		return fmt.Errorf("storage: failed to set youtube match %s: %w", v.ID, err)
+	}
+	return nil
+}
+
+func (s *Store) ListYoutubeMatches(ctx context.Context, page, size int, orderBy string, filter ...Filter) ([]*YoutubeMatch, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * size
+	vs := []*YoutubeMatch{}
+
+	q := s.db.Preload("Song").Offset(offset).Limit(size)
+	for _, f := range filter {
+		q = q.Where(f.Query, f.Args...)
+	}
+	// Order by
+	if orderBy != "" {
+		q = q.Order(orderBy)
+	}
+	if err := q.Find(&vs).Error; err != nil {
+		return nil, fmt.Errorf("storage: failed to list youtube matches: %w", err)
+	}
+	return vs, nil
+}
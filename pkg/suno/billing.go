@@ -0,0 +1,23 @@
+package suno
+
+import (
+	"context"
+	"fmt"
+)
+
+type billingInfo struct {
+	TotalCreditsLeft int  `json:"total_credits_left"`
+	IsActive         bool `json:"is_active"`
+}
+
+// Credits returns the credits left on the account.
+func (c *Client) Credits(ctx context.Context) (int, error) {
+	if err := c.Auth(ctx); err != nil {
+		return 0, err
+	}
+	var info billingInfo
+	if _, err := c.do(ctx, "GET", "billing/info/", nil, &info); err != nil {
+		return 0, fmt.Errorf("suno: couldn't get billing info: %w", err)
+	}
+	return info.TotalCreditsLeft, nil
+}
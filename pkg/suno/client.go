@@ -8,14 +8,18 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	http "github.com/bogdanfinn/fhttp"
 	"github.com/igolaizola/musikai/pkg/fhttp"
+	"github.com/igolaizola/musikai/pkg/music"
 	"github.com/igolaizola/musikai/pkg/ratelimit"
+	"github.com/igolaizola/musikai/pkg/sound"
 )
 
 type Client struct {
@@ -35,6 +39,13 @@ type Client struct {
 	minDuration     float32
 	maxDuration     float32
 	maxExtensions   int
+	extendStrategy  string
+	fadeOut         sound.Option
+
+	// rnd is used to choose the clips to extend, guarded by rndLck as the
+	// client can be used concurrently.
+	rnd    *rand.Rand
+	rndLck sync.Mutex
 }
 
 type Config struct {
@@ -51,6 +62,16 @@ type Config struct {
 	MinDuration    time.Duration
 	MaxDuration    time.Duration
 	MaxExtensions  int
+	// ExtendStrategy is how the clip to continue is chosen on each
+	// extension (heuristic, longest, random).
+	ExtendStrategy string
+	// FadeOutWindow and FadeOutThreshold tune the fade out detection used
+	// to decide if a clip ends (zero values use the defaults).
+	FadeOutWindow    time.Duration
+	FadeOutThreshold float64
+	// Seed initializes the random source used to choose the clips to extend.
+	// If zero a time based seed is used.
+	Seed int64
 }
 
 type cookieStore struct {
@@ -104,6 +125,19 @@ func New(cfg *Config) *Client {
 		maxExtensions = cfg.MaxExtensions
 	}
 
+	extendStrategy := ExtendHeuristic
+	if cfg.ExtendStrategy != "" {
+		extendStrategy = cfg.ExtendStrategy
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if cfg.Debug {
+		log.Printf("suno: random seed %d\n", seed)
+	}
+
 	return &Client{
 		client:         client,
 		ratelimit:      ratelimit.New(wait),
@@ -118,9 +152,27 @@ func New(cfg *Config) *Client {
 		minDuration:    float32(minDuration.Seconds()),
 		maxDuration:    float32(maxDuration.Seconds()),
 		maxExtensions:  maxExtensions,
+		extendStrategy: extendStrategy,
+		fadeOut:        sound.WithFadeOut(cfg.FadeOutWindow, cfg.FadeOutThreshold),
+		rnd:            rand.New(rand.NewSource(seed)),
 	}
 }
 
+// intn returns a random number in [0, n) using the client random source.
+func (c *Client) intn(n int) int {
+	c.rndLck.Lock()
+	defer c.rndLck.Unlock()
+	return c.rnd.Intn(n)
+}
+
+// SetProxy changes the proxy used by the client.
+func (c *Client) SetProxy(proxy string) error {
+	if err := c.client.SetProxy(proxy); err != nil {
+		return fmt.Errorf("suno: couldn't set proxy: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) Start(ctx context.Context) error {
 	// Create log folder if it doesn't exist
 	if _, err := os.Stat("logs"); os.IsNotExist(err) {
@@ -229,9 +281,11 @@ func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]by
 			case http.StatusUnauthorized:
 				// Retry on unauthorized
 				if err := c.Auth(ctx); err != nil {
-					return nil, err
+					return nil, fmt.Errorf("%w: %w", music.ErrAccountUnavailable, err)
 				}
 				retry = true
+			case http.StatusPaymentRequired, http.StatusForbidden:
+				return nil, fmt.Errorf("%w: %w", music.ErrAccountUnavailable, err)
 			default:
 				return nil, err
 			}
@@ -0,0 +1,66 @@
+package suno
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/igolaizola/musikai/pkg/music"
+)
+
+const jobsMaxPages = 5
+
+// Jobs returns the clips of the account that are still pending.
+func (c *Client) Jobs(ctx context.Context) ([]music.Job, error) {
+	if err := c.Auth(ctx); err != nil {
+		return nil, err
+	}
+	var jobs []music.Job
+	for page := 0; page < jobsMaxPages; page++ {
+		var clips []clip
+		if _, err := c.do(ctx, "GET", fmt.Sprintf("feed/?page=%d", page), nil, &clips); err != nil {
+			return nil, fmt.Errorf("suno: couldn't get feed: %w", err)
+		}
+		if len(clips) == 0 {
+			break
+		}
+		for _, clp := range clips {
+			if clp.IsTrashed {
+				continue
+			}
+			switch clp.Status {
+			case "complete", "error":
+				continue
+			}
+			jobs = append(jobs, music.Job{
+				ID:        clp.ID,
+				Title:     clp.Title,
+				Status:    clp.Status,
+				CreatedAt: clp.CreatedAt,
+			})
+		}
+	}
+	return jobs, nil
+}
+
+type trashRequest struct {
+	Trash   bool     `json:"trash"`
+	ClipIDs []string `json:"clip_ids"`
+}
+
+// Cancel moves the clips to the trash.
+func (c *Client) Cancel(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := c.Auth(ctx); err != nil {
+		return err
+	}
+	req := &trashRequest{
+		Trash:   true,
+		ClipIDs: ids,
+	}
+	if _, err := c.do(ctx, "POST", "gen/trash/", req, nil); err != nil {
+		return fmt.Errorf("suno: couldn't trash clips: %w", err)
+	}
+	return nil
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"sort"
 	"strings"
 	"sync"
@@ -23,6 +22,17 @@ const (
 	defaultModel         = "chirp-v3-0"
 )
 
+// Strategies to choose the clip to continue when extending a song
+const (
+	// ExtendHeuristic chooses the first clip that seems to end (short
+	// duration, final silence or fade out) or a random one otherwise.
+	ExtendHeuristic = "heuristic"
+	// ExtendLongest chooses the longest clip.
+	ExtendLongest = "longest"
+	// ExtendRandom chooses a random clip.
+	ExtendRandom = "random"
+)
+
 type generateRequest struct {
 	Prompt               string   `json:"prompt"`
 	Tags                 string   `json:"tags,omitempty"`
@@ -225,6 +235,8 @@ func (c *Client) extend(ctx context.Context, clp *clip, lyrics *[]string) ([]*cl
 	originalStyle := clp.Metadata.Tags
 	var duration float32
 	var extensions int
+	strategy := c.extendStrategy
+	fadeOut := c.fadeOut
 
 	for {
 		// Choose the best clip
@@ -235,7 +247,7 @@ func (c *Client) extend(ctx context.Context, clp *clip, lyrics *[]string) ([]*cl
 		}{}
 
 		for _, c := range clips {
-			a, err := sound.NewAnalyzer(c.AudioURL)
+			a, err := sound.NewAnalyzer(c.AudioURL, fadeOut)
 			if err != nil {
 				return nil, fmt.Errorf("suno: couldn't create analyzer: %w", err)
 			}
@@ -266,6 +278,11 @@ func (c *Client) extend(ctx context.Context, clp *clip, lyrics *[]string) ([]*cl
 				clip:                 &c,
 			}
 
+			// Only the heuristic strategy checks if the clip ends
+			if strategy != ExtendHeuristic {
+				continue
+			}
+
 			// Check if the clip ends
 			if c.Metadata.Duration < 59.0 {
 				best = c.ID
@@ -281,10 +298,18 @@ func (c *Client) extend(ctx context.Context, clp *clip, lyrics *[]string) ([]*cl
 			}
 		}
 
+		if strategy == ExtendLongest {
+			for _, c := range clips {
+				if best == "" || c.Metadata.Duration > lookup[best].clip.Metadata.Duration {
+					best = c.ID
+				}
+			}
+		}
+
 		var firstSilence time.Duration
 		if best == "" {
 			// Choose random clip
-			rnd := rand.Intn(len(clips))
+			rnd := c.intn(len(clips))
 			clp = &clips[rnd]
 		} else {
 			clp = lookup[best].clip
@@ -0,0 +1,82 @@
+package timing
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile accumulates the time spent on each stage of a command.
+// It is safe for concurrent use and a disabled or nil profile does nothing.
+type Profile struct {
+	lck     sync.Mutex
+	enabled bool
+	stages  map[string]*stage
+}
+
+type stage struct {
+	name  string
+	count int
+	total time.Duration
+}
+
+// New creates a new profile.
+func New(enabled bool) *Profile {
+	return &Profile{
+		enabled: enabled,
+		stages:  map[string]*stage{},
+	}
+}
+
+// Start starts measuring a stage and returns a function that stops it.
+func (p *Profile) Start(name string) func() {
+	if p == nil || !p.enabled {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		p.lck.Lock()
+		defer p.lck.Unlock()
+		s, ok := p.stages[name]
+		if !ok {
+			s = &stage{name: name}
+			p.stages[name] = s
+		}
+		s.count++
+		s.total += elapsed
+	}
+}
+
+// Log prints the time breakdown of the stages, sorted by total time.
+func (p *Profile) Log(prefix string) {
+	if p == nil || !p.enabled {
+		return
+	}
+	p.lck.Lock()
+	defer p.lck.Unlock()
+
+	var stages []*stage
+	var total time.Duration
+	for _, s := range p.stages {
+		stages = append(stages, s)
+		total += s.total
+	}
+	if len(stages) == 0 {
+		return
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		return stages[i].total > stages[j].total
+	})
+	lines := []string{fmt.Sprintf("%s: profile", prefix)}
+	for _, s := range stages {
+		lines = append(lines, fmt.Sprintf("  %-20s count %-5d total %-12s avg %-12s %5.1f%%",
+			s.name, s.count, s.total.Round(time.Millisecond),
+			(s.total/time.Duration(s.count)).Round(time.Millisecond),
+			100*float64(s.total)/float64(total)))
+	}
+	log.Println(strings.Join(lines, "\n"))
+}
@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/igolaizola/musikai/pkg/music"
 )
 
 type apiUsageResponse struct {
@@ -34,20 +36,40 @@ func (c *Client) CheckLimit(ctx context.Context) error {
 		return fmt.Errorf("udio: couldn't get api usage: %w", err)
 	}
 	if resp.Data.Disabled {
-		return errors.New("udio: api disabled")
+		return fmt.Errorf("udio: api disabled: %w", music.ErrAccountUnavailable)
 	}
 	if resp.Data.DailyThrottled {
-		return errors.New("udio: daily throttled")
+		return fmt.Errorf("udio: daily throttled: %w", music.ErrAccountUnavailable)
 	}
 	if resp.Data.DailyUsed >= resp.Data.DailyThrottleLimit {
-		return errors.New("udio: daily limit reached")
+		return fmt.Errorf("udio: daily limit reached: %w", music.ErrAccountUnavailable)
 	}
 	if resp.Data.MonthlyUsed >= resp.Data.MonthlyLimit {
-		return errors.New("udio: monthly limit reached")
+		return fmt.Errorf("udio: monthly limit reached: %w", music.ErrAccountUnavailable)
 	}
 	return nil
 }
 
+// Credits returns the generations left on the account, which is the minimum
+// between the daily and the monthly remaining usage.
+func (c *Client) Credits(ctx context.Context) (int, error) {
+	var resp apiUsageResponse
+	if _, err := c.do(ctx, "GET", "users/current/api-usage", nil, &resp); err != nil {
+		return 0, fmt.Errorf("udio: couldn't get api usage: %w", err)
+	}
+	if resp.Data.Disabled || resp.Data.DailyThrottled {
+		return 0, nil
+	}
+	left := resp.Data.DailyThrottleLimit - resp.Data.DailyUsed
+	if monthly := resp.Data.MonthlyLimit - resp.Data.MonthlyUsed; monthly < left {
+		left = monthly
+	}
+	if left < 0 {
+		left = 0
+	}
+	return left, nil
+}
+
 type refreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
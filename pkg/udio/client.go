@@ -8,15 +8,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	http "github.com/bogdanfinn/fhttp"
 	"github.com/igolaizola/musikai/pkg/fhttp"
+	"github.com/igolaizola/musikai/pkg/music"
 	"github.com/igolaizola/musikai/pkg/nopecha"
 	"github.com/igolaizola/musikai/pkg/ratelimit"
+	"github.com/igolaizola/musikai/pkg/sound"
 	"github.com/igolaizola/musikai/pkg/twocaptcha"
 )
 
@@ -36,6 +40,15 @@ type Client struct {
 	intro          bool
 	resolveCaptcha func(context.Context) (string, error)
 	parallel       bool
+
+	generateAttempts int
+	captchaRefresh   bool
+	fadeOut          sound.Option
+
+	// rnd is used to choose the clips to extend, guarded by rndLck as the
+	// client can be used concurrently.
+	rnd    *rand.Rand
+	rndLck sync.Mutex
 }
 
 type Config struct {
@@ -51,6 +64,25 @@ type Config struct {
 	CaptchaProvider string
 	CaptchaProxy    string
 	SkipIntro       bool
+	IntroDuration   time.Duration
+	IntroExtensions int
+
+	// GenerateAttempts is the number of attempts when generation returns
+	// status 500.
+	GenerateAttempts int
+	// SkipCaptchaRefresh disables solving a new captcha when generation
+	// fails because of the captcha.
+	SkipCaptchaRefresh bool
+	// NoCaptcha creates a client that can't generate songs, useful for
+	// account operations.
+	NoCaptcha bool
+	// FadeOutWindow and FadeOutThreshold tune the fade out detection used
+	// to decide if a clip ends (zero values use the defaults).
+	FadeOutWindow    time.Duration
+	FadeOutThreshold float64
+	// Seed initializes the random source used to choose the clips to extend.
+	// If zero a time based seed is used.
+	Seed int64
 }
 
 type cookieStore struct {
@@ -102,23 +134,40 @@ func New(cfg *Config) (*Client, error) {
 		maxExtensions = cfg.MaxExtensions
 	}
 
+	// Reserve duration and extensions for the intro
 	intro := !cfg.SkipIntro
 	if intro {
-		if maxExtensions <= 1 || minDuration <= 30*time.Second || maxDuration <= 30*time.Second {
-			return nil, fmt.Errorf("udio: intro requires at least 2 extensions and 30 seconds duration")
+		introDuration := defaultIntroDuration
+		if cfg.IntroDuration > 0 {
+			introDuration = cfg.IntroDuration
+		}
+		introExtensions := defaultIntroExtensions
+		if cfg.IntroExtensions > 0 {
+			introExtensions = cfg.IntroExtensions
 		}
-		maxExtensions -= 1
-		maxDuration -= 30 * time.Second
-		minDuration -= 30 * time.Second
+		if maxExtensions <= introExtensions || minDuration <= introDuration || maxDuration <= introDuration {
+			return nil, fmt.Errorf("udio: intro requires more than %d extensions and %s duration", introExtensions, introDuration)
+		}
+		maxExtensions -= introExtensions
+		maxDuration -= introDuration
+		minDuration -= introDuration
 	}
 
-	// Set up captcha resolver
-	if cfg.CaptchaKey == "" {
-		return nil, fmt.Errorf("udio: captcha key is empty")
+	generateAttempts := defaultGenerateAttempts
+	if cfg.GenerateAttempts > 0 {
+		generateAttempts = cfg.GenerateAttempts
 	}
+
+	// Set up captcha resolver
 	var resolveCaptcha func(context.Context) (string, error)
-	switch cfg.CaptchaProvider {
-	case "2captcha":
+	switch {
+	case cfg.NoCaptcha:
+		resolveCaptcha = func(ctx context.Context) (string, error) {
+			return "", errors.New("udio: captcha is disabled")
+		}
+	case cfg.CaptchaKey == "":
+		return nil, fmt.Errorf("udio: captcha key is empty")
+	case cfg.CaptchaProvider == "2captcha":
 		cli := twocaptcha.NewClient(cfg.CaptchaKey)
 		resolveCaptcha = func(ctx context.Context) (string, error) {
 			req := (&twocaptcha.HCaptcha{
@@ -135,7 +184,7 @@ func New(cfg *Config) (*Client, error) {
 			}
 			return code, nil
 		}
-	case "nopecha":
+	case cfg.CaptchaProvider == "nopecha":
 		cli, err := nopecha.New(&nopecha.Config{
 			Wait:  1 * time.Second,
 			Key:   cfg.CaptchaKey,
@@ -156,6 +205,14 @@ func New(cfg *Config) (*Client, error) {
 		return nil, fmt.Errorf("udio: invalid captcha provider: %s", cfg.CaptchaProvider)
 	}
 
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if cfg.Debug {
+		log.Printf("udio: random seed %d\n", seed)
+	}
+
 	return &Client{
 		client:         client,
 		ratelimit:      ratelimit.New(wait),
@@ -166,10 +223,30 @@ func New(cfg *Config) (*Client, error) {
 		maxExtensions:  maxExtensions,
 		resolveCaptcha: resolveCaptcha,
 		parallel:       cfg.Parallel,
-		intro:          true,
+		intro:          intro,
+
+		generateAttempts: generateAttempts,
+		captchaRefresh:   !cfg.SkipCaptchaRefresh,
+		fadeOut:          sound.WithFadeOut(cfg.FadeOutWindow, cfg.FadeOutThreshold),
+		rnd:              rand.New(rand.NewSource(seed)),
 	}, nil
 }
 
+// intn returns a random number in [0, n) using the client random source.
+func (c *Client) intn(n int) int {
+	c.rndLck.Lock()
+	defer c.rndLck.Unlock()
+	return c.rnd.Intn(n)
+}
+
+// SetProxy changes the proxy used by the client.
+func (c *Client) SetProxy(proxy string) error {
+	if err := c.client.SetProxy(proxy); err != nil {
+		return fmt.Errorf("udio: couldn't set proxy: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) Start(ctx context.Context) error {
 	// Create log folder if it doesn't exist
 	if _, err := os.Stat("logs"); os.IsNotExist(err) {
@@ -277,9 +354,11 @@ func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]by
 			case http.StatusUnauthorized:
 				// Retry on unauthorized
 				if err := c.refresh(ctx); err != nil {
-					return nil, err
+					return nil, fmt.Errorf("%w: %w", music.ErrAccountUnavailable, err)
 				}
 				retry = true
+			case http.StatusPaymentRequired, http.StatusForbidden:
+				return nil, fmt.Errorf("%w: %w", music.ErrAccountUnavailable, err)
 			default:
 				return nil, err
 			}
@@ -288,7 +367,7 @@ func (c *Client) do(ctx context.Context, method, path string, in, out any) ([]by
 			if msg == "unauthorized" {
 				// Retry on unauthorized
 				if err := c.refresh(ctx); err != nil {
-					return nil, err
+					return nil, fmt.Errorf("%w: %w", music.ErrAccountUnavailable, err)
 				}
 				retry = true
 			} else {
@@ -0,0 +1,61 @@
+package udio
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/igolaizola/musikai/pkg/music"
+)
+
+const jobsMaxPages = 5
+
+// Jobs returns the songs of the account that are still being generated.
+func (c *Client) Jobs(ctx context.Context) ([]music.Job, error) {
+	if err := c.Auth(ctx); err != nil {
+		return nil, err
+	}
+	var jobs []music.Job
+	for page := 0; page < jobsMaxPages; page++ {
+		var resp clipsResponse
+		u := fmt.Sprintf("songs/me?likedOnly=false&pageParam=%d", page)
+		if _, err := c.do(ctx, "GET", u, nil, &resp); err != nil {
+			return nil, fmt.Errorf("udio: couldn't get songs: %w", err)
+		}
+		if len(resp.Clips) == 0 {
+			break
+		}
+		for _, clp := range resp.Clips {
+			if clp.Finished || clp.ErrorID != nil || clp.SongPath != "" {
+				continue
+			}
+			jobs = append(jobs, music.Job{
+				ID:        clp.ID,
+				Title:     clp.Title,
+				Status:    "pending",
+				CreatedAt: clp.CreatedAt,
+			})
+		}
+	}
+	return jobs, nil
+}
+
+type deleteRequest struct {
+	SongIDs []string `json:"songIds"`
+}
+
+// Cancel deletes the songs.
+func (c *Client) Cancel(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := c.Auth(ctx); err != nil {
+		return err
+	}
+	req := &deleteRequest{
+		SongIDs: ids,
+	}
+	if _, err := c.do(ctx, "POST", "songs/delete", req, nil); err != nil {
+		return fmt.Errorf("udio: couldn't delete songs: %w", err)
+	}
+	return nil
+}
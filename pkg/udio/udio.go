@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"sort"
 	"strings"
 	"sync"
@@ -20,6 +19,11 @@ const (
 	defaultMinDuration   = 2*time.Minute + 5*time.Second
 	defaultMaxDuration   = 3*time.Minute + 55*time.Second
 	defaultMaxExtensions = 6
+
+	defaultIntroDuration   = 30 * time.Second
+	defaultIntroExtensions = 1
+
+	defaultGenerateAttempts = 3
 )
 
 type generateRequest struct {
@@ -67,7 +71,7 @@ func (c *Client) Generate(ctx context.Context, prompt string, manual, instrument
 			BypassPromptOptimize: manual,
 		},
 	}
-	resp, err := c.tryGenerate(ctx, req, 0)
+	resp, err := c.tryGenerate(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -146,28 +150,58 @@ func (c *Client) Generate(ctx context.Context, prompt string, manual, instrument
 	return songs, nil
 }
 
-func (c *Client) tryGenerate(ctx context.Context, req *generateRequest, attempt int) (*generateResponse, error) {
-	if attempt > 3 {
-		return nil, errors.New("udio: too many attempts")
-	}
+func (c *Client) tryGenerate(ctx context.Context, req *generateRequest) (*generateResponse, error) {
+	var captchaToken string
+	for attempt := 1; ; attempt++ {
+		// Solve a new captcha only when we don't have a valid one
+		if captchaToken == "" {
+			var err error
+			captchaToken, err = c.resolveCaptcha(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		req.CaptchaToken = captchaToken
 
-	captchaToken, err := c.resolveCaptcha(ctx)
-	if err != nil {
-		return nil, err
-	}
-	req.CaptchaToken = captchaToken
-
-	var resp generateResponse
-	_, err = c.do(ctx, "POST", "generate-proxy", req, &resp)
-	var errStatus errStatusCode
-	if errors.As(err, &errStatus) && int(errStatus) == 500 {
-		log.Println("❌ udio: generation failed with status 500, retrying with new captcha token")
-		return c.tryGenerate(ctx, req, attempt+1)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("udio: couldn't generate: %w", err)
+		var resp generateResponse
+		_, err := c.do(ctx, "POST", "generate-proxy", req, &resp)
+		var errStatus errStatusCode
+		if !errors.As(err, &errStatus) || int(errStatus) != 500 {
+			if err != nil {
+				return nil, fmt.Errorf("udio: couldn't generate: %w", err)
+			}
+			return &resp, nil
+		}
+		if attempt >= c.generateAttempts {
+			return nil, fmt.Errorf("udio: too many attempts (%d): %w", attempt, err)
+		}
+
+		// Refresh the captcha only if the error was caused by it
+		if isCaptchaError(err) {
+			if !c.captchaRefresh {
+				return nil, fmt.Errorf("udio: captcha rejected and refresh is disabled: %w", err)
+			}
+			log.Println("❌ udio: generation failed with status 500 due to captcha, retrying with new captcha token")
+			captchaToken = ""
+			continue
+		}
+		idx := attempt - 1
+		if idx >= len(backoff) {
+			idx = len(backoff) - 1
+		}
+		log.Printf("❌ udio: generation failed with status 500 (server error), retrying in %s with the same captcha token\n", backoff[idx])
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("udio: %w", ctx.Err())
+		case <-time.After(backoff[idx]):
+		}
 	}
-	return &resp, nil
+}
+
+// isCaptchaError returns true if the error message returned by udio refers
+// to the captcha.
+func isCaptchaError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "captcha")
 }
 
 type clipsResponse struct {
@@ -209,6 +243,7 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 	var duration, prevDuration float32
 	var extensions int
 	var over bool
+	fadeOut := c.fadeOut
 
 	for {
 		// Check clip silences
@@ -224,7 +259,7 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 		}
 
 		for _, c := range clips {
-			a, err := sound.NewAnalyzer(c.SongPath)
+			a, err := sound.NewAnalyzer(c.SongPath, fadeOut)
 			if err != nil {
 				return nil, fmt.Errorf("udio: couldn't create analyzer: %w", err)
 			}
@@ -282,7 +317,7 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 		}
 
 		// Choose random clip
-		rnd := rand.Intn(len(okClips))
+		rnd := c.intn(len(okClips))
 		clp = okClips[rnd]
 
 		duration = clp.Duration
@@ -300,7 +335,8 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 		}
 
 		// Check if has ended and we don't want to add an intro
-		if over && !c.intro {
+		conditioning, ok := nextConditioning(c.intro, over)
+		if !ok {
 			break
 		}
 
@@ -320,10 +356,8 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 		}
 
 		cropStartTime := 0.0
-		conditioning := "continuation"
-		if c.intro && over {
+		if conditioning == "precede" {
 			log.Println("▶️ udio: setting intro", clp.Title)
-			conditioning = "precede"
 		} else {
 			// If the duration is over the min duration, set outro settings
 			if prevDuration+30.0 > c.maxDuration || extensions == c.maxExtensions {
@@ -351,7 +385,7 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 				AudioConditioningType:        conditioning,
 			},
 		}
-		resp, err := c.tryGenerate(ctx, req, 0)
+		resp, err := c.tryGenerate(ctx, req)
 		if err != nil {
 			return nil, err
 		}
@@ -383,6 +417,19 @@ func (c *Client) extend(ctx context.Context, clp *clip, manual bool, lyrics *str
 	return clips, nil
 }
 
+// nextConditioning returns the conditioning type of the next fragment and
+// whether a next fragment must be generated at all.
+func nextConditioning(intro, over bool) (string, bool) {
+	switch {
+	case over && intro:
+		return "precede", true
+	case over:
+		return "", false
+	default:
+		return "continuation", true
+	}
+}
+
 func (c *Client) waitClips(ctx context.Context, ids []string) ([]*clip, error) {
 	u := fmt.Sprintf("songs?songIds=%s", strings.Join(ids, ","))
 	var last []byte
@@ -0,0 +1,58 @@
+package udio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkipIntro(t *testing.T) {
+	c, err := New(&Config{
+		CaptchaKey:      "key",
+		CaptchaProvider: "2captcha",
+		SkipIntro:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.intro {
+		t.Error("intro enabled with skip intro")
+	}
+	if c.maxExtensions != defaultMaxExtensions {
+		t.Errorf("max extensions %d, want %d", c.maxExtensions, defaultMaxExtensions)
+	}
+	if c.maxDuration != float32(defaultMaxDuration.Seconds()) {
+		t.Errorf("max duration %v, want %v", c.maxDuration, defaultMaxDuration.Seconds())
+	}
+
+	// No precede fragment must be generated once the song is over
+	if conditioning, ok := nextConditioning(c.intro, true); ok {
+		t.Errorf("unexpected %q fragment", conditioning)
+	}
+	if conditioning, ok := nextConditioning(c.intro, false); !ok || conditioning != "continuation" {
+		t.Errorf("got %q (%v), want continuation", conditioning, ok)
+	}
+}
+
+func TestIntro(t *testing.T) {
+	c, err := New(&Config{
+		CaptchaKey:      "key",
+		CaptchaProvider: "2captcha",
+		IntroDuration:   45 * time.Second,
+		IntroExtensions: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.intro {
+		t.Error("intro disabled")
+	}
+	if c.maxExtensions != defaultMaxExtensions-2 {
+		t.Errorf("max extensions %d, want %d", c.maxExtensions, defaultMaxExtensions-2)
+	}
+	if want := float32((defaultMaxDuration - 45*time.Second).Seconds()); c.maxDuration != want {
+		t.Errorf("max duration %v, want %v", c.maxDuration, want)
+	}
+	if conditioning, ok := nextConditioning(c.intro, true); !ok || conditioning != "precede" {
+		t.Errorf("got %q (%v), want precede", conditioning, ok)
+	}
+}
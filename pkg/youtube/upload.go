@@ -0,0 +1,164 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+)
+
+// MusicCategory is the youtube category ID of music videos.
+const MusicCategory = "10"
+
+// Limits of the video metadata.
+const (
+	maxTitleLength       = 100
+	maxDescriptionLength = 5000
+	maxTagsLength        = 500
+)
+
+var googleEndpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// tokenSource returns the source of the OAuth token, that can be an access
+// token or a JSON token.
+// JSON tokens with a refresh token are refreshed when they expire if the
+// client ID and secret are set.
+func tokenSource(ctx context.Context, token, clientID, clientSecret string) (oauth2.TokenSource, error) {
+	token = strings.TrimSpace(token)
+	var tok oauth2.Token
+	if strings.HasPrefix(token, "{") {
+		if err := json.Unmarshal([]byte(token), &tok); err != nil {
+			return nil, fmt.Errorf("youtube: couldn't parse oauth token: %w", err)
+		}
+	} else {
+		tok.AccessToken = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	}
+	if tok.AccessToken == "" && tok.RefreshToken == "" {
+		return nil, errors.New("youtube: oauth token is empty")
+	}
+	if tok.RefreshToken == "" || clientID == "" || clientSecret == "" {
+		if tok.AccessToken == "" {
+			return nil, errors.New("youtube: client id and secret are required to refresh the oauth token")
+		}
+		return oauth2.StaticTokenSource(&tok), nil
+	}
+	cfg := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     googleEndpoint,
+		Scopes:       []string{youtube.YoutubeUploadScope},
+	}
+	return cfg.TokenSource(ctx, &tok), nil
+}
+
+// Upload is a video to be uploaded.
+type Upload struct {
+	Title       string
+	Description string
+	Tags        []string
+	CategoryID  string
+	// Privacy is the privacy status: public, unlisted or private.
+	Privacy string
+	File    string
+}
+
+func (u *Upload) Validate() error {
+	if u.Title == "" {
+		return errors.New("youtube: missing title")
+	}
+	if utf8.RuneCountInString(u.Title) > maxTitleLength {
+		return fmt.Errorf("youtube: title longer than %d characters", maxTitleLength)
+	}
+	if len(u.Description) > maxDescriptionLength {
+		return fmt.Errorf("youtube: description longer than %d bytes", maxDescriptionLength)
+	}
+	if strings.ContainsAny(u.Title+u.Description, "<>") {
+		return errors.New("youtube: title and description can't contain < or >")
+	}
+	switch u.Privacy {
+	case "public", "unlisted", "private":
+	default:
+		return fmt.Errorf("youtube: invalid privacy status %q", u.Privacy)
+	}
+	if u.File == "" {
+		return errors.New("youtube: missing file")
+	}
+	return nil
+}
+
+// Upload uploads a video and returns its ID.
+// The client must be created with an OAuth token.
+func (c *Client) Upload(ctx context.Context, upload *Upload) (string, error) {
+	if err := upload.Validate(); err != nil {
+		return "", err
+	}
+	video := &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Title:       upload.Title,
+			Description: upload.Description,
+			Tags:        LimitTags(upload.Tags),
+			CategoryId:  upload.CategoryID,
+		},
+		Status: &youtube.VideoStatus{
+			PrivacyStatus:           upload.Privacy,
+			SelfDeclaredMadeForKids: false,
+		},
+	}
+	var id string
+	if err := c.do(ctx, UploadCost, func() error {
+		// The file is opened on each attempt as the upload consumes it
+		f, err := os.Open(upload.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		resp, err := c.service.Videos.Insert([]string{"snippet", "status"}, video).Media(f).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		id = resp.Id
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("youtube: couldn't upload %s: %w", upload.File, err)
+	}
+	if c.debug {
+		log.Println("youtube: uploaded", upload.File, id)
+	}
+	return id, nil
+}
+
+// LimitTags returns the tags that fit in the tags length limit, counting the
+// quotes added to the tags with spaces and the separators.
+func LimitTags(tags []string) []string {
+	var limited []string
+	var n int
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.NewReplacer("<", "", ">", "", ",", "").Replace(t))
+		if t == "" {
+			continue
+		}
+		l := utf8.RuneCountInString(t)
+		if strings.Contains(t, " ") {
+			l += 2
+		}
+		if len(limited) > 0 {
+			l++
+		}
+		if n+l > maxTagsLength {
+			break
+		}
+		n += l
+		limited = append(limited, t)
+	}
+	return limited
+}
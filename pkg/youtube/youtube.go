@@ -2,46 +2,113 @@ package youtube
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html"
 	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/igolaizola/musikai/pkg/ratelimit"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
 
+// Number of quota units consumed by each type of request.
+const (
+	SearchCost = 100
+	VideosCost = 1
+	UploadCost = 1600
+)
+
+// ErrQuotaExceeded is returned when the daily quota has been exhausted.
+var ErrQuotaExceeded = errors.New("youtube: quota exceeded")
+
 type Client struct {
-	service *youtube.Service
-	debug   bool
+	service   *youtube.Service
+	debug     bool
+	ratelimit ratelimit.Lock
+	quota     int64
+	used      atomic.Int64
+}
+
+type Config struct {
+	Key string
+	// Token is the OAuth token used instead of the key for requests on
+	// behalf of a channel, like uploads. It can be an access token or a
+	// JSON token with a refresh token, which is refreshed if the client ID
+	// and secret are set.
+	Token        string
+	ClientID     string
+	ClientSecret string
+	Debug        bool
+	// Wait is the minimum time between requests.
+	Wait time.Duration
+	// Quota is the maximum number of quota units to consume (0 means no limit).
+	Quota int
 }
 
-func New(ctx context.Context, key string, debug bool) (*Client, error) {
-	service, err := youtube.NewService(ctx, option.WithAPIKey(key))
+func New(ctx context.Context, cfg *Config) (*Client, error) {
+	opt := option.WithAPIKey(cfg.Key)
+	if cfg.Token != "" {
+		ts, err := tokenSource(ctx, cfg.Token, cfg.ClientID, cfg.ClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		opt = option.WithTokenSource(ts)
+	}
+	service, err := youtube.NewService(ctx, opt)
 	if err != nil {
 		return nil, fmt.Errorf("youtube: couldn't create service: %w", err)
 	}
+	wait := cfg.Wait
+	if wait == 0 {
+		wait = 1 * time.Second
+	}
 	return &Client{
-		service: service,
-		debug:   debug,
+		service:   service,
+		debug:     cfg.Debug,
+		ratelimit: ratelimit.New(wait),
+		quota:     int64(cfg.Quota),
 	}, nil
 }
 
+// QuotaUsed returns the estimated number of quota units consumed by the client.
+// The API doesn't report quota usage, so it is computed from the cost of each
+// request.
+func (c *Client) QuotaUsed() int {
+	return int(c.used.Load())
+}
+
 type Video struct {
-	Title string
-	ID    string
+	Title       string
+	ID          string
+	PublishedAt time.Time
+	Duration    time.Duration
 }
 
-func (c *Client) GetVideos(ctx context.Context, channelID string, after time.Time) ([]Video, error) {
+// GetVideos returns the videos of a channel published between after and before,
+// newest first.
+// A zero after or before means no limit.
+// If an error occurs, the videos fetched so far are returned along with it.
+func (c *Client) GetVideos(ctx context.Context, channelID string, after, before time.Time) ([]Video, error) {
 	// Prepare a search call
 	call := c.service.Search.List([]string{"snippet"}).
 		ChannelId(channelID).
 		MaxResults(50).
+		Order("date").
 		Type("video").
 		Context(ctx)
 	if !after.IsZero() {
 		call = call.PublishedAfter(after.Format(time.RFC3339))
 	}
+	if !before.IsZero() {
+		call = call.PublishedBefore(before.Format(time.RFC3339))
+	}
 
 	var videos []Video
 	var pageToken string
@@ -53,20 +120,36 @@ func (c *Client) GetVideos(ctx context.Context, channelID string, after time.Tim
 		if c.debug {
 			log.Println("youtube: fetching videos", page+1, channelID, pageToken)
 		}
-		resp, err := call.Do()
-		if err != nil {
-			return nil, fmt.Errorf("youtube: couldn't fetch videos: %w", err)
+		var resp *youtube.SearchListResponse
+		if err := c.do(ctx, SearchCost, func() error {
+			var err error
+			resp, err = call.Do()
+			return err
+		}); err != nil {
+			return videos, fmt.Errorf("youtube: couldn't fetch videos: %w", err)
 		}
 		if c.debug {
 			b, _ := resp.MarshalJSON()
 			log.Println("youtube:", string(b))
 		}
 
+		var ids []string
+		for _, item := range resp.Items {
+			ids = append(ids, item.Id.VideoId)
+		}
+		durations, err := c.getDurations(ctx, ids)
+		if err != nil {
+			return videos, err
+		}
+
 		for _, item := range resp.Items {
 			title := html.UnescapeString(item.Snippet.Title)
+			publishedAt, _ := time.Parse(time.RFC3339, item.Snippet.PublishedAt)
 			videos = append(videos, Video{
-				Title: title,
-				ID:    item.Id.VideoId,
+				Title:       title,
+				ID:          item.Id.VideoId,
+				PublishedAt: publishedAt,
+				Duration:    durations[item.Id.VideoId],
 			})
 		}
 		if resp.NextPageToken == "" {
@@ -77,3 +160,133 @@ func (c *Client) GetVideos(ctx context.Context, channelID string, after time.Tim
 	}
 	return videos, nil
 }
+
+// getDurations returns the duration of the given videos (up to 50).
+func (c *Client) getDurations(ctx context.Context, ids []string) (map[string]time.Duration, error) {
+	durations := map[string]time.Duration{}
+	if len(ids) == 0 {
+		return durations, nil
+	}
+	call := c.service.Videos.List([]string{"contentDetails"}).
+		Id(ids...).
+		MaxResults(50).
+		Context(ctx)
+	var resp *youtube.VideoListResponse
+	if err := c.do(ctx, VideosCost, func() error {
+		var err error
+		resp, err = call.Do()
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("youtube: couldn't fetch video details: %w", err)
+	}
+	for _, item := range resp.Items {
+		if item.ContentDetails == nil {
+			continue
+		}
+		d, err := parseDuration(item.ContentDetails.Duration)
+		if err != nil {
+			log.Printf("youtube: %v\n", err)
+			continue
+		}
+		durations[item.Id] = d
+	}
+	return durations, nil
+}
+
+var durationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an ISO 8601 duration as returned by the API
+// (e.g. PT3M25S).
+func parseDuration(v string) (time.Duration, error) {
+	m := durationRegex.FindStringSubmatch(v)
+	if m == nil || v == "P" || v == "PT" {
+		return 0, fmt.Errorf("youtube: invalid duration %q", v)
+	}
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, u := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("youtube: invalid duration %q: %w", v, err)
+		}
+		d += time.Duration(n) * u
+	}
+	return d, nil
+}
+
+var backoff = []time.Duration{
+	30 * time.Second,
+	1 * time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+}
+
+// do runs a request that consumes the given quota units, retrying it if the
+// rate limit is exceeded.
+func (c *Client) do(ctx context.Context, cost int64, fn func() error) error {
+	maxAttempts := 5
+	attempts := 0
+	for {
+		if c.quota > 0 && c.used.Load()+cost > c.quota {
+			return fmt.Errorf("%w: %d of %d units used", ErrQuotaExceeded, c.used.Load(), c.quota)
+		}
+		unlock := c.ratelimit.Lock(ctx)
+		err := fn()
+		c.used.Add(cost)
+		unlock()
+		if err == nil {
+			return nil
+		}
+
+		// Daily quota won't be restored until the next day
+		reason := errorReason(err)
+		switch reason {
+		case "quotaExceeded", "dailyLimitExceeded":
+			return fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+		case "rateLimitExceeded", "userRateLimitExceeded":
+		default:
+			return err
+		}
+
+		// Rate limit exceeded, wait and retry
+		attempts++
+		if attempts >= maxAttempts {
+			return err
+		}
+		idx := attempts - 1
+		if idx >= len(backoff) {
+			idx = len(backoff) - 1
+		}
+		wait := backoff[idx]
+		log.Printf("youtube: rate limit exceeded, retrying in %s\n", wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// errorReason returns the reason of a quota or rate limit error.
+func errorReason(err error) string {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	if apiErr.Code == http.StatusTooManyRequests {
+		return "rateLimitExceeded"
+	}
+	if apiErr.Code != http.StatusForbidden {
+		return ""
+	}
+	for _, e := range apiErr.Errors {
+		switch e.Reason {
+		case "quotaExceeded", "dailyLimitExceeded", "rateLimitExceeded", "userRateLimitExceeded":
+			return e.Reason
+		}
+	}
+	return ""
+}
@@ -2,8 +2,13 @@ package youtube
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/api/googleapi"
 )
 
 func TestGetVideos(t *testing.T) {
@@ -12,11 +17,77 @@ func TestGetVideos(t *testing.T) {
 	key := ""
 	channelID := ""
 
-	c, err := New(ctx, key, true)
+	c, err := New(ctx, &Config{Key: key, Debug: true})
 	if err != nil {
 		t.Fatalf("New() = %v", err)
 	}
-	if _, err := c.GetVideos(ctx, channelID, time.Now().AddDate(0, 0, -15)); err != nil {
+	if _, err := c.GetVideos(ctx, channelID, time.Now().AddDate(0, 0, -15), time.Time{}); err != nil {
 		t.Fatalf("GetVideos() = %v", err)
 	}
 }
+
+func TestErrorReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("boom"), ""},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}, "quotaExceeded"},
+		{fmt.Errorf("wrapped: %w", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}), "rateLimitExceeded"},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}}, ""},
+		{&googleapi.Error{Code: 429}, "rateLimitExceeded"},
+		{&googleapi.Error{Code: 500}, ""},
+	}
+	for _, tt := range tests {
+		if got := errorReason(tt.err); got != tt.want {
+			t.Errorf("errorReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"PT3M25S", 3*time.Minute + 25*time.Second},
+		{"PT1H2M", time.Hour + 2*time.Minute},
+		{"PT45S", 45 * time.Second},
+		{"P1DT1S", 24*time.Hour + time.Second},
+		{"P0D", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if err != nil {
+			t.Fatalf("parseDuration(%q) = %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{"", "P", "PT", "3M25S", "PT3X"} {
+		if _, err := parseDuration(in); err == nil {
+			t.Errorf("parseDuration(%q) expected error", in)
+		}
+	}
+}
+
+func TestLimitTags(t *testing.T) {
+	long := strings.Repeat("a", 200)
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"jazz", " lo-fi ", "", "<b>"}, []string{"jazz", "lo-fi", "b"}},
+		{[]string{long, long, long}, []string{long, long}},
+		// Tags with spaces count their quotes
+		{[]string{long, long, strings.Repeat("b", 95) + " b"}, []string{long, long}},
+		{[]string{long, long, strings.Repeat("b", 95)}, []string{long, long, strings.Repeat("b", 95)}},
+	}
+	for _, tt := range tests {
+		got := LimitTags(tt.tags)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("LimitTags() = %v, want %v", got, tt.want)
+		}
+	}
+}